@@ -57,6 +57,8 @@ type book struct {
 	intervalStr          string
 	interval             time.Duration
 	loop                 *Loop
+	retry                *Retry
+	retries              int
 	concurrency          []string
 	useMap               bool
 	t                    *testing.T
@@ -64,6 +66,8 @@ type book struct {
 	force                bool
 	trace                bool
 	attach               bool
+	dapAddr              string
+	updateBaselines      bool
 	waitTimeout          time.Duration // waitTimout is the time to wait for sub-processes to complete after the Run or RunN context is canceled
 	failFast             bool
 	skipIncluded         bool
@@ -86,6 +90,8 @@ type book struct {
 	runConcurrent        bool
 	runConcurrentMax     int
 	runRandom            int
+	rerunFailed          *failedRunbooks
+	runPaths             map[string]struct{}
 	runnerErrs           map[string]error
 	beforeFuncs          []func(*RunResult) error
 	afterFuncs           []func(*RunResult) error
@@ -476,6 +482,14 @@ func (bk *book) parseGRPCRunnerWithDetailed(name string, b []byte) (bool, error)
 		r.bufConfigs = append(r.bufConfigs, pp)
 	}
 	r.bufModules = c.BufModules
+	if err := validateGRPCProtocol(c.Protocol, c.Codec); err != nil {
+		return false, err
+	}
+	r.protocol = c.Protocol
+	r.codec = c.Codec
+	if err := r.setClientOptions(c); err != nil {
+		return false, err
+	}
 	r.trace = c.Trace.Enable
 	r.traceHeaderName = c.Trace.HeaderName
 
@@ -708,6 +722,7 @@ func (bk *book) merge(loaded *book) error {
 		bk.trace = loaded.trace
 	}
 	bk.loop = loaded.loop
+	bk.retry = loaded.retry
 	bk.concurrency = loaded.concurrency
 	bk.openAPI3DocLocations = loaded.openAPI3DocLocations
 	bk.grpcNoTLS = loaded.grpcNoTLS
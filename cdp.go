@@ -10,6 +10,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/cdproto/target"
 	"github.com/chromedp/chromedp"
 	"github.com/k1LoW/donegroup"
@@ -32,7 +34,17 @@ type cdpRunner struct {
 	store         map[string]any
 	opts          []chromedp.ExecAllocatorOption
 	timeoutByStep time.Duration
-	mu            sync.Mutex
+	// updateBaselines - Overwrite baseline images of compareScreenshot with captured screenshots.
+	updateBaselines bool
+	// frame - The iframe node changed by `frame` action.
+	frame *cdp.Node
+	// viewport - The viewport emulation actions kept across steps.
+	viewport []chromedp.Action
+	// dialog - The handling policy of JavaScript dialogs set by `handleDialog` action.
+	dialog   *cdpDialogPolicy
+	dialogs  []any
+	dialogMu sync.Mutex
+	mu       sync.Mutex
 	// operatorID - The id of the operator for which the runner is defined.
 	operatorID string
 }
@@ -103,6 +115,7 @@ func (rnr *cdpRunner) Close() error {
 	rnr.cancel()
 	rnr.ctx = nil
 	rnr.cancel = nil
+	rnr.frame = nil
 	return nil
 }
 
@@ -111,6 +124,11 @@ func (rnr *cdpRunner) Renew() error {
 		return err
 	}
 	rnr.store = map[string]any{}
+	rnr.viewport = nil
+	rnr.dialogMu.Lock()
+	rnr.dialog = nil
+	rnr.dialogs = nil
+	rnr.dialogMu.Unlock()
 	return nil
 }
 
@@ -166,6 +184,9 @@ func (rnr *cdpRunner) run(ctx context.Context, cas CDPActions, s *step) error {
 	before := []chromedp.Action{
 		chromedp.EmulateViewport(cdpWindowWidth, cdpWindowHeight),
 	}
+	if rnr.viewport != nil {
+		before = rnr.viewport
+	}
 	if err := chromedp.Run(rnr.ctx, before...); err != nil {
 		return err
 	}
@@ -192,6 +213,27 @@ func (rnr *cdpRunner) run(ctx context.Context, cas CDPActions, s *step) error {
 			}
 			targetCtx, _ := chromedp.NewContext(rnr.ctx, chromedp.WithTargetID(ti.TargetID))
 			rnr.ctx = targetCtx
+			rnr.frame = nil
+			continue
+		}
+		switch k {
+		case "frame":
+			sel, ok := ca.Args["sel"]
+			if !ok || sel == nil {
+				return fmt.Errorf("actions[%d] error: invalid action: %v: arg %q not found", i, ca, "sel")
+			}
+			var opts []chromedp.QueryOption
+			if rnr.frame != nil {
+				opts = append(opts, chromedp.FromNode(rnr.frame))
+			}
+			var nodes []*cdp.Node
+			if err := chromedp.Run(rnr.ctx, chromedp.Nodes(sel, &nodes, append(opts, chromedp.ByQuery)...)); err != nil {
+				return fmt.Errorf("actions[%d] error: %w", i, err)
+			}
+			rnr.frame = nodes[0]
+			continue
+		case "mainFrame":
+			rnr.frame = nil
 			continue
 		}
 		as, err := rnr.evalAction(ca, s)
@@ -201,22 +243,16 @@ func (rnr *cdpRunner) run(ctx context.Context, cas CDPActions, s *step) error {
 		if err := chromedp.Run(rnr.ctx, as...); err != nil {
 			return fmt.Errorf("actions[%d] error: %w", i, err)
 		}
+		switch k {
+		case "emulateViewport", "emulateDevice":
+			rnr.viewport = as
+		}
 		ras := fn.Args.ResArgs()
 		if len(ras) > 0 {
 			// capture
 			res := map[string]any{}
 			for _, arg := range ras {
-				v := rnr.store[arg.Key]
-				switch vv := v.(type) {
-				case *string:
-					res[arg.Key] = *vv
-				case *map[string]string:
-					res[arg.Key] = *vv
-				case *[]byte:
-					res[arg.Key] = *vv
-				default:
-					res[arg.Key] = vv
-				}
+				res[arg.Key] = cdpStoreValue(rnr.store[arg.Key])
 			}
 			o.capturers.captureCDPResponse(ca, res)
 		}
@@ -225,17 +261,14 @@ func (rnr *cdpRunner) run(ctx context.Context, cas CDPActions, s *step) error {
 	// record
 	r := map[string]any{}
 	for k, v := range rnr.store {
-		switch vv := v.(type) {
-		case *string:
-			r[k] = *vv
-		case *map[string]string:
-			r[k] = *vv
-		case *[]byte:
-			r[k] = *vv
-		default:
-			r[k] = vv
-		}
+		r[k] = cdpStoreValue(v)
+	}
+	rnr.dialogMu.Lock()
+	if len(rnr.dialogs) > 0 {
+		r["dialogs"] = rnr.dialogs
+		rnr.dialogs = nil
 	}
+	rnr.dialogMu.Unlock()
 	o.record(s.idx, r)
 
 	rnr.store = map[string]any{} // clear
@@ -245,28 +278,41 @@ func (rnr *cdpRunner) run(ctx context.Context, cas CDPActions, s *step) error {
 
 func (rnr *cdpRunner) evalAction(ca CDPAction, s *step) ([]chromedp.Action, error) {
 	o := s.parent
-	_, fn, err := findCDPFn(ca.Fn)
+	k, fn, err := findCDPFn(ca.Fn)
 	if err != nil {
 		return nil, err
 	}
 
-	// path resolution for setUploadFile.path
-	if ca.Fn == "setUploadFile" {
-		p, ok := ca.Args["path"]
+	// path resolution for setUploadFile.path, download.path and compareScreenshot.baseline
+	pathKey := ""
+	switch k {
+	case "setUploadFile", "download":
+		pathKey = "path"
+	case "compareScreenshot":
+		pathKey = "baseline"
+	}
+	if pathKey != "" {
+		p, ok := ca.Args[pathKey]
 		if !ok {
-			return nil, fmt.Errorf("invalid action: %v: arg %q not found", ca, "path")
+			return nil, fmt.Errorf("invalid action: %v: arg %q not found", ca, pathKey)
 		}
 		pp, ok := p.(string)
 		if !ok {
 			return nil, fmt.Errorf("invalid action: %v", ca)
 		}
-		ca.Args["path"], err = fs.Path(pp, o.root)
+		ca.Args[pathKey], err = fs.Path(pp, o.root)
 		if err != nil {
 			return nil, fmt.Errorf("invalid action: %v: %w", ca, err)
 		}
 	}
 
+	if k == "a11yAudit" && rnr.frame != nil {
+		// The accessibility tree and the audit script are only available for the main frame
+		return nil, fmt.Errorf("invalid action: %v: %s cannot be used in a frame (use mainFrame before it)", ca, k)
+	}
+
 	fv := reflect.ValueOf(fn.Fn)
+	ft := reflect.TypeOf(fn.Fn)
 	var vs []reflect.Value
 	for i, a := range fn.Args {
 		switch a.Typ {
@@ -279,22 +325,35 @@ func (rnr *cdpRunner) evalAction(ca CDPAction, s *step) ([]chromedp.Action, erro
 				return nil, fmt.Errorf("invalid action arg: %s.%s = %v", ca.Fn, a.Key, v)
 			}
 			vs = append(vs, reflect.ValueOf(v))
+		case CDPArgTypeOptArg:
+			v, ok := ca.Args[a.Key]
+			if !ok || v == nil {
+				vs = append(vs, reflect.Zero(ft.In(i)))
+				continue
+			}
+			rv := reflect.ValueOf(v)
+			if !rv.Type().AssignableTo(ft.In(i)) {
+				return nil, fmt.Errorf("invalid action arg: %s.%s = %v", ca.Fn, a.Key, v)
+			}
+			vs = append(vs, rv)
 		case CDPArgTypeRes:
 			k := a.Key
-			switch reflect.TypeOf(fn.Fn).In(i).Elem().Kind() {
+			switch ft.In(i).Elem().Kind() {
 			case reflect.String:
 				var v string
 				rnr.store[k] = &v
 				vs = append(vs, reflect.ValueOf(&v))
 			case reflect.Map:
 				// e.g. attributes
-				v := map[string]string{}
-				rnr.store[k] = &v
-				vs = append(vs, reflect.ValueOf(&v))
+				v := reflect.New(ft.In(i).Elem())
+				v.Elem().Set(reflect.MakeMap(ft.In(i).Elem()))
+				rnr.store[k] = v.Interface()
+				vs = append(vs, v)
 			case reflect.Slice:
-				var v []byte
-				rnr.store[k] = &v
-				vs = append(vs, reflect.ValueOf(&v))
+				// e.g. screenshot, cookies
+				v := reflect.New(ft.In(i).Elem())
+				rnr.store[k] = v.Interface()
+				vs = append(vs, v)
 			default:
 				return nil, fmt.Errorf("invalid action: %v", ca)
 			}
@@ -302,9 +361,19 @@ func (rnr *cdpRunner) evalAction(ca CDPAction, s *step) ([]chromedp.Action, erro
 			return nil, fmt.Errorf("invalid action: %v", ca)
 		}
 	}
+	// Query actions are performed within the iframe changed by `frame`
+	if rnr.frame != nil && ft.IsVariadic() && ft.In(ft.NumIn()-1) == reflect.TypeOf([]chromedp.QueryOption{}) {
+		vs = append(vs, reflect.ValueOf(chromedp.FromNode(rnr.frame)))
+	}
 	res := fv.Call(vs)
 	a, ok := res[0].Interface().(chromedp.Action)
 	if ok {
+		switch aa := a.(type) {
+		case *compareScreenshotAction:
+			aa.update = rnr.updateBaselines
+		case *handleDialogAction:
+			aa.rnr = rnr
+		}
 		return []chromedp.Action{a}, nil
 	}
 	as, ok := res[0].Interface().([]chromedp.Action)
@@ -313,3 +382,58 @@ func (rnr *cdpRunner) evalAction(ca CDPAction, s *step) ([]chromedp.Action, erro
 	}
 	return nil, fmt.Errorf("invalid action: %v", ca)
 }
+
+// handleDialog sets the handling policy of JavaScript dialogs and starts listening to dialog events on the current target.
+func (rnr *cdpRunner) handleDialog(ctx context.Context, accept bool, promptText string) error {
+	rnr.dialogMu.Lock()
+	defer rnr.dialogMu.Unlock()
+	listening := rnr.dialog != nil && rnr.dialog.ctx == rnr.ctx
+	rnr.dialog = &cdpDialogPolicy{
+		accept:     accept,
+		promptText: promptText,
+		ctx:        rnr.ctx,
+	}
+	if listening {
+		return nil
+	}
+	chromedp.ListenTarget(ctx, func(ev any) {
+		e, ok := ev.(*page.EventJavascriptDialogOpening)
+		if !ok {
+			return
+		}
+		rnr.dialogMu.Lock()
+		if rnr.dialog == nil {
+			rnr.dialogMu.Unlock()
+			return
+		}
+		accept, promptText := rnr.dialog.accept, rnr.dialog.promptText
+		rnr.dialogs = append(rnr.dialogs, map[string]any{
+			"type":     string(e.Type),
+			"message":  e.Message,
+			"url":      e.URL,
+			"accepted": accept,
+		})
+		rnr.dialogMu.Unlock()
+		// The dialog blocks the page, so it must be handled outside the event handler.
+		go func() {
+			_ = chromedp.Run(ctx, page.HandleJavaScriptDialog(accept).WithPromptText(promptText))
+		}()
+	})
+	return nil
+}
+
+type cdpDialogPolicy struct {
+	accept     bool
+	promptText string
+	// ctx - The chromedp context where the dialog events are listened.
+	ctx context.Context //nostyle:contexts
+}
+
+// cdpStoreValue dereferences the pointer of the result of CDP action.
+func cdpStoreValue(v any) any {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return v
+	}
+	return rv.Elem().Interface()
+}
@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/chromedp/cdproto/cdp"
 	"github.com/google/go-cmp/cmp"
 	"github.com/k1LoW/donegroup"
 	"github.com/k1LoW/runn/testutil"
@@ -191,6 +192,58 @@ func TestCDPRunner(t *testing.T) {
 				"session": "storage",
 			},
 		},
+		{
+			CDPActions{
+				{
+					Fn: "navigate",
+					Args: map[string]any{
+						"url": fmt.Sprintf("%s/iframe", hs.URL),
+					},
+				},
+				{
+					Fn: "frame",
+					Args: map[string]any{
+						"sel": "iframe#child",
+					},
+				},
+				{
+					Fn: "text",
+					Args: map[string]any{
+						"sel": "h1",
+					},
+				},
+			},
+			"text",
+			"Hello",
+		},
+		{
+			CDPActions{
+				{
+					Fn: "navigate",
+					Args: map[string]any{
+						"url": fmt.Sprintf("%s/iframe", hs.URL),
+					},
+				},
+				{
+					Fn: "frame",
+					Args: map[string]any{
+						"sel": "iframe#child",
+					},
+				},
+				{
+					Fn:   "mainFrame",
+					Args: map[string]any{},
+				},
+				{
+					Fn: "text",
+					Args: map[string]any{
+						"sel": "h1",
+					},
+				},
+			},
+			"text",
+			"Parent",
+		},
 	}
 	o, err := New()
 	if err != nil {
@@ -343,6 +396,7 @@ func TestCDP(t *testing.T) {
 		book string
 	}{
 		{"testdata/book/cdp.yml"},
+		{"testdata/book/cdp_a11y.yml"},
 	}
 	ctx := context.Background()
 	for _, tt := range tests {
@@ -364,3 +418,124 @@ func TestCDP(t *testing.T) {
 		})
 	}
 }
+
+func TestCDPCookies(t *testing.T) {
+	if testutil.SkipCDPTest(t) {
+		t.Skip("chrome not found")
+	}
+	ctx, cancel := donegroup.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	hs := testutil.HTTPServer(t)
+	as := CDPActions{
+		{
+			Fn: "navigate",
+			Args: map[string]any{
+				"url": fmt.Sprintf("%s/form", hs.URL),
+			},
+		},
+		{
+			Fn: "setCookie",
+			Args: map[string]any{
+				"name":  "session_id",
+				"value": "xxxxxx",
+			},
+		},
+		{
+			Fn:   "cookies",
+			Args: map[string]any{},
+		},
+	}
+	o, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := newCDPRunner("cc", cdpNewKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := r.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	s := newStep(0, "stepKey", o, nil)
+	if err := r.run(ctx, as, s); err != nil {
+		t.Fatal(err)
+	}
+	sm := o.store.ToMap()
+	sl, ok := sm["steps"].([]map[string]any)
+	if !ok {
+		t.Fatal("steps not found")
+	}
+	cookies, ok := sl[0]["cookies"].([]any)
+	if !ok || len(cookies) != 1 {
+		t.Fatalf("invalid cookies: %v", sl[0]["cookies"])
+	}
+	c, ok := cookies[0].(map[string]any)
+	if !ok {
+		t.Fatalf("invalid cookie: %v", cookies[0])
+	}
+	if c["name"] != "session_id" || c["value"] != "xxxxxx" {
+		t.Errorf("got %v", c)
+	}
+}
+
+func TestFindDevice(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"iPhone 12 Pro", "iPhone 12 Pro", false},
+		{"pixel 5", "Pixel 5", false},
+		{"unknown device", "", true},
+	}
+	for _, tt := range tests {
+		got, err := findDevice(tt.name)
+		if err != nil {
+			if !tt.wantErr {
+				t.Errorf("got error: %v", err)
+			}
+			continue
+		}
+		if tt.wantErr {
+			t.Errorf("want error: %s", tt.name)
+			continue
+		}
+		if got.Device().Name != tt.want {
+			t.Errorf("got %v want %v", got.Device().Name, tt.want)
+		}
+	}
+}
+
+func TestEvalActionInFrame(t *testing.T) {
+	o, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newStep(0, "stepKey", o, nil)
+	rnr := &cdpRunner{
+		store: map[string]any{},
+		frame: &cdp.Node{NodeID: 1},
+	}
+
+	t.Run("compareScreenshot", func(t *testing.T) {
+		as, err := rnr.evalAction(CDPAction{Fn: "compareScreenshot", Args: map[string]any{"baseline": "testdata/top.png", "sel": "h1"}}, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, ok := as[0].(*compareScreenshotAction)
+		if !ok {
+			t.Fatalf("invalid action: %#v", as[0])
+		}
+		if len(a.opts) != 1 {
+			t.Errorf("the frame node is not passed: %v", a.opts)
+		}
+	})
+
+	t.Run("a11yAudit", func(t *testing.T) {
+		if _, err := rnr.evalAction(CDPAction{Fn: "a11yAudit", Args: map[string]any{"sel": "main"}}, s); err == nil {
+			t.Error("want error")
+		}
+	})
+}
@@ -0,0 +1,301 @@
+package runn
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/chromedp/cdproto/accessibility"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+	"github.com/goccy/go-json"
+	"github.com/spf13/cast"
+)
+
+// Rules of accessibility audit.
+const (
+	a11yRuleImageAlt        = "imageAlt"
+	a11yRuleFormLabel       = "formLabel"
+	a11yRuleColorContrast   = "colorContrast"
+	a11yRuleHeadingOrder    = "headingOrder"
+	a11yRuleAriaRole        = "ariaRole"
+	a11yRuleAriaHiddenFocus = "ariaHiddenFocus"
+)
+
+var a11yRules = []string{
+	a11yRuleImageAlt,
+	a11yRuleFormLabel,
+	a11yRuleColorContrast,
+	a11yRuleHeadingOrder,
+	a11yRuleAriaRole,
+	a11yRuleAriaHiddenFocus,
+}
+
+// a11yFormControlRoles are the roles of form controls that require accessible names.
+var a11yFormControlRoles = []string{
+	"textbox",
+	"searchbox",
+	"combobox",
+	"listbox",
+	"checkbox",
+	"radio",
+	"switch",
+	"slider",
+	"spinbutton",
+}
+
+var _ chromedp.Action = (*a11yAuditAction)(nil)
+
+type a11yViolation struct {
+	Rule    string `json:"rule"`
+	Role    string `json:"role"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+type a11yAuditAction struct {
+	sel   string
+	rules any
+	res   *map[string]any
+}
+
+func newA11yAuditAction(sel string, rules any, res *map[string]any) chromedp.Action {
+	return &a11yAuditAction{
+		sel:   sel,
+		rules: rules,
+		res:   res,
+	}
+}
+
+func (a *a11yAuditAction) Do(ctx context.Context) error {
+	rules, err := parseA11yRules(a.rules)
+	if err != nil {
+		return err
+	}
+	nodes, err := accessibility.GetFullAXTree().Do(ctx)
+	if err != nil {
+		return err
+	}
+	if a.sel != "" {
+		var dn []*cdp.Node
+		if err := chromedp.Nodes(a.sel, &dn, chromedp.ByQuery).Do(ctx); err != nil {
+			return err
+		}
+		nodes = subAXTree(nodes, dn[0].BackendNodeID)
+	}
+	vs := auditAXNodes(nodes, rules)
+
+	// Rules that require computed styles or DOM attributes are checked by JavaScript
+	if slices.Contains(rules, a11yRuleColorContrast) || slices.Contains(rules, a11yRuleAriaRole) || slices.Contains(rules, a11yRuleAriaHiddenFocus) {
+		sel, err := json.Marshal(a.sel)
+		if err != nil {
+			return err
+		}
+		var jsvs []a11yViolation
+		if err := chromedp.Evaluate(fmt.Sprintf(a11yAuditScript, sel), &jsvs).Do(ctx); err != nil {
+			return err
+		}
+		for _, v := range jsvs {
+			if slices.Contains(rules, v.Rule) {
+				vs = append(vs, v)
+			}
+		}
+	}
+	*a.res = a11yResult(vs, rules)
+	return nil
+}
+
+func parseA11yRules(v any) ([]string, error) {
+	if v == nil {
+		return a11yRules, nil
+	}
+	rules, err := cast.ToStringSliceE(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules: %v", v)
+	}
+	for _, r := range rules {
+		if !slices.Contains(a11yRules, r) {
+			return nil, fmt.Errorf("invalid rule: %s (available rules: %s)", r, strings.Join(a11yRules, ", "))
+		}
+	}
+	return rules, nil
+}
+
+// subAXTree returns the accessibility nodes of the subtree whose root is associated with the DOM node.
+func subAXTree(nodes []*accessibility.Node, id cdp.BackendNodeID) []*accessibility.Node {
+	m := map[accessibility.NodeID]*accessibility.Node{}
+	var root *accessibility.Node
+	for _, n := range nodes {
+		m[n.NodeID] = n
+		if root == nil && n.BackendDOMNodeID == id {
+			root = n
+		}
+	}
+	if root == nil {
+		return nil
+	}
+	var res []*accessibility.Node
+	var walk func(n *accessibility.Node)
+	walk = func(n *accessibility.Node) {
+		res = append(res, n)
+		for _, c := range n.ChildIDs {
+			if cn, ok := m[c]; ok {
+				walk(cn)
+			}
+		}
+	}
+	walk(root)
+	return res
+}
+
+// auditAXNodes checks the rules that can be determined from the accessibility tree.
+func auditAXNodes(nodes []*accessibility.Node, rules []string) []a11yViolation {
+	var vs []a11yViolation
+	prevLevel := 0
+	for _, n := range nodes {
+		if n.Ignored {
+			continue
+		}
+		role := axValueString(n.Role)
+		name := strings.TrimSpace(axValueString(n.Name))
+		switch {
+		case role == "image" || role == "img":
+			if name == "" && slices.Contains(rules, a11yRuleImageAlt) {
+				vs = append(vs, a11yViolation{
+					Rule:    a11yRuleImageAlt,
+					Role:    role,
+					Message: "image has no alternative text",
+				})
+			}
+		case slices.Contains(a11yFormControlRoles, role):
+			if name == "" && slices.Contains(rules, a11yRuleFormLabel) {
+				vs = append(vs, a11yViolation{
+					Rule:    a11yRuleFormLabel,
+					Role:    role,
+					Message: "form control has no label",
+				})
+			}
+		case role == "heading":
+			level := cast.ToInt(axProperty(n, accessibility.PropertyNameLevel))
+			if level == 0 {
+				continue
+			}
+			if prevLevel > 0 && level > prevLevel+1 && slices.Contains(rules, a11yRuleHeadingOrder) {
+				vs = append(vs, a11yViolation{
+					Rule:    a11yRuleHeadingOrder,
+					Role:    role,
+					Name:    name,
+					Message: fmt.Sprintf("heading level jumps from h%d to h%d", prevLevel, level),
+				})
+			}
+			prevLevel = level
+		}
+	}
+	return vs
+}
+
+func a11yResult(vs []a11yViolation, rules []string) map[string]any {
+	counts := map[string]any{}
+	for _, r := range rules {
+		counts[r] = 0
+	}
+	violations := []any{}
+	for _, v := range vs {
+		counts[v.Rule] = cast.ToInt(counts[v.Rule]) + 1
+		violations = append(violations, map[string]any{
+			"rule":    v.Rule,
+			"role":    v.Role,
+			"name":    v.Name,
+			"message": v.Message,
+		})
+	}
+	return map[string]any{
+		"count":      len(vs),
+		"rules":      counts,
+		"violations": violations,
+	}
+}
+
+func axValueString(v *accessibility.Value) string {
+	if v == nil || len(v.Value) == 0 {
+		return ""
+	}
+	var s any
+	if err := json.Unmarshal(v.Value, &s); err != nil {
+		return string(v.Value)
+	}
+	return cast.ToString(s)
+}
+
+func axProperty(n *accessibility.Node, name accessibility.PropertyName) any {
+	for _, p := range n.Properties {
+		if p.Name != name || p.Value == nil {
+			continue
+		}
+		var v any
+		if err := json.Unmarshal(p.Value.Value, &v); err != nil {
+			return nil
+		}
+		return v
+	}
+	return nil
+}
+
+// a11yAuditScript checks color contrast (WCAG 2.x AA) and ARIA misuse. %s is the JSON string of the selector.
+const a11yAuditScript = `(() => {
+  const sel = %s;
+  const root = sel ? document.querySelector(sel) : document.body;
+  if (!root) { return []; }
+  const roles = ["alert","alertdialog","application","article","banner","blockquote","button","caption","cell","checkbox","code","columnheader","combobox","complementary","contentinfo","definition","deletion","dialog","directory","document","emphasis","feed","figure","form","generic","grid","gridcell","group","heading","img","image","insertion","link","list","listbox","listitem","log","main","marquee","math","menu","menubar","menuitem","menuitemcheckbox","menuitemradio","meter","navigation","none","note","option","paragraph","presentation","progressbar","radio","radiogroup","region","row","rowgroup","rowheader","scrollbar","search","searchbox","separator","slider","spinbutton","status","strong","subscript","superscript","switch","tab","table","tablist","tabpanel","term","textbox","time","timer","toolbar","tooltip","tree","treegrid","treeitem"];
+  const violations = [];
+  const describe = (el) => (el.getAttribute("aria-label") || el.textContent || "").trim().slice(0, 80);
+  const parse = (c) => {
+    const m = c.match(/rgba?\(([^)]+)\)/);
+    if (!m) { return null; }
+    const p = m[1].split(/[ ,\/]+/).filter((v) => v !== "").map(Number);
+    return { r: p[0], g: p[1], b: p[2], a: p.length > 3 ? p[3] : 1 };
+  };
+  const luminance = (c) => {
+    const f = (v) => { v /= 255; return v <= 0.03928 ? v / 12.92 : Math.pow((v + 0.055) / 1.055, 2.4); };
+    return 0.2126 * f(c.r) + 0.7152 * f(c.g) + 0.0722 * f(c.b);
+  };
+  const background = (el) => {
+    for (let e = el; e && e.nodeType === 1; e = e.parentElement) {
+      const c = parse(getComputedStyle(e).backgroundColor);
+      if (c && c.a > 0) { return c; }
+    }
+    return { r: 255, g: 255, b: 255, a: 1 };
+  };
+  const elements = [root, ...root.querySelectorAll("*")];
+  for (const el of elements) {
+    const role = el.getAttribute("role");
+    if (role !== null) {
+      for (const r of role.trim().split(/\s+/)) {
+        if (!roles.includes(r)) {
+          violations.push({ rule: "ariaRole", role: r, name: describe(el), message: "invalid ARIA role: " + r });
+        }
+      }
+    }
+    if (el.getAttribute("aria-hidden") === "true" && el.matches("a[href],button,input,select,textarea,[tabindex]:not([tabindex='-1'])") && !el.disabled) {
+      violations.push({ rule: "ariaHiddenFocus", role: el.tagName.toLowerCase(), name: describe(el), message: "focusable element is hidden by aria-hidden" });
+    }
+    const hasText = [...el.childNodes].some((n) => n.nodeType === 3 && n.textContent.trim() !== "");
+    if (!hasText) { continue; }
+    const style = getComputedStyle(el);
+    if (style.visibility === "hidden" || style.display === "none") { continue; }
+    const fg = parse(style.color);
+    if (!fg) { continue; }
+    const l1 = luminance(fg);
+    const l2 = luminance(background(el));
+    const ratio = (Math.max(l1, l2) + 0.05) / (Math.min(l1, l2) + 0.05);
+    const size = parseFloat(style.fontSize);
+    const bold = parseInt(style.fontWeight, 10) >= 700;
+    const large = size >= 24 || (bold && size >= 18.66);
+    const min = large ? 3 : 4.5;
+    if (ratio < min) {
+      violations.push({ rule: "colorContrast", role: el.tagName.toLowerCase(), name: describe(el), message: "insufficient color contrast ratio " + ratio.toFixed(2) + ":1 (expected " + min + ":1)" });
+    }
+  }
+  return violations;
+})()`
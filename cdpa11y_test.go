@@ -0,0 +1,139 @@
+package runn
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/chromedp/cdproto/accessibility"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAuditAXNodes(t *testing.T) {
+	value := func(v string) *accessibility.Value {
+		return &accessibility.Value{Type: accessibility.ValueTypeString, Value: []byte(v)}
+	}
+	heading := func(level int) *accessibility.Node {
+		return &accessibility.Node{
+			Role: value(`"heading"`),
+			Name: value(fmt.Sprintf(`"h%d"`, level)),
+			Properties: []*accessibility.Property{
+				{Name: accessibility.PropertyNameLevel, Value: &accessibility.Value{Type: accessibility.ValueTypeInteger, Value: []byte(fmt.Sprint(level))}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		nodes []*accessibility.Node
+		rules []string
+		want  []string
+	}{
+		{
+			"image without alt",
+			[]*accessibility.Node{
+				{Role: value(`"image"`), Name: value(`""`)},
+				{Role: value(`"image"`), Name: value(`"logo"`)},
+			},
+			a11yRules,
+			[]string{a11yRuleImageAlt},
+		},
+		{
+			"ignored node",
+			[]*accessibility.Node{
+				{Ignored: true, Role: value(`"image"`)},
+			},
+			a11yRules,
+			nil,
+		},
+		{
+			"unlabeled form controls",
+			[]*accessibility.Node{
+				{Role: value(`"textbox"`)},
+				{Role: value(`"checkbox"`), Name: value(`"agree"`)},
+				{Role: value(`"combobox"`), Name: value(`"  "`)},
+			},
+			a11yRules,
+			[]string{a11yRuleFormLabel, a11yRuleFormLabel},
+		},
+		{
+			"heading order",
+			[]*accessibility.Node{heading(1), heading(2), heading(4), heading(2), heading(3)},
+			a11yRules,
+			[]string{a11yRuleHeadingOrder},
+		},
+		{
+			"disabled rule",
+			[]*accessibility.Node{
+				{Role: value(`"image"`)},
+				{Role: value(`"textbox"`)},
+			},
+			[]string{a11yRuleFormLabel},
+			[]string{a11yRuleFormLabel},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vs := auditAXNodes(tt.nodes, tt.rules)
+			var got []string
+			for _, v := range vs {
+				got = append(got, v.Rule)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestSubAXTree(t *testing.T) {
+	nodes := []*accessibility.Node{
+		{NodeID: "1", BackendDOMNodeID: cdp.BackendNodeID(10), ChildIDs: []accessibility.NodeID{"2", "3"}},
+		{NodeID: "2", BackendDOMNodeID: cdp.BackendNodeID(20), ChildIDs: []accessibility.NodeID{"4"}},
+		{NodeID: "3", BackendDOMNodeID: cdp.BackendNodeID(30)},
+		{NodeID: "4", BackendDOMNodeID: cdp.BackendNodeID(40)},
+	}
+	tests := []struct {
+		id   cdp.BackendNodeID
+		want []accessibility.NodeID
+	}{
+		{10, []accessibility.NodeID{"1", "2", "4", "3"}},
+		{20, []accessibility.NodeID{"2", "4"}},
+		{50, nil},
+	}
+	for _, tt := range tests {
+		var got []accessibility.NodeID
+		for _, n := range subAXTree(nodes, tt.id) {
+			got = append(got, n.NodeID)
+		}
+		if diff := cmp.Diff(got, tt.want); diff != "" {
+			t.Error(diff)
+		}
+	}
+}
+
+func TestA11yResult(t *testing.T) {
+	vs := []a11yViolation{
+		{Rule: a11yRuleImageAlt, Role: "image", Message: "image has no alternative text"},
+		{Rule: a11yRuleImageAlt, Role: "image", Message: "image has no alternative text"},
+	}
+	got := a11yResult(vs, []string{a11yRuleImageAlt, a11yRuleFormLabel})
+	want := map[string]any{
+		"count": 2,
+		"rules": map[string]any{
+			a11yRuleImageAlt:  2,
+			a11yRuleFormLabel: 0,
+		},
+		"violations": []any{
+			map[string]any{"rule": a11yRuleImageAlt, "role": "image", "name": "", "message": "image has no alternative text"},
+			map[string]any{"rule": a11yRuleImageAlt, "role": "image", "name": "", "message": "image has no alternative text"},
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Error(diff)
+	}
+
+	if _, err := parseA11yRules([]any{"imageAlt", "unknown"}); err == nil {
+		t.Error("want error")
+	}
+}
@@ -0,0 +1,298 @@
+package runn
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+	"github.com/spf13/cast"
+)
+
+const (
+	screenshotDiffSuffix   = ".diff.png"
+	screenshotActualSuffix = ".actual.png"
+)
+
+var _ chromedp.Action = (*compareScreenshotAction)(nil)
+
+// compareScreenshotAction captures a screenshot and compares it with the baseline image.
+type compareScreenshotAction struct {
+	baseline  string
+	sel       string
+	threshold any
+	tolerance any
+	ignore    any
+	res       *map[string]any
+	// update - Overwrite the baseline image with the captured screenshot.
+	update bool
+	// opts - The query options such as the iframe node changed by `frame` action.
+	opts []chromedp.QueryOption
+}
+
+// screenshotThreshold is the allowable difference between screenshots.
+type screenshotThreshold struct {
+	pixels  int
+	percent float64
+	isRatio bool
+}
+
+type screenshotComparison struct {
+	diffPixels  int
+	totalPixels int
+	diff        *image.NRGBA
+}
+
+func newCompareScreenshotAction(baseline, sel string, threshold, tolerance, ignore any, res *map[string]any, opts ...chromedp.QueryOption) chromedp.Action {
+	return &compareScreenshotAction{
+		baseline:  baseline,
+		sel:       sel,
+		threshold: threshold,
+		tolerance: tolerance,
+		ignore:    ignore,
+		res:       res,
+		opts:      opts,
+	}
+}
+
+func (a *compareScreenshotAction) Do(ctx context.Context) error {
+	th, err := parseScreenshotThreshold(a.threshold)
+	if err != nil {
+		return err
+	}
+	tol := 0
+	if a.tolerance != nil {
+		tol, err = cast.ToIntE(a.tolerance)
+		if err != nil {
+			return fmt.Errorf("invalid tolerance: %v", a.tolerance)
+		}
+		if tol < 0 || tol > 255 {
+			return fmt.Errorf("invalid tolerance (0-255): %d", tol)
+		}
+	}
+	ignores, err := parseIgnoreRegions(a.ignore)
+	if err != nil {
+		return err
+	}
+
+	var b []byte
+	if a.sel != "" {
+		err = chromedp.Screenshot(a.sel, &b, append([]chromedp.QueryOption{chromedp.NodeVisible}, a.opts...)...).Do(ctx)
+	} else {
+		err = chromedp.FullScreenshot(&b, 100).Do(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	res, err := a.compare(b, th, tol, ignores)
+	if res != nil {
+		// Record the result even if the screenshot does not match so that the diff can be referred to.
+		*a.res = res
+	}
+	return err
+}
+
+// compare compares the captured screenshot with the baseline image.
+// If the baseline does not exist, the screenshot is saved as the baseline.
+// A mismatch returns both the result (`passed: false`) and the error.
+func (a *compareScreenshotAction) compare(b []byte, th *screenshotThreshold, tol int, ignores []image.Rectangle) (map[string]any, error) {
+	actual, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode screenshot: %w", err)
+	}
+
+	res := map[string]any{
+		"baseline": a.baseline,
+		"updated":  false,
+	}
+
+	_, err = os.Stat(a.baseline)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if a.update || errors.Is(err, os.ErrNotExist) {
+		if err := writeScreenshotFile(a.baseline, b); err != nil {
+			return nil, err
+		}
+		res["updated"] = true
+		res["pixels"] = 0
+		res["ratio"] = 0.0
+		res["passed"] = true
+		return res, nil
+	}
+
+	f, err := os.Open(a.baseline)
+	if err != nil {
+		return nil, err
+	}
+	base, err := png.Decode(f)
+	_ = f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode baseline %s: %w", a.baseline, err)
+	}
+
+	c := compareImages(base, actual, tol, ignores)
+	ratio := 0.0
+	if c.totalPixels > 0 {
+		ratio = float64(c.diffPixels) / float64(c.totalPixels)
+	}
+	passed := th.allows(c.diffPixels, ratio)
+	res["pixels"] = c.diffPixels
+	res["ratio"] = ratio
+	res["passed"] = passed
+	if passed {
+		return res, nil
+	}
+
+	prefix := strings.TrimSuffix(a.baseline, filepath.Ext(a.baseline))
+	diffPath := prefix + screenshotDiffSuffix
+	actualPath := prefix + screenshotActualSuffix
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, c.diff); err != nil {
+		return nil, err
+	}
+	if err := writeScreenshotFile(diffPath, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := writeScreenshotFile(actualPath, b); err != nil {
+		return nil, err
+	}
+	res["diff"] = diffPath
+	res["actual"] = actualPath
+	return res, fmt.Errorf("screenshot does not match the baseline %s: %d pixels (%.4f%%) differ (diff: %s)", a.baseline, c.diffPixels, ratio*100, diffPath)
+}
+
+// parseScreenshotThreshold parses threshold. Numbers are treated as the number of pixels, and strings like "0.5%" as the percentage of pixels.
+func parseScreenshotThreshold(v any) (*screenshotThreshold, error) {
+	if v == nil {
+		return &screenshotThreshold{}, nil
+	}
+	if s, ok := v.(string); ok && strings.HasSuffix(strings.TrimSpace(s), "%") {
+		p, err := cast.ToFloat64E(strings.TrimSuffix(strings.TrimSpace(s), "%"))
+		if err != nil || p < 0 {
+			return nil, fmt.Errorf("invalid threshold: %v", v)
+		}
+		return &screenshotThreshold{percent: p, isRatio: true}, nil
+	}
+	n, err := cast.ToIntE(v)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid threshold: %v", v)
+	}
+	return &screenshotThreshold{pixels: n}, nil
+}
+
+func (th *screenshotThreshold) allows(diffPixels int, ratio float64) bool {
+	if th.isRatio {
+		return ratio*100 <= th.percent
+	}
+	return diffPixels <= th.pixels
+}
+
+// parseIgnoreRegions parses `ignore:` ( list of {x, y, width, height} ).
+func parseIgnoreRegions(v any) ([]image.Rectangle, error) {
+	if v == nil {
+		return nil, nil
+	}
+	l, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid ignore regions: %v", v)
+	}
+	var rs []image.Rectangle
+	for _, r := range l {
+		m, ok := r.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid ignore region: %v", r)
+		}
+		var vs []int
+		for _, k := range []string{"x", "y", "width", "height"} {
+			vv, ok := m[k]
+			if !ok {
+				return nil, fmt.Errorf("invalid ignore region: %q not found: %v", k, r)
+			}
+			n, err := cast.ToIntE(vv)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ignore region: %v", r)
+			}
+			vs = append(vs, n)
+		}
+		rs = append(rs, image.Rect(vs[0], vs[1], vs[0]+vs[2], vs[1]+vs[3]))
+	}
+	return rs, nil
+}
+
+// compareImages compares images pixel by pixel and generates a diff image.
+// Pixels outside the overlapping area of images of different sizes are treated as different.
+func compareImages(base, actual image.Image, tolerance int, ignores []image.Rectangle) *screenshotComparison {
+	bb := base.Bounds()
+	ab := actual.Bounds()
+	w := max(bb.Dx(), ab.Dx())
+	h := max(bb.Dy(), ab.Dy())
+	c := &screenshotComparison{
+		diff: image.NewNRGBA(image.Rect(0, 0, w, h)),
+	}
+	red := color.NRGBA{R: 255, A: 255}
+	for y := range h {
+		for x := range w {
+			p := image.Pt(x, y)
+			if inRegions(p, ignores) {
+				c.diff.Set(x, y, color.NRGBA{B: 255, A: 64})
+				continue
+			}
+			c.totalPixels++
+			bp := p.Add(bb.Min)
+			ap := p.Add(ab.Min)
+			if !bp.In(bb) || !ap.In(ab) {
+				c.diffPixels++
+				c.diff.Set(x, y, red)
+				continue
+			}
+			bc := base.At(bp.X, bp.Y)
+			if !colorEqual(bc, actual.At(ap.X, ap.Y), tolerance) {
+				c.diffPixels++
+				c.diff.Set(x, y, red)
+				continue
+			}
+			// Dim matched pixels so that differences stand out.
+			g := color.GrayModel.Convert(bc).(color.Gray)
+			c.diff.Set(x, y, color.NRGBA{R: g.Y, G: g.Y, B: g.Y, A: 64})
+		}
+	}
+	return c
+}
+
+func inRegions(p image.Point, rs []image.Rectangle) bool {
+	for _, r := range rs {
+		if p.In(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func colorEqual(a, b color.Color, tolerance int) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	t := uint32(tolerance) * 0x101 //nolint:gosec
+	return absDiff(ar, br) <= t && absDiff(ag, bg) <= t && absDiff(ab, bb) <= t && absDiff(aa, ba) <= t
+}
+
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func writeScreenshotFile(p string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil { //nolint:gosec
+		return err
+	}
+	return os.WriteFile(p, b, 0o600)
+}
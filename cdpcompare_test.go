@@ -0,0 +1,202 @@
+package runn
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareImages(t *testing.T) {
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	newImage := func(w, h int, dots ...image.Point) image.Image {
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for y := range h {
+			for x := range w {
+				img.Set(x, y, white)
+			}
+		}
+		for _, d := range dots {
+			img.Set(d.X, d.Y, color.NRGBA{R: 250, A: 255})
+		}
+		return img
+	}
+
+	tests := []struct {
+		name      string
+		base      image.Image
+		actual    image.Image
+		tolerance int
+		ignores   []image.Rectangle
+		want      int
+		wantTotal int
+	}{
+		{"same", newImage(10, 10), newImage(10, 10), 0, nil, 0, 100},
+		{"different", newImage(10, 10), newImage(10, 10, image.Pt(1, 1), image.Pt(2, 2)), 0, nil, 2, 100},
+		{"within tolerance", newImage(10, 10), newImage(10, 10, image.Pt(1, 1)), 255, nil, 0, 100},
+		{"ignore region", newImage(10, 10), newImage(10, 10, image.Pt(1, 1), image.Pt(8, 8)), 0, []image.Rectangle{image.Rect(0, 0, 5, 5)}, 1, 75},
+		{"different size", newImage(10, 10), newImage(10, 12), 0, nil, 20, 120},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareImages(tt.base, tt.actual, tt.tolerance, tt.ignores)
+			if got.diffPixels != tt.want {
+				t.Errorf("got %v want %v", got.diffPixels, tt.want)
+			}
+			if got.totalPixels != tt.wantTotal {
+				t.Errorf("got %v want %v", got.totalPixels, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestCompareScreenshot(t *testing.T) {
+	encode := func(t *testing.T, c color.Color) []byte {
+		t.Helper()
+		img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+		for y := range 4 {
+			for x := range 4 {
+				img.Set(x, y, c)
+			}
+		}
+		buf := new(bytes.Buffer)
+		if err := png.Encode(buf, img); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	white := encode(t, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	black := encode(t, color.NRGBA{A: 255})
+
+	t.Run("baseline not found", func(t *testing.T) {
+		a := &compareScreenshotAction{baseline: filepath.Join(t.TempDir(), "top.png")}
+		got, err := a.compare(white, &screenshotThreshold{}, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got["updated"] != true || got["passed"] != true {
+			t.Errorf("got %v", got)
+		}
+		if _, err := os.Stat(a.baseline); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("update baseline", func(t *testing.T) {
+		a := &compareScreenshotAction{baseline: filepath.Join(t.TempDir(), "top.png"), update: true}
+		got, err := a.compare(white, &screenshotThreshold{}, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got["updated"] != true || got["passed"] != true {
+			t.Errorf("got %v", got)
+		}
+		if _, err := os.Stat(a.baseline); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("passed", func(t *testing.T) {
+		a := &compareScreenshotAction{baseline: filepath.Join(t.TempDir(), "top.png")}
+		if err := os.WriteFile(a.baseline, white, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := a.compare(white, &screenshotThreshold{}, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got["passed"] != true || got["pixels"] != 0 {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("not passed", func(t *testing.T) {
+		a := &compareScreenshotAction{baseline: filepath.Join(t.TempDir(), "top.png")}
+		if err := os.WriteFile(a.baseline, white, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := a.compare(black, &screenshotThreshold{pixels: 1}, 0, nil)
+		if err == nil {
+			t.Error("want error")
+		}
+		if got["passed"] != false || got["pixels"] != 16 || got["ratio"] != 1.0 {
+			t.Errorf("got %v", got)
+		}
+		for _, k := range []string{"diff", "actual"} {
+			p, ok := got[k].(string)
+			if !ok {
+				t.Fatalf("%s not found: %v", k, got)
+			}
+			if _, err := os.Stat(p); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+}
+
+func TestScreenshotThreshold(t *testing.T) {
+	tests := []struct {
+		threshold  any
+		diffPixels int
+		ratio      float64
+		want       bool
+		wantErr    bool
+	}{
+		{nil, 0, 0, true, false},
+		{nil, 1, 0.0001, false, false},
+		{10, 10, 0.1, true, false},
+		{uint64(10), 11, 0.1, false, false},
+		{"0.5%", 100, 0.005, true, false},
+		{"0.5%", 100, 0.006, false, false},
+		{"-1", 0, 0, false, true},
+		{"invalid%", 0, 0, false, true},
+	}
+	for _, tt := range tests {
+		th, err := parseScreenshotThreshold(tt.threshold)
+		if err != nil {
+			if !tt.wantErr {
+				t.Errorf("got error: %v", err)
+			}
+			continue
+		}
+		if tt.wantErr {
+			t.Errorf("want error: %v", tt.threshold)
+			continue
+		}
+		if got := th.allows(tt.diffPixels, tt.ratio); got != tt.want {
+			t.Errorf("threshold %v: got %v want %v", tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestParseIgnoreRegions(t *testing.T) {
+	tests := []struct {
+		in      any
+		want    []image.Rectangle
+		wantErr bool
+	}{
+		{nil, nil, false},
+		{[]any{map[string]any{"x": 1, "y": 2, "width": 3, "height": uint64(4)}}, []image.Rectangle{image.Rect(1, 2, 4, 6)}, false},
+		{[]any{map[string]any{"x": 1, "y": 2, "width": 3}}, nil, true},
+		{"invalid", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseIgnoreRegions(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("got error: %v", err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("got %v want %v", got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("got %v want %v", got[i], tt.want[i])
+			}
+		}
+	}
+}
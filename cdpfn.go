@@ -6,20 +6,28 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/chromedp/cdproto/browser"
 	"github.com/chromedp/cdproto/domstorage"
+	"github.com/chromedp/cdproto/emulation"
 	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/device"
+	"github.com/goccy/go-json"
 	"github.com/k1LoW/duration"
+	"github.com/spf13/cast"
 )
 
 type CDPArgType string
 
 const (
-	CDPArgTypeArg CDPArgType = "arg"
-	CDPArgTypeRes CDPArgType = "res"
+	CDPArgTypeArg    CDPArgType = "arg"
+	CDPArgTypeOptArg CDPArgType = "optarg"
+	CDPArgTypeRes    CDPArgType = "res"
 )
 
 type CDPFnArg struct {
@@ -188,7 +196,7 @@ var CDPFnMap = map[string]CDPFn{
 	},
 	"setUploadFile": {
 		Desc: "Set upload file (`path`) to the first element node matching the selector (`sel`).",
-		Fn: func(sel, path string) chromedp.Action {
+		Fn: func(sel, path string, opts ...chromedp.QueryOption) chromedp.Action {
 			abs, err := filepath.Abs(path)
 			if err != nil {
 				return &errAction{err: err}
@@ -196,7 +204,7 @@ var CDPFnMap = map[string]CDPFn{
 			if _, err := os.Stat(abs); err != nil {
 				return &errAction{err: err}
 			}
-			return chromedp.SetUploadFiles(sel, []string{abs})
+			return chromedp.SetUploadFiles(sel, []string{abs}, opts...)
 		},
 		Args: CDPFnArgs{
 			{CDPArgTypeArg, "sel", "input[name=avator]"},
@@ -239,6 +247,29 @@ var CDPFnMap = map[string]CDPFn{
 		},
 		Aliases: []string{"getScreenshot"},
 	},
+	"compareScreenshot": {
+		Desc: "Take a screenshot of the first element node matching the selector (`sel`) or the entire browser viewport, and compare it with the `baseline` image. The step fails if the difference exceeds `threshold` (number of pixels or percentage such as `0.5%`), and a diff image and the actual screenshot are written next to the baseline. `tolerance` is the allowable color difference per channel (0-255) and `ignore` is a list of regions (`x`, `y`, `width`, `height`) to exclude. If the baseline does not exist or `--update-baselines` is specified, the screenshot is saved as the baseline.",
+		Fn:   newCompareScreenshotAction,
+		Args: CDPFnArgs{
+			{CDPArgTypeArg, "baseline", "testdata/screenshots/top.png"},
+			{CDPArgTypeOptArg, "sel", "body > header"},
+			{CDPArgTypeOptArg, "threshold", "0.1%"},
+			{CDPArgTypeOptArg, "tolerance", "8"},
+			{CDPArgTypeOptArg, "ignore", `[{"x": 0, "y": 0, "width": 100, "height": 30}]`},
+			{CDPArgTypeRes, "screenshot", `{"baseline": "testdata/screenshots/top.png", "pixels": 0, "ratio": 0, "passed": true, "updated": false}`},
+		},
+		Aliases: []string{"compareScreenshots", "visualRegression"},
+	},
+	"a11yAudit": {
+		Desc: "Audit the accessibility of the page or the first element node matching the selector (`sel`) using the accessibility tree. Available `rules` are `imageAlt`, `formLabel`, `colorContrast`, `headingOrder`, `ariaRole` and `ariaHiddenFocus` (default: all rules). The number of violations, the number of violations per rule and the violations are recorded.",
+		Fn:   newA11yAuditAction,
+		Args: CDPFnArgs{
+			{CDPArgTypeOptArg, "sel", "main"},
+			{CDPArgTypeOptArg, "rules", `["imageAlt", "formLabel"]`},
+			{CDPArgTypeRes, "a11y", `{"count": 1, "rules": {"imageAlt": 1, "formLabel": 0}, "violations": [{"rule": "imageAlt", "role": "image", "name": "", "message": "image has no alternative text"}]}`},
+		},
+		Aliases: []string{"accessibilityAudit", "auditAccessibility"},
+	},
 	"evaluate": {
 		Desc: "Evaluate the Javascript expression (`expr`).",
 		Fn: func(expr string) chromedp.Action {
@@ -318,6 +349,237 @@ var CDPFnMap = map[string]CDPFn{
 		},
 		Aliases: []string{"getSessionStorage"},
 	},
+	"pdf": {
+		Desc: "Print the current page as PDF.",
+		Fn: func(landscape any, b *[]byte) chromedp.Action {
+			return chromedp.ActionFunc(func(ctx context.Context) error {
+				l, err := cast.ToBoolE(landscape)
+				if err != nil {
+					return fmt.Errorf("invalid landscape: %v", landscape)
+				}
+				buf, _, err := page.PrintToPDF().WithPrintBackground(true).WithLandscape(l).Do(ctx)
+				if err != nil {
+					return err
+				}
+				*b = buf
+				return nil
+			})
+		},
+		Args: CDPFnArgs{
+			{CDPArgTypeOptArg, "landscape", "false"},
+			{CDPArgTypeRes, "pdf", "[]byte"},
+		},
+		Aliases: []string{"printToPDF", "getPDF"},
+	},
+	"cookies": {
+		Desc: "Get the browser cookies for the current page.",
+		Fn: func(cookies *[]any) chromedp.Action {
+			return chromedp.ActionFunc(func(ctx context.Context) error {
+				cs, err := network.GetCookies().Do(ctx)
+				if err != nil {
+					return err
+				}
+				b, err := json.Marshal(cs)
+				if err != nil {
+					return err
+				}
+				var v []any
+				if err := json.Unmarshal(b, &v); err != nil {
+					return err
+				}
+				*cookies = v
+				return nil
+			})
+		},
+		Args: CDPFnArgs{
+			{CDPArgTypeRes, "cookies", `[{"name": "session_id", "value": "xxxxxx", "domain": "github.com", "path": "/"}]`},
+		},
+		Aliases: []string{"getCookies"},
+	},
+	"setCookie": {
+		Desc: "Set the browser cookie (`name`, `value`). If `domain` and `url` are not specified, the cookie is set for the current page.",
+		Fn: func(name, value, domain, path, u string) chromedp.Action {
+			return chromedp.ActionFunc(func(ctx context.Context) error {
+				p := network.SetCookie(name, value)
+				switch {
+				case u != "":
+					p = p.WithURL(u)
+				case domain != "":
+					p = p.WithDomain(domain)
+				default:
+					var loc string
+					if err := chromedp.Location(&loc).Do(ctx); err != nil {
+						return err
+					}
+					p = p.WithURL(loc)
+				}
+				if path != "" {
+					p = p.WithPath(path)
+				}
+				return p.Do(ctx)
+			})
+		},
+		Args: CDPFnArgs{
+			{CDPArgTypeArg, "name", "session_id"},
+			{CDPArgTypeArg, "value", "xxxxxx"},
+			{CDPArgTypeOptArg, "domain", "github.com"},
+			{CDPArgTypeOptArg, "path", "/"},
+			{CDPArgTypeOptArg, "url", "https://github.com"},
+		},
+	},
+	"clearCookies": {
+		Desc: "Clear all browser cookies.",
+		Fn: func() chromedp.Action {
+			return network.ClearBrowserCookies()
+		},
+		Args:    CDPFnArgs{},
+		Aliases: []string{"deleteCookies"},
+	},
+	"download": {
+		Desc: "Click the first element node matching the selector (`sel`), wait for the triggered file download to complete, and save the file into the directory (`path`).",
+		Fn: func(sel, path string, res *map[string]any, opts ...chromedp.QueryOption) chromedp.Action {
+			return &downloadAction{
+				sel:  sel,
+				dir:  path,
+				res:  res,
+				opts: opts,
+			}
+		},
+		Args: CDPFnArgs{
+			{CDPArgTypeArg, "sel", "a.download"},
+			{CDPArgTypeArg, "path", "/path/to/downloads"},
+			{CDPArgTypeRes, "download", `{"path": "/path/to/downloads/report.csv", "filename": "report.csv", "url": "https://example.com/report.csv"}`},
+		},
+		Aliases: []string{"waitDownload"},
+	},
+	"frame": {
+		Desc: "Change current frame to the iframe matching the selector (`sel`). Subsequent actions are performed within the iframe, except `a11yAudit` which only audits the main frame.",
+		Fn: func() chromedp.Action {
+			// dummy
+			return nil
+		},
+		Args: CDPFnArgs{
+			{CDPArgTypeArg, "sel", "iframe#content"},
+		},
+		Aliases: []string{"switchFrame", "iframe"},
+	},
+	"mainFrame": {
+		Desc: "Change current frame back to the main frame from the iframe.",
+		Fn: func() chromedp.Action {
+			// dummy
+			return nil
+		},
+		Args:    CDPFnArgs{},
+		Aliases: []string{"topFrame"},
+	},
+	"emulateViewport": {
+		Desc: "Emulate the viewport (`width`, `height`). The emulation is kept across steps.",
+		Fn: func(width, height, scale, mobile any) chromedp.Action {
+			w, err := cast.ToInt64E(width)
+			if err != nil {
+				return &errAction{err: fmt.Errorf("invalid width: %v", width)}
+			}
+			h, err := cast.ToInt64E(height)
+			if err != nil {
+				return &errAction{err: fmt.Errorf("invalid height: %v", height)}
+			}
+			var opts []chromedp.EmulateViewportOption
+			if scale != nil {
+				sc, err := cast.ToFloat64E(scale)
+				if err != nil {
+					return &errAction{err: fmt.Errorf("invalid scale: %v", scale)}
+				}
+				opts = append(opts, chromedp.EmulateScale(sc))
+			}
+			m, err := cast.ToBoolE(mobile)
+			if err != nil {
+				return &errAction{err: fmt.Errorf("invalid mobile: %v", mobile)}
+			}
+			if m {
+				opts = append(opts, chromedp.EmulateMobile, chromedp.EmulateTouch)
+			}
+			return chromedp.EmulateViewport(w, h, opts...)
+		},
+		Args: CDPFnArgs{
+			{CDPArgTypeArg, "width", "390"},
+			{CDPArgTypeArg, "height", "844"},
+			{CDPArgTypeOptArg, "scale", "3"},
+			{CDPArgTypeOptArg, "mobile", "true"},
+		},
+		Aliases: []string{"viewport", "setViewport"},
+	},
+	"emulateDevice": {
+		Desc: "Emulate the `device` (viewport and User-Agent) such as `iPhone 12 Pro` or `Pixel 5`. The emulation is kept across steps.",
+		Fn: func(name string) chromedp.Action {
+			d, err := findDevice(name)
+			if err != nil {
+				return &errAction{err: err}
+			}
+			return chromedp.Emulate(d)
+		},
+		Args: CDPFnArgs{
+			{CDPArgTypeArg, "device", "iPhone 12 Pro"},
+		},
+		Aliases: []string{"device"},
+	},
+	"emulateGeolocation": {
+		Desc: "Emulate the geolocation (`latitude`, `longitude`) and grant the geolocation permission.",
+		Fn: func(latitude, longitude, accuracy any) []chromedp.Action {
+			lat, err := cast.ToFloat64E(latitude)
+			if err != nil {
+				return []chromedp.Action{&errAction{err: fmt.Errorf("invalid latitude: %v", latitude)}}
+			}
+			lng, err := cast.ToFloat64E(longitude)
+			if err != nil {
+				return []chromedp.Action{&errAction{err: fmt.Errorf("invalid longitude: %v", longitude)}}
+			}
+			acc := 1.0
+			if accuracy != nil {
+				acc, err = cast.ToFloat64E(accuracy)
+				if err != nil {
+					return []chromedp.Action{&errAction{err: fmt.Errorf("invalid accuracy: %v", accuracy)}}
+				}
+			}
+			return []chromedp.Action{
+				browser.GrantPermissions([]browser.PermissionType{browser.PermissionTypeGeolocation}),
+				emulation.SetGeolocationOverride().WithLatitude(lat).WithLongitude(lng).WithAccuracy(acc),
+			}
+		},
+		Args: CDPFnArgs{
+			{CDPArgTypeArg, "latitude", "33.5902"},
+			{CDPArgTypeArg, "longitude", "130.4017"},
+			{CDPArgTypeOptArg, "accuracy", "1"},
+		},
+		Aliases: []string{"geolocation", "setGeolocation"},
+	},
+	"emulateTimezone": {
+		Desc: "Emulate the `timezone`.",
+		Fn: func(tz string) chromedp.Action {
+			return emulation.SetTimezoneOverride(tz)
+		},
+		Args: CDPFnArgs{
+			{CDPArgTypeArg, "timezone", "Asia/Tokyo"},
+		},
+		Aliases: []string{"timezone", "setTimezone"},
+	},
+	"handleDialog": {
+		Desc: "Handle subsequent JavaScript dialogs (alert, confirm, prompt and beforeunload) by accepting or dismissing (`accept`) them. `promptText` is the text to enter into the prompt dialog. Handled dialogs are recorded to `current.dialogs`.",
+		Fn: func(accept any, promptText string) chromedp.Action {
+			a, err := cast.ToBoolE(accept)
+			if err != nil {
+				return &errAction{err: fmt.Errorf("invalid accept: %v", accept)}
+			}
+			return &handleDialogAction{
+				accept:     a,
+				promptText: promptText,
+			}
+		},
+		Args: CDPFnArgs{
+			{CDPArgTypeArg, "accept", "true"},
+			{CDPArgTypeOptArg, "promptText", "hello"},
+		},
+		Aliases: []string{"dialog"},
+	},
 }
 
 func findCDPFn(k string) (string, CDPFn, error) {
@@ -343,6 +605,16 @@ func (a CDPFnArgs) ArgArgs() CDPFnArgs { //nostyle:recvtype
 	return res
 }
 
+func (a CDPFnArgs) OptArgs() CDPFnArgs { //nostyle:recvtype
+	res := CDPFnArgs{}
+	for _, arg := range a {
+		if arg.Typ == CDPArgTypeOptArg {
+			res = append(res, arg)
+		}
+	}
+	return res
+}
+
 func (a CDPFnArgs) ResArgs() CDPFnArgs { //nostyle:recvtype
 	res := CDPFnArgs{}
 	for _, arg := range a {
@@ -356,6 +628,8 @@ func (a CDPFnArgs) ResArgs() CDPFnArgs { //nostyle:recvtype
 var (
 	_ chromedp.Action = (*waitAction)(nil)
 	_ chromedp.Action = (*errAction)(nil)
+	_ chromedp.Action = (*downloadAction)(nil)
+	_ chromedp.Action = (*handleDialogAction)(nil)
 )
 
 type waitAction struct {
@@ -378,3 +652,107 @@ type errAction struct {
 func (e *errAction) Do(ctx context.Context) error {
 	return e.err
 }
+
+type downloadAction struct {
+	sel  string
+	dir  string
+	res  *map[string]any
+	opts []chromedp.QueryOption
+}
+
+func (d *downloadAction) Do(ctx context.Context) error {
+	if err := os.MkdirAll(d.dir, 0o755); err != nil { //nolint:gosec
+		return err
+	}
+	lctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	begin := make(chan *browser.EventDownloadWillBegin, 1)
+	// Progress events of other downloads may arrive, so the finished states are buffered by GUID.
+	var mu sync.Mutex
+	finished := map[string]browser.DownloadProgressState{}
+	notify := make(chan struct{}, 1)
+	chromedp.ListenTarget(lctx, func(ev any) {
+		switch e := ev.(type) {
+		case *browser.EventDownloadWillBegin:
+			select {
+			case begin <- e:
+			default:
+			}
+		case *browser.EventDownloadProgress:
+			if e.State != browser.DownloadProgressStateCompleted && e.State != browser.DownloadProgressStateCanceled {
+				return
+			}
+			mu.Lock()
+			finished[e.GUID] = e.State
+			mu.Unlock()
+			select {
+			case notify <- struct{}{}:
+			default:
+			}
+		}
+	})
+	if err := browser.SetDownloadBehavior(browser.SetDownloadBehaviorBehaviorAllowAndName).WithDownloadPath(d.dir).WithEventsEnabled(true).Do(ctx); err != nil {
+		return err
+	}
+	if err := chromedp.Click(d.sel, append([]chromedp.QueryOption{chromedp.NodeVisible}, d.opts...)...).Do(ctx); err != nil {
+		return err
+	}
+	var b *browser.EventDownloadWillBegin
+	select {
+	case b = <-begin:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for {
+		mu.Lock()
+		state, ok := finished[b.GUID]
+		mu.Unlock()
+		if ok {
+			if state == browser.DownloadProgressStateCanceled {
+				return fmt.Errorf("download canceled: %s", b.URL)
+			}
+			break
+		}
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	// With allowAndName, the downloaded file is named by GUID.
+	// The suggested filename comes from the server, so only the base name is used.
+	name := filepath.Base(b.SuggestedFilename)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		name = b.GUID
+	}
+	p := filepath.Join(d.dir, name)
+	if err := os.Rename(filepath.Join(d.dir, b.GUID), p); err != nil {
+		return err
+	}
+	*d.res = map[string]any{
+		"path":     p,
+		"filename": name,
+		"url":      b.URL,
+	}
+	return nil
+}
+
+type handleDialogAction struct {
+	accept     bool
+	promptText string
+	rnr        *cdpRunner
+}
+
+func (h *handleDialogAction) Do(ctx context.Context) error {
+	return h.rnr.handleDialog(ctx, h.accept, h.promptText)
+}
+
+// findDevice finds the device for emulation by name (case-insensitive).
+func findDevice(name string) (chromedp.Device, error) {
+	for d := device.Reset + 1; d <= device.MotoG4landscape; d++ {
+		if strings.EqualFold(d.String(), name) {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("not found device: %s", name)
+}
@@ -59,8 +59,11 @@ var sortByMethod = []string{
 var coverageCmd = &cobra.Command{
 	Use:   "coverage [PATH_PATTERN ...]",
 	Short: "show coverage for paths/operations of OpenAPI spec and methods of protocol buffers",
-	Long:  `show coverage for paths/operations of OpenAPI spec and methods of protocol buffers.`,
-	Args:  cobra.MinimumNArgs(1),
+	Long: `show coverage for paths/operations of OpenAPI spec and methods of protocol buffers.
+
+Response statuses are counted only for the steps actually executed, so they are not covered by this command,
+which only loads runbooks. Use 'runn run --coverage' to collect them.`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
 		opts, err := flgs.ToOpts()
@@ -90,122 +93,205 @@ var coverageCmd = &cobra.Command{
 			return err
 		}
 
-		if flgs.Format == "json" {
-			b, err := json.MarshalIndent(cov, "", "  ")
+		if err := renderCoverage(cmd, cov); err != nil {
+			return err
+		}
+		return cov.CheckThreshold(flgs.CoverageThreshold)
+	},
+}
+
+// coverageMergeCmd represents the coverage merge command.
+var coverageMergeCmd = &cobra.Command{
+	Use:   "merge [COVERAGE_JSON ...]",
+	Short: "merge coverage results (e.g. runn.coverage.json of each shard)",
+	Long:  `merge coverage results (e.g. runn.coverage.json of each shard).`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cov := &runn.Coverage{}
+		for _, p := range args {
+			b, err := os.ReadFile(filepath.Clean(p))
 			if err != nil {
 				return err
 			}
-			_, _ = fmt.Println(string(b))
-			return nil
+			c := &runn.Coverage{}
+			if err := json.Unmarshal(b, c); err != nil {
+				return fmt.Errorf("invalid coverage %s: %w", p, err)
+			}
+			cov.Merge(c)
+		}
+		if err := renderCoverage(cmd, cov); err != nil {
+			return err
+		}
+		return cov.CheckThreshold(flgs.CoverageThreshold)
+	},
+}
+
+// renderCoverage renders the coverage as a table or JSON.
+func renderCoverage(cmd *cobra.Command, cov *runn.Coverage) error {
+	if flgs.Format == "json" {
+		b, err := json.MarshalIndent(cov, "", "  ")
+		if err != nil {
+			return err
 		}
+		_, _ = fmt.Println(string(b))
+		return nil
+	}
 
-		table := tablewriter.NewTable(os.Stdout,
-			tablewriter.WithTrimSpace(tw.Off),
-			tablewriter.WithRenderer(renderer.NewColorized(renderer.ColorizedConfig{
-				Borders: tw.BorderNone,
-				Symbols: tw.NewSymbols(tw.StyleASCII),
-				Header: renderer.Tint{
-					FG: renderer.Colors{color.Bold},
-					BG: renderer.Colors{color.Bold},
-				},
-				Column: renderer.Tint{
-					FG: renderer.Colors{color.FgWhite},
-					BG: renderer.Colors{color.FgWhite},
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithTrimSpace(tw.Off),
+		tablewriter.WithRenderer(renderer.NewColorized(renderer.ColorizedConfig{
+			Borders: tw.BorderNone,
+			Symbols: tw.NewSymbols(tw.StyleASCII),
+			Header: renderer.Tint{
+				FG: renderer.Colors{color.Bold},
+				BG: renderer.Colors{color.Bold},
+			},
+			Column: renderer.Tint{
+				FG: renderer.Colors{color.FgWhite},
+				BG: renderer.Colors{color.FgWhite},
+			},
+			Settings: tw.Settings{
+				Separators: tw.Separators{
+					ShowHeader:     tw.On,
+					ShowFooter:     tw.Off,
+					BetweenRows:    tw.Off,
+					BetweenColumns: tw.Off,
 				},
-				Settings: tw.Settings{
-					Separators: tw.Separators{
-						ShowHeader:     tw.On,
-						ShowFooter:     tw.Off,
-						BetweenRows:    tw.Off,
-						BetweenColumns: tw.Off,
-					},
-				},
-			})),
-			tablewriter.WithHeaderConfig(tw.CellConfig{
-				Formatting: tw.CellFormatting{
-					AutoFormat: tw.Off,
-					Alignment:  tw.AlignLeft,
-				},
-				Padding: tw.CellPadding{
-					Global: tw.Padding{Left: tw.Space, Right: tw.Space, Top: tw.Empty, Bottom: tw.Empty},
-				},
-			}),
-			tablewriter.WithRowConfig(tw.CellConfig{
-				ColumnAligns: []tw.Align{tw.AlignLeft, tw.AlignRight},
-				Padding: tw.CellPadding{
-					Global: tw.Padding{Left: tw.Space, Right: tw.Space, Top: tw.Empty, Bottom: tw.Empty},
-				},
-			}),
-		)
-		ct := "Coverage"
+			},
+		})),
+		tablewriter.WithHeaderConfig(tw.CellConfig{
+			Formatting: tw.CellFormatting{
+				AutoFormat: tw.Off,
+				Alignment:  tw.AlignLeft,
+			},
+			Padding: tw.CellPadding{
+				Global: tw.Padding{Left: tw.Space, Right: tw.Space, Top: tw.Empty, Bottom: tw.Empty},
+			},
+		}),
+		tablewriter.WithRowConfig(tw.CellConfig{
+			ColumnAligns: []tw.Align{tw.AlignLeft, tw.AlignRight},
+			Padding: tw.CellPadding{
+				Global: tw.Padding{Left: tw.Space, Right: tw.Space, Top: tw.Empty, Bottom: tw.Empty},
+			},
+		}),
+	)
+	ct := "Coverage"
+	if flgs.Long {
+		ct = "Coverage/Count"
+	}
+	table.Header([]string{"Spec", ct})
+	var (
+		coverages      [][]string
+		total, covered int
+	)
+	for _, spec := range cov.Specs {
+		c, t := spec.Covered()
+		total += t
+		covered += c
+		coverages = append(coverages, []string{fmt.Sprintf("  %s", spec.Key), fmt.Sprintf("%.1f%%", float64(c)/float64(t)*100)})
 		if flgs.Long {
-			ct = "Coverage/Count"
-		}
-		table.Header([]string{"Spec", ct})
-		var (
-			coverages      [][]string
-			total, covered int
-		)
-		for _, spec := range cov.Specs {
-			var t, c int
-			for _, v := range spec.Coverages {
-				t++
-				if v > 0 {
-					c++
+			keys := lo.Keys(spec.Coverages)
+			sort.SliceStable(keys, func(i, j int) bool {
+				if !strings.Contains(keys[i], " ") || !strings.Contains(keys[j], " ") {
+					// Sort by method ( protocol buffers )
+					return keys[i] < keys[j]
 				}
-			}
-			total += t
-			covered += c
-			coverages = append(coverages, []string{fmt.Sprintf("  %s", spec.Key), fmt.Sprintf("%.1f%%", float64(c)/float64(t)*100)})
-			if flgs.Long {
-				keys := lo.Keys(spec.Coverages)
-				sort.SliceStable(keys, func(i, j int) bool {
-					if !strings.Contains(keys[i], " ") || !strings.Contains(keys[j], " ") {
-						// Sort by method ( protocol buffers )
-						return keys[i] < keys[j]
-					}
-					// Sort by path ( OpenAPI )
-					mpi := strings.SplitN(keys[i], " ", 2)
-					mpj := strings.SplitN(keys[j], " ", 2)
-					if mpi[1] == mpj[1] {
-						// Sort by method ( OpenAPI )
-						return slices.Index(sortByMethod, mpi[0]) < slices.Index(sortByMethod, mpj[0])
-					}
-					return mpi[1] < mpj[1]
-				})
-				for _, k := range keys {
-					v := spec.Coverages[k]
-					if v == 0 {
-						coverages = append(coverages, []string{color.RedString("    %s", k), ""})
-						continue
-					}
+				// Sort by path ( OpenAPI )
+				mpi := strings.SplitN(keys[i], " ", 2)
+				mpj := strings.SplitN(keys[j], " ", 2)
+				if mpi[1] == mpj[1] {
+					// Sort by method ( OpenAPI )
+					return slices.Index(sortByMethod, mpi[0]) < slices.Index(sortByMethod, mpj[0])
+				}
+				return mpi[1] < mpj[1]
+			})
+			for _, k := range keys {
+				v := spec.Coverages[k]
+				if v == 0 {
+					coverages = append(coverages, []string{color.RedString("    %s", k), ""})
+				} else {
 					coverages = append(coverages, []string{color.GreenString("    %s", k), color.HiGreenString("%d", v)})
 				}
+				coverages = append(coverages, detailCoverageRows(spec.Details[k])...)
 			}
 		}
-		if flgs.Debug {
-			cmd.Println()
-		}
-		if len(coverages) == 0 {
-			return errors.New("could not find any specs")
-		}
-		if err := table.Append([]string{"Total", fmt.Sprintf("%.1f%%", float64(covered)/float64(total)*100)}); err != nil {
+	}
+	if flgs.Debug {
+		cmd.Println()
+	}
+	if len(coverages) == 0 {
+		return errors.New("could not find any specs")
+	}
+	if err := table.Append([]string{"Total", fmt.Sprintf("%.1f%%", float64(covered)/float64(total)*100)}); err != nil {
+		return err
+	}
+	for _, v := range coverages {
+		if err := table.Append(v); err != nil {
 			return err
 		}
-		for _, v := range coverages {
-			if err := table.Append(v); err != nil {
-				return err
+	}
+	if err := table.Render(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// detailCoverageRows returns the rows of request fields, response statuses and enum values of the operation/method.
+func detailCoverageRows(d *runn.DetailCoverage) [][]string {
+	if d == nil {
+		return nil
+	}
+	var rows [][]string
+	for _, c := range []struct {
+		name      string
+		coverages map[string]int
+	}{
+		{"fields", d.Fields},
+		{"statuses", d.Statuses},
+		{"enums", d.Enums},
+	} {
+		if len(c.coverages) == 0 {
+			continue
+		}
+		keys := lo.Keys(c.coverages)
+		sort.Strings(keys)
+		var (
+			names   []string
+			covered int
+		)
+		for _, k := range keys {
+			if c.coverages[k] == 0 {
+				names = append(names, color.RedString(k))
+				continue
 			}
+			covered++
+			names = append(names, color.GreenString(k))
 		}
-		if err := table.Render(); err != nil {
-			return err
+		rows = append(rows, []string{fmt.Sprintf("      %s: %s", c.name, strings.Join(names, " ")), fmt.Sprintf("%d/%d", covered, len(keys))})
+	}
+	if len(d.Undocumented) > 0 {
+		// The statuses observed but not documented are not part of the coverage, so only the number of observations is shown.
+		keys := lo.Keys(d.Undocumented)
+		sort.Strings(keys)
+		var (
+			names []string
+			count int
+		)
+		for _, k := range keys {
+			names = append(names, color.YellowString(k))
+			count += d.Undocumented[k]
 		}
-		return nil
-	},
+		rows = append(rows, []string{fmt.Sprintf("      undocumented statuses: %s", strings.Join(names, " ")), fmt.Sprintf("%d", count)})
+	}
+	return rows
 }
 
 func init() {
 	rootCmd.AddCommand(coverageCmd)
+	coverageCmd.AddCommand(coverageMergeCmd)
+	coverageMergeCmd.Flags().BoolVarP(&flgs.Long, "long", "l", false, flgs.Usage("Long"))
+	coverageMergeCmd.Flags().StringVarP(&flgs.Format, "format", "", "", flgs.Usage("Format"))
+	coverageMergeCmd.Flags().StringVarP(&flgs.CoverageThreshold, "coverage-threshold", "", "", flgs.Usage("CoverageThreshold"))
 	coverageCmd.Flags().BoolVarP(&flgs.Long, "long", "l", false, flgs.Usage("Long"))
 	coverageCmd.Flags().BoolVarP(&flgs.Debug, "debug", "", false, flgs.Usage("Debug"))
 	coverageCmd.Flags().StringSliceVarP(&flgs.Vars, "var", "", []string{}, flgs.Usage("Vars"))
@@ -227,6 +313,7 @@ func init() {
 	coverageCmd.Flags().StringVarP(&flgs.CacheDir, "cache-dir", "", "", flgs.Usage("CacheDir"))
 	coverageCmd.Flags().StringVarP(&flgs.Format, "format", "", "", flgs.Usage("Format"))
 	coverageCmd.Flags().BoolVarP(&flgs.RetainCacheDir, "retain-cache-dir", "", false, flgs.Usage("RetainCacheDir"))
+	coverageCmd.Flags().StringVarP(&flgs.CoverageThreshold, "coverage-threshold", "", "", flgs.Usage("CoverageThreshold"))
 	coverageCmd.Flags().StringVarP(&flgs.EnvFile, "env-file", "", "", flgs.Usage("EnvFile"))
 	if err := coverageCmd.MarkFlagFilename("env-file"); err != nil {
 		panic(err)
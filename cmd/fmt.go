@@ -22,16 +22,34 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
-	"io"
+	iofs "io/fs"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/goccy/go-yaml/token"
+	"github.com/k1LoW/runn/internal/sliceutil"
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/spf13/cobra"
 )
 
-var fmtWrite bool
+var (
+	fmtWrite bool
+	fmtCheck bool
+)
+
+// fmtIndent is the number of spaces of the indentation of formatted runbooks.
+const fmtIndent = 2
 
 // keyOrder defines the canonical order of keys in a runbook.
 var keyOrder = []string{
@@ -46,6 +64,7 @@ var keyOrder = []string{
 	"if",
 	"skipTest",
 	"loop",
+	"retry",
 	"concurrency",
 	"force",
 	"trace",
@@ -53,89 +72,336 @@ var keyOrder = []string{
 	"hostRules",
 }
 
+// stepSectionKeyOrder defines the canonical order of the sections placed before the runner in a step.
+var stepSectionKeyOrder = []string{
+	"desc",
+	"if",
+	"loop",
+	"defer",
+	"force",
+}
+
+// stepSubRunnerKeyOrder defines the canonical order of the sub runners placed after the runner in a step (the order of execution).
+var stepSubRunnerKeyOrder = []string{
+	"dump",
+	"bind",
+	"test",
+}
+
 // fmtCmd represents the fmt command.
 var fmtCmd = &cobra.Command{
-	Use:     "fmt [PATH ...]",
-	Short:   "format runbook YAML files",
-	Long:    `format runbook YAML files with consistent style and key ordering.`,
+	Use:   "fmt [PATH_PATTERN ...]",
+	Short: "format runbook YAML files",
+	Long: `format runbook YAML files with consistent style and key ordering.
+
+Comments, anchors/aliases and multiline strings are preserved.`,
 	Aliases: []string{"format"},
 	Args:    cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var hasError bool
-		for _, path := range args {
-			formatted, err := formatFile(path)
+		if fmtWrite && fmtCheck {
+			return errors.New("cannot use --write with --check")
+		}
+		paths, err := fmtPaths(args)
+		if err != nil {
+			return err
+		}
+		var hasError, hasDiff bool
+		for _, path := range paths {
+			original, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "%s: %v\n", path, err)
+				hasError = true
+				continue
+			}
+			formatted, err := formatRunbook(original)
 			if err != nil {
 				fmt.Fprintf(cmd.ErrOrStderr(), "%s: %v\n", path, err)
 				hasError = true
 				continue
 			}
-			if fmtWrite {
+			switch {
+			case fmtCheck:
+				if bytes.Equal(original, formatted) {
+					continue
+				}
+				hasDiff = true
+				d, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+					A:        difflib.SplitLines(string(original)),
+					B:        difflib.SplitLines(string(formatted)),
+					FromFile: path,
+					ToFile:   path + " (formatted)",
+					Context:  3,
+				})
+				if err != nil {
+					return err
+				}
+				fmt.Fprint(cmd.OutOrStdout(), d)
+			case fmtWrite:
+				if bytes.Equal(original, formatted) {
+					continue
+				}
 				if err := os.WriteFile(path, formatted, 0o644); err != nil {
 					fmt.Fprintf(cmd.ErrOrStderr(), "%s: %v\n", path, err)
 					hasError = true
 					continue
 				}
-			} else {
-				io.WriteString(cmd.OutOrStdout(), string(formatted))
+			default:
+				_, _ = cmd.OutOrStdout().Write(formatted)
 			}
 		}
 		if hasError {
 			return errors.New("format failed")
 		}
+		if hasDiff {
+			return errors.New("some files are not formatted")
+		}
 		return nil
 	},
 }
 
+// fmtPaths expands the local path patterns ( like `path/to/**/*.yml` ) .
+func fmtPaths(patterns []string) ([]string, error) {
+	var paths []string
+	for _, pp := range patterns {
+		base, pattern := doublestar.SplitPattern(filepath.ToSlash(pp))
+		if !strings.ContainsAny(pattern, "*?[{") {
+			paths = append(paths, pp)
+			continue
+		}
+		if err := doublestar.GlobWalk(os.DirFS(base), pattern, func(p string, d iofs.DirEntry) error {
+			if d.IsDir() {
+				return nil
+			}
+			paths = append(paths, filepath.Join(base, p))
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return sliceutil.Unique(paths), nil
+}
+
 func formatFile(path string) ([]byte, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	return formatRunbook(b)
+}
 
-	// Parse as MapSlice to preserve structure
-	var ms yaml.MapSlice
-	if err := yaml.Unmarshal(b, &ms); err != nil {
+// formatRunbook formats the runbook on the YAML AST so that comments, anchors/aliases and multiline strings are preserved.
+// If normalizing the style or reordering the keys changes the content of the runbook ( e.g. an alias is moved before its anchor ), it is skipped.
+func formatRunbook(b []byte) ([]byte, error) {
+	var want any
+	if err := yaml.Unmarshal(b, &want); err != nil {
 		return nil, err
 	}
+	var errs error
+	for _, o := range []struct{ reorder, normalize bool }{
+		{reorder: true, normalize: true},
+		{reorder: true, normalize: false},
+		{reorder: false, normalize: true},
+	} {
+		formatted, err := formatRunbookAST(b, o.reorder, o.normalize)
+		if err != nil {
+			return nil, err
+		}
+		var got any
+		if err := yaml.Unmarshal(formatted, &got); err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			errs = errors.Join(errs, errors.New("the content of the formatted runbook differs from the original"))
+			continue
+		}
+		return formatted, nil
+	}
+	return nil, fmt.Errorf("failed to format: %w", errs)
+}
+
+func formatRunbookAST(b []byte, reorder, normalize bool) ([]byte, error) {
+	f, err := parser.ParseBytes(b, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range f.Docs {
+		m, ok := doc.Body.(*ast.MappingNode)
+		if !ok {
+			continue
+		}
+		if reorder {
+			reorderRunbookKeys(m)
+		}
+		if normalize {
+			normalizeQuotes(doc.Body)
+			reindent(doc.Body, 1)
+		}
+	}
+	out := strings.TrimLeft(f.String(), "\n")
+	return []byte(strings.TrimRight(out, "\n") + "\n"), nil
+}
 
-	// Reorder keys
-	ordered := reorderKeys(ms)
+func reorderRunbookKeys(m *ast.MappingNode) {
+	reorderValues(m, keyOrder, nil)
+	for _, mv := range m.Values {
+		if mappingKey(mv) != "steps" {
+			continue
+		}
+		switch steps := unwrapNode(mv.Value).(type) {
+		case *ast.SequenceNode:
+			for _, s := range steps.Values {
+				reorderStepKeys(s)
+			}
+		case *ast.MappingNode:
+			for _, s := range steps.Values {
+				reorderStepKeys(s.Value)
+			}
+		}
+	}
+}
 
-	// Marshal with consistent formatting
-	return yaml.Marshal(ordered)
+func reorderStepKeys(n ast.Node) {
+	if m, ok := unwrapNode(n).(*ast.MappingNode); ok {
+		reorderValues(m, stepSectionKeyOrder, stepSubRunnerKeyOrder)
+	}
+}
+
+// reorderValues reorders the values of the mapping. The keys in heads come first, the keys in tails come last, and the other keys keep their order between them.
+func reorderValues(m *ast.MappingNode, heads, tails []string) {
+	rank := func(mv *ast.MappingValueNode) int {
+		k := mappingKey(mv)
+		if i := slices.Index(heads, k); i >= 0 {
+			return i - len(heads)
+		}
+		if i := slices.Index(tails, k); i >= 0 {
+			return i + 1
+		}
+		return 0
+	}
+	slices.SortStableFunc(m.Values, func(a, b *ast.MappingValueNode) int {
+		return rank(a) - rank(b)
+	})
+}
+
+func mappingKey(mv *ast.MappingValueNode) string {
+	if mv.Key == nil {
+		return ""
+	}
+	return mv.Key.GetToken().Value
+}
+
+func unwrapNode(n ast.Node) ast.Node {
+	switch v := n.(type) {
+	case *ast.AnchorNode:
+		return unwrapNode(v.Value)
+	case *ast.TagNode:
+		return unwrapNode(v.Value)
+	}
+	return n
 }
 
-func reorderKeys(ms yaml.MapSlice) yaml.MapSlice {
-	// Create a map for quick lookup
-	keyMap := make(map[string]yaml.MapItem)
-	for _, item := range ms {
-		if key, ok := item.Key.(string); ok {
-			keyMap[key] = item
+// reindent indents the block mappings and the block sequences by fmtIndent spaces. col is the column where the node should start.
+func reindent(n ast.Node, col int) {
+	switch v := unwrapNode(n).(type) {
+	case *ast.MappingNode:
+		if v.IsFlowStyle {
+			return
+		}
+		for _, mv := range v.Values {
+			reindentMappingValue(mv, col)
+		}
+	case *ast.MappingValueNode:
+		reindentMappingValue(v, col)
+	case *ast.SequenceNode:
+		if v.IsFlowStyle {
+			return
+		}
+		if d := col - v.Start.Position.Column; d != 0 {
+			v.AddColumn(d)
+		}
+		for _, e := range v.Values {
+			// The value of the entry starts after `- `
+			reindent(e, col+2)
 		}
 	}
+}
 
-	// Build ordered slice
-	var ordered yaml.MapSlice
-	for _, key := range keyOrder {
-		if item, ok := keyMap[key]; ok {
-			ordered = append(ordered, item)
-			delete(keyMap, key)
+func reindentMappingValue(mv *ast.MappingValueNode, col int) {
+	if d := col - mv.Key.GetToken().Position.Column; d != 0 {
+		mv.AddColumn(d)
+	}
+	switch v := unwrapNode(mv.Value).(type) {
+	case *ast.MappingNode, *ast.SequenceNode:
+		if v.GetToken().Position.Line > mv.Key.GetToken().Position.Line {
+			reindent(v, col+fmtIndent)
 		}
+	case *ast.LiteralNode:
+		reindentLiteral(v, col+fmtIndent)
 	}
+}
 
-	// Append any remaining keys not in keyOrder (preserve original order)
-	for _, item := range ms {
-		if key, ok := item.Key.(string); ok {
-			if _, exists := keyMap[key]; exists {
-				ordered = append(ordered, item)
-			}
+// reindentLiteral indents the content of the block scalar (`|` or `>`) to start at col.
+func reindentLiteral(n *ast.LiteralNode, col int) {
+	if n.Value == nil || n.Start.Value != strings.TrimRight(n.Start.Value, "0123456789") {
+		// Explicit indentation indicator
+		return
+	}
+	tk := n.Value.GetToken()
+	lines := strings.Split(tk.Origin, "\n")
+	current := -1
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
 		}
+		if i := len(l) - len(strings.TrimLeft(l, " ")); current < 0 || i < current {
+			current = i
+		}
+	}
+	if current < 0 || current == col-1 {
+		return
 	}
+	indent := strings.Repeat(" ", col-1)
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		lines[i] = indent + l[current:]
+	}
+	tk.Origin = strings.Join(lines, "\n")
+}
+
+// normalizeQuotes removes the quotes of the single line strings that do not need them, and double-quotes the single-quoted strings if possible.
+func normalizeQuotes(n ast.Node) {
+	ast.Walk(quoteNormalizer{}, n)
+}
 
-	return ordered
+type quoteNormalizer struct{}
+
+// Visit implements ast.Visitor interface.
+func (v quoteNormalizer) Visit(node ast.Node) ast.Visitor {
+	s, ok := node.(*ast.StringNode)
+	if !ok {
+		return v
+	}
+	tk := s.Token
+	if tk.Type != token.SingleQuoteType && tk.Type != token.DoubleQuoteType {
+		return v
+	}
+	if strconv.Quote(s.Value) != `"`+s.Value+`"` || strings.Contains(s.Value, "${") {
+		// Strings with escape sequences, and strings that are expanded by environment variables before parsing
+		return v
+	}
+	if !token.IsNeedQuoted(s.Value) {
+		tk.Type = token.StringType
+		return v
+	}
+	tk.Type = token.DoubleQuoteType
+	return v
 }
 
 func init() {
 	rootCmd.AddCommand(fmtCmd)
 	fmtCmd.Flags().BoolVarP(&fmtWrite, "write", "w", false, "write result to (source) file instead of stdout")
+	fmtCmd.Flags().BoolVarP(&fmtCheck, "check", "", false, "check that the files are formatted. If not, print the diff and exit with non-zero status")
 }
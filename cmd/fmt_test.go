@@ -25,7 +25,10 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestFmtCommand(t *testing.T) {
@@ -141,3 +144,108 @@ func TestFmtCommandWriteOption(t *testing.T) {
 		t.Errorf("runners should come before steps in formatted file")
 	}
 }
+
+func TestFormatRunbook(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{
+			"steps:\n  -\n    test: 'true'\ndesc: 'desc'\n",
+			"desc: desc\nsteps:\n  - test: \"true\"\n",
+		},
+		{
+			"steps:\n    a:\n        test: true # check\n        # request\n        req:\n            /users:\n                get:\n                    body: null\n        desc: get users\n",
+			"steps:\n  a:\n    desc: get users\n    # request\n    req:\n      /users:\n        get:\n          body: null\n    test: true # check\n",
+		},
+		{
+			"vars:\n    base: &base\n        a: 1\n    derived:\n        <<: *base\nsteps:\n    -\n        exec:\n            command: |\n                echo hello\n                  world\n",
+			"vars:\n  base: &base\n    a: 1\n  derived:\n    <<: *base\nsteps:\n  - exec:\n      command: |\n        echo hello\n          world\n",
+		},
+		{
+			// An alias cannot be moved before its anchor
+			"vars:\n  d: &d 'x'\ndesc: *d\n",
+			"vars:\n  d: &d x\ndesc: *d\n",
+		},
+		{
+			"desc: 'a: b'\nvars:\n  c: '${ENV}'\n  d: \"line\\nbreak\"\n",
+			"desc: \"a: b\"\nvars:\n  c: '${ENV}'\n  d: \"line\\nbreak\"\n",
+		},
+	}
+	for _, tt := range tests {
+		got, err := formatRunbook([]byte(tt.in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+			t.Error(diff)
+		}
+		again, err := formatRunbook(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(string(got), string(again)); diff != "" {
+			t.Errorf("not idempotent: %s", diff)
+		}
+	}
+}
+
+func TestFmtCommandCheckOption(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	formatted := filepath.Join(tmpDir, "formatted.yml")
+	unformatted := filepath.Join(tmpDir, "sub", "unformatted.yml")
+	if err := os.WriteFile(formatted, []byte("desc: ok\nsteps:\n  - test: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(unformatted, []byte("steps:\n  -\n    test: true\ndesc: ng\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pattern string
+		wantErr bool
+		wantOut []string
+	}{
+		{formatted, false, nil},
+		{unformatted, true, []string{"--- " + unformatted, "-desc: ng", "+desc: ng"}},
+		{filepath.Join(tmpDir, "**", "*.yml"), true, []string{"--- " + unformatted}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			rootCmd.SetArgs([]string{"fmt", "--check", tt.pattern})
+			rootCmd.SetOut(out)
+			rootCmd.SetErr(&bytes.Buffer{})
+			fmtWrite = false
+			t.Cleanup(func() { fmtCheck = false })
+
+			err := rootCmd.Execute()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("fmt --check error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(tt.wantOut) == 0 && out.Len() > 0 {
+				t.Errorf("got unexpected diff: %s", out.String())
+			}
+			for _, w := range tt.wantOut {
+				if !strings.Contains(out.String(), w) {
+					t.Errorf("want %q in %s", w, out.String())
+				}
+			}
+			if strings.Contains(out.String(), "--- "+formatted) {
+				t.Errorf("formatted file should not be reported: %s", out.String())
+			}
+		})
+	}
+
+	// --check does not write the files
+	b, err := os.ReadFile(unformatted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "steps:") {
+		t.Errorf("file was modified: %s", b)
+	}
+}
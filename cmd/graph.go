@@ -0,0 +1,98 @@
+/*
+Copyright © 2022 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/k1LoW/runn"
+	"github.com/k1LoW/runn/internal/fs"
+	"github.com/spf13/cobra"
+)
+
+// graphCmd represents the graph command.
+var graphCmd = &cobra.Command{
+	Use:   "graph [PATH_PATTERN ...]",
+	Short: "show dependency graph of runbooks",
+	Long: `show dependency graph of runbooks by needs: and include:.
+
+The runbooks are grouped by concurrency:, and the cycles are highlighted.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pathp := strings.Join(args, string(filepath.ListSeparator))
+		opts, err := flgs.ToOpts()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, runn.LoadOnly())
+
+		// setup cache dir
+		if err := fs.SetCacheDir(flgs.CacheDir); err != nil {
+			return err
+		}
+		defer func() {
+			if !flgs.RetainCacheDir {
+				_ = fs.RemoveCacheDir()
+			}
+		}()
+
+		o, err := runn.Load(pathp, opts...)
+		if err != nil {
+			return err
+		}
+		g, err := o.Graph()
+		if err != nil {
+			return err
+		}
+		switch flgs.GraphFormat {
+		case "dot", "":
+			return g.OutDOT(cmd.OutOrStdout())
+		case "mermaid":
+			return g.OutMermaid(cmd.OutOrStdout())
+		case "json":
+			return g.OutJSON(cmd.OutOrStdout())
+		default:
+			return fmt.Errorf("invalid format: %s", flgs.GraphFormat)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(graphCmd)
+	graphCmd.Flags().StringVarP(&flgs.GraphFormat, "format", "", "dot", flgs.Usage("GraphFormat"))
+	graphCmd.Flags().BoolVarP(&flgs.SkipIncluded, "skip-included", "", false, flgs.Usage("SkipIncluded"))
+	graphCmd.Flags().StringSliceVarP(&flgs.Vars, "var", "", []string{}, flgs.Usage("Vars"))
+	graphCmd.Flags().StringSliceVarP(&flgs.Runners, "runner", "", []string{}, flgs.Usage("Runners"))
+	graphCmd.Flags().StringSliceVarP(&flgs.Overlays, "overlay", "", []string{}, flgs.Usage("Overlays"))
+	graphCmd.Flags().StringSliceVarP(&flgs.Underlays, "underlay", "", []string{}, flgs.Usage("Underlays"))
+	graphCmd.Flags().StringVarP(&flgs.RunMatch, "run", "", "", flgs.Usage("RunMatch"))
+	graphCmd.Flags().StringSliceVarP(&flgs.RunIDs, "id", "", []string{}, flgs.Usage("RunIDs"))
+	graphCmd.Flags().StringSliceVarP(&flgs.RunLabels, "label", "", []string{}, flgs.Usage("RunLabels"))
+	graphCmd.Flags().StringVarP(&flgs.CacheDir, "cache-dir", "", "", flgs.Usage("CacheDir"))
+	graphCmd.Flags().BoolVarP(&flgs.RetainCacheDir, "retain-cache-dir", "", false, flgs.Usage("RetainCacheDir"))
+	graphCmd.Flags().StringVarP(&flgs.EnvFile, "env-file", "", "", flgs.Usage("EnvFile"))
+	if err := graphCmd.MarkFlagFilename("env-file"); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright © 2022 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/goccy/go-yaml"
+	"github.com/k1LoW/runn"
+	"github.com/spf13/cobra"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// grpcCmd represents the grpc command.
+var grpcCmd = &cobra.Command{
+	Use:   "grpc",
+	Short: "inspect gRPC services",
+	Long:  `inspect gRPC services using proto sources ( or buf ) or server reflection.`,
+}
+
+// grpcListCmd represents the grpc list command.
+var grpcListCmd = &cobra.Command{
+	Use:     "list TARGET [SERVICE]",
+	Short:   "list services or methods of the service",
+	Long:    `list services or methods of the service.`,
+	Aliases: []string{"ls"},
+	Args:    cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		i, err := newGRPCInspector(args[0])
+		if err != nil {
+			return err
+		}
+		defer i.Close()
+		var names []string
+		if len(args) == 1 {
+			names, err = i.Services(ctx)
+		} else {
+			names, err = i.Methods(ctx, args[1])
+		}
+		if err != nil {
+			return err
+		}
+		for _, n := range names {
+			_, _ = fmt.Fprintln(os.Stdout, n)
+		}
+		return nil
+	},
+}
+
+// grpcDescribeCmd represents the grpc describe command.
+var grpcDescribeCmd = &cobra.Command{
+	Use:   "describe TARGET SYMBOL",
+	Short: "describe the service, method or message as YAML",
+	Long:  `describe the service, method or message as YAML. The description of a method contains the zero-valued request message.`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		i, err := newGRPCInspector(args[0])
+		if err != nil {
+			return err
+		}
+		defer i.Close()
+		d, err := i.Describe(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		return yaml.NewEncoder(os.Stdout).Encode(d)
+	},
+}
+
+// grpcHealthCmd represents the grpc health command.
+var grpcHealthCmd = &cobra.Command{
+	Use:   "health TARGET [SERVICE]",
+	Short: "check the health of the server or the service",
+	Long:  `check the health of the server or the service using grpc.health.v1.Health/Check.`,
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		i, err := newGRPCInspector(args[0])
+		if err != nil {
+			return err
+		}
+		defer i.Close()
+		var svc string
+		if len(args) == 2 {
+			svc = args[1]
+		}
+		st, err := i.HealthCheck(ctx, svc)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(os.Stdout, st)
+		if st != healthpb.HealthCheckResponse_SERVING.String() {
+			return fmt.Errorf("not serving: %s", st)
+		}
+		return nil
+	},
+}
+
+func newGRPCInspector(target string) (*runn.GRPCInspector, error) {
+	return runn.NewGRPCInspector(target, grpcOptions()...)
+}
+
+// grpcOptions returns the options of the gRPC runner from the --grpc-* flags.
+func grpcOptions() []runn.Option {
+	return []runn.Option{
+		runn.GRPCNoTLS(flgs.GRPCNoTLS),
+		runn.GRPCProtos(flgs.GRPCProtos),
+		runn.GRPCImportPaths(flgs.GRPCImportPaths),
+		runn.GRPCBufDir(flgs.GRPCBufDirs...),
+		runn.GRPCBufLock(flgs.GRPCBufLocks...),
+		runn.GRPCBufConfig(flgs.GRPCBufConfigs...),
+		runn.GRPCBufModule(flgs.GRPCBufModules...),
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(grpcCmd)
+	grpcCmd.AddCommand(grpcListCmd, grpcDescribeCmd, grpcHealthCmd)
+	grpcCmd.PersistentFlags().BoolVarP(&flgs.GRPCNoTLS, "grpc-no-tls", "", false, flgs.Usage("GRPCNoTLS"))
+	grpcCmd.PersistentFlags().StringSliceVarP(&flgs.GRPCProtos, "grpc-proto", "", []string{}, flgs.Usage("GRPCProtos"))
+	grpcCmd.PersistentFlags().StringSliceVarP(&flgs.GRPCImportPaths, "grpc-import-path", "", []string{}, flgs.Usage("GRPCImportPaths"))
+	grpcCmd.PersistentFlags().StringSliceVarP(&flgs.GRPCBufDirs, "grpc-buf-dir", "", []string{}, flgs.Usage("GRPCBufDirs"))
+	grpcCmd.PersistentFlags().StringSliceVarP(&flgs.GRPCBufLocks, "grpc-buf-lock", "", []string{}, flgs.Usage("GRPCBufLocks"))
+	grpcCmd.PersistentFlags().StringSliceVarP(&flgs.GRPCBufConfigs, "grpc-buf-config", "", []string{}, flgs.Usage("GRPCBufConfigs"))
+	grpcCmd.PersistentFlags().StringSliceVarP(&flgs.GRPCBufModules, "grpc-buf-module", "", []string{}, flgs.Usage("GRPCBufModules"))
+}
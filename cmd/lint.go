@@ -24,45 +24,59 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"os"
+	"path/filepath"
+	"strings"
 
-	"github.com/goccy/go-yaml"
+	"github.com/k1LoW/runn"
 	"github.com/spf13/cobra"
 )
 
 // lintCmd represents the lint command.
 var lintCmd = &cobra.Command{
-	Use:   "lint [PATH ...]",
-	Short: "lint runbook YAML files",
-	Long:  `lint runbook YAML files for syntax errors.`,
-	Args:  cobra.MinimumNArgs(1),
+	Use:   "lint [PATH_PATTERN ...]",
+	Short: "lint runbooks",
+	Long: `lint runbooks without running them.
+
+It reports syntax errors, invalid steps, undefined runners and vars, expression syntax errors,
+invalid step references, unused vars, missing included runbooks and cycles of needs.`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var hasError bool
-		for _, path := range args {
-			if err := lintFile(path); err != nil {
-				fmt.Fprintf(cmd.ErrOrStderr(), "%s: %v\n", path, err)
-				hasError = true
-			}
+		pathp := strings.Join(args, string(filepath.ListSeparator))
+		opts, err := flgs.ToOpts()
+		if err != nil {
+			return err
+		}
+		issues, err := runn.Lint(pathp, opts...)
+		if err != nil {
+			return err
+		}
+		switch flgs.LintFormat {
+		case "json":
+			err = issues.OutJSON(cmd.OutOrStdout())
+		case "sarif":
+			err = issues.OutSARIF(cmd.OutOrStdout())
+		case "":
+			err = issues.Out(cmd.OutOrStdout())
+		default:
+			return fmt.Errorf("invalid format: %s", flgs.LintFormat)
 		}
-		if hasError {
+		if err != nil {
+			return err
+		}
+		if issues.HasError() {
 			return errors.New("lint failed")
 		}
 		return nil
 	},
 }
 
-func lintFile(path string) error {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return err
-	}
-	var v any
-	if err := yaml.Unmarshal(b, &v); err != nil {
-		return err
-	}
-	return nil
-}
-
 func init() {
 	rootCmd.AddCommand(lintCmd)
+	lintCmd.Flags().StringVarP(&flgs.LintFormat, "format", "", "", flgs.Usage("LintFormat"))
+	lintCmd.Flags().StringSliceVarP(&flgs.Vars, "var", "", []string{}, flgs.Usage("Vars"))
+	lintCmd.Flags().StringSliceVarP(&flgs.Runners, "runner", "", []string{}, flgs.Usage("Runners"))
+	lintCmd.Flags().StringVarP(&flgs.EnvFile, "env-file", "", "", flgs.Usage("EnvFile"))
+	if err := lintCmd.MarkFlagFilename("env-file"); err != nil {
+		panic(err)
+	}
 }
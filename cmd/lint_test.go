@@ -57,6 +57,21 @@ func TestLintCommand(t *testing.T) {
 			args:    []string{"lint", "testdata/nonexistent.yml"},
 			wantErr: true,
 		},
+		{
+			name:    "semantic issues",
+			args:    []string{"lint", "../testdata/lint/issues.yml"},
+			wantErr: true,
+		},
+		{
+			name:    "sarif format",
+			args:    []string{"lint", "--format", "sarif", "testdata/valid.yml"},
+			wantErr: false,
+		},
+		{
+			name:    "invalid format",
+			args:    []string{"lint", "--format", "xml", "testdata/valid.yml"},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
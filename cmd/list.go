@@ -22,6 +22,7 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -72,13 +73,26 @@ var listCmd = &cobra.Command{
 				},
 			}),
 			tablewriter.WithRowConfig(tw.CellConfig{
-				ColumnAligns: []tw.Align{tw.AlignLeft, tw.AlignLeft, tw.AlignLeft, tw.AlignRight, tw.AlignLeft},
+				ColumnAligns: []tw.Align{tw.AlignLeft, tw.AlignLeft, tw.AlignLeft, tw.AlignRight, tw.AlignLeft, tw.AlignLeft},
 				Padding: tw.CellPadding{
 					Global: tw.Padding{Left: tw.Space, Right: tw.Space, Top: tw.Empty, Bottom: tw.Empty},
 				},
 			}),
 		)
-		table.Header([]string{"id:", "desc:", "if:", "steps:", "path"})
+		header := []string{"id:", "desc:", "if:", "steps:", "path"}
+		var h *runn.History
+		if flgs.Flaky {
+			hp, err := runn.HistoryPath()
+			if err != nil {
+				return err
+			}
+			h, err = runn.LoadHistory(hp)
+			if err != nil {
+				return err
+			}
+			header = append(header, "history:")
+		}
+		table.Header(header)
 		pathp := strings.Join(args, string(filepath.ListSeparator))
 		opts, err := flgs.ToOpts()
 		if err != nil {
@@ -116,7 +130,15 @@ var listCmd = &cobra.Command{
 			}
 			c := strconv.Itoa(oo.NumberOfSteps())
 			ifCond := oo.If()
-			if err := table.Append([]string{id, desc, ifCond, c, p}); err != nil {
+			row := []string{id, desc, ifCond, c, p}
+			if h != nil {
+				rh := h.Lookup(oo.ID(), oo.BookPath())
+				if rh == nil || !rh.IsFlaky() {
+					continue
+				}
+				row = append(row, fmt.Sprintf("%d flaky, %d failures / %d runs", rh.Flaky, rh.Failure, rh.Runs))
+			}
+			if err := table.Append(row); err != nil {
 				return err
 			}
 		}
@@ -132,6 +154,7 @@ var listCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(listCmd)
 	listCmd.Flags().BoolVarP(&flgs.Long, "long", "l", false, flgs.Usage("Long"))
+	listCmd.Flags().BoolVarP(&flgs.Flaky, "flaky", "", false, flgs.Usage("Flaky"))
 	listCmd.Flags().BoolVarP(&flgs.SkipIncluded, "skip-included", "", false, flgs.Usage("SkipIncluded"))
 	listCmd.Flags().StringSliceVarP(&flgs.Vars, "var", "", []string{}, flgs.Usage("Vars"))
 	listCmd.Flags().StringSliceVarP(&flgs.Runners, "runner", "", []string{}, flgs.Usage("Runners"))
@@ -24,11 +24,17 @@ package cmd
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/goccy/go-json"
 	"github.com/k1LoW/donegroup"
 	"github.com/k1LoW/duration"
 	"github.com/k1LoW/runn"
@@ -51,6 +57,11 @@ var loadtCmd = &cobra.Command{
 			cancel()
 			err = errors.Join(err, donegroup.Wait(ctx))
 		}()
+		switch flgs.LoadTFormat {
+		case "text", "json", "csv":
+		default:
+			return fmt.Errorf("invalid format: %s (available formats: text, json, csv)", flgs.LoadTFormat)
+		}
 		pathp := strings.Join(args, string(filepath.ListSeparator))
 		flgs.Format = "none" // Disable runn output
 		opts, err := flgs.ToOpts()
@@ -72,51 +83,113 @@ var loadtCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		d, err := duration.Parse(flgs.LoadTDuration)
-		if err != nil {
-			return err
-		}
-		w, err := duration.Parse(flgs.LoadTWarmUp)
-		if err != nil {
-			return err
-		}
-		s, err := setting.New(flgs.LoadTConcurrent, flgs.LoadTMaxRPS, d, w)
-		if err != nil {
-			return err
-		}
-		selected, err := o.SelectedOperators()
-		if err != nil {
-			return err
+		var lr interface {
+			ReportWithFormat(w io.Writer, format string) error
+			CheckThreshold(threshold string) error
 		}
-		ot, err := otchkiss.FromConfig(o, s, 100_000_000)
-		if err != nil {
-			return err
+		if len(flgs.LoadTWorkers) > 0 && (flgs.LoadTTimeSeries != "" || flgs.LoadTMetricsAddr != "") {
+			return errors.New("--time-series and --metrics-addr cannot be used with --workers")
 		}
-
-		if isatty.IsTerminal(os.Stdout.Fd()) {
-			// With tty
-			p := tea.NewProgram(newSpinnerModel(), tea.WithContext(ctx))
-			go func() {
-				if _, errr := p.Run(); errr != nil {
-					err = errr
+		if flgs.LoadTTimeSeries != "" || flgs.LoadTMetricsAddr != "" {
+			var tsw io.Writer
+			if flgs.LoadTTimeSeries != "" {
+				f, err := os.Create(flgs.LoadTTimeSeries)
+				if err != nil {
+					return err
 				}
+				defer f.Close()
+				tsw = f
+			}
+			ts := o.StartTimeSeries(tsw)
+			defer func() {
+				err = errors.Join(err, ts.Stop())
 			}()
-			if err := ot.Start(ctx); err != nil {
+			if flgs.LoadTMetricsAddr != "" {
+				mux := http.NewServeMux()
+				mux.Handle("/metrics", ts.MetricsHandler())
+				srv := &http.Server{Addr: flgs.LoadTMetricsAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+				ln, err := net.Listen("tcp", flgs.LoadTMetricsAddr)
+				if err != nil {
+					return err
+				}
+				go func() {
+					_ = srv.Serve(ln)
+				}()
+				defer func() {
+					err = errors.Join(err, srv.Close())
+				}()
+			}
+		}
+		if len(flgs.LoadTWorkers) > 0 {
+			token, err := loadtWorkerToken("--worker-token")
+			if err != nil {
+				return err
+			}
+			b, err := loadtJobOptions()
+			if err != nil {
+				return err
+			}
+			job, err := o.NewLoadtJob(b)
+			if err != nil {
+				return err
+			}
+			if err := setLoadtJobLoad(job); err != nil {
+				return err
+			}
+			if err := withSpinner(ctx, func() error {
+				lr, err = runn.RunLoadtWorkers(ctx, flgs.LoadTWorkers, token, job)
+				return err
+			}); err != nil {
+				return err
+			}
+		} else if flgs.LoadTProfile != "" {
+			lp, err := runn.ReadLoadProfile(flgs.LoadTProfile)
+			if err != nil {
+				return err
+			}
+			if err := withSpinner(ctx, func() error {
+				lr, err = o.RunLoadProfile(ctx, lp)
+				return err
+			}); err != nil {
 				return err
 			}
-			p.Quit()
-			p.Wait()
 		} else {
-			if err := ot.Start(ctx); err != nil {
+			d, err := duration.Parse(flgs.LoadTDuration)
+			if err != nil {
 				return err
 			}
+			w, err := duration.Parse(flgs.LoadTWarmUp)
+			if err != nil {
+				return err
+			}
+			s, err := setting.New(flgs.LoadTConcurrent, flgs.LoadTMaxRPS, d, w)
+			if err != nil {
+				return err
+			}
+			selected, err := o.SelectedOperators()
+			if err != nil {
+				return err
+			}
+			o.SetLoadtWarmUp(w)
+			ot, err := otchkiss.FromConfig(o, s, 100_000_000)
+			if err != nil {
+				return err
+			}
+			if err := withSpinner(ctx, func() error {
+				return ot.Start(ctx)
+			}); err != nil {
+				return err
+			}
+			r, err := runn.NewLoadtResult(len(selected), w, d, flgs.LoadTConcurrent, flgs.LoadTMaxRPS, ot.Result)
+			if err != nil {
+				return err
+			}
+			if err := r.CollectBreakdown(o); err != nil {
+				return err
+			}
+			lr = r
 		}
-
-		lr, err := runn.NewLoadtResult(len(selected), w, d, flgs.LoadTConcurrent, flgs.LoadTMaxRPS, ot.Result)
-		if err != nil {
-			return err
-		}
-		if err := lr.Report(os.Stdout); err != nil {
+		if err := lr.ReportWithFormat(os.Stdout, flgs.LoadTFormat); err != nil {
 			return err
 		}
 		if err := lr.CheckThreshold(flgs.LoadTThreshold); err != nil {
@@ -126,6 +199,59 @@ var loadtCmd = &cobra.Command{
 	},
 }
 
+// loadtJobOptions returns the flags to ship to the workers.
+func loadtJobOptions() (json.RawMessage, error) {
+	jf, err := newLoadtJobFlags(flgs)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jf)
+}
+
+// setLoadtJobLoad sets the load profile or the load settings to the job.
+func setLoadtJobLoad(job *runn.LoadtJob) error {
+	if flgs.LoadTProfile != "" {
+		b, err := os.ReadFile(flgs.LoadTProfile)
+		if err != nil {
+			return err
+		}
+		job.Profile = string(b)
+		return nil
+	}
+	d, err := duration.Parse(flgs.LoadTDuration)
+	if err != nil {
+		return err
+	}
+	w, err := duration.Parse(flgs.LoadTWarmUp)
+	if err != nil {
+		return err
+	}
+	job.Concurrent = flgs.LoadTConcurrent
+	job.MaxRPS = flgs.LoadTMaxRPS
+	job.Duration = d
+	job.WarmUp = w
+	return nil
+}
+
+// withSpinner runs fn while showing the spinner if stdout is a terminal.
+func withSpinner(ctx context.Context, fn func() error) (err error) {
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		return fn()
+	}
+	p := tea.NewProgram(newSpinnerModel(), tea.WithContext(ctx))
+	go func() {
+		if _, errr := p.Run(); errr != nil {
+			err = errr
+		}
+	}()
+	if err := fn(); err != nil {
+		return err
+	}
+	p.Quit()
+	p.Wait()
+	return err
+}
+
 func init() {
 	rootCmd.AddCommand(loadtCmd)
 	loadtCmd.Flags().BoolVarP(&flgs.Debug, "debug", "", false, flgs.Usage("Debug"))
@@ -168,4 +294,13 @@ func init() {
 	loadtCmd.Flags().StringVarP(&flgs.LoadTWarmUp, "warm-up", "", "5sec", flgs.Usage("LoadTWarmUp"))
 	loadtCmd.Flags().StringVarP(&flgs.LoadTThreshold, "threshold", "", "", flgs.Usage("LoadTThreshold"))
 	loadtCmd.Flags().IntVarP(&flgs.LoadTMaxRPS, "max-rps", "", 1, flgs.Usage("LoadTMaxRPS"))
+	loadtCmd.Flags().StringVarP(&flgs.LoadTProfile, "load-profile", "", "", flgs.Usage("LoadTProfile"))
+	if err := loadtCmd.MarkFlagFilename("load-profile", "yml", "yaml"); err != nil {
+		panic(err)
+	}
+	loadtCmd.Flags().StringVarP(&flgs.LoadTFormat, "format", "", "text", flgs.Usage("LoadTFormat"))
+	loadtCmd.Flags().StringVarP(&flgs.LoadTTimeSeries, "time-series", "", "", flgs.Usage("LoadTTimeSeries"))
+	loadtCmd.Flags().StringVarP(&flgs.LoadTMetricsAddr, "metrics-addr", "", "", flgs.Usage("LoadTMetricsAddr"))
+	loadtCmd.Flags().StringSliceVarP(&flgs.LoadTWorkers, "workers", "", []string{}, flgs.Usage("LoadTWorkers"))
+	loadtCmd.Flags().StringVarP(&flgs.LoadTWorkerToken, "worker-token", "", "", flgs.Usage("LoadTWorkerToken"))
 }
@@ -0,0 +1,165 @@
+/*
+Copyright © 2026 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/k1LoW/runn"
+	"github.com/k1LoW/runn/internal/flags"
+	"github.com/spf13/cobra"
+)
+
+// loadtWorkerTokenEnv is the environment variable of the shared token of the coordinator and the workers.
+const loadtWorkerTokenEnv = "RUNN_LOADT_WORKER_TOKEN"
+
+// loadtWorkerCmd represents the loadt-worker command.
+var loadtWorkerCmd = &cobra.Command{
+	Use:   "loadt-worker",
+	Short: "run worker of distributed load test",
+	Long:  `run worker of distributed load test. The worker runs the runbooks shipped by "runn loadt --workers".`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		token, err := loadtWorkerToken("--token")
+		if err != nil {
+			return err
+		}
+		scopes := flgs.Scopes
+		w := runn.NewLoadtWorker(token, func(options json.RawMessage) ([]runn.Option, error) {
+			jf := &loadtJobFlags{}
+			if err := json.Unmarshal(options, jf); err != nil {
+				return nil, err
+			}
+			f := jf.toFlags()
+			f.Format = "none" // Disable runn output
+			f.Scopes = scopes // Scopes are controlled by the worker, not by the coordinator
+			return f.ToOpts()
+		})
+		ln, err := net.Listen("tcp", flgs.LoadTWorkerListen)
+		if err != nil {
+			return err
+		}
+		cmd.PrintErrln(fmt.Sprintf("runn loadt-worker is listening on %s", ln.Addr().String()))
+		srv := &http.Server{Handler: w, ReadHeaderTimeout: 10 * time.Second}
+		return srv.Serve(ln)
+	},
+}
+
+// loadtWorkerToken returns the shared token from the flag or the environment variable.
+func loadtWorkerToken(flag string) (string, error) {
+	token := flgs.LoadTWorkerToken
+	if token == "" {
+		token = os.Getenv(loadtWorkerTokenEnv)
+	}
+	if token == "" {
+		return "", fmt.Errorf("token is required: use %s flag or %s environment variable", flag, loadtWorkerTokenEnv)
+	}
+	return token, nil
+}
+
+// loadtJobFlags are the flags that the coordinator ships to the workers and the workers accept.
+// Flags that refer to the files or the environment of the host (e.g. --env-file, --var, --runner, --overlay) are not included.
+type loadtJobFlags struct {
+	FailFast     bool
+	SkipTest     bool
+	SkipIncluded bool
+	GRPCNoTLS    bool
+	RunMatch     string
+	RunIDs       []string
+	RunLabels    []string
+	Sample       int
+	Shuffle      string
+	Concurrent   string
+	Random       int
+	ShardIndex   int
+	ShardN       int
+	WaitTimeout  string
+}
+
+// newLoadtJobFlags returns the flags to ship to the workers.
+// It returns an error if the flags that the workers do not accept are set.
+func newLoadtJobFlags(f *flags.Flags) (*loadtJobFlags, error) {
+	for _, u := range []struct {
+		name string
+		set  bool
+	}{
+		{"--var", len(f.Vars) > 0},
+		{"--runner", len(f.Runners) > 0},
+		{"--overlay", len(f.Overlays) > 0},
+		{"--underlay", len(f.Underlays) > 0},
+		{"--host-rules", len(f.HostRules) > 0},
+		{"--http-openapi3", len(f.HTTPOpenApi3s) > 0},
+		{"--grpc-proto", len(f.GRPCProtos) > 0},
+		{"--grpc-import-path", len(f.GRPCImportPaths) > 0},
+		{"--grpc-buf-*", len(f.GRPCBufDirs) > 0 || len(f.GRPCBufLocks) > 0 || len(f.GRPCBufConfigs) > 0 || len(f.GRPCBufModules) > 0},
+	} {
+		if u.set {
+			return nil, fmt.Errorf("%s cannot be used with --workers", u.name)
+		}
+	}
+	return &loadtJobFlags{
+		FailFast:     f.FailFast,
+		SkipTest:     f.SkipTest,
+		SkipIncluded: f.SkipIncluded,
+		GRPCNoTLS:    f.GRPCNoTLS,
+		RunMatch:     f.RunMatch,
+		RunIDs:       f.RunIDs,
+		RunLabels:    f.RunLabels,
+		Sample:       f.Sample,
+		Shuffle:      f.Shuffle,
+		Concurrent:   f.Concurrent,
+		Random:       f.Random,
+		ShardIndex:   f.ShardIndex,
+		ShardN:       f.ShardN,
+		WaitTimeout:  f.WaitTimeout,
+	}, nil
+}
+
+func (jf *loadtJobFlags) toFlags() *flags.Flags {
+	return &flags.Flags{
+		FailFast:     jf.FailFast,
+		SkipTest:     jf.SkipTest,
+		SkipIncluded: jf.SkipIncluded,
+		GRPCNoTLS:    jf.GRPCNoTLS,
+		RunMatch:     jf.RunMatch,
+		RunIDs:       jf.RunIDs,
+		RunLabels:    jf.RunLabels,
+		Sample:       jf.Sample,
+		Shuffle:      jf.Shuffle,
+		Concurrent:   jf.Concurrent,
+		Random:       jf.Random,
+		ShardIndex:   jf.ShardIndex,
+		ShardN:       jf.ShardN,
+		WaitTimeout:  jf.WaitTimeout,
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(loadtWorkerCmd)
+	loadtWorkerCmd.Flags().StringVarP(&flgs.LoadTWorkerListen, "listen", "", "127.0.0.1:8080", flgs.Usage("LoadTWorkerListen"))
+	loadtWorkerCmd.Flags().StringVarP(&flgs.LoadTWorkerToken, "token", "", "", flgs.Usage("LoadTWorkerToken"))
+}
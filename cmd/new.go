@@ -29,6 +29,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/goccy/go-yaml"
 	"github.com/k1LoW/runn"
@@ -77,6 +78,12 @@ var newCmd = &cobra.Command{
 			}
 		}
 		for _, args := range al {
+			if len(args) == 1 && strings.HasPrefix(args[0], "grpc://") {
+				if err := rb.AppendGRPCStep(ctx, args[0], grpcOptions()...); err != nil {
+					return err
+				}
+				continue
+			}
 			if err := rb.AppendStep(args...); err != nil {
 				return err
 			}
@@ -126,6 +133,10 @@ func init() {
 	newCmd.Flags().BoolVarP(&flgs.GRPCNoTLS, "grpc-no-tls", "", false, flgs.Usage("GRPCNoTLS"))
 	newCmd.Flags().StringSliceVarP(&flgs.GRPCProtos, "grpc-proto", "", []string{}, flgs.Usage("GRPCProtos"))
 	newCmd.Flags().StringSliceVarP(&flgs.GRPCImportPaths, "grpc-import-path", "", []string{}, flgs.Usage("GRPCImportPaths"))
+	newCmd.Flags().StringSliceVarP(&flgs.GRPCBufDirs, "grpc-buf-dir", "", []string{}, flgs.Usage("GRPCBufDirs"))
+	newCmd.Flags().StringSliceVarP(&flgs.GRPCBufLocks, "grpc-buf-lock", "", []string{}, flgs.Usage("GRPCBufLocks"))
+	newCmd.Flags().StringSliceVarP(&flgs.GRPCBufConfigs, "grpc-buf-config", "", []string{}, flgs.Usage("GRPCBufConfigs"))
+	newCmd.Flags().StringSliceVarP(&flgs.GRPCBufModules, "grpc-buf-module", "", []string{}, flgs.Usage("GRPCBufModules"))
 }
 
 func runAndCapture(ctx context.Context, o *os.File, fn func(*os.File) error) error {
@@ -153,11 +164,9 @@ func runAndCapture(ctx context.Context, o *os.File, fn func(*os.File) error) err
 	opts := []runn.Option{
 		runn.Book(tf.Name()),
 		runn.Capture(capture.Runbook(td, capture.RunbookLoadDesc(true))),
-		runn.GRPCNoTLS(flgs.GRPCNoTLS),
-		runn.GRPCProtos(flgs.GRPCProtos),
-		runn.GRPCImportPaths(flgs.GRPCImportPaths),
 		runn.Scopes(scope.AllowReadParent),
 	}
+	opts = append(opts, grpcOptions()...)
 	oo, err := runn.New(opts...)
 	if err != nil {
 		return err
@@ -25,9 +25,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/fatih/color"
 	"github.com/goccy/go-json"
@@ -72,25 +75,37 @@ var runCmd = &cobra.Command{
 			color.NoColor = false
 		}
 
+		if flgs.Watch {
+			if flgs.DryRun {
+				return errors.New("--watch and --dry-run cannot be used together")
+			}
+			return runWatch(ctx, pathp, opts)
+		}
+
+		if flgs.DryRun {
+			opts = append(opts, runn.LoadOnly())
+		}
+
 		o, err := runn.Load(pathp, opts...)
 		if err != nil {
 			return err
 		}
+		if flgs.DryRun {
+			p, err := o.Plan()
+			if err != nil {
+				return err
+			}
+			if flgs.Format == "json" {
+				return p.OutJSON(os.Stdout)
+			}
+			return p.OutYAML(os.Stdout)
+		}
 		if err := o.RunN(ctx); err != nil {
 			return err
 		}
 		r := o.Result()
-		switch flgs.Format {
-		case "json":
-			if err := r.OutJSON(os.Stdout); err != nil {
-				return err
-			}
-		case "none":
-		default:
-			// If --verbose == true, leave it to cmdout to display results
-			if err := r.Out(os.Stdout); err != nil {
-				return err
-			}
+		if err := outRunResult(r); err != nil {
+			return err
 		}
 
 		if flgs.Profile {
@@ -109,11 +124,14 @@ var runCmd = &cobra.Command{
 			}
 		}
 
-		if flgs.Coverage {
-			cov, err := o.CollectCoverage(ctx)
+		var cov *runn.Coverage
+		if flgs.Coverage || flgs.CoverageThreshold != "" {
+			cov, err = o.CollectCoverage(ctx)
 			if err != nil {
 				return err
 			}
+		}
+		if flgs.Coverage {
 			b, err := json.MarshalIndent(cov, "", "  ")
 			if err != nil {
 				return err
@@ -123,17 +141,101 @@ var runCmd = &cobra.Command{
 			}
 		}
 
+		if flgs.ReportOut != "" {
+			f, err := os.Create(filepath.Clean(flgs.ReportOut))
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err := f.Close(); err != nil {
+					_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
+					os.Exit(1)
+				}
+			}()
+			if err := o.DumpReport(f, cov); err != nil {
+				return err
+			}
+		}
+
 		if r.HasFailure() {
 			os.Exit(1)
 		}
+		if cov != nil {
+			if err := cov.CheckThreshold(flgs.CoverageThreshold); err != nil {
+				return err
+			}
+		}
 		return nil
 	},
 }
 
+// runResult is the result of the run of runbooks.
+type runResult interface {
+	Out(out io.Writer) error
+	OutJSON(out io.Writer) error
+	SaveLastResult() error
+	SaveHistory() error
+}
+
+// outRunResult saves the result to the state directory and outputs it.
+func outRunResult(r runResult) error {
+	// Save the result for --rerun-failed last
+	if err := r.SaveLastResult(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to save the result of the run: %v\n", err)
+	}
+	if flgs.History {
+		if err := r.SaveHistory(); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "failed to save the history of the run: %v\n", err)
+		}
+	}
+	switch flgs.Format {
+	case "json":
+		if err := r.OutJSON(os.Stdout); err != nil {
+			return err
+		}
+	case "none":
+	default:
+		// If --verbose == true, leave it to cmdout to display results
+		if err := r.Out(os.Stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runWatch runs the runbooks and reruns the affected runbooks on changes until interrupted.
+func runWatch(ctx context.Context, pathp string, opts []runn.Option) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	w := runn.NewWatcher(pathp, opts...)
+	defer w.Close()
+	for {
+		o, err := w.Run(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if o != nil {
+			if err := outRunResult(o.Result()); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+		_, _ = fmt.Fprintln(os.Stderr, "Waiting for changes... (Ctrl+C to quit)")
+		if err := w.Wait(ctx); err != nil {
+			// Interrupted
+			return nil
+		}
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(runCmd)
 	runCmd.Flags().BoolVarP(&flgs.Debug, "debug", "", false, flgs.Usage("Debug"))
 	runCmd.Flags().BoolVarP(&flgs.FailFast, "fail-fast", "", false, flgs.Usage("FailFast"))
+	runCmd.Flags().IntVarP(&flgs.Retries, "retries", "", 0, flgs.Usage("Retries"))
+	runCmd.Flags().BoolVarP(&flgs.History, "history", "", false, flgs.Usage("History"))
 	runCmd.Flags().BoolVarP(&flgs.SkipTest, "skip-test", "", false, flgs.Usage("SkipTest"))
 	runCmd.Flags().BoolVarP(&flgs.SkipIncluded, "skip-included", "", false, flgs.Usage("SkipIncluded"))
 	runCmd.Flags().StringSliceVarP(&flgs.HostRules, "host-rules", "", []string{}, flgs.Usage("HostRules"))
@@ -152,6 +254,7 @@ func init() {
 	runCmd.Flags().StringSliceVarP(&flgs.Underlays, "underlay", "", []string{}, flgs.Usage("Underlays"))
 	runCmd.Flags().StringVarP(&flgs.RunMatch, "run", "", "", flgs.Usage("RunMatch"))
 	runCmd.Flags().StringSliceVarP(&flgs.RunIDs, "id", "", []string{}, flgs.Usage("RunIDs"))
+	runCmd.Flags().StringVarP(&flgs.RerunFailed, "rerun-failed", "", "", flgs.Usage("RerunFailed"))
 	runCmd.Flags().StringSliceVarP(&flgs.RunLabels, "label", "", []string{}, flgs.Usage("RunLabels"))
 	runCmd.Flags().IntVarP(&flgs.Sample, "sample", "", 0, flgs.Usage("Sample"))
 	runCmd.Flags().StringVarP(&flgs.Shuffle, "shuffle", "", "off", flgs.Usage("Shuffle"))
@@ -160,6 +263,8 @@ func init() {
 	runCmd.Flags().IntVarP(&flgs.ShardN, "shard-n", "", 0, flgs.Usage("ShardN"))
 	runCmd.Flags().IntVarP(&flgs.Random, "random", "", 0, flgs.Usage("Random"))
 	runCmd.Flags().StringVarP(&flgs.Format, "format", "", "", flgs.Usage("Format"))
+	runCmd.Flags().BoolVarP(&flgs.DryRun, "dry-run", "", false, flgs.Usage("DryRun"))
+	runCmd.Flags().BoolVarP(&flgs.Watch, "watch", "", false, flgs.Usage("Watch"))
 	runCmd.Flags().BoolVarP(&flgs.Profile, "profile", "", false, flgs.Usage("Profile"))
 	runCmd.Flags().StringVarP(&flgs.ProfileOut, "profile-out", "", "runn.prof", flgs.Usage("ProfileOut"))
 	runCmd.Flags().StringVarP(&flgs.CacheDir, "cache-dir", "", "", flgs.Usage("CacheDir"))
@@ -171,7 +276,11 @@ func init() {
 	}
 	runCmd.Flags().BoolVarP(&flgs.Verbose, "verbose", "", false, flgs.Usage("Verbose"))
 	runCmd.Flags().BoolVarP(&flgs.Attach, "attach", "", false, flgs.Usage("Attach"))
+	runCmd.Flags().StringVarP(&flgs.DAP, "dap", "", "", flgs.Usage("DAP"))
+	runCmd.Flags().BoolVarP(&flgs.UpdateBaselines, "update-baselines", "", false, flgs.Usage("UpdateBaselines"))
 	runCmd.Flags().BoolVarP(&flgs.ForceColor, "force-color", "", false, flgs.Usage("ForceColor"))
 	runCmd.Flags().BoolVarP(&flgs.Coverage, "coverage", "", false, flgs.Usage("Coverage"))
 	runCmd.Flags().StringVarP(&flgs.CoverageOut, "coverage-out", "", "runn.coverage.json", flgs.Usage("CoverageOut"))
+	runCmd.Flags().StringVarP(&flgs.CoverageThreshold, "coverage-threshold", "", "", flgs.Usage("CoverageThreshold"))
+	runCmd.Flags().StringVarP(&flgs.ReportOut, "report-out", "", "", flgs.Usage("ReportOut"))
 }
@@ -6,13 +6,19 @@ import (
 	"net/http"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/k1LoW/runn/internal/expr"
 	"github.com/pb33f/libopenapi-validator/paths"
+	"github.com/pb33f/libopenapi/datamodel/high/base"
 	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
 	"github.com/pb33f/libopenapi/orderedmap"
 	"github.com/samber/lo"
+	"github.com/spf13/cast"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 var varRep = regexp.MustCompile(`\{\{([^}]+)\}\}`)
@@ -25,10 +31,28 @@ type Coverage struct {
 
 // SpecCoverage is a coverage of spec (e.g. OpenAPI Document, servive of protocol buffers).
 type SpecCoverage struct {
-	Key       string         `json:"key"`
-	Coverages map[string]int `json:"coverages"`
+	Key       string                     `json:"key"`
+	Coverages map[string]int             `json:"coverages"`
+	Details   map[string]*DetailCoverage `json:"details,omitempty"`
 }
 
+// DetailCoverage is a detailed coverage of an operation of OpenAPI Document or a method of protocol buffers.
+type DetailCoverage struct {
+	// Fields is the coverage of request fields (e.g. "name", "user.id").
+	Fields map[string]int `json:"fields,omitempty"`
+	// Statuses is the coverage of response statuses (HTTP status codes or gRPC status codes) observed in the executed steps.
+	// For OpenAPI, the observed statuses are mapped onto the statuses documented in `responses` (exact code, `NXX`, then `default`).
+	// For protocol buffers, only the observed statuses are listed.
+	Statuses map[string]int `json:"statuses,omitempty"`
+	// Enums is the coverage of enum values of request fields (e.g. "status=ACTIVE").
+	Enums map[string]int `json:"enums,omitempty"`
+	// Undocumented is the number of observed response statuses that are not documented in `responses` of the OpenAPI Document.
+	Undocumented map[string]int `json:"undocumented,omitempty"`
+}
+
+// coverageMaxFieldDepth is the max depth of nested fields to collect coverage (for recursive schemas).
+const coverageMaxFieldDepth = 5
+
 func (o *operator) collectCoverage(ctx context.Context) (*Coverage, error) {
 	cov := &Coverage{}
 	// Collect coverage for openapi3
@@ -52,6 +76,7 @@ func (o *operator) collectCoverage(ctx context.Context) (*Coverage, error) {
 			scov = &SpecCoverage{
 				Key:       key,
 				Coverages: map[string]int{},
+				Details:   map[string]*DetailCoverage{},
 			}
 			cov.Specs = append(cov.Specs, scov)
 		}
@@ -61,6 +86,9 @@ func (o *operator) collectCoverage(ctx context.Context) (*Coverage, error) {
 			for op := range orderedmap.Iterate(ctx, p.Value().GetOperations()) {
 				mkey := fmt.Sprintf("%s %s", strings.ToUpper(op.Key()), p.Key())
 				scov.Coverages[mkey] += 0
+				if _, ok := scov.Details[mkey]; !ok {
+					scov.Details[mkey] = newOpenAPI3DetailCoverage(op.Value())
+				}
 			}
 		}
 		regexCache := &sync.Map{}
@@ -90,6 +118,9 @@ func (o *operator) collectCoverage(ctx context.Context) (*Coverage, error) {
 					}
 					mkey := fmt.Sprintf("%s %s", method, pathValue)
 					scov.Coverages[mkey]++
+					if d, ok := scov.Details[mkey]; ok {
+						d.countOpenAPI3(mm[mmm], o.statuses[s.idx])
+					}
 					continue L
 				}
 				o.Debugf("%s %s was not matched in %s (%s)\n", method, p, key, o.bookPath)
@@ -126,10 +157,14 @@ func (o *operator) collectCoverage(ctx context.Context) (*Coverage, error) {
 				scov = &SpecCoverage{
 					Key:       service,
 					Coverages: map[string]int{},
+					Details:   map[string]*DetailCoverage{},
 				}
 				cov.Specs = append(cov.Specs, scov)
 			}
 			scov.Coverages[method] += 0
+			if _, ok := scov.Details[method]; !ok {
+				scov.Details[method] = newGRPCDetailCoverage(r.mds[k])
+			}
 		}
 		for _, s := range o.steps {
 			if s.grpcRunner != r {
@@ -147,6 +182,9 @@ func (o *operator) collectCoverage(ctx context.Context) (*Coverage, error) {
 					continue
 				}
 				scov.Coverages[method]++
+				if d, ok := scov.Details[method]; ok {
+					d.countGRPC(r.mds[k], s.grpcRequest[k], o.statuses[s.idx])
+				}
 			}
 		}
 	}
@@ -157,3 +195,326 @@ func (o *operator) collectCoverage(ctx context.Context) (*Coverage, error) {
 
 	return cov, nil
 }
+
+// Merge merges the coverage into c.
+// It can be used to merge the coverages of shards (e.g. `--shard-n`).
+func (c *Coverage) Merge(in *Coverage) {
+	if in == nil {
+		return
+	}
+	for _, sc := range in.Specs {
+		spec, ok := lo.Find(c.Specs, func(i *SpecCoverage) bool {
+			return sc.Key == i.Key
+		})
+		if !ok {
+			spec = &SpecCoverage{
+				Key:       sc.Key,
+				Coverages: map[string]int{},
+			}
+			c.Specs = append(c.Specs, spec)
+		}
+		for k, v := range sc.Coverages {
+			spec.Coverages[k] += v
+		}
+		for k, d := range sc.Details {
+			if spec.Details == nil {
+				spec.Details = map[string]*DetailCoverage{}
+			}
+			if _, ok := spec.Details[k]; !ok {
+				spec.Details[k] = &DetailCoverage{}
+			}
+			spec.Details[k].Merge(d)
+		}
+	}
+	sort.SliceStable(c.Specs, func(i, j int) bool {
+		return c.Specs[i].Key < c.Specs[j].Key
+	})
+}
+
+// CheckThreshold checks the coverage against the threshold condition (e.g. `coverage >= 80 && specs["grpctest.GrpcTestService"].coverage == 100`).
+func (c *Coverage) CheckThreshold(threshold string) error {
+	if threshold == "" {
+		return nil
+	}
+	var covered, total int
+	specs := map[string]any{}
+	for _, spec := range c.Specs {
+		sc, st := spec.Covered()
+		covered += sc
+		total += st
+		specs[spec.Key] = map[string]any{
+			"coverage": coveragePercent(sc, st),
+			"covered":  sc,
+			"total":    st,
+		}
+	}
+	store := map[string]any{
+		"coverage": coveragePercent(covered, total),
+		"covered":  covered,
+		"total":    total,
+		"specs":    specs,
+	}
+	tf, err := expr.EvalWithTrace(threshold, store)
+	if err != nil {
+		return err
+	}
+	if !tf.OutputAsBool() {
+		bt, err := tf.FormatTraceTree()
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("(%s) is not true\n%s", threshold, bt)
+	}
+	return nil
+}
+
+// Covered returns the number of covered operations/methods and the number of all of them.
+func (s *SpecCoverage) Covered() (covered, total int) {
+	for _, v := range s.Coverages {
+		total++
+		if v > 0 {
+			covered++
+		}
+	}
+	return covered, total
+}
+
+func coveragePercent(covered, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(covered) / float64(total) * 100
+}
+
+// Merge merges the detailed coverage into d.
+func (d *DetailCoverage) Merge(in *DetailCoverage) {
+	if in == nil {
+		return
+	}
+	for _, m := range []struct {
+		dst *map[string]int
+		src map[string]int
+	}{
+		{&d.Fields, in.Fields},
+		{&d.Statuses, in.Statuses},
+		{&d.Enums, in.Enums},
+		{&d.Undocumented, in.Undocumented},
+	} {
+		if len(m.src) == 0 {
+			continue
+		}
+		if *m.dst == nil {
+			*m.dst = map[string]int{}
+		}
+		for k, v := range m.src {
+			(*m.dst)[k] += v
+		}
+	}
+}
+
+func newOpenAPI3DetailCoverage(op *v3.Operation) *DetailCoverage {
+	d := &DetailCoverage{
+		Fields:   map[string]int{},
+		Statuses: map[string]int{},
+		Enums:    map[string]int{},
+	}
+	if op.RequestBody != nil {
+		for _, mt := range op.RequestBody.Content.FromOldest() {
+			if mt == nil || mt.Schema == nil {
+				continue
+			}
+			openAPI3SchemaFields("", mt.Schema, d, 0)
+		}
+	}
+	if op.Responses != nil {
+		for code := range op.Responses.Codes.KeysFromOldest() {
+			d.Statuses[strings.ToUpper(code)] += 0
+		}
+		if op.Responses.Default != nil {
+			d.Statuses["default"] += 0
+		}
+	}
+	return d
+}
+
+func openAPI3SchemaFields(prefix string, sp *base.SchemaProxy, d *DetailCoverage, depth int) {
+	if depth > coverageMaxFieldDepth {
+		return
+	}
+	sc := sp.Schema()
+	if sc == nil {
+		return
+	}
+	if sc.Items != nil && sc.Items.IsA() {
+		openAPI3SchemaFields(prefix, sc.Items.A, d, depth+1)
+	}
+	for name, p := range sc.Properties.FromOldest() {
+		key := coverageFieldKey(prefix, name)
+		d.Fields[key] += 0
+		if ps := p.Schema(); ps != nil {
+			for _, e := range ps.Enum {
+				d.Enums[fmt.Sprintf("%s=%s", key, e.Value)] += 0
+			}
+		}
+		openAPI3SchemaFields(key, p, d, depth+1)
+	}
+}
+
+// countOpenAPI3 counts the documented fields and enum values set in the request of the step, and the statuses observed by the step.
+// The statuses that are not documented are counted in Undocumented.
+func (d *DetailCoverage) countOpenAPI3(req any, statuses []int) {
+	if m, ok := req.(map[string]any); ok {
+		if b, ok := m[httpStoreBodyKey].(map[string]any); ok {
+			for _, v := range b {
+				countValueFields("", v, d, 0)
+			}
+		}
+	}
+	for _, code := range statuses {
+		k := strconv.Itoa(code)
+		switch {
+		case lo.HasKey(d.Statuses, k):
+		case lo.HasKey(d.Statuses, fmt.Sprintf("%dXX", code/100)):
+			k = fmt.Sprintf("%dXX", code/100)
+		case lo.HasKey(d.Statuses, "default"):
+			k = "default"
+		default:
+			if d.Undocumented == nil {
+				d.Undocumented = map[string]int{}
+			}
+			d.Undocumented[k]++
+			continue
+		}
+		d.Statuses[k]++
+	}
+}
+
+func countValueFields(prefix string, v any, d *DetailCoverage, depth int) {
+	if depth > coverageMaxFieldDepth {
+		return
+	}
+	switch vv := v.(type) {
+	case map[string]any:
+		for k, fv := range vv {
+			key := coverageFieldKey(prefix, k)
+			if _, ok := d.Fields[key]; ok {
+				d.Fields[key]++
+			}
+			if _, ok := fv.(map[string]any); !ok {
+				if _, ok := fv.([]any); !ok {
+					ek := fmt.Sprintf("%s=%v", key, fv)
+					if _, ok := d.Enums[ek]; ok {
+						d.Enums[ek]++
+					}
+				}
+			}
+			countValueFields(key, fv, d, depth+1)
+		}
+	case []any:
+		for _, e := range vv {
+			countValueFields(prefix, e, d, depth+1)
+		}
+	}
+}
+
+func newGRPCDetailCoverage(md protoreflect.MethodDescriptor) *DetailCoverage {
+	d := &DetailCoverage{
+		Fields:   map[string]int{},
+		Statuses: map[string]int{},
+		Enums:    map[string]int{},
+	}
+	if md == nil {
+		return d
+	}
+	grpcMessageFields("", md.Input(), d, 0)
+	return d
+}
+
+func grpcMessageFields(prefix string, md protoreflect.MessageDescriptor, d *DetailCoverage, depth int) {
+	if depth > coverageMaxFieldDepth || md.ParentFile().Package() == "google.protobuf" {
+		// Well-known types are treated as scalar values
+		return
+	}
+	fields := md.Fields()
+	for i := range fields.Len() {
+		fd := fields.Get(i)
+		key := coverageFieldKey(prefix, string(fd.Name()))
+		d.Fields[key] += 0
+		switch {
+		case fd.IsMap():
+		case fd.Kind() == protoreflect.EnumKind:
+			values := fd.Enum().Values()
+			for j := range values.Len() {
+				d.Enums[fmt.Sprintf("%s=%s", key, values.Get(j).Name())] += 0
+			}
+		case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+			grpcMessageFields(key, fd.Message(), d, depth+1)
+		}
+	}
+}
+
+// countGRPC counts the fields and enum values set in the request of the step, and the statuses observed by the step.
+func (d *DetailCoverage) countGRPC(md protoreflect.MethodDescriptor, req any, statuses []int) {
+	if m, ok := req.(map[string]any); ok && md != nil {
+		if msg, ok := m["message"]; ok {
+			countGRPCMessage("", md.Input(), msg, d, 0)
+		}
+		if msgs, ok := m["messages"].([]any); ok {
+			for _, msg := range msgs {
+				countGRPCMessage("", md.Input(), msg, d, 0)
+			}
+		}
+	}
+	for _, code := range statuses {
+		d.Statuses[codes.Code(uint32(code)).String()]++ //nolint:gosec
+	}
+}
+
+func countGRPCMessage(prefix string, md protoreflect.MessageDescriptor, v any, d *DetailCoverage, depth int) {
+	m, ok := v.(map[string]any)
+	if !ok || depth > coverageMaxFieldDepth || md.ParentFile().Package() == "google.protobuf" {
+		return
+	}
+	for k, fv := range m {
+		fd := md.Fields().ByName(protoreflect.Name(k))
+		if fd == nil {
+			fd = md.Fields().ByJSONName(k)
+		}
+		if fd == nil {
+			continue
+		}
+		key := coverageFieldKey(prefix, string(fd.Name()))
+		d.Fields[key]++
+		if fd.IsMap() {
+			continue
+		}
+		values := []any{fv}
+		if l, ok := fv.([]any); ok && fd.IsList() {
+			values = l
+		}
+		for _, e := range values {
+			switch fd.Kind() {
+			case protoreflect.EnumKind:
+				name := cast.ToString(e)
+				if n, err := cast.ToInt32E(e); err == nil {
+					if ev := fd.Enum().Values().ByNumber(protoreflect.EnumNumber(n)); ev != nil {
+						name = string(ev.Name())
+					}
+				}
+				ek := fmt.Sprintf("%s=%s", key, name)
+				if _, ok := d.Enums[ek]; ok {
+					d.Enums[ek]++
+				}
+			case protoreflect.MessageKind, protoreflect.GroupKind:
+				countGRPCMessage(key, fd.Message(), e, d, depth+1)
+			}
+		}
+	}
+}
+
+func coverageFieldKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return fmt.Sprintf("%s.%s", prefix, name)
+}
@@ -4,14 +4,22 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/k1LoW/donegroup"
 	"github.com/k1LoW/runn/internal/scope"
 	"github.com/k1LoW/runn/testutil"
 	"github.com/tenntenn/golden"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 func TestCoverage(t *testing.T) {
@@ -54,3 +62,156 @@ func TestCoverage(t *testing.T) {
 		})
 	}
 }
+
+func TestCoverageDetails(t *testing.T) {
+	ctx, cancel := donegroup.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if _, ok := body["role"]; ok {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(hs.Close)
+	gs := grpc.NewServer()
+	healthpb.RegisterHealthServer(gs, health.NewServer())
+	reflection.Register(gs)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = gs.Serve(lis)
+	}()
+	t.Cleanup(gs.Stop)
+	t.Setenv("TEST_HTTP_ENDPOINT", hs.URL)
+	t.Setenv("TEST_GRPC_ADDR", lis.Addr().String())
+
+	o, err := New(Book("testdata/book/coverage_details.yml"), Scopes(scope.AllowReadParent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cov, err := o.collectCoverage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*SpecCoverage{
+		{
+			Key:       "coverage spec:0.0.1",
+			Coverages: map[string]int{"POST /users": 3},
+			Details: map[string]*DetailCoverage{
+				"POST /users": {
+					Fields:   map[string]int{"username": 3, "role": 1, "profile": 1, "profile.email": 1},
+					Statuses: map[string]int{"201": 1, "400": 0, "4XX": 1, "default": 0},
+					Enums:    map[string]int{"role=admin": 1, "role=member": 0},
+				},
+			},
+		},
+		{
+			Key:       "grpc.health.v1.Health",
+			Coverages: map[string]int{"Check": 2, "List": 0, "Watch": 0},
+			Details: map[string]*DetailCoverage{
+				"Check": {
+					Fields:   map[string]int{"service": 2},
+					Statuses: map[string]int{"OK": 1, "NotFound": 1},
+					Enums:    map[string]int{},
+				},
+				"List": {
+					Fields:   map[string]int{},
+					Statuses: map[string]int{},
+					Enums:    map[string]int{},
+				},
+				"Watch": {
+					Fields:   map[string]int{"service": 0},
+					Statuses: map[string]int{},
+					Enums:    map[string]int{},
+				},
+			},
+		},
+	}
+	// The services for reflection are also listed after them
+	if diff := cmp.Diff(cov.Specs[:len(want)], want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCountGRPCMessageEnums(t *testing.T) {
+	md := (&healthpb.HealthCheckResponse{}).ProtoReflect().Descriptor()
+	d := &DetailCoverage{Fields: map[string]int{}, Enums: map[string]int{}}
+	grpcMessageFields("", md, d, 0)
+	for _, v := range []any{
+		map[string]any{"status": "SERVING"},
+		map[string]any{"status": 2},
+		map[string]any{"status": "{{ vars.status }}"},
+	} {
+		countGRPCMessage("", md, v, d, 0)
+	}
+	want := &DetailCoverage{
+		Fields: map[string]int{"status": 3},
+		Enums:  map[string]int{"status=UNKNOWN": 0, "status=SERVING": 1, "status=NOT_SERVING": 1, "status=SERVICE_UNKNOWN": 0},
+	}
+	if diff := cmp.Diff(d, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCoverageMerge(t *testing.T) {
+	cov := &Coverage{}
+	cov.Merge(&Coverage{Specs: []*SpecCoverage{
+		{Key: "svc", Coverages: map[string]int{"A": 1, "B": 0}, Details: map[string]*DetailCoverage{"A": {Statuses: map[string]int{"OK": 1}}}},
+	}})
+	cov.Merge(&Coverage{Specs: []*SpecCoverage{
+		{Key: "svc", Coverages: map[string]int{"A": 0, "B": 2}, Details: map[string]*DetailCoverage{"A": {Statuses: map[string]int{"OK": 1, "NotFound": 1}}}},
+		{Key: "api:1", Coverages: map[string]int{"GET /x": 0}},
+	}})
+	cov.Merge(nil)
+	want := []*SpecCoverage{
+		{Key: "api:1", Coverages: map[string]int{"GET /x": 0}},
+		{Key: "svc", Coverages: map[string]int{"A": 1, "B": 2}, Details: map[string]*DetailCoverage{"A": {Statuses: map[string]int{"OK": 2, "NotFound": 1}}}},
+	}
+	if diff := cmp.Diff(cov.Specs, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCoverageCheckThreshold(t *testing.T) {
+	cov := &Coverage{Specs: []*SpecCoverage{
+		{Key: "api:1", Coverages: map[string]int{"GET /x": 0}},
+		{Key: "svc", Coverages: map[string]int{"A": 1, "B": 2}},
+	}}
+	tests := []struct {
+		threshold string
+		wantErr   bool
+	}{
+		{"", false},
+		{"coverage > 60", false},
+		{"coverage > 70", true},
+		{`covered == 2 && total == 3`, false},
+		{`specs["svc"].coverage == 100 && specs["api:1"].covered == 0`, false},
+		{`specs["api:1"].coverage >= 50`, true},
+		{"invalid(", true},
+	}
+	for _, tt := range tests {
+		if err := cov.CheckThreshold(tt.threshold); (err != nil) != tt.wantErr {
+			t.Errorf("%q: got %v", tt.threshold, err)
+		}
+	}
+}
+
+func TestCountOpenAPI3Statuses(t *testing.T) {
+	d := &DetailCoverage{Statuses: map[string]int{"200": 0, "4XX": 0}}
+	d.countOpenAPI3(nil, []int{200, 404, 500, 500})
+	want := &DetailCoverage{
+		Statuses:     map[string]int{"200": 1, "4XX": 1},
+		Undocumented: map[string]int{"500": 2},
+	}
+	if diff := cmp.Diff(d, want); diff != "" {
+		t.Error(diff)
+	}
+}
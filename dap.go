@@ -0,0 +1,1007 @@
+package runn
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-json"
+	"github.com/k1LoW/runn/internal/expr"
+	"github.com/k1LoW/runn/internal/fs"
+)
+
+const (
+	dapStopReasonEntry      = "entry"
+	dapStopReasonStep       = "step"
+	dapStopReasonBreakpoint = "breakpoint"
+	dapStopReasonPause      = "pause"
+	dapStopReasonException  = "exception"
+)
+
+// dapExceptionFilterFailure is the exception breakpoint filter to stop when the step fails.
+const dapExceptionFilterFailure = "failure"
+
+const (
+	dapResumeContinue = "continue"
+	dapResumeNext     = "next"
+	dapResumeStepIn   = "stepIn"
+	dapResumeStepOut  = "stepOut"
+	// dapResumeRestartFrame runs the step of the top frame again ( or rewinds to it if the step has not been run yet ).
+	dapResumeRestartFrame = "restartFrame"
+	// dapResumeStepBack rewinds to the previous step.
+	dapResumeStepBack = "stepBack"
+)
+
+// dapServer is the Debug Adapter Protocol ( https://microsoft.github.io/debug-adapter-protocol/ ) server of runn debugger.
+// Each root runbook being run is a thread, and each step ( and the steps including it ) is a stack frame.
+type dapServer struct {
+	ln             net.Listener
+	conn           net.Conn
+	seq            int
+	wmu            sync.Mutex // wmu guards conn and seq
+	ready          chan struct{}
+	readyOnce      sync.Once
+	linesStartAt1  bool
+	stopOnEntry    bool
+	detached       bool
+	quit           bool
+	breakpoints    map[string]map[int][]string // key is the absolute path of the runbook and value is the conditions of the breakpoints of the steps ( "" is unconditional )
+	breakOnFailure bool
+	areas          map[string]*areas
+	threads        map[*operator]*dapThread
+	threadSeq      int
+	handles        map[int]any // handles of the stack frames ( *dapFrame ) and the variables
+	handleSeq      int
+	mu             sync.Mutex
+}
+
+// dapThread is the thread of the DAP server that runs the root runbook.
+type dapThread struct {
+	id      int
+	name    string
+	entered bool
+	step    *step // step where the thread is stopped
+	after   bool  // after is true if the thread is stopped after the step runs
+	resume  chan string
+	mode    string // resume mode ( next, stepIn or stepOut )
+	depth   int    // depth of the included runbook when resumed
+	pause   bool
+}
+
+// dapFrame is the stack frame of the stopped thread.
+type dapFrame struct {
+	step  *step
+	after bool
+}
+
+type dapMessage struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type dapResponse struct {
+	Seq        int    `json:"seq"`
+	Type       string `json:"type"`
+	RequestSeq int    `json:"request_seq"`
+	Success    bool   `json:"success"`
+	Command    string `json:"command"`
+	Message    string `json:"message,omitempty"`
+	Body       any    `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int    `json:"seq"`
+	Type  string `json:"type"`
+	Event string `json:"event"`
+	Body  any    `json:"body,omitempty"`
+}
+
+type dapSource struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+type dapSourceBreakpoint struct {
+	Line      int    `json:"line"`
+	Condition string `json:"condition,omitempty"`
+}
+
+type dapBreakpoint struct {
+	ID       int        `json:"id,omitempty"`
+	Verified bool       `json:"verified"`
+	Message  string     `json:"message,omitempty"`
+	Source   *dapSource `json:"source,omitempty"`
+	Line     int        `json:"line,omitempty"`
+}
+
+type dapStackFrame struct {
+	ID     int        `json:"id"`
+	Name   string     `json:"name"`
+	Source *dapSource `json:"source,omitempty"`
+	Line   int        `json:"line"`
+	Column int        `json:"column"`
+}
+
+type dapScope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type dapVariable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+type dapThreadInfo struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// newDAPServer starts listening on the address. Runbooks wait for a DAP client to be configured before running steps.
+func newDAPServer(addr string) (*dapServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start DAP server: %w", err)
+	}
+	d := &dapServer{
+		ln:            ln,
+		ready:         make(chan struct{}),
+		linesStartAt1: true,
+		breakpoints:   map[string]map[int][]string{},
+		areas:         map[string]*areas{},
+		threads:       map[*operator]*dapThread{},
+		handles:       map[int]any{},
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "runn is waiting for a DAP client on %s\n", ln.Addr().String())
+	go d.serve()
+	return d, nil
+}
+
+// Addr returns the address the DAP server is listening on.
+func (d *dapServer) Addr() net.Addr {
+	return d.ln.Addr()
+}
+
+func (d *dapServer) serve() {
+	for {
+		conn, err := d.ln.Accept()
+		if err != nil {
+			return
+		}
+		d.wmu.Lock()
+		d.conn = conn
+		d.wmu.Unlock()
+		d.handle(conn)
+		d.wmu.Lock()
+		d.conn = nil
+		d.wmu.Unlock()
+		_ = conn.Close()
+		d.detach(false)
+	}
+}
+
+// handle handles the requests of the DAP client until the client disconnects.
+func (d *dapServer) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		m, err := readDAPMessage(r)
+		if err != nil {
+			return
+		}
+		if m.Type != "request" {
+			continue
+		}
+		body, err := d.request(m)
+		if err != nil {
+			d.respond(m, nil, err)
+			continue
+		}
+		d.respond(m, body, nil)
+		switch m.Command {
+		case "initialize":
+			d.send(&dapEvent{Type: "event", Event: "initialized"})
+		case "terminate":
+			d.send(&dapEvent{Type: "event", Event: "terminated"})
+		case "disconnect":
+			return
+		}
+	}
+}
+
+// request handles the request and returns the body of the response.
+func (d *dapServer) request(m *dapMessage) (any, error) {
+	switch m.Command {
+	case "initialize":
+		args := struct {
+			LinesStartAt1 *bool `json:"linesStartAt1"`
+		}{}
+		if err := unmarshalDAPArguments(m, &args); err != nil {
+			return nil, err
+		}
+		d.mu.Lock()
+		d.linesStartAt1 = args.LinesStartAt1 == nil || *args.LinesStartAt1
+		d.detached = false
+		d.mu.Unlock()
+		return map[string]any{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+			"supportsConditionalBreakpoints":   true,
+			"supportsRestartFrame":             true,
+			"supportsStepBack":                 true,
+			"exceptionBreakpointFilters": []map[string]any{
+				{"filter": dapExceptionFilterFailure, "label": "Step failure", "default": false},
+			},
+		}, nil
+	case "launch", "attach":
+		args := struct {
+			StopOnEntry bool `json:"stopOnEntry"`
+		}{}
+		if err := unmarshalDAPArguments(m, &args); err != nil {
+			return nil, err
+		}
+		d.mu.Lock()
+		d.stopOnEntry = args.StopOnEntry
+		d.mu.Unlock()
+		return nil, nil
+	case "setBreakpoints":
+		args := struct {
+			Source      dapSource              `json:"source"`
+			Breakpoints []*dapSourceBreakpoint `json:"breakpoints"`
+		}{}
+		if err := unmarshalDAPArguments(m, &args); err != nil {
+			return nil, err
+		}
+		return map[string]any{"breakpoints": d.setBreakpoints(args.Source.Path, args.Breakpoints)}, nil
+	case "setExceptionBreakpoints":
+		args := struct {
+			Filters []string `json:"filters"`
+		}{}
+		if err := unmarshalDAPArguments(m, &args); err != nil {
+			return nil, err
+		}
+		d.mu.Lock()
+		d.breakOnFailure = slices.Contains(args.Filters, dapExceptionFilterFailure)
+		d.mu.Unlock()
+		return map[string]any{}, nil
+	case "configurationDone":
+		d.readyOnce.Do(func() {
+			close(d.ready)
+		})
+		return nil, nil
+	case "threads":
+		d.mu.Lock()
+		threads := []*dapThreadInfo{}
+		for _, th := range d.threads {
+			threads = append(threads, &dapThreadInfo{ID: th.id, Name: th.name})
+		}
+		d.mu.Unlock()
+		sort.Slice(threads, func(i, j int) bool {
+			return threads[i].ID < threads[j].ID
+		})
+		return map[string]any{"threads": threads}, nil
+	case "stackTrace":
+		args := struct {
+			ThreadID int `json:"threadId"`
+		}{}
+		if err := unmarshalDAPArguments(m, &args); err != nil {
+			return nil, err
+		}
+		frames, err := d.stackTrace(args.ThreadID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"stackFrames": frames, "totalFrames": len(frames)}, nil
+	case "scopes":
+		args := struct {
+			FrameID int `json:"frameId"`
+		}{}
+		if err := unmarshalDAPArguments(m, &args); err != nil {
+			return nil, err
+		}
+		f, err := d.frame(args.FrameID)
+		if err != nil {
+			return nil, err
+		}
+		d.mu.Lock()
+		ref := d.newHandle(storeMapForDbg(f.step, f.after))
+		d.mu.Unlock()
+		return map[string]any{"scopes": []*dapScope{{Name: "Store", VariablesReference: ref}}}, nil
+	case "variables":
+		args := struct {
+			VariablesReference int `json:"variablesReference"`
+		}{}
+		if err := unmarshalDAPArguments(m, &args); err != nil {
+			return nil, err
+		}
+		vars, err := d.variables(args.VariablesReference)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"variables": vars}, nil
+	case "evaluate":
+		args := struct {
+			Expression string `json:"expression"`
+			FrameID    int    `json:"frameId"`
+			Context    string `json:"context"`
+		}{}
+		if err := unmarshalDAPArguments(m, &args); err != nil {
+			return nil, err
+		}
+		f, err := d.frame(args.FrameID)
+		if err != nil {
+			return nil, err
+		}
+		if e, ok := strings.CutPrefix(args.Expression, dbgCmdSet+" "); ok && args.Context == "repl" {
+			// `set vars.<key> <expression>` or `set <key> <expression>` in the debug console
+			kv := strings.SplitN(strings.TrimSpace(e), " ", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid args %s", e)
+			}
+			if err := setStoreForDbg(f.step, kv[0], kv[1], f.after); err != nil {
+				return nil, err
+			}
+			d.send(&dapEvent{Type: "event", Event: "invalidated", Body: map[string]any{"areas": []string{"variables"}}})
+			return map[string]any{"result": "", "variablesReference": 0}, nil
+		}
+		v, err := expr.Eval(args.Expression, storeMapForDbg(f.step, f.after))
+		if err != nil {
+			return nil, err
+		}
+		d.mu.Lock()
+		variable := d.newVariable("", v)
+		d.mu.Unlock()
+		return map[string]any{
+			"result":             variable.Value,
+			"type":               variable.Type,
+			"variablesReference": variable.VariablesReference,
+		}, nil
+	case "restartFrame":
+		args := struct {
+			FrameID int `json:"frameId"`
+		}{}
+		if err := unmarshalDAPArguments(m, &args); err != nil {
+			return nil, err
+		}
+		f, err := d.frame(args.FrameID)
+		if err != nil {
+			return nil, err
+		}
+		d.mu.Lock()
+		var threadID int
+		for _, th := range d.threads {
+			if th.step == f.step {
+				threadID = th.id
+			}
+		}
+		d.mu.Unlock()
+		if threadID == 0 {
+			return nil, errors.New("only the top stack frame can be restarted")
+		}
+		if err := d.resume(threadID, dapResumeRestartFrame); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	case "continue", "next", "stepIn", "stepOut", "stepBack":
+		args := struct {
+			ThreadID int `json:"threadId"`
+		}{}
+		if err := unmarshalDAPArguments(m, &args); err != nil {
+			return nil, err
+		}
+		if err := d.resume(args.ThreadID, m.Command); err != nil {
+			return nil, err
+		}
+		if m.Command == "continue" {
+			return map[string]any{"allThreadsContinued": false}, nil
+		}
+		return nil, nil
+	case "pause":
+		args := struct {
+			ThreadID int `json:"threadId"`
+		}{}
+		if err := unmarshalDAPArguments(m, &args); err != nil {
+			return nil, err
+		}
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		th := d.thread(args.ThreadID)
+		if th == nil {
+			return nil, fmt.Errorf("thread not found: %d", args.ThreadID)
+		}
+		th.pause = true
+		return nil, nil
+	case "disconnect":
+		args := struct {
+			TerminateDebuggee bool `json:"terminateDebuggee"`
+		}{}
+		if err := unmarshalDAPArguments(m, &args); err != nil {
+			return nil, err
+		}
+		d.detach(args.TerminateDebuggee)
+		return nil, nil
+	case "terminate":
+		d.detach(true)
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported request: %s", m.Command)
+	}
+}
+
+// attach stops the thread running the step if needed and waits until the DAP client resumes it.
+func (d *dapServer) attach(ctx context.Context, s *step) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-d.ready:
+	}
+	root, depth := dapRootOperator(s)
+	d.mu.Lock()
+	if d.quit {
+		d.mu.Unlock()
+		s.parent.skipped = true
+		return errStepSkipped
+	}
+	th, created := d.threadOf(root)
+	reason := d.stopReason(th, s, depth)
+	d.mu.Unlock()
+	if created {
+		d.send(&dapEvent{Type: "event", Event: "thread", Body: map[string]any{"reason": "started", "threadId": th.id}})
+	}
+	if reason == "" {
+		return nil
+	}
+	if err := d.stop(ctx, th, s, depth, false, map[string]any{"reason": reason}); err != nil {
+		if errors.Is(err, errStepSkipped) {
+			s.parent.skipped = true
+		}
+		return err
+	}
+	return nil
+}
+
+// attachAfter stops the thread after the step runs if the step fails with the failure exception filter or the condition of the breakpoint is met.
+// It returns errStepRetried or *stepRewoundError if the DAP client restarts the frame or steps back.
+func (d *dapServer) attachAfter(ctx context.Context, s *step, stepErr error) error {
+	if errors.Is(stepErr, errStepSkipped) {
+		return nil
+	}
+	root, depth := dapRootOperator(s)
+	d.mu.Lock()
+	th, ok := d.threads[root]
+	if !ok || d.quit || d.detached {
+		d.mu.Unlock()
+		return nil
+	}
+	var body map[string]any
+	switch {
+	case stepErr != nil && d.breakOnFailure:
+		body = map[string]any{"reason": dapStopReasonException, "description": "Step failed", "text": stepErr.Error()}
+	case d.conditionMet(s):
+		body = map[string]any{"reason": dapStopReasonBreakpoint}
+	}
+	d.mu.Unlock()
+	if body == nil {
+		return nil
+	}
+	if err := d.stop(ctx, th, s, depth, true, body); err != nil && !errors.Is(err, errStepSkipped) && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// stop stops the thread at the step and waits until the DAP client resumes it.
+// It returns errStepSkipped if the DAP client terminates the runbooks, and errStepRetried or *stepRewoundError if the DAP client restarts the frame or steps back.
+func (d *dapServer) stop(ctx context.Context, th *dapThread, s *step, depth int, after bool, body map[string]any) error {
+	d.mu.Lock()
+	if d.detached {
+		d.mu.Unlock()
+		return nil
+	}
+	th.step = s
+	th.after = after
+	th.mode = ""
+	th.pause = false
+	d.mu.Unlock()
+	body["threadId"] = th.id
+	d.send(&dapEvent{Type: "event", Event: "stopped", Body: body})
+
+	var mode string
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case mode = <-th.resume:
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	th.step = nil
+	th.after = false
+	th.mode = mode
+	th.depth = depth
+	if d.stoppedThreads() == 0 {
+		// Invalidate the handles of the stack frames and the variables
+		d.handles = map[int]any{}
+	}
+	if d.quit {
+		return errStepSkipped
+	}
+	switch mode {
+	case dapResumeRestartFrame:
+		// Stop before the step runs again
+		th.mode = dapResumeStepIn
+		if after {
+			return errStepRetried
+		}
+		return &stepRewoundError{idx: s.idx}
+	case dapResumeStepBack:
+		th.mode = dapResumeStepIn
+		idx := s.idx
+		if !after {
+			for i := s.idx - 1; i >= 0; i-- {
+				if !s.parent.steps[i].deferred {
+					idx = i
+					break
+				}
+			}
+		}
+		return &stepRewoundError{idx: idx}
+	}
+	return nil
+}
+
+// stopReason returns the reason to stop the thread before running the step. It returns "" if the thread does not stop.
+func (d *dapServer) stopReason(th *dapThread, s *step, depth int) string {
+	if d.detached {
+		return ""
+	}
+	if !th.entered {
+		th.entered = true
+		if d.stopOnEntry {
+			return dapStopReasonEntry
+		}
+	}
+	if th.pause {
+		return dapStopReasonPause
+	}
+	switch th.mode {
+	case dapResumeStepIn:
+		return dapStopReasonStep
+	case dapResumeNext:
+		if depth <= th.depth {
+			return dapStopReasonStep
+		}
+	case dapResumeStepOut:
+		if depth < th.depth {
+			return dapStopReasonStep
+		}
+	}
+	if slices.Contains(d.stepBreakpoints(s), "") {
+		return dapStopReasonBreakpoint
+	}
+	return ""
+}
+
+// conditionMet returns whether the condition of any breakpoint of the step is met after the step runs.
+func (d *dapServer) conditionMet(s *step) bool {
+	var sm map[string]any
+	for _, cond := range d.stepBreakpoints(s) {
+		if cond == "" {
+			continue
+		}
+		if sm == nil {
+			sm = storeMapForDbg(s, true)
+		}
+		tf, err := expr.EvalCond(cond, sm)
+		if err != nil {
+			d.send(&dapEvent{Type: "event", Event: "output", Body: map[string]any{
+				"category": "stderr",
+				"output":   fmt.Sprintf("failed to evaluate the condition of the breakpoint: %v\n", err),
+			}})
+			continue
+		}
+		if tf {
+			return true
+		}
+	}
+	return false
+}
+
+// stepBreakpoints returns the conditions of the breakpoints of the step.
+func (d *dapServer) stepBreakpoints(s *step) []string {
+	p, err := filepath.Abs(s.parent.bookPath)
+	if err != nil {
+		return nil
+	}
+	return d.breakpoints[p][s.idx]
+}
+
+// threadOf returns the thread running the root runbook. It creates the thread if not exists.
+func (d *dapServer) threadOf(root *operator) (*dapThread, bool) {
+	if th, ok := d.threads[root]; ok {
+		return th, false
+	}
+	d.threadSeq++
+	th := &dapThread{
+		id:     d.threadSeq,
+		name:   root.bookPathOrID(),
+		resume: make(chan string, 1),
+	}
+	d.threads[root] = th
+	return th, true
+}
+
+// dapRootOperator returns the root runbook of the step and the depth of the included runbook.
+func dapRootOperator(s *step) (*operator, int) {
+	root, depth := s.parent, 0
+	for root.parent != nil {
+		root = root.parent.parent
+		depth++
+	}
+	return root, depth
+}
+
+// finish sends the exited event of the thread running the root runbook.
+func (d *dapServer) finish(op *operator) {
+	d.mu.Lock()
+	th, ok := d.threads[op]
+	if ok {
+		delete(d.threads, op)
+	}
+	d.mu.Unlock()
+	if !ok {
+		return
+	}
+	d.send(&dapEvent{Type: "event", Event: "thread", Body: map[string]any{"reason": "exited", "threadId": th.id}})
+}
+
+// close sends the terminated event and stops the DAP server.
+func (d *dapServer) close() {
+	d.send(&dapEvent{Type: "event", Event: "terminated"})
+	_ = d.ln.Close()
+	d.wmu.Lock()
+	if d.conn != nil {
+		_ = d.conn.Close()
+	}
+	d.wmu.Unlock()
+}
+
+// detach clears the breakpoints and resumes all threads. If quit is true, the remaining steps are skipped.
+func (d *dapServer) detach(quit bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.detached = true
+	d.quit = d.quit || quit
+	d.breakpoints = map[string]map[int][]string{}
+	for _, th := range d.threads {
+		th.pause = false
+		if th.step != nil {
+			select {
+			case th.resume <- dapResumeContinue:
+			default:
+			}
+		}
+	}
+	d.readyOnce.Do(func() {
+		close(d.ready)
+	})
+}
+
+// resume resumes the stopped thread.
+func (d *dapServer) resume(threadID int, mode string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	th := d.thread(threadID)
+	if th == nil || th.step == nil {
+		return fmt.Errorf("thread is not stopped: %d", threadID)
+	}
+	if th.step.deferred && (mode == dapResumeRestartFrame || mode == dapResumeStepBack) {
+		return errors.New("deferred steps cannot be restarted or rewound")
+	}
+	select {
+	case th.resume <- mode:
+	default:
+		return fmt.Errorf("thread is already resumed: %d", threadID)
+	}
+	return nil
+}
+
+// setBreakpoints replaces the breakpoints of the runbook. A breakpoint is set on the step whose area contains the line.
+func (d *dapServer) setBreakpoints(path string, sbps []*dapSourceBreakpoint) []*dapBreakpoint {
+	bps := []*dapBreakpoint{}
+	p, err := filepath.Abs(path)
+	if err != nil {
+		for range sbps {
+			bps = append(bps, &dapBreakpoint{Verified: false, Message: err.Error()})
+		}
+		return bps
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	a, err := d.runbookAreas(p)
+	steps := map[int][]string{}
+	for _, sbp := range sbps {
+		l := sbp.Line
+		if err != nil {
+			bps = append(bps, &dapBreakpoint{Verified: false, Message: err.Error(), Line: l})
+			continue
+		}
+		line := l
+		if !d.linesStartAt1 {
+			line++
+		}
+		idx := -1
+		for i, sa := range a.Steps {
+			if sa.Start.Line <= line && line <= sa.End.Line {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			bps = append(bps, &dapBreakpoint{Verified: false, Message: "no step found at the line", Line: l})
+			continue
+		}
+		steps[idx] = append(steps[idx], sbp.Condition)
+		d.handleSeq++
+		bps = append(bps, &dapBreakpoint{
+			ID:       d.handleSeq,
+			Verified: true,
+			Source:   &dapSource{Name: filepath.Base(p), Path: p},
+			Line:     d.clientLine(a.Steps[idx].Start.Line),
+		})
+	}
+	d.breakpoints[p] = steps
+	return bps
+}
+
+// stackTrace returns the stack frames of the stopped thread. The top frame is the current step and the others are the steps including it.
+func (d *dapServer) stackTrace(threadID int) ([]*dapStackFrame, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	th := d.thread(threadID)
+	if th == nil || th.step == nil {
+		return nil, fmt.Errorf("thread is not stopped: %d", threadID)
+	}
+	frames := []*dapStackFrame{}
+	after := th.after
+	for s := th.step; s != nil; s = s.parent.parent {
+		f := &dapStackFrame{
+			ID:     d.newHandle(&dapFrame{step: s, after: after}),
+			Name:   s.parent.stepName(s.idx),
+			Column: d.clientLine(1),
+		}
+		if s.desc != "" {
+			f.Name = fmt.Sprintf("%s: %s", f.Name, s.desc)
+		}
+		if p, err := filepath.Abs(s.parent.bookPath); err == nil && s.parent.bookPath != "" {
+			f.Source = &dapSource{Name: filepath.Base(p), Path: p}
+			if a, err := d.runbookAreas(p); err == nil && s.idx < len(a.Steps) {
+				f.Line = d.clientLine(a.Steps[s.idx].Start.Line)
+			}
+		}
+		frames = append(frames, f)
+		after = false
+	}
+	return frames, nil
+}
+
+// variables returns the children of the map or the slice of the handle.
+func (d *dapServer) variables(ref int) ([]*dapVariable, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	h, ok := d.handles[ref]
+	if !ok {
+		return nil, fmt.Errorf("variables not found: %d", ref)
+	}
+	vars := []*dapVariable{}
+	v := reflect.ValueOf(h)
+	switch v.Kind() {
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			vars = append(vars, d.newVariable(fmt.Sprint(k.Interface()), v.MapIndex(k).Interface()))
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			vars = append(vars, d.newVariable(strconv.Itoa(i), v.Index(i).Interface()))
+		}
+	}
+	return vars, nil
+}
+
+// newVariable returns the variable of the value. Maps and slices have the handle of the children.
+func (d *dapServer) newVariable(name string, v any) *dapVariable {
+	variable := &dapVariable{Name: name, Type: fmt.Sprintf("%T", v)}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		if rv.Kind() != reflect.Array && rv.IsNil() {
+			variable.Value = "null"
+			return variable
+		}
+		if _, ok := v.([]byte); ok {
+			variable.Value = strconv.Quote(string(v.([]byte)))
+			return variable
+		}
+		variable.Value = fmt.Sprintf("%s (len=%d)", variable.Type, rv.Len())
+		if rv.Len() > 0 {
+			variable.VariablesReference = d.newHandle(v)
+		}
+		return variable
+	case reflect.Invalid:
+		variable.Type = ""
+		variable.Value = "null"
+		return variable
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		variable.Value = fmt.Sprintf("%v", v)
+		return variable
+	}
+	variable.Value = string(b)
+	return variable
+}
+
+func (d *dapServer) newHandle(v any) int {
+	d.handleSeq++
+	d.handles[d.handleSeq] = v
+	return d.handleSeq
+}
+
+// frame returns the step of the stack frame.
+func (d *dapServer) frame(frameID int) (*dapFrame, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if f, ok := d.handles[frameID].(*dapFrame); ok {
+		return f, nil
+	}
+	if frameID == 0 {
+		// Use the step of the first stopped thread if the frame is not specified
+		var stopped *dapThread
+		for _, th := range d.threads {
+			if th.step != nil && (stopped == nil || th.id < stopped.id) {
+				stopped = th
+			}
+		}
+		if stopped != nil {
+			return &dapFrame{step: stopped.step, after: stopped.after}, nil
+		}
+	}
+	return nil, fmt.Errorf("stack frame not found: %d", frameID)
+}
+
+func (d *dapServer) thread(threadID int) *dapThread {
+	for _, th := range d.threads {
+		if th.id == threadID {
+			return th
+		}
+	}
+	return nil
+}
+
+func (d *dapServer) stoppedThreads() int {
+	n := 0
+	for _, th := range d.threads {
+		if th.step != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func (d *dapServer) runbookAreas(p string) (*areas, error) {
+	if a, ok := d.areas[p]; ok {
+		return a, nil
+	}
+	b, err := fs.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	a := detectRunbookAreas(string(b))
+	d.areas[p] = a
+	return a, nil
+}
+
+func (d *dapServer) clientLine(line int) int {
+	if d.linesStartAt1 {
+		return line
+	}
+	return line - 1
+}
+
+func (d *dapServer) respond(m *dapMessage, body any, err error) {
+	res := &dapResponse{
+		Type:       "response",
+		RequestSeq: m.Seq,
+		Success:    err == nil,
+		Command:    m.Command,
+		Body:       body,
+	}
+	if err != nil {
+		res.Message = err.Error()
+	}
+	d.send(res)
+}
+
+// send sends the response or the event to the DAP client.
+func (d *dapServer) send(m any) {
+	d.wmu.Lock()
+	defer d.wmu.Unlock()
+	if d.conn == nil {
+		return
+	}
+	d.seq++
+	switch v := m.(type) {
+	case *dapResponse:
+		v.Seq = d.seq
+	case *dapEvent:
+		v.Seq = d.seq
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	_, _ = fmt.Fprintf(d.conn, "Content-Length: %d\r\n\r\n%s", len(b), b)
+}
+
+func readDAPMessage(r *bufio.Reader) (*dapMessage, error) {
+	l := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(k), "Content-Length") {
+			l, err = strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("invalid Content-Length: %w", err)
+			}
+		}
+	}
+	if l < 0 {
+		return nil, errors.New("Content-Length not found")
+	}
+	b := make([]byte, l)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	m := &dapMessage{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func unmarshalDAPArguments(m *dapMessage, v any) error {
+	if len(m.Arguments) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(m.Arguments, v); err != nil {
+		return fmt.Errorf("invalid arguments of %s: %w", m.Command, err)
+	}
+	return nil
+}
@@ -0,0 +1,349 @@
+package runn
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+type dapTestClient struct {
+	t      *testing.T
+	conn   net.Conn
+	r      *bufio.Reader
+	seq    int
+	events []map[string]any
+}
+
+func newDAPTestClient(t *testing.T, addr string) *dapTestClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	if err := conn.SetDeadline(time.Now().Add(30 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	return &dapTestClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *dapTestClient) read() map[string]any {
+	c.t.Helper()
+	var l int
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		if _, err := fmt.Sscanf(line, "Content-Length: %d", &l); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	b := make([]byte, l)
+	if _, err := io.ReadFull(c.r, b); err != nil {
+		c.t.Fatal(err)
+	}
+	m := map[string]any{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		c.t.Fatal(err)
+	}
+	return m
+}
+
+// request sends the request and returns the body of the response.
+func (c *dapTestClient) request(command string, args map[string]any) map[string]any {
+	c.t.Helper()
+	c.seq++
+	b, err := json.Marshal(map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.conn, "Content-Length: %d\r\n\r\n%s", len(b), b); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		m := c.read()
+		if m["type"] == "event" {
+			c.events = append(c.events, m)
+			continue
+		}
+		if m["success"] != true {
+			c.t.Fatalf("%s failed: %v", command, m["message"])
+		}
+		body, _ := m["body"].(map[string]any)
+		return body
+	}
+}
+
+// waitEvent returns the body of the next event of the name.
+func (c *dapTestClient) waitEvent(name string) map[string]any {
+	c.t.Helper()
+	for {
+		var m map[string]any
+		if len(c.events) > 0 {
+			m, c.events = c.events[0], c.events[1:]
+		} else {
+			m = c.read()
+		}
+		if m["type"] == "event" && m["event"] == name {
+			body, _ := m["body"].(map[string]any)
+			return body
+		}
+	}
+}
+
+func TestDAP(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	opn, err := Load("testdata/book/dap.yml", DAP("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- opn.RunN(ctx)
+	}()
+
+	c := newDAPTestClient(t, opn.dbg.dap.Addr().String())
+	c.request("initialize", map[string]any{"linesStartAt1": true})
+	c.waitEvent("initialized")
+	c.request("launch", map[string]any{})
+	// Line 10 is in the area of steps[1] (lines 8-10)
+	got := c.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": "testdata/book/dap.yml"},
+		"breakpoints": []map[string]any{{"line": 10}, {"line": 2}},
+	})
+	bps := got["breakpoints"].([]any)
+	if bp := bps[0].(map[string]any); bp["verified"] != true || bp["line"] != float64(8) {
+		t.Errorf("got %v", bp)
+	}
+	if bp := bps[1].(map[string]any); bp["verified"] != false {
+		t.Errorf("got %v", bp)
+	}
+	c.request("configurationDone", nil)
+
+	stackTrace := func() []any {
+		t.Helper()
+		stopped := c.waitEvent("stopped")
+		got := c.request("stackTrace", map[string]any{"threadId": stopped["threadId"]})
+		return got["stackFrames"].([]any)
+	}
+
+	// Stop at the breakpoint
+	frames := stackTrace()
+	top := frames[0].(map[string]any)
+	if want := `"For DAP test".steps[1]: test message`; top["name"] != want || top["line"] != float64(8) {
+		t.Errorf("got %v", top)
+	}
+	got = c.request("evaluate", map[string]any{"expression": "message + ' world'", "frameId": top["id"]})
+	if want := `"hello world"`; got["result"] != want {
+		t.Errorf("got %v, want %v", got["result"], want)
+	}
+	got = c.request("scopes", map[string]any{"frameId": top["id"]})
+	scope := got["scopes"].([]any)[0].(map[string]any)
+	got = c.request("variables", map[string]any{"variablesReference": scope["variablesReference"]})
+	var varsRef any
+	for _, v := range got["variables"].([]any) {
+		if v := v.(map[string]any); v["name"] == "vars" {
+			varsRef = v["variablesReference"]
+		}
+	}
+	got = c.request("variables", map[string]any{"variablesReference": varsRef})
+	if v := got["variables"].([]any)[0].(map[string]any); v["name"] != "greeting" || v["value"] != `"hello"` {
+		t.Errorf("got %v", v)
+	}
+
+	// Step over to the include step
+	c.request("next", map[string]any{"threadId": 1})
+	frames = stackTrace()
+	if len(frames) != 1 || frames[0].(map[string]any)["line"] != float64(11) {
+		t.Errorf("got %v", frames)
+	}
+
+	// Step in to the included runbook
+	c.request("stepIn", map[string]any{"threadId": 1})
+	frames = stackTrace()
+	if len(frames) != 2 {
+		t.Fatalf("got %v", frames)
+	}
+	if got := frames[0].(map[string]any)["source"].(map[string]any)["name"]; got != "dap_included.yml" {
+		t.Errorf("got %v", got)
+	}
+
+	// Step out to the step after the include step
+	c.request("stepOut", map[string]any{"threadId": 1})
+	frames = stackTrace()
+	if len(frames) != 1 || frames[0].(map[string]any)["line"] != float64(14) {
+		t.Errorf("got %v", frames)
+	}
+
+	c.request("continue", map[string]any{"threadId": 1})
+	c.waitEvent("terminated")
+	if err := <-errCh; err != nil {
+		t.Error(err)
+	}
+	if r := opn.Result(); r.HasFailure() {
+		t.Errorf("got %v", r.RunResults[0].Err)
+	}
+}
+
+func TestDAPConditionalBreakpointAndFailure(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	opn, err := Load("testdata/book/dap_failure.yml", DAP("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- opn.RunN(ctx)
+	}()
+
+	c := newDAPTestClient(t, opn.dbg.dap.Addr().String())
+	got := c.request("initialize", map[string]any{})
+	if filters := got["exceptionBreakpointFilters"].([]any); filters[0].(map[string]any)["filter"] != "failure" {
+		t.Errorf("got %v", filters)
+	}
+	c.request("launch", map[string]any{})
+	c.request("setBreakpoints", map[string]any{
+		"source": map[string]any{"path": "testdata/book/dap_failure.yml"},
+		"breakpoints": []map[string]any{
+			// The condition is evaluated after the step runs
+			{"line": 5, "condition": "count == 1 && current != nil"},
+			{"line": 8, "condition": "count == 100"},
+		},
+	})
+	c.request("setExceptionBreakpoints", map[string]any{"filters": []string{"failure"}})
+	c.request("configurationDone", nil)
+
+	// Stop after steps[0] by the conditional breakpoint
+	stopped := c.waitEvent("stopped")
+	if stopped["reason"] != "breakpoint" {
+		t.Errorf("got %v", stopped)
+	}
+	got = c.request("stackTrace", map[string]any{"threadId": stopped["threadId"]})
+	top := got["stackFrames"].([]any)[0].(map[string]any)
+	if top["line"] != float64(5) {
+		t.Errorf("got %v", top)
+	}
+	// Set the bind variable so that steps[1] succeeds
+	c.request("evaluate", map[string]any{"expression": "set count vars.expected", "frameId": top["id"], "context": "repl"})
+	got = c.request("evaluate", map[string]any{"expression": "count", "frameId": top["id"], "context": "watch"})
+	if got["result"] != "2" {
+		t.Errorf("got %v", got["result"])
+	}
+	c.request("continue", map[string]any{"threadId": stopped["threadId"]})
+
+	// Stop after steps[2] by the failure
+	stopped = c.waitEvent("stopped")
+	if stopped["reason"] != "exception" || !strings.Contains(stopped["text"].(string), "steps[2]") {
+		t.Errorf("got %v", stopped)
+	}
+	c.request("continue", map[string]any{"threadId": stopped["threadId"]})
+	c.waitEvent("terminated")
+	if err := <-errCh; err != nil {
+		t.Error(err)
+	}
+	r := opn.Result()
+	if !r.HasFailure() {
+		t.Fatal("want failure")
+	}
+	sr := r.RunResults[0].StepResults
+	if sr[1].Err != nil || sr[2].Err == nil {
+		t.Errorf("got %v, %v", sr[1].Err, sr[2].Err)
+	}
+}
+
+func TestDAPRestartFrameAndStepBack(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	opn, err := Load("testdata/book/dap_failure.yml", DAP("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- opn.RunN(ctx)
+	}()
+
+	c := newDAPTestClient(t, opn.dbg.dap.Addr().String())
+	c.request("initialize", map[string]any{})
+	c.request("launch", map[string]any{})
+	c.request("setExceptionBreakpoints", map[string]any{"filters": []string{"failure"}})
+	c.request("configurationDone", nil)
+
+	type stop struct {
+		reason string
+		line   float64
+		frame  any
+	}
+	waitStop := func() stop {
+		t.Helper()
+		stopped := c.waitEvent("stopped")
+		got := c.request("stackTrace", map[string]any{"threadId": stopped["threadId"]})
+		top := got["stackFrames"].([]any)[0].(map[string]any)
+		return stop{reason: stopped["reason"].(string), line: top["line"].(float64), frame: top["id"]}
+	}
+	set := func(frame any, e string) {
+		t.Helper()
+		c.request("evaluate", map[string]any{"expression": "set " + e, "frameId": frame, "context": "repl"})
+	}
+	eval := func(frame any, e string) any {
+		t.Helper()
+		return c.request("evaluate", map[string]any{"expression": e, "frameId": frame, "context": "watch"})["result"]
+	}
+
+	// steps[1] fails, then run it again after fixing the store
+	got := waitStop()
+	if got.reason != "exception" || got.line != 8 {
+		t.Fatalf("got %v", got)
+	}
+	set(got.frame, "count 2")
+	c.request("restartFrame", map[string]any{"frameId": got.frame})
+	if got = waitStop(); got.reason != "step" || got.line != 8 {
+		t.Fatalf("got %v", got)
+	}
+	c.request("continue", map[string]any{"threadId": 1})
+
+	// steps[2] fails, then rewind to steps[1]
+	if got = waitStop(); got.reason != "exception" || got.line != 10 {
+		t.Fatalf("got %v", got)
+	}
+	c.request("stepBack", map[string]any{"threadId": 1})
+	if got = waitStop(); got.reason != "step" || got.line != 10 {
+		t.Fatalf("got %v", got)
+	}
+	c.request("stepBack", map[string]any{"threadId": 1})
+	if got = waitStop(); got.reason != "step" || got.line != 8 {
+		t.Fatalf("got %v", got)
+	}
+	// The store is restored to the snapshot taken before steps[1] ran first
+	if v := eval(got.frame, "count"); v != "1" {
+		t.Errorf("got %v", v)
+	}
+	if v := eval(got.frame, "len(steps)"); v != "1" {
+		t.Errorf("got %v", v)
+	}
+	set(got.frame, "count 3")
+	set(got.frame, "vars.expected 3")
+	c.request("continue", map[string]any{"threadId": 1})
+	c.waitEvent("terminated")
+	if err := <-errCh; err != nil {
+		t.Error(err)
+	}
+	if r := opn.Result(); r.HasFailure() {
+		t.Errorf("got %v", r.RunResults[0].Err)
+	}
+}
@@ -2,8 +2,10 @@ package runn
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"sort"
 	"strconv"
 	"strings"
@@ -11,6 +13,7 @@ import (
 
 	"github.com/elk-language/go-prompt"
 	pstrings "github.com/elk-language/go-prompt/strings"
+	"github.com/goccy/go-yaml"
 	"github.com/k0kubun/pp/v3"
 	"github.com/k1LoW/runn/internal/expr"
 	"github.com/k1LoW/runn/internal/fs"
@@ -36,25 +39,63 @@ const (
 	dbgCmdInfoShort     = "i"
 	dbgCmdList          = "list"
 	dbgCmdListShort     = "l"
+	dbgCmdWatch         = "watch"
+	dbgCmdWatchShort    = "w"
+	dbgCmdSet           = "set"
+	dbgCmdRetry         = "retry"
+	dbgCmdRetryShort    = "r"
+	dbgCmdSkip          = "skip"
+	dbgCmdSkipShort     = "s"
+	dbgCmdRewind        = "rewind"
+	dbgCmdEdit          = "edit"
+	dbgCmdEditShort     = "e"
 )
 
-const bpSep = ":"
+// errStepRetried is returned by the debugger to run the step again.
+var errStepRetried = errors.New("step retried")
+
+// stepRewoundError is returned by the debugger to rewind to the step by restoring the store snapshot taken before it.
+type stepRewoundError struct {
+	idx int
+}
+
+func (e *stepRewoundError) Error() string {
+	return fmt.Sprintf("rewound to step %d", e.idx)
+}
+
+const (
+	bpSep        = ":"
+	bpCondSep    = " if "
+	bpOnFailure  = "failure"
+	bpCondPrompt = "after"
+)
 
 type breakpoint struct {
 	runbookID string
 	stepKey   string
+	cond      string // cond is evaluated after the step runs, so it can refer to `current`
+}
+
+func (bp breakpoint) match(s *step) bool {
+	if !strings.HasPrefix(s.parent.ID(), bp.runbookID) {
+		return false
+	}
+	return bp.stepKey == s.key || bp.stepKey == strconv.Itoa(s.idx)
 }
 
 // dbg is runn debugger.
 type dbg struct {
-	enable      bool
-	showPrompt  bool
-	quit        bool
-	history     []string
-	breakpoints []breakpoint
-	opn         *operatorN
-	pp          *pp.PrettyPrinter
-	mu          sync.Mutex
+	enable         bool
+	showPrompt     bool
+	quit           bool
+	history        []string
+	breakpoints    []breakpoint
+	breakOnFailure bool
+	watches        []string
+	opn            *operatorN
+	dap            *dapServer
+	pp             *pp.PrettyPrinter
+	mu             sync.Mutex
 }
 
 func newDBG(enable bool) *dbg {
@@ -92,12 +133,18 @@ func (c *completer) do(d prompt.Document) ([]prompt.Suggest, pstrings.RuneNumber
 			{Text: dbgCmdQuit, Description: "(q) quit debugger and skip all steps"},
 			{Text: dbgCmdContinue, Description: "(c) continue to run until next breakpoint"},
 			{Text: dbgCmdPrint, Description: "(p) print variable. ('print [variable]')"},
-			{Text: dbgCmdBreak, Description: "(b) set breakpoint. ('break [id]' 'break [id]:[step]' 'break :[step]')"},
+			{Text: dbgCmdBreak, Description: "(b) set breakpoint. ('break [id]' 'break [id]:[step]' 'break :[step]' 'break [id]:[step] if [condition]' 'break failure')"},
 			{Text: dbgCmdInfo, Description: "(i) show information"},
 			{Text: dbgCmdList, Description: "(l) list codes of step. ('list' 'list [id]' 'list [id]:[step]' 'list :[step]')"},
+			{Text: dbgCmdWatch, Description: "(w) watch expression printed at every stop. ('watch [expression]')"},
+			{Text: dbgCmdSet, Description: "set vars or bind variables. ('set vars.[key] [expression]' 'set [key] [expression]')"},
+			{Text: dbgCmdRetry, Description: "(r) run the current step again after the step runs"},
+			{Text: dbgCmdSkip, Description: "(s) skip the current step"},
+			{Text: dbgCmdRewind, Description: "rewind to the step restoring the store. ('rewind' 'rewind [step]')"},
+			{Text: dbgCmdEdit, Description: "(e) edit the expanded request of the current step using $EDITOR"},
 		}
-	case splitted[0] == dbgCmdPrint || splitted[0] == dbgCmdPrintShort:
-		// print
+	case splitted[0] == dbgCmdPrint || splitted[0] == dbgCmdPrintShort || splitted[0] == dbgCmdWatch || splitted[0] == dbgCmdWatchShort || splitted[0] == dbgCmdSet:
+		// print, watch, set
 		sm := c.step.parent.store.ToMap()
 		sm[store.RootKeyIncluded] = c.step.parent.included
 		if !c.step.deferred {
@@ -109,6 +156,11 @@ func (c *completer) do(d prompt.Document) ([]prompt.Suggest, pstrings.RuneNumber
 				s = append(s, prompt.Suggest{Text: k})
 			}
 		}
+	case splitted[0] == dbgCmdRewind:
+		// rewind
+		for i := 0; i <= c.step.idx && i < len(c.step.parent.steps); i++ {
+			s = append(s, prompt.Suggest{Text: c.step.parent.steps[i].key})
+		}
 	case splitted[0] == dbgCmdBreak || splitted[0] == dbgCmdBreakShort || splitted[0] == dbgCmdList || splitted[0] == dbgCmdListShort:
 		// break, list
 		for _, o := range c.dbg.opn.ops {
@@ -131,12 +183,16 @@ func (c *completer) do(d prompt.Document) ([]prompt.Suggest, pstrings.RuneNumber
 		// info
 		s = append(s, prompt.Suggest{Text: "breakpoints", Description: "(b) show breakpoints"})
 		s = append(s, prompt.Suggest{Text: "variables", Description: "(v) show variables"})
+		s = append(s, prompt.Suggest{Text: "watches", Description: "(w) show watch expressions"})
 	}
 
 	return prompt.FilterHasPrefix(s, w, true), startIndex, endIndex
 }
 
 func (d *dbg) attach(ctx context.Context, s *step) error {
+	if d.dap != nil && s != nil {
+		return d.dap.attach(ctx, s)
+	}
 	prpt := "> "
 
 	if d.quit {
@@ -148,20 +204,17 @@ func (d *dbg) attach(ctx context.Context, s *step) error {
 	}
 
 	if s != nil {
-		id := s.parent.ID()
-		stepKey := s.key
-		stepIdx := strconv.Itoa(s.idx)
 		// check breakpoints
 		for _, bp := range d.breakpoints {
-			if !strings.HasPrefix(id, bp.runbookID) {
+			if bp.cond != "" {
+				// Conditional breakpoints are checked after the step runs
 				continue
 			}
-			if bp.stepKey != stepKey && bp.stepKey != stepIdx {
-				continue
+			if bp.match(s) {
+				d.showPrompt = true
 			}
-			d.showPrompt = true
 		}
-		prpt = fmt.Sprintf("%s[%s]> ", id[:7], s.key)
+		prpt = fmt.Sprintf("%s[%s]> ", s.parent.ID()[:7], s.key)
 	}
 
 	if !d.showPrompt {
@@ -169,6 +222,55 @@ func (d *dbg) attach(ctx context.Context, s *step) error {
 	}
 	d.showPrompt = false
 
+	return d.prompt(ctx, s, prpt, false)
+}
+
+// attachAfter stops after the step runs if the step fails in break-on-failure mode or the condition of the breakpoint is met.
+// It returns errStepRetried, errStepSkipped or *stepRewoundError if the command to change the flow of the steps is input.
+func (d *dbg) attachAfter(ctx context.Context, s *step, stepErr error) error {
+	if d.dap != nil {
+		return d.dap.attachAfter(ctx, s, stepErr)
+	}
+	if !d.enable || d.quit || errors.Is(stepErr, errStepSkipped) {
+		return nil
+	}
+	mark := bpCondPrompt
+	stop := false
+	if stepErr != nil && d.breakOnFailure {
+		_, _ = fmt.Fprintf(os.Stderr, "%s\n", red(stepErr.Error()))
+		mark = bpOnFailure
+		stop = true
+	}
+	if !stop {
+		sm := storeMapForDbg(s, true)
+		for _, bp := range d.breakpoints {
+			if bp.cond == "" || !bp.match(s) {
+				continue
+			}
+			tf, err := expr.EvalCond(bp.cond, sm)
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "failed to evaluate the condition of the breakpoint: %v\n", err)
+				continue
+			}
+			if tf {
+				stop = true
+				break
+			}
+		}
+	}
+	if !stop {
+		return nil
+	}
+	d.showPrompt = false
+	if err := d.prompt(ctx, s, fmt.Sprintf("%s[%s](%s)> ", s.parent.ID()[:7], s.key, mark), true); err != nil && !d.quit && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// prompt shows the prompt until the command to resume is input. If after is true, the step has already been run.
+func (d *dbg) prompt(ctx context.Context, s *step, prpt string, after bool) error {
+	d.printWatches(s, after)
 L:
 	for {
 		in := prompt.Input(
@@ -180,6 +282,14 @@ L:
 		cmd := strings.SplitN(strings.TrimSpace(in), " ", 2)
 		prog := cmd[0]
 		switch prog {
+		case dbgCmdRetry, dbgCmdRetryShort, dbgCmdSkip, dbgCmdSkipShort, dbgCmdRewind:
+			if s.deferred {
+				// Deferred steps run after all other steps
+				_, _ = fmt.Fprintf(os.Stderr, "deferred steps cannot be retried, skipped or rewound\n")
+				continue
+			}
+		}
+		switch prog {
 		case dbgCmdNext, dbgCmdNextShort:
 			// next
 			d.showPrompt = true
@@ -192,31 +302,84 @@ L:
 			d.quit = true
 			s.parent.skipped = true
 			return errStepSkipped
+		case dbgCmdRetry, dbgCmdRetryShort:
+			// retry
+			if !after {
+				_, _ = fmt.Fprintf(os.Stderr, "the step has not been run yet\n")
+				continue
+			}
+			return errStepRetried
+		case dbgCmdSkip, dbgCmdSkipShort:
+			// skip
+			d.showPrompt = true
+			return errStepSkipped
+		case dbgCmdRewind:
+			// rewind
+			key := ""
+			if len(cmd) == 2 {
+				key = strings.TrimSpace(cmd[1])
+			}
+			idx, err := rewindableStep(s, key)
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+				continue
+			}
+			d.showPrompt = true
+			return &stepRewoundError{idx: idx}
+		case dbgCmdEdit, dbgCmdEditShort:
+			// edit
+			if err := editStepRequest(ctx, s); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+				continue
+			}
 		case dbgCmdPrint, dbgCmdPrintShort:
 			// print
 			if len(cmd) != 2 {
 				_, _ = fmt.Fprintf(os.Stderr, "args required")
 				continue
 			}
-			sm := s.parent.store.ToMapForDbg()
-			sm[store.RootKeyIncluded] = s.parent.included
-			if !s.deferred {
-				sm[store.RootKeyPrevious] = s.parent.store.Latest()
-			}
-			e, err := expr.Eval(cmd[1], sm)
+			e, err := expr.Eval(cmd[1], storeMapForDbg(s, after))
 			if err != nil {
 				_, _ = fmt.Fprintf(os.Stderr, "%s\n", err.Error())
 				continue
 			}
 			d.pp.Println(e)
+		case dbgCmdWatch, dbgCmdWatchShort:
+			// watch
+			if len(cmd) != 2 {
+				_, _ = fmt.Fprintf(os.Stderr, "args required")
+				continue
+			}
+			d.watches = append(d.watches, cmd[1])
+		case dbgCmdSet:
+			// set
+			if len(cmd) != 2 {
+				_, _ = fmt.Fprintf(os.Stderr, "args required")
+				continue
+			}
+			kv := strings.SplitN(strings.TrimSpace(cmd[1]), " ", 2)
+			if len(kv) != 2 {
+				_, _ = fmt.Fprintf(os.Stderr, "invalid args %s\n", cmd[1])
+				continue
+			}
+			if err := setStoreForDbg(s, kv[0], kv[1], after); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+				continue
+			}
 		case dbgCmdBreak, dbgCmdBreakShort:
 			// break
 			if len(cmd) != 2 {
 				_, _ = fmt.Fprintf(os.Stderr, "args required")
 				continue
 			}
-			splitted := strings.Split(cmd[1], bpSep)
-			bp := breakpoint{}
+			spec, cond, _ := strings.Cut(cmd[1], bpCondSep)
+			spec = strings.TrimSpace(spec)
+			if spec == bpOnFailure {
+				d.breakOnFailure = true
+				continue
+			}
+			splitted := strings.Split(spec, bpSep)
+			bp := breakpoint{cond: strings.TrimSpace(cond)}
 			if splitted[0] != "" {
 				bp.runbookID = splitted[0]
 			} else {
@@ -259,38 +422,40 @@ L:
 						Formatting: tw.CellFormatting{
 							AutoFormat: tw.Off,
 						},
-						ColumnAligns: []tw.Align{tw.AlignRight, tw.AlignLeft, tw.AlignRight},
+						ColumnAligns: []tw.Align{tw.AlignRight, tw.AlignLeft, tw.AlignRight, tw.AlignLeft},
 						Padding: tw.CellPadding{
 							Global: tw.Padding{Left: tw.Space, Right: tw.Space, Top: tw.Empty, Bottom: tw.Empty},
 						},
 					}),
 					tablewriter.WithRowConfig(tw.CellConfig{
-						ColumnAligns: []tw.Align{tw.AlignRight, tw.AlignLeft, tw.AlignRight},
+						ColumnAligns: []tw.Align{tw.AlignRight, tw.AlignLeft, tw.AlignRight, tw.AlignLeft},
 						Padding: tw.CellPadding{
 							Global: tw.Padding{Left: tw.Space, Right: tw.Space, Top: tw.Empty, Bottom: tw.Empty},
 						},
 					}),
 				)
-				table.Header([]string{"Num", "ID", "Step"})
+				table.Header([]string{"Num", "ID", "Step", "Condition"})
 				for i, bp := range d.breakpoints {
-					if err := table.Append([]string{strconv.Itoa(i + 1), bp.runbookID, bp.stepKey}); err != nil {
+					if err := table.Append([]string{strconv.Itoa(i + 1), bp.runbookID, bp.stepKey, bp.cond}); err != nil {
 						return err
 					}
 				}
 				if err := table.Render(); err != nil {
 					return err
 				}
-			case "variables", "v":
-				sm := s.parent.store.ToMapForDbg()
-				sm[store.RootKeyIncluded] = s.parent.included
-				if !s.deferred {
-					sm[store.RootKeyPrevious] = s.parent.store.Latest()
+				if d.breakOnFailure {
+					fmt.Println("Break on failure")
 				}
-				keys := lo.Keys(sm)
+			case "variables", "v":
+				keys := lo.Keys(storeMapForDbg(s, after))
 				sort.Strings(keys)
 				for _, k := range keys {
 					fmt.Println(k)
 				}
+			case "watches", "w":
+				for i, w := range d.watches {
+					fmt.Printf("%d: %s\n", i+1, w)
+				}
 			default:
 				_, _ = fmt.Fprintf(os.Stderr, "unknown args %s\n", cmd[1])
 				continue
@@ -389,6 +554,75 @@ func (d *dbg) setOperatorN(opn *operatorN) {
 	d.opn = opn
 }
 
+// finish is called when the root runbook finishes.
+func (d *dbg) finish(op *operator) {
+	if d.dap != nil {
+		d.dap.finish(op)
+	}
+}
+
+// close is called when all runbooks finish.
+func (d *dbg) close() {
+	if d.dap != nil {
+		d.dap.close()
+	}
+}
+
+// enabled returns whether the debugger is enabled.
+func (d *dbg) enabled() bool {
+	return d.enable || d.dap != nil
+}
+
+// printWatches prints the values of the watch expressions.
+func (d *dbg) printWatches(s *step, after bool) {
+	if s == nil || len(d.watches) == 0 {
+		return
+	}
+	sm := storeMapForDbg(s, after)
+	for i, w := range d.watches {
+		fmt.Printf("%d: %s = ", i+1, w)
+		v, err := expr.Eval(w, sm)
+		if err != nil {
+			fmt.Println(err.Error())
+			continue
+		}
+		d.pp.Println(v)
+	}
+}
+
+// storeMapForDbg returns the store of the step for dbg. If after is true, `current` is the result of the step.
+func storeMapForDbg(s *step, after bool) map[string]any {
+	sm := s.parent.store.ToMapForDbg()
+	sm[store.RootKeyIncluded] = s.parent.included
+	switch {
+	case after:
+		sm[store.RootKeyCurrent] = s.parent.store.Latest()
+		if !s.deferred {
+			sm[store.RootKeyPrevious] = s.parent.store.Previous()
+		}
+	case !s.deferred:
+		sm[store.RootKeyPrevious] = s.parent.store.Latest()
+	}
+	return sm
+}
+
+// setStoreForDbg sets the evaluated value of the expression to `vars.<key>` or the bind variable of the store.
+func setStoreForDbg(s *step, key, e string, after bool) error {
+	sm := storeMapForDbg(s, after)
+	if k, ok := strings.CutPrefix(key, store.RootKeyVars+"."); ok {
+		if k == "" || strings.ContainsAny(k, ".[") {
+			return fmt.Errorf("only top-level vars can be set: %s", key)
+		}
+		v, err := expr.Eval(e, sm)
+		if err != nil {
+			return err
+		}
+		s.parent.store.SetVar(k, v)
+		return nil
+	}
+	return s.parent.store.RecordBindVar(key, e, sm)
+}
+
 // storeKeys lists all keys in the store.
 func storeKeys(store map[string]any) []string {
 	const storeKeySep = "."
@@ -406,3 +640,98 @@ func storeKeys(store map[string]any) []string {
 	sort.Strings(keys)
 	return keys
 }
+
+// rewindableStep returns the index of the step to rewind to. The key is the key or the index of the step ( default: the current step ).
+func rewindableStep(s *step, key string) (int, error) {
+	if s.deferred {
+		return 0, errors.New("deferred steps cannot be rewound")
+	}
+	if key == "" {
+		return s.idx, nil
+	}
+	for _, ss := range s.parent.steps[:s.idx+1] {
+		if ss.key != key && strconv.Itoa(ss.idx) != key {
+			continue
+		}
+		if ss.deferred {
+			return 0, fmt.Errorf("deferred steps cannot be rewound: %s", key)
+		}
+		return ss.idx, nil
+	}
+	return 0, fmt.Errorf("step not found before the current step: %s", key)
+}
+
+// editStepRequest edits the expanded request of the step using $EDITOR. The edited request is used for the subsequent runs of the step.
+func editStepRequest(ctx context.Context, s *step) error {
+	req := stepRequest(s)
+	if req == nil {
+		return errors.New("the step has no request to edit")
+	}
+	expanded, err := s.parent.expandBeforeRecord(*req, s)
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(expanded)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp("", "runn-step-*.yml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+	cmd := exec.CommandContext(ctx, editor[0], append(editor[1:], f.Name())...) //nolint:gosec
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to edit the request: %w", err)
+	}
+	b, err = os.ReadFile(f.Name())
+	if err != nil {
+		return err
+	}
+	var edited map[string]any
+	if err := yaml.Unmarshal(b, &edited); err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	v, ok := normalize(edited).(map[string]any)
+	if !ok {
+		return fmt.Errorf("invalid request: %v", edited)
+	}
+	*req = v
+	return nil
+}
+
+// stepRequest returns the pointer to the request of the runner of the step.
+func stepRequest(s *step) *map[string]any {
+	switch {
+	case s.httpRunner != nil && s.httpRequest != nil:
+		return &s.httpRequest
+	case s.dbRunner != nil && s.dbQuery != nil:
+		return &s.dbQuery
+	case s.grpcRunner != nil && s.grpcRequest != nil:
+		return &s.grpcRequest
+	case s.cdpRunner != nil && s.cdpActions != nil:
+		return &s.cdpActions
+	case s.sshRunner != nil && s.sshCommand != nil:
+		return &s.sshCommand
+	case s.execRunner != nil && s.execCommand != nil:
+		return &s.execCommand
+	case s.runnerValues != nil:
+		// Runner not yet detected
+		return &s.runnerValues
+	}
+	return nil
+}
@@ -64,6 +64,13 @@ func (d *debugger) CaptureGRPCResponseStatus(s *status.Status) {
 	if c != codes.OK {
 		m = fmt.Sprintf("%s (%d): %s", c.String(), int(c), s.Message())
 	}
+	if details := grpcStatusDetails(s); len(details) > 0 {
+		m += "\ndetails:"
+		for _, dt := range details {
+			b, _ := json.Marshal(dt)
+			m += fmt.Sprintf("\n  - %s", string(b))
+		}
+	}
 	_, _ = fmt.Fprintf(d.out, "-----START gRPC RESPONSE STATUS-----\n%s\n-----END gRPC RESPONSE STATUS-----\n", m)
 }
 
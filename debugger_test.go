@@ -13,6 +13,9 @@ import (
 	"github.com/k1LoW/runn/internal/scope"
 	"github.com/k1LoW/runn/testutil"
 	"github.com/tenntenn/golden"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var testDebuggerHostRe = regexp.MustCompile(`(?s)Host:[^\r\n]+\r\n`)
@@ -157,3 +160,25 @@ func TestDebuggerWithSecrets(t *testing.T) {
 		})
 	}
 }
+
+func TestDebuggerCaptureGRPCResponseStatus(t *testing.T) {
+	stat, err := status.New(codes.InvalidArgument, "invalid request").WithDetails(&errdetails.ErrorInfo{Reason: "INVALID_NAME", Domain: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		stat *status.Status
+		want string
+	}{
+		{status.New(codes.OK, ""), "-----START gRPC RESPONSE STATUS-----\nOK (0)\n-----END gRPC RESPONSE STATUS-----\n"},
+		{stat, "-----START gRPC RESPONSE STATUS-----\nInvalidArgument (3): invalid request\ndetails:\n  - {\"@type\":\"type.googleapis.com/google.rpc.ErrorInfo\",\"domain\":\"example.com\",\"metadata\":{},\"reason\":\"INVALID_NAME\"}\n-----END gRPC RESPONSE STATUS-----\n"},
+	}
+	for _, tt := range tests {
+		out := new(bytes.Buffer)
+		d := NewDebugger(out)
+		d.CaptureGRPCResponseStatus(tt.stat)
+		if got := out.String(); got != tt.want {
+			t.Errorf("got %q\nwant %q", got, tt.want)
+		}
+	}
+}
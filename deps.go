@@ -0,0 +1,153 @@
+package runn
+
+import (
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/goccy/go-yaml"
+	"github.com/k1LoW/runn/internal/fs"
+)
+
+// runnerFilesConfig is the part of the runner config that refers to the files.
+type runnerFilesConfig struct {
+	OpenAPI3DocLocation string   `yaml:"openapi3,omitempty"`
+	ImportPaths         []string `yaml:"importPaths,omitempty"`
+	Protos              []string `yaml:"protos,omitempty"`
+}
+
+// dependentFiles returns the local files that the runbook depends on except the runbooks of `include:` and `needs:` .
+// ( the runbook itself, the files of `vars:` , OpenAPI documents and proto files )
+// The paths of the proto files are the candidates, so they may not exist.
+// bk is the book of the options ( e.g. --http-openapi3, --grpc-proto ) .
+func (op *operator) dependentFiles(bk *book) ([]string, error) {
+	files := []string{op.bookPath}
+	b, err := os.ReadFile(op.bookPath)
+	if err != nil {
+		return nil, err
+	}
+	rb, err := parseRunbook(b)
+	if err != nil {
+		return nil, err
+	}
+	// vars:
+	for _, v := range rb.Vars {
+		files = append(files, evaluatedFiles(v, op.root)...)
+	}
+	for _, s := range op.steps {
+		if s.includeConfig == nil {
+			continue
+		}
+		for _, v := range s.includeConfig.vars {
+			files = append(files, evaluatedFiles(v, op.root)...)
+		}
+	}
+	// runners:
+	for _, v := range rb.Runners {
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		c := &runnerFilesConfig{}
+		if err := yaml.Unmarshal(b, c); err != nil {
+			// DSN
+			continue
+		}
+		if c.OpenAPI3DocLocation != "" {
+			files = append(files, localFiles([]string{c.OpenAPI3DocLocation}, op.root)...)
+		}
+		importPaths := localFiles(c.ImportPaths, op.root)
+		files = append(files, protoFiles(c.Protos, importPaths, op.root)...)
+		files = append(files, importedProtoFiles(importPaths)...)
+	}
+	if len(op.httpRunners) > 0 {
+		for _, l := range bk.openAPI3DocLocations {
+			_, p := fs.SplitKeyAndPath(l)
+			files = append(files, localFiles([]string{p}, "")...)
+		}
+	}
+	if len(op.grpcRunners) > 0 {
+		importPaths := localFiles(bk.grpcImportPaths, "")
+		files = append(files, protoFiles(bk.grpcProtos, importPaths, "")...)
+		files = append(files, importedProtoFiles(importPaths)...)
+	}
+	return files, nil
+}
+
+// evaluatedFiles returns the files loaded by the value of `vars:` ( e.g. `json://path/to/vars.json` ) .
+func evaluatedFiles(v any, root string) []string {
+	s, ok := v.(string)
+	if !ok {
+		return nil
+	}
+	for _, e := range evaluators {
+		if !strings.HasPrefix(s, e.scheme) {
+			continue
+		}
+		p := s[len(e.scheme):]
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(root, p)
+		}
+		if !strings.Contains(p, multiple) {
+			return []string{p}
+		}
+		base, pattern := doublestar.SplitPattern(p)
+		matches, err := doublestar.Glob(os.DirFS(base), pattern)
+		if err != nil {
+			return nil
+		}
+		var files []string
+		for _, m := range matches {
+			files = append(files, filepath.Join(base, m))
+		}
+		return files
+	}
+	return nil
+}
+
+// localFiles returns the local paths of the locations. Remote locations are ignored.
+func localFiles(locations []string, root string) []string {
+	var files []string
+	for _, l := range locations {
+		if strings.Contains(l, "://") {
+			continue
+		}
+		if root != "" && !filepath.IsAbs(l) {
+			l = filepath.Join(root, l)
+		}
+		files = append(files, l)
+	}
+	return files
+}
+
+// protoFiles returns the candidates of the paths of the proto files.
+// A proto file is resolved relative to the root or the import paths.
+func protoFiles(protos, importPaths []string, root string) []string {
+	var files []string
+	for _, p := range protos {
+		files = append(files, localFiles([]string{p}, root)...)
+		for _, ip := range importPaths {
+			files = append(files, filepath.Join(ip, p))
+		}
+	}
+	return files
+}
+
+// importedProtoFiles returns the proto files under the import paths, which may be imported by the proto files.
+func importedProtoFiles(importPaths []string) []string {
+	var files []string
+	for _, ip := range importPaths {
+		_ = filepath.WalkDir(ip, func(p string, d iofs.DirEntry, err error) error {
+			if err != nil {
+				return nil //nolint:nilerr
+			}
+			if !d.IsDir() && filepath.Ext(p) == ".proto" {
+				files = append(files, p)
+			}
+			return nil
+		})
+	}
+	return files
+}
@@ -6,12 +6,21 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
 	"os"
+	osexec "os/exec"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/cli/safeexec"
 	"github.com/k1LoW/donegroup"
 	"github.com/k1LoW/exec"
+	"github.com/k1LoW/runn/internal/fs"
 	"github.com/k1LoW/runn/internal/scope"
 	"github.com/mattn/go-shellwords"
 )
@@ -22,6 +31,22 @@ const (
 	execStoreStdoutKey   = "stdout"
 	execStoreStderrKey   = "stderr"
 	execStoreExitCodeKey = "exit_code"
+	execStorePIDKey      = "pid"
+	execStoreTimedOutKey = "timed_out"
+	execStoreStatusKey   = "status"
+)
+
+// Status of the background command at the time of recording.
+const (
+	execStatusRunning = "running"
+	execStatusExited  = "exited"
+)
+
+const (
+	execDefaultReadyTimeout  = 30 * time.Second
+	execDefaultReadyInterval = 100 * time.Millisecond
+	// execTerminateGracePeriod is the time to wait for the background process group to exit after sending SIGTERM.
+	execTerminateGracePeriod = 5 * time.Second
 )
 
 const execDefaultShell = "bash -e -c {0}"
@@ -31,12 +56,43 @@ const execBash = "bash --noprofile --norc -eo pipefail -c {0}"
 type execRunner struct{}
 
 type execCommand struct {
-	command    string
-	shell      string
-	stdin      string
-	background bool
-	liveOutput bool
-	env        map[string]string
+	command           string
+	shell             string
+	stdin             string
+	background        bool
+	liveOutput        bool
+	env               map[string]string
+	timeout           time.Duration
+	dir               string
+	expectedExitCodes []int
+	ready             *execReadiness
+}
+
+// execReadiness is the readiness probe of the background command.
+type execReadiness struct {
+	stdout   *regexp.Regexp
+	tcp      string
+	http     string
+	timeout  time.Duration
+	interval time.Duration
+}
+
+// execBuffer is a buffer that can be read while the command is writing.
+type execBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (b *execBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *execBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
 }
 
 func newExecRunner() *execRunner {
@@ -68,8 +124,8 @@ func (rnr *execRunner) Run(ctx context.Context, s *step) error {
 
 func (rnr *execRunner) run(ctx context.Context, c *execCommand, s *step) error {
 	o := s.parent
-	stdout := new(bytes.Buffer)
-	stderr := new(bytes.Buffer)
+	stdout := new(execBuffer)
+	stderr := new(execBuffer)
 	switch c.shell {
 	case "":
 		c.shell = execDefaultShell
@@ -103,7 +159,27 @@ func (rnr *execRunner) run(ctx context.Context, c *execCommand, s *step) error {
 		sh = fallback
 	}
 
-	cmd := exec.CommandContext(ctx, sh, shWithOpts[1:]...)
+	cctx := ctx
+	if c.timeout > 0 && !c.background {
+		var cancel context.CancelFunc
+		cctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	var cmd *osexec.Cmd
+	if c.background {
+		// The process group of the background command is terminated when the run finishes (the context is canceled or the cleanup of donegroup).
+		cmd = (&exec.Exec{Signal: syscall.SIGTERM}).CommandContext(ctx, sh, shWithOpts[1:]...)
+		cmd.WaitDelay = execTerminateGracePeriod
+	} else {
+		cmd = exec.CommandContext(cctx, sh, shWithOpts[1:]...)
+	}
+	if c.dir != "" {
+		dir, err := fs.Path(c.dir, o.root)
+		if err != nil {
+			return newErrUnrecoverable(err)
+		}
+		cmd.Dir = dir
+	}
 	if len(c.env) > 0 {
 		currentEnv := os.Environ()
 		cmd.Env = make([]string, 0, len(currentEnv)+len(c.env))
@@ -137,23 +213,172 @@ func (rnr *execRunner) run(ctx context.Context, c *execCommand, s *step) error {
 			})
 			return nil
 		}
+		exited := make(chan struct{})
+		recorded := make(chan struct{})
 		donegroup.Go(ctx, func() error {
 			_ = cmd.Wait() // WHY: Because it is only necessary to wait. For example, SIGNAL KILL is also normal.
+			close(exited)
+			select {
+			case <-recorded:
+			case <-ctx.Done():
+				return nil
+			}
+			if ctx.Err() == nil {
+				// The process exited while the run is in progress. The exit is reported because it can no longer be recorded in the step.
+				o.Warnf("background command (pid: %d) exited with code %d: %s\n", cmd.Process.Pid, cmd.ProcessState.ExitCode(), stderr.String())
+			}
 			return nil
 		})
-		o.record(s.idx, map[string]any{})
+		if err := donegroup.Cleanup(ctx, func() error {
+			return terminateExecCommand(cmd, exited)
+		}); err != nil {
+			return newErrUnrecoverable(err)
+		}
+		if c.timeout > 0 {
+			t := time.AfterFunc(c.timeout, func() {
+				_ = terminateExecCommand(cmd, exited)
+			})
+			donegroup.Go(ctx, func() error {
+				<-exited
+				t.Stop()
+				return nil
+			})
+		}
+		if c.ready != nil {
+			if err := c.ready.wait(ctx, stdout, exited); err != nil {
+				o.capturers.captureExecStdout(stdout.String())
+				o.capturers.captureExecStderr(stderr.String())
+				select {
+				case <-exited:
+					o.record(s.idx, exitedBackgroundResult(cmd, stdout, stderr))
+				default:
+				}
+				return fmt.Errorf("background command is not ready: %w: %s", err, stderr.String())
+			}
+		}
+		select {
+		case <-exited:
+			// The process has already exited, so the result is recorded as same as the foreground command.
+			o.capturers.captureExecStdout(stdout.String())
+			o.capturers.captureExecStderr(stderr.String())
+			o.record(s.idx, exitedBackgroundResult(cmd, stdout, stderr))
+		default:
+			o.record(s.idx, map[string]any{
+				string(execStorePIDKey):    cmd.Process.Pid,
+				string(execStoreStatusKey): execStatusRunning,
+			})
+			close(recorded)
+		}
 		return nil
 	}
 
 	_ = cmd.Run()
+	// Only the deadline of the step's own timeout is treated as a timeout, not the one inherited from the runbook.
+	timedOut := c.timeout > 0 && errors.Is(cctx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
 
 	o.capturers.captureExecStdout(stdout.String())
 	o.capturers.captureExecStderr(stderr.String())
 
-	o.record(s.idx, map[string]any{
+	exitCode := cmd.ProcessState.ExitCode()
+	r := map[string]any{
 		string(execStoreStdoutKey):   stdout.String(),
 		string(execStoreStderrKey):   stderr.String(),
-		string(execStoreExitCodeKey): cmd.ProcessState.ExitCode(),
-	})
+		string(execStoreExitCodeKey): exitCode,
+	}
+	if c.timeout > 0 {
+		r[string(execStoreTimedOutKey)] = timedOut
+	}
+	o.record(s.idx, r)
+	if timedOut {
+		return fmt.Errorf("command timed out after %s", c.timeout)
+	}
+	if len(c.expectedExitCodes) > 0 && !slices.Contains(c.expectedExitCodes, exitCode) {
+		return fmt.Errorf("unexpected exit code: got %d, expected %v", exitCode, c.expectedExitCodes)
+	}
 	return nil
 }
+
+// exitedBackgroundResult returns the result of the background command that has already exited.
+func exitedBackgroundResult(cmd *osexec.Cmd, stdout, stderr *execBuffer) map[string]any {
+	return map[string]any{
+		string(execStorePIDKey):      cmd.Process.Pid,
+		string(execStoreStatusKey):   execStatusExited,
+		string(execStoreExitCodeKey): cmd.ProcessState.ExitCode(),
+		string(execStoreStdoutKey):   stdout.String(),
+		string(execStoreStderrKey):   stderr.String(),
+	}
+}
+
+// terminateExecCommand sends SIGTERM to the process group of the command, and sends SIGKILL if it does not exit within the grace period.
+func terminateExecCommand(cmd *osexec.Cmd, exited <-chan struct{}) error {
+	select {
+	case <-exited:
+		return nil
+	default:
+	}
+	if err := exec.TerminateCommand(cmd, syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(execTerminateGracePeriod):
+		if err := exec.KillCommand(cmd); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return err
+		}
+		return nil
+	}
+}
+
+// wait waits until the background command is ready.
+func (r *execReadiness) wait(ctx context.Context, stdout *execBuffer, exited <-chan struct{}) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if r.ready(ctx, stdout) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out after %s", r.timeout)
+		case <-exited:
+			// The last check after the process exits
+			if r.ready(ctx, stdout) {
+				return nil
+			}
+			return errors.New("process exited")
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *execReadiness) ready(ctx context.Context, stdout *execBuffer) bool {
+	if r.stdout != nil && !r.stdout.MatchString(stdout.String()) {
+		return false
+	}
+	if r.tcp != "" {
+		d := net.Dialer{Timeout: r.interval}
+		conn, err := d.DialContext(ctx, "tcp", r.tcp)
+		if err != nil {
+			return false
+		}
+		_ = conn.Close()
+	}
+	if r.http != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.http, nil)
+		if err != nil {
+			return false
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return false
+		}
+		_ = res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return false
+		}
+	}
+	return true
+}
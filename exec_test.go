@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"net"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cli/safeexec"
 	"github.com/google/go-cmp/cmp"
@@ -39,7 +42,9 @@ func TestExecRun(t *testing.T) {
 			"stderr":    "",
 			"exit_code": 0,
 		}},
-		{"sleep 1000", "", "", true, map[string]any{}},
+		{"sleep 1000", "", "", true, map[string]any{
+			"status": "running",
+		}},
 		{"exit 1 | exit 0", "", "", false, map[string]any{
 			"stdout":    "",
 			"stderr":    "",
@@ -82,6 +87,200 @@ func TestExecRun(t *testing.T) {
 				t.Fatal("steps not found")
 			}
 			got := sl[0]
+			if tt.background {
+				if pid, ok := got["pid"].(int); !ok || pid <= 0 {
+					t.Errorf("invalid pid: %v", got["pid"])
+				}
+				delete(got, "pid")
+			}
+			if diff := cmp.Diff(got, tt.want, nil); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestExecRunWithOptions(t *testing.T) {
+	if err := scope.Set(scope.AllowRunExec); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := scope.Set(scope.DenyRunExec); err != nil {
+			t.Fatal(err)
+		}
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+	tests := []struct {
+		name string
+		c    *execCommand
+		// deadline - The deadline of the runbook context.
+		deadline time.Duration
+		want     map[string]any
+		wantErr  bool
+	}{
+		{
+			"timeout",
+			&execCommand{command: "sleep 10", timeout: 100 * time.Millisecond},
+			0,
+			map[string]any{
+				"stdout":    "",
+				"stderr":    "",
+				"exit_code": -1,
+				"timed_out": true,
+			},
+			true,
+		},
+		{
+			"within timeout",
+			&execCommand{command: "echo hello", timeout: 10 * time.Second},
+			0,
+			map[string]any{
+				"stdout":    "hello\n",
+				"stderr":    "",
+				"exit_code": 0,
+				"timed_out": false,
+			},
+			false,
+		},
+		{
+			"dir",
+			&execCommand{command: "basename $(pwd)", dir: "testdata"},
+			0,
+			map[string]any{
+				"stdout":    "testdata\n",
+				"stderr":    "",
+				"exit_code": 0,
+			},
+			false,
+		},
+		{
+			"expected exit code",
+			&execCommand{command: "exit 2", expectedExitCodes: []int{0, 2}},
+			0,
+			map[string]any{
+				"stdout":    "",
+				"stderr":    "",
+				"exit_code": 2,
+			},
+			false,
+		},
+		{
+			"unexpected exit code",
+			&execCommand{command: "exit 1", expectedExitCodes: []int{0}},
+			0,
+			map[string]any{
+				"stdout":    "",
+				"stderr":    "",
+				"exit_code": 1,
+			},
+			true,
+		},
+		{
+			"ready stdout",
+			&execCommand{command: "sleep 0.2; echo ready; sleep 1000", background: true, ready: &execReadiness{
+				stdout:   regexp.MustCompile(`^ready`),
+				timeout:  5 * time.Second,
+				interval: 10 * time.Millisecond,
+			}},
+			0,
+			map[string]any{
+				"status": "running",
+			},
+			false,
+		},
+		{
+			"ready tcp",
+			&execCommand{command: "sleep 1000", background: true, ready: &execReadiness{
+				tcp:      ln.Addr().String(),
+				timeout:  5 * time.Second,
+				interval: 10 * time.Millisecond,
+			}},
+			0,
+			map[string]any{
+				"status": "running",
+			},
+			false,
+		},
+		{
+			"ready timeout",
+			&execCommand{command: "sleep 1000", background: true, ready: &execReadiness{
+				stdout:   regexp.MustCompile(`^ready`),
+				timeout:  100 * time.Millisecond,
+				interval: 10 * time.Millisecond,
+			}},
+			0,
+			nil,
+			true,
+		},
+		{
+			"exited before ready",
+			&execCommand{command: "echo failed >&2; exit 1", background: true, ready: &execReadiness{
+				stdout:   regexp.MustCompile(`^ready`),
+				timeout:  5 * time.Second,
+				interval: 10 * time.Millisecond,
+			}},
+			0,
+			map[string]any{
+				"stdout":    "",
+				"stderr":    "failed\n",
+				"exit_code": 1,
+				"status":    "exited",
+			},
+			true,
+		},
+		{
+			"runbook deadline is not a timeout of the step",
+			&execCommand{command: "sleep 10", timeout: 10 * time.Second},
+			100 * time.Millisecond,
+			map[string]any{
+				"stdout":    "",
+				"stderr":    "",
+				"exit_code": -1,
+				"timed_out": false,
+			},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := donegroup.WithCancel(context.Background())
+			t.Cleanup(func() {
+				cancel()
+				if err := donegroup.Wait(ctx); err != nil {
+					t.Error(err)
+				}
+			})
+			o, err := New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := newExecRunner()
+			s := newStep(0, "stepKey", o, nil)
+			rctx := ctx
+			if tt.deadline > 0 {
+				var rcancel context.CancelFunc
+				rctx, rcancel = context.WithTimeout(ctx, tt.deadline)
+				t.Cleanup(rcancel)
+			}
+			if err := r.run(rctx, tt.c, s); (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				return
+			}
+			sm := o.store.ToMap()
+			sl, ok := sm["steps"].([]map[string]any)
+			if !ok {
+				t.Fatal("steps not found")
+			}
+			got := sl[0]
+			delete(got, "pid")
 			if diff := cmp.Diff(got, tt.want, nil); diff != "" {
 				t.Error(diff)
 			}
@@ -0,0 +1,404 @@
+package runn
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/k1LoW/runn/internal/fs"
+)
+
+const (
+	// GraphEdgeNeeds is the kind of the edge from the runbook to the runbook of `needs:`.
+	GraphEdgeNeeds = "needs"
+	// GraphEdgeInclude is the kind of the edge from the runbook to the runbook included by `include:`.
+	GraphEdgeInclude = "include"
+)
+
+// Graph is the dependency graph of runbooks by `needs:` and `include:`.
+type Graph struct {
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []*GraphEdge `json:"edges"`
+	// Cycles - The runbook paths of each cycle of the graph.
+	Cycles [][]string `json:"cycles"`
+}
+
+// GraphNode is the runbook of the graph.
+type GraphNode struct {
+	ID          string   `json:"id,omitempty"`
+	Desc        string   `json:"desc,omitempty"`
+	Path        string   `json:"path"`
+	Concurrency []string `json:"concurrency,omitempty"`
+	// Missing - The runbook file is not found ( e.g. the path of `include:` is an expression ).
+	Missing bool `json:"missing,omitempty"`
+}
+
+// GraphEdge is the dependency between runbooks.
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+	// Key - The key of `needs:` or the step key ( index ) of `include:`.
+	Key   string `json:"key"`
+	Cycle bool   `json:"cycle,omitempty"`
+}
+
+type graphBuilder struct {
+	opn   *operatorN
+	graph *Graph
+	nodes map[string]*GraphNode
+	ops   map[string]*operator // Operators of the nodes. key is the runbook path.
+}
+
+// Graph returns the dependency graph of the loaded runbooks by `needs:` and `include:`.
+func (opn *operatorN) Graph() (*Graph, error) {
+	gb, err := opn.buildGraph()
+	if err != nil {
+		return nil, err
+	}
+	return gb.graph, nil
+}
+
+func (opn *operatorN) buildGraph() (*graphBuilder, error) {
+	gb := &graphBuilder{
+		opn:   opn,
+		graph: &Graph{Nodes: []*GraphNode{}, Edges: []*GraphEdge{}, Cycles: [][]string{}},
+		nodes: map[string]*GraphNode{},
+		ops:   map[string]*operator{},
+	}
+	for _, op := range opn.ops {
+		if err := gb.walk(op); err != nil {
+			return nil, err
+		}
+	}
+	gb.detectCycles()
+	return gb, nil
+}
+
+func (gb *graphBuilder) walk(op *operator) error {
+	if _, ok := gb.nodes[op.bookPath]; ok {
+		return nil
+	}
+	n := &GraphNode{
+		ID:          op.id,
+		Desc:        op.desc,
+		Path:        op.bookPath,
+		Concurrency: op.concurrency,
+	}
+	gb.nodes[op.bookPath] = n
+	gb.ops[op.bookPath] = op
+	gb.graph.Nodes = append(gb.graph.Nodes, n)
+
+	// needs:
+	keys := make([]string, 0, len(op.needs))
+	for k := range op.needs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		nd := op.needs[k]
+		gb.graph.Edges = append(gb.graph.Edges, &GraphEdge{From: op.bookPath, To: nd.path, Kind: GraphEdgeNeeds, Key: k})
+		oo := nd.op
+		if oo == nil {
+			var err error
+			oo, err = gb.load(nd.path)
+			if err != nil {
+				return err
+			}
+		}
+		if oo == nil {
+			gb.addMissing(nd.path)
+			continue
+		}
+		if err := gb.walk(oo); err != nil {
+			return err
+		}
+	}
+
+	// include:
+	for _, s := range op.steps {
+		if s.includeRunner == nil || s.includeConfig == nil {
+			continue
+		}
+		p, err := fs.Path(s.includeConfig.path, op.root)
+		if err != nil {
+			return err
+		}
+		key := s.key
+		if key == "" {
+			key = fmt.Sprintf("%d", s.idx)
+		}
+		gb.graph.Edges = append(gb.graph.Edges, &GraphEdge{From: op.bookPath, To: p, Kind: GraphEdgeInclude, Key: key})
+		oo, err := gb.load(p)
+		if err != nil {
+			return err
+		}
+		if oo == nil {
+			gb.addMissing(p)
+			continue
+		}
+		if err := gb.walk(oo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load returns the operator of the runbook that is not selected to run ( e.g. included runbooks ).
+// It returns nil if the runbook is not found.
+func (gb *graphBuilder) load(p string) (*operator, error) {
+	if oo, ok := gb.opn.om[p]; ok {
+		return oo, nil
+	}
+	if _, ok := gb.nodes[p]; ok {
+		return nil, nil
+	}
+	if _, err := os.Stat(p); err != nil {
+		return nil, nil //nolint:nilerr
+	}
+	return New(append([]Option{Book(p)}, gb.opn.opts...)...)
+}
+
+func (gb *graphBuilder) addMissing(p string) {
+	if _, ok := gb.nodes[p]; ok {
+		return
+	}
+	n := &GraphNode{Path: p, Missing: true}
+	gb.nodes[p] = n
+	gb.graph.Nodes = append(gb.graph.Nodes, n)
+}
+
+// detectCycles finds the strongly connected components that make cycles ( Tarjan's algorithm ).
+func (gb *graphBuilder) detectCycles() {
+	adj := map[string][]string{}
+	for _, e := range gb.graph.Edges {
+		adj[e.From] = append(adj[e.From], e.To)
+	}
+	var (
+		index   int
+		stack   []string
+		onStack = map[string]bool{}
+		indexes = map[string]int{}
+		lowlink = map[string]int{}
+		comp    = map[string]int{}
+	)
+	var strongConnect func(v string)
+	strongConnect = func(v string) {
+		indexes[v] = index
+		lowlink[v] = index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range adj[v] {
+			if _, ok := indexes[w]; !ok {
+				strongConnect(w)
+				lowlink[v] = min(lowlink[v], lowlink[w])
+			} else if onStack[w] {
+				lowlink[v] = min(lowlink[v], indexes[w])
+			}
+		}
+		if lowlink[v] != indexes[v] {
+			return
+		}
+		var scc []string
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			scc = append(scc, w)
+			if w == v {
+				break
+			}
+		}
+		if len(scc) == 1 && !slices.Contains(adj[v], v) {
+			return
+		}
+		for _, w := range scc {
+			comp[w] = len(gb.graph.Cycles)
+		}
+		sort.Strings(scc)
+		gb.graph.Cycles = append(gb.graph.Cycles, scc)
+	}
+	for _, n := range gb.graph.Nodes {
+		if _, ok := indexes[n.Path]; !ok {
+			strongConnect(n.Path)
+		}
+	}
+	for _, e := range gb.graph.Edges {
+		cf, okf := comp[e.From]
+		ct, okt := comp[e.To]
+		e.Cycle = okf && okt && cf == ct
+	}
+}
+
+// OutJSON writes the graph as JSON.
+func (g *Graph) OutJSON(w io.Writer) error {
+	b, err := json.MarshalIndentWithOption(g, "", "  ", json.DisableHTMLEscape())
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// OutDOT writes the graph in the DOT language of Graphviz.
+// The runbooks are grouped by the first key of `concurrency:`, and the edges of cycles are colored red.
+func (g *Graph) OutDOT(w io.Writer) error {
+	ids := g.nodeIDs()
+	var sb strings.Builder
+	sb.WriteString("digraph runn {\n")
+	sb.WriteString("  node [shape=box];\n")
+	groups, ungrouped := g.concurrencyGroups()
+	for i, grp := range groups {
+		fmt.Fprintf(&sb, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(&sb, "    label=%q;\n", "concurrency: "+grp.key)
+		sb.WriteString("    style=dashed;\n")
+		for _, n := range grp.nodes {
+			fmt.Fprintf(&sb, "    %s;\n", g.dotNode(ids[n.Path], n))
+		}
+		sb.WriteString("  }\n")
+	}
+	for _, n := range ungrouped {
+		fmt.Fprintf(&sb, "  %s;\n", g.dotNode(ids[n.Path], n))
+	}
+	for _, e := range g.Edges {
+		attrs := []string{fmt.Sprintf("label=%q", e.Kind+": "+e.Key)}
+		if e.Kind == GraphEdgeInclude {
+			attrs = append(attrs, "style=dashed")
+		}
+		if e.Cycle {
+			attrs = append(attrs, "color=red", "fontcolor=red")
+		}
+		fmt.Fprintf(&sb, "  %s -> %s [%s];\n", ids[e.From], ids[e.To], strings.Join(attrs, ", "))
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (g *Graph) dotNode(id string, n *GraphNode) string {
+	attrs := []string{fmt.Sprintf("label=%q", strings.Join(n.labels(), "\n"))}
+	if n.Missing {
+		attrs = append(attrs, "style=dotted")
+	}
+	if g.inCycle(n.Path) {
+		attrs = append(attrs, "color=red")
+	}
+	return fmt.Sprintf("%s [%s]", id, strings.Join(attrs, ", "))
+}
+
+// OutMermaid writes the graph as the flowchart of Mermaid.
+// The runbooks are grouped by the first key of `concurrency:`, and the edges of cycles are colored red.
+func (g *Graph) OutMermaid(w io.Writer) error {
+	ids := g.nodeIDs()
+	var sb strings.Builder
+	sb.WriteString("flowchart TD\n")
+	groups, ungrouped := g.concurrencyGroups()
+	for i, grp := range groups {
+		fmt.Fprintf(&sb, "  subgraph concurrency_%d [\"concurrency: %s\"]\n", i, mermaidEscape(grp.key))
+		for _, n := range grp.nodes {
+			fmt.Fprintf(&sb, "    %s\n", mermaidNode(ids[n.Path], n))
+		}
+		sb.WriteString("  end\n")
+	}
+	for _, n := range ungrouped {
+		fmt.Fprintf(&sb, "  %s\n", mermaidNode(ids[n.Path], n))
+	}
+	var cycles []string
+	for i, e := range g.Edges {
+		arrow := "-->"
+		if e.Kind == GraphEdgeInclude {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&sb, "  %s %s|\"%s\"| %s\n", ids[e.From], arrow, mermaidEscape(e.Kind+": "+e.Key), ids[e.To])
+		if e.Cycle {
+			cycles = append(cycles, fmt.Sprintf("%d", i))
+		}
+	}
+	if len(cycles) > 0 {
+		fmt.Fprintf(&sb, "  linkStyle %s stroke:red,color:red\n", strings.Join(cycles, ","))
+	}
+	for _, n := range g.Nodes {
+		if g.inCycle(n.Path) {
+			fmt.Fprintf(&sb, "  style %s stroke:red\n", ids[n.Path])
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func mermaidNode(id string, n *GraphNode) string {
+	labels := make([]string, 0, 2)
+	for _, l := range n.labels() {
+		labels = append(labels, mermaidEscape(l))
+	}
+	return fmt.Sprintf("%s[\"%s\"]", id, strings.Join(labels, "<br/>"))
+}
+
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
+
+func (n *GraphNode) labels() []string {
+	var labels []string
+	if n.Desc != "" {
+		labels = append(labels, n.Desc)
+	}
+	p := n.Path
+	if n.Missing {
+		p += " (not found)"
+	}
+	labels = append(labels, p)
+	if len(n.Concurrency) > 1 {
+		labels = append(labels, "concurrency: "+strings.Join(n.Concurrency, ", "))
+	}
+	return labels
+}
+
+func (g *Graph) nodeIDs() map[string]string {
+	ids := map[string]string{}
+	for i, n := range g.Nodes {
+		ids[n.Path] = fmt.Sprintf("n%d", i)
+	}
+	return ids
+}
+
+func (g *Graph) inCycle(p string) bool {
+	for _, c := range g.Cycles {
+		if slices.Contains(c, p) {
+			return true
+		}
+	}
+	return false
+}
+
+type graphGroup struct {
+	key   string
+	nodes []*GraphNode
+}
+
+// concurrencyGroups groups the nodes by the first key of `concurrency:`.
+func (g *Graph) concurrencyGroups() ([]*graphGroup, []*GraphNode) {
+	var (
+		groups    []*graphGroup
+		ungrouped []*GraphNode
+	)
+	for _, n := range g.Nodes {
+		if len(n.Concurrency) == 0 {
+			ungrouped = append(ungrouped, n)
+			continue
+		}
+		key := n.Concurrency[0]
+		i := slices.IndexFunc(groups, func(grp *graphGroup) bool { return grp.key == key })
+		if i < 0 {
+			groups = append(groups, &graphGroup{key: key})
+			i = len(groups) - 1
+		}
+		groups[i].nodes = append(groups[i].nodes, n)
+	}
+	return groups, ungrouped
+}
@@ -0,0 +1,146 @@
+package runn
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGraph(t *testing.T) {
+	tests := []struct {
+		pathp      string
+		wantEdges  []string
+		wantCycles [][]string
+	}{
+		{
+			"testdata/book/needs_3.yml",
+			[]string{
+				"testdata/book/needs_3.yml -needs:needs1-> testdata/book/needs_1.yml",
+				"testdata/book/needs_3.yml -needs:needs2-> testdata/book/needs_2.yml",
+				"testdata/book/needs_2.yml -needs:needs1-> testdata/book/needs_1.yml",
+			},
+			[][]string{},
+		},
+		{
+			"testdata/book/include_main.yml",
+			[]string{
+				"testdata/book/include_main.yml -include:a-> testdata/book/include_a.yml",
+				"testdata/book/include_main.yml -include:b-> testdata/book/include_b.yml",
+				"testdata/book/include_main.yml -include:b_loop-> testdata/book/include_b.yml",
+			},
+			[][]string{},
+		},
+		{
+			"testdata/lint/needs_a.yml",
+			[]string{
+				"testdata/lint/needs_a.yml -needs:b-> testdata/lint/needs_b.yml (cycle)",
+				"testdata/lint/needs_b.yml -needs:a-> testdata/lint/needs_a.yml (cycle)",
+			},
+			[][]string{{"testdata/lint/needs_a.yml", "testdata/lint/needs_b.yml"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pathp, func(t *testing.T) {
+			opn, err := Load(tt.pathp, LoadOnly())
+			if err != nil {
+				t.Fatal(err)
+			}
+			g, err := opn.Graph()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range g.Edges {
+				s := e.From + " -" + e.Kind + ":" + e.Key + "-> " + e.To
+				if e.Cycle {
+					s += " (cycle)"
+				}
+				got = append(got, s)
+			}
+			if diff := cmp.Diff(tt.wantEdges, got); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(tt.wantCycles, g.Cycles); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestGraphOut(t *testing.T) {
+	g := &Graph{
+		Nodes: []*GraphNode{
+			{Desc: "A", Path: "a.yml", Concurrency: []string{"db"}},
+			{Desc: "B", Path: "b.yml", Concurrency: []string{"db", "api"}},
+			{Path: "c.yml"},
+			{Path: "{{ vars.path }}", Missing: true},
+		},
+		Edges: []*GraphEdge{
+			{From: "a.yml", To: "b.yml", Kind: GraphEdgeNeeds, Key: "b", Cycle: true},
+			{From: "b.yml", To: "a.yml", Kind: GraphEdgeNeeds, Key: "a", Cycle: true},
+			{From: "a.yml", To: "c.yml", Kind: GraphEdgeInclude, Key: "0"},
+			{From: "c.yml", To: "{{ vars.path }}", Kind: GraphEdgeInclude, Key: "inc"},
+		},
+		Cycles: [][]string{{"a.yml", "b.yml"}},
+	}
+	t.Run("dot", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		if err := g.OutDOT(buf); err != nil {
+			t.Fatal(err)
+		}
+		want := `digraph runn {
+  node [shape=box];
+  subgraph cluster_0 {
+    label="concurrency: db";
+    style=dashed;
+    n0 [label="A\na.yml", color=red];
+    n1 [label="B\nb.yml\nconcurrency: db, api", color=red];
+  }
+  n2 [label="c.yml"];
+  n3 [label="{{ vars.path }} (not found)", style=dotted];
+  n0 -> n1 [label="needs: b", color=red, fontcolor=red];
+  n1 -> n0 [label="needs: a", color=red, fontcolor=red];
+  n0 -> n2 [label="include: 0", style=dashed];
+  n2 -> n3 [label="include: inc", style=dashed];
+}
+`
+		if diff := cmp.Diff(want, buf.String()); diff != "" {
+			t.Error(diff)
+		}
+	})
+	t.Run("mermaid", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		if err := g.OutMermaid(buf); err != nil {
+			t.Fatal(err)
+		}
+		want := `flowchart TD
+  subgraph concurrency_0 ["concurrency: db"]
+    n0["A<br/>a.yml"]
+    n1["B<br/>b.yml<br/>concurrency: db, api"]
+  end
+  n2["c.yml"]
+  n3["{{ vars.path }} (not found)"]
+  n0 -->|"needs: b"| n1
+  n1 -->|"needs: a"| n0
+  n0 -.->|"include: 0"| n2
+  n2 -.->|"include: inc"| n3
+  linkStyle 0,1 stroke:red,color:red
+  style n0 stroke:red
+  style n1 stroke:red
+`
+		if diff := cmp.Diff(want, buf.String()); diff != "" {
+			t.Error(diff)
+		}
+	})
+	t.Run("json", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		if err := g.OutJSON(buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), `"from": "a.yml"`) {
+			t.Errorf("got %s", buf.String())
+		}
+	})
+}
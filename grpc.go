@@ -4,9 +4,11 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"slices"
 	"strings"
@@ -15,6 +17,7 @@ import (
 
 	"github.com/bufbuild/protocompile"
 	"github.com/bufbuild/protocompile/linker"
+	"github.com/dustin/go-humanize"
 	"github.com/goccy/go-json"
 	"github.com/jhump/protoreflect/v2/grpcreflect"
 	"github.com/k1LoW/bufresolv"
@@ -24,10 +27,14 @@ import (
 	"github.com/k1LoW/runn/internal/sliceutil"
 	"github.com/k1LoW/runn/version"
 	"github.com/mitchellh/copystructure"
+	"github.com/spf13/cast"
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails" // register well-known error detail types
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/protojson"
@@ -35,6 +42,7 @@ import (
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/reflect/protoregistry"
 	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
 )
 
 type GRPCType string
@@ -60,6 +68,7 @@ const (
 	grpcStoreTrailerKey  = "trailers"
 	grpcStoreMessageKey  = "message"
 	grpcStoreMessagesKey = "messages"
+	grpcStoreDetailsKey  = "details"
 	grpcStoreResponseKey = "res"
 )
 
@@ -77,7 +86,18 @@ type grpcRunner struct {
 	bufLocks        []string
 	bufConfigs      []string
 	bufModules      []string
+	protocol        string
+	codec           string
+	compression     string
+	keepalive       *keepalive.ClientParameters
+	maxRecvMsgSize  int
+	maxSendMsgSize  int
+	authority       string
+	serviceConfig   string
+	bearerToken     string
+	perRPCMetadata  map[string]string
 	cc              *grpc.ClientConn
+	hc              *http.Client
 	refc            *grpcreflect.Client
 	mds             map[string]protoreflect.MethodDescriptor
 	hostRules       hostRules
@@ -123,6 +143,10 @@ func (rnr *grpcRunner) Renew() error {
 }
 
 func (rnr *grpcRunner) Close() error {
+	if rnr.hc != nil {
+		rnr.hc.CloseIdleConnections()
+		rnr.hc = nil
+	}
 	if rnr.cc == nil {
 		rnr.refc = nil
 		return nil
@@ -167,6 +191,13 @@ func (rnr *grpcRunner) run(ctx context.Context, r *grpcRequest, s *step) error {
 	if err := r.setTraceHeader(s); err != nil {
 		return err
 	}
+	ctx, err := rnr.withPerRPCCredentials(ctx, s)
+	if err != nil {
+		return err
+	}
+	if rnr.isHTTPProtocol() {
+		return rnr.invokeHTTP(ctx, md, r, s)
+	}
 	switch {
 	case !md.IsStreamingServer() && !md.IsStreamingClient():
 		o.capturers.captureGRPCStart(rnr.name, GRPCUnary, r.service, r.method)
@@ -191,56 +222,9 @@ func (rnr *grpcRunner) run(ctx context.Context, r *grpcRequest, s *step) error {
 
 func (rnr *grpcRunner) connectAndResolve(ctx context.Context, o *operator) error {
 	if rnr.cc == nil {
-		opts := []grpc.DialOption{
-			grpc.WithUserAgent(fmt.Sprintf("runn/%s", version.Version)),
-		}
-		if len(rnr.hostRules) > 0 {
-			opts = append(opts, grpc.WithContextDialer(rnr.hostRules.contextDialerFunc()))
-		}
-		useTLS := true
-		if strings.HasSuffix(rnr.target, ":80") {
-			useTLS = false
-		}
-		if rnr.tls != nil {
-			useTLS = *rnr.tls
-		}
-		if useTLS {
-			tlsc := tls.Config{MinVersion: tls.VersionTLS12}
-			if len(rnr.cert) != 0 {
-				certificate, err := tls.X509KeyPair(rnr.cert, rnr.key)
-				if err != nil {
-					return err
-				}
-				tlsc.Certificates = []tls.Certificate{certificate}
-			}
-			if rnr.skipVerify {
-				//#nosec G402
-				tlsc.InsecureSkipVerify = true
-			} else if len(rnr.cacert) != 0 {
-				certpool, err := x509.SystemCertPool()
-				if err != nil {
-					// FIXME for Windows
-					// ref: https://github.com/golang/go/issues/18609
-					certpool = x509.NewCertPool()
-				}
-				if ok := certpool.AppendCertsFromPEM(rnr.cacert); !ok {
-					return errors.New("failed to append cacert")
-				}
-				tlsc.RootCAs = certpool
-			}
-			opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tlsc)))
-		} else {
-			opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
-		}
-		target := rnr.target
-		if strings.Count(target, ":") < 2 {
-			target = fmt.Sprintf("passthrough:%s", target)
-		}
-		cc, err := grpc.NewClient(target, opts...)
-		if err != nil {
+		if err := rnr.dial(); err != nil {
 			return err
 		}
-		rnr.cc = cc
 		if rnr.target != "" {
 			if err := donegroup.Cleanup(ctx, func() error {
 				// In the case of Reused runners, leave the cleanup to the main cleanup
@@ -253,6 +237,48 @@ func (rnr *grpcRunner) connectAndResolve(ctx context.Context, o *operator) error
 			}
 		}
 	}
+	if rnr.isHTTPProtocol() && rnr.hc == nil {
+		hc, err := rnr.newHTTPClient()
+		if err != nil {
+			return err
+		}
+		rnr.hc = hc
+	}
+	return rnr.resolveAllMethods(ctx)
+}
+
+// dial creates the client connection to the target.
+func (rnr *grpcRunner) dial() error {
+	opts := []grpc.DialOption{
+		grpc.WithUserAgent(fmt.Sprintf("runn/%s", version.Version)),
+	}
+	if len(rnr.hostRules) > 0 {
+		opts = append(opts, grpc.WithContextDialer(rnr.hostRules.contextDialerFunc()))
+	}
+	opts = append(opts, rnr.clientDialOptions()...)
+	tlsc, err := rnr.tlsConfig()
+	if err != nil {
+		return err
+	}
+	if tlsc != nil {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsc)))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	target := rnr.target
+	if strings.Count(target, ":") < 2 {
+		target = fmt.Sprintf("passthrough:%s", target)
+	}
+	cc, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return err
+	}
+	rnr.cc = cc
+	return nil
+}
+
+// resolveAllMethods resolves the method descriptors using protos/buf if set, otherwise using reflection.
+func (rnr *grpcRunner) resolveAllMethods(ctx context.Context) error {
 	if len(rnr.importPaths) > 0 || len(rnr.protos) > 0 || len(rnr.bufDirs) > 0 || len(rnr.bufLocks) > 0 || len(rnr.bufConfigs) > 0 || len(rnr.bufModules) > 0 {
 		if err := rnr.resolveAllMethodsUsingProtos(ctx); err != nil {
 			return err
@@ -270,6 +296,45 @@ func (rnr *grpcRunner) connectAndResolve(ctx context.Context, o *operator) error
 	return nil
 }
 
+// useTLS returns whether to use TLS to connect to the target.
+func (rnr *grpcRunner) useTLS() bool {
+	if rnr.tls != nil {
+		return *rnr.tls
+	}
+	return !strings.HasSuffix(rnr.target, ":80")
+}
+
+// tlsConfig returns the TLS config of the runner. It returns nil if TLS is not used.
+func (rnr *grpcRunner) tlsConfig() (*tls.Config, error) {
+	if !rnr.useTLS() {
+		return nil, nil
+	}
+	tlsc := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(rnr.cert) != 0 {
+		certificate, err := tls.X509KeyPair(rnr.cert, rnr.key)
+		if err != nil {
+			return nil, err
+		}
+		tlsc.Certificates = []tls.Certificate{certificate}
+	}
+	if rnr.skipVerify {
+		//#nosec G402
+		tlsc.InsecureSkipVerify = true
+	} else if len(rnr.cacert) != 0 {
+		certpool, err := x509.SystemCertPool()
+		if err != nil {
+			// FIXME for Windows
+			// ref: https://github.com/golang/go/issues/18609
+			certpool = x509.NewCertPool()
+		}
+		if ok := certpool.AppendCertsFromPEM(rnr.cacert); !ok {
+			return nil, errors.New("failed to append cacert")
+		}
+		tlsc.RootCAs = certpool
+	}
+	return tlsc, nil
+}
+
 func (rnr *grpcRunner) invokeUnary(ctx context.Context, md protoreflect.MethodDescriptor, r *grpcRequest, s *step) error {
 	o := s.parent
 	if len(r.messages) != 1 {
@@ -306,8 +371,10 @@ func (rnr *grpcRunner) invokeUnary(ctx context.Context, md protoreflect.MethodDe
 		string(grpcStoreHeaderKey):  resHeaders,
 		string(grpcStoreTrailerKey): resTrailers,
 		string(grpcStoreMessageKey): nil,
+		string(grpcStoreDetailsKey): []any{},
 	}
 
+	rnr.resolveDetailTypes(stat)
 	o.capturers.captureGRPCResponseStatus(stat)
 	o.capturers.captureGRPCResponseHeaders(resHeaders)
 	o.capturers.captureGRPCResponseTrailers(resTrailers)
@@ -330,6 +397,7 @@ func (rnr *grpcRunner) invokeUnary(ctx context.Context, md protoreflect.MethodDe
 		d[grpcStoreMessagesKey] = messages
 	} else {
 		d[grpcStoreMessageKey] = stat.Message()
+		d[grpcStoreDetailsKey] = grpcStatusDetails(stat)
 	}
 
 	o.record(s.idx, map[string]any{
@@ -376,6 +444,7 @@ func (rnr *grpcRunner) invokeServerStreaming(ctx context.Context, md protoreflec
 		string(grpcStoreHeaderKey):  metadata.MD{},
 		string(grpcStoreTrailerKey): metadata.MD{},
 		string(grpcStoreMessageKey): nil,
+		string(grpcStoreDetailsKey): []any{},
 	}
 	var messages []map[string]any
 
@@ -396,6 +465,7 @@ func (rnr *grpcRunner) invokeServerStreaming(ctx context.Context, md protoreflec
 		}
 		d[grpcStoreStatusKey] = int64(stat.Code())
 
+		rnr.resolveDetailTypes(stat)
 		o.capturers.captureGRPCResponseStatus(stat)
 
 		if stat.Code() == codes.OK {
@@ -414,6 +484,7 @@ func (rnr *grpcRunner) invokeServerStreaming(ctx context.Context, md protoreflec
 			messages = append(messages, msg)
 		} else {
 			d[grpcStoreMessageKey] = stat.Message()
+			d[grpcStoreDetailsKey] = grpcStatusDetails(stat)
 		}
 	}
 	d[grpcStoreMessagesKey] = messages
@@ -459,6 +530,7 @@ func (rnr *grpcRunner) invokeClientStreaming(ctx context.Context, md protoreflec
 		string(grpcStoreHeaderKey):  metadata.MD{},
 		string(grpcStoreTrailerKey): metadata.MD{},
 		string(grpcStoreMessageKey): nil,
+		string(grpcStoreDetailsKey): []any{},
 	}
 	var messages []map[string]any
 	for _, m := range r.messages {
@@ -493,6 +565,7 @@ func (rnr *grpcRunner) invokeClientStreaming(ctx context.Context, md protoreflec
 
 	d[grpcStoreStatusKey] = int64(stat.Code())
 
+	rnr.resolveDetailTypes(stat)
 	o.capturers.captureGRPCResponseStatus(stat)
 
 	if stat.Code() == codes.OK {
@@ -511,6 +584,7 @@ func (rnr *grpcRunner) invokeClientStreaming(ctx context.Context, md protoreflec
 		messages = append(messages, msg)
 	} else {
 		d[grpcStoreMessageKey] = stat.Message()
+		d[grpcStoreDetailsKey] = grpcStatusDetails(stat)
 	}
 
 	d[grpcStoreMessagesKey] = messages
@@ -555,6 +629,7 @@ func (rnr *grpcRunner) invokeBidiStreaming(ctx context.Context, md protoreflect.
 		string(grpcStoreHeaderKey):  metadata.MD{},
 		string(grpcStoreTrailerKey): metadata.MD{},
 		string(grpcStoreMessageKey): nil,
+		string(grpcStoreDetailsKey): []any{},
 	}
 	var messages []map[string]any
 	clientClose := false
@@ -590,6 +665,7 @@ L:
 			}
 			d[grpcStoreStatusKey] = int64(stat.Code())
 
+			rnr.resolveDetailTypes(stat)
 			o.capturers.captureGRPCResponseStatus(stat)
 
 			if h, err := stream.Header(); err == nil {
@@ -613,6 +689,7 @@ L:
 				messages = append(messages, msg)
 			} else {
 				d[grpcStoreMessageKey] = stat.Message()
+				d[grpcStoreDetailsKey] = grpcStatusDetails(stat)
 			}
 		case GRPCOpClose:
 			clientClose = true
@@ -630,7 +707,9 @@ L:
 	if stat.Code() != codes.OK {
 		d[grpcStoreStatusKey] = int64(stat.Code())
 		d[grpcStoreMessageKey] = stat.Message()
+		d[grpcStoreDetailsKey] = grpcStatusDetails(stat)
 
+		rnr.resolveDetailTypes(stat)
 		o.capturers.captureGRPCResponseStatus(stat)
 	}
 
@@ -668,6 +747,7 @@ L:
 				}
 				d[grpcStoreStatusKey] = int64(stat.Code())
 
+				rnr.resolveDetailTypes(stat)
 				o.capturers.captureGRPCResponseStatus(stat)
 				if stat.Code() == codes.OK {
 					b, err := protojson.MarshalOptions{UseProtoNames: true, UseEnumNumbers: true, EmitUnpopulated: true}.Marshal(res)
@@ -685,6 +765,7 @@ L:
 					messages = append(messages, msg)
 				} else {
 					d[grpcStoreMessageKey] = stat.Message()
+					d[grpcStoreDetailsKey] = grpcStatusDetails(stat)
 				}
 			}
 		}
@@ -720,6 +801,169 @@ L:
 	return nil
 }
 
+// setClientOptions sets the client options ( compression, keepalive, message sizes, authority, service config and per-RPC credentials ) from the config.
+func (rnr *grpcRunner) setClientOptions(c *grpcRunnerConfig) error {
+	switch c.Compression {
+	case "", gzip.Name:
+		rnr.compression = c.Compression
+	default:
+		return fmt.Errorf("invalid compression: %s (available compressions: %s)", c.Compression, gzip.Name)
+	}
+	if c.Keepalive != nil {
+		kp := &keepalive.ClientParameters{
+			PermitWithoutStream: c.Keepalive.PermitWithoutStream,
+		}
+		if c.Keepalive.Time != "" {
+			d, err := parseDuration(c.Keepalive.Time)
+			if err != nil {
+				return fmt.Errorf("invalid keepalive.time: %w", err)
+			}
+			kp.Time = d
+		}
+		if c.Keepalive.Timeout != "" {
+			d, err := parseDuration(c.Keepalive.Timeout)
+			if err != nil {
+				return fmt.Errorf("invalid keepalive.timeout: %w", err)
+			}
+			kp.Timeout = d
+		}
+		rnr.keepalive = kp
+	}
+	var err error
+	rnr.maxRecvMsgSize, err = parseMsgSize(c.MaxRecvMsgSize)
+	if err != nil {
+		return fmt.Errorf("invalid maxRecvMsgSize: %w", err)
+	}
+	rnr.maxSendMsgSize, err = parseMsgSize(c.MaxSendMsgSize)
+	if err != nil {
+		return fmt.Errorf("invalid maxSendMsgSize: %w", err)
+	}
+	rnr.authority = c.Authority
+	switch v := c.ServiceConfig.(type) {
+	case nil:
+	case string:
+		rnr.serviceConfig = v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("invalid serviceConfig: %w", err)
+		}
+		rnr.serviceConfig = string(b)
+	}
+	if rnr.serviceConfig != "" && !json.Valid([]byte(rnr.serviceConfig)) {
+		return fmt.Errorf("invalid serviceConfig: %s", rnr.serviceConfig)
+	}
+	if c.PerRPCCredentials != nil {
+		rnr.bearerToken = c.PerRPCCredentials.Bearer
+		rnr.perRPCMetadata = c.PerRPCCredentials.Metadata
+	}
+	return nil
+}
+
+func (rnr *grpcRunner) clientDialOptions() []grpc.DialOption {
+	var (
+		opts     []grpc.DialOption
+		callOpts []grpc.CallOption
+	)
+	if rnr.compression != "" {
+		callOpts = append(callOpts, grpc.UseCompressor(rnr.compression))
+	}
+	if rnr.maxRecvMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(rnr.maxRecvMsgSize))
+	}
+	if rnr.maxSendMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(rnr.maxSendMsgSize))
+	}
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+	if rnr.keepalive != nil {
+		opts = append(opts, grpc.WithKeepaliveParams(*rnr.keepalive))
+	}
+	if rnr.authority != "" {
+		opts = append(opts, grpc.WithAuthority(rnr.authority))
+	}
+	if rnr.serviceConfig != "" {
+		opts = append(opts, grpc.WithDefaultServiceConfig(rnr.serviceConfig))
+	}
+	if rnr.bearerToken != "" || len(rnr.perRPCMetadata) > 0 {
+		opts = append(opts, grpc.WithPerRPCCredentials(grpcPerRPCCredentials{}))
+	}
+	return opts
+}
+
+type grpcPerRPCCredentialsKey struct{}
+
+var _ credentials.PerRPCCredentials = grpcPerRPCCredentials{}
+
+// grpcPerRPCCredentials sends the metadata expanded for each step as per-RPC credentials.
+type grpcPerRPCCredentials struct{}
+
+func (grpcPerRPCCredentials) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	md, _ := ctx.Value(grpcPerRPCCredentialsKey{}).(map[string]string)
+	return md, nil
+}
+
+// RequireTransportSecurity returns false so that the credentials can also be sent to plaintext servers for testing.
+func (grpcPerRPCCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// withPerRPCCredentials expands the per-RPC credentials using the store and sets them to the context.
+func (rnr *grpcRunner) withPerRPCCredentials(ctx context.Context, s *step) (context.Context, error) {
+	if rnr.bearerToken == "" && len(rnr.perRPCMetadata) == 0 {
+		return ctx, nil
+	}
+	o := s.parent
+	in := map[string]any{}
+	for k, v := range rnr.perRPCMetadata {
+		in[strings.ToLower(k)] = v
+	}
+	if rnr.bearerToken != "" {
+		in["authorization"] = fmt.Sprintf("Bearer %s", rnr.bearerToken)
+	}
+	e, err := o.expandBeforeRecord(in, s)
+	if err != nil {
+		return nil, err
+	}
+	em, ok := e.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid per-RPC credentials: %v", e)
+	}
+	md := map[string]string{}
+	for k, v := range em {
+		md[k] = cast.ToString(v)
+	}
+	return context.WithValue(ctx, grpcPerRPCCredentialsKey{}, md), nil
+}
+
+func perRPCCredentialsFromContext(ctx context.Context) map[string]string {
+	md, _ := grpcPerRPCCredentials{}.GetRequestMetadata(ctx)
+	return md
+}
+
+func parseMsgSize(v any) (int, error) {
+	switch vv := v.(type) {
+	case nil:
+		return 0, nil
+	case string:
+		n, err := humanize.ParseBytes(vv)
+		if err != nil {
+			return 0, err
+		}
+		return int(n), nil //nolint:gosec
+	default:
+		n, err := cast.ToIntE(vv)
+		if err != nil {
+			return 0, err
+		}
+		if n < 0 {
+			return 0, fmt.Errorf("invalid size: %d", n)
+		}
+		return n, nil
+	}
+}
+
 func setHeaders(ctx context.Context, h metadata.MD) context.Context {
 	var kv []string
 	for k, v := range h {
@@ -863,6 +1107,77 @@ func (r *grpcRequest) setTraceHeader(s *step) error {
 	return nil
 }
 
+// resolveDetailTypes registers the descriptors of the error detail types using reflection if they cannot be resolved yet.
+func (rnr *grpcRunner) resolveDetailTypes(stat *status.Status) {
+	if rnr.refc == nil {
+		return
+	}
+	for _, a := range stat.Proto().GetDetails() {
+		if _, err := findMessageType(a.MessageName()); err == nil {
+			continue
+		}
+		_, _ = rnr.findDescripter(a.MessageName())
+	}
+}
+
+// grpcStatusDetails decodes the error details (google.rpc.Status.details) into the list of messages.
+func grpcStatusDetails(stat *status.Status) []any {
+	details := []any{}
+	for _, a := range stat.Proto().GetDetails() {
+		details = append(details, grpcStatusDetail(a))
+	}
+	return details
+}
+
+func grpcStatusDetail(a *anypb.Any) map[string]any {
+	raw := map[string]any{
+		"@type": a.GetTypeUrl(),
+		"value": base64.StdEncoding.EncodeToString(a.GetValue()),
+	}
+	mt, err := findMessageType(a.MessageName())
+	if err != nil {
+		return raw
+	}
+	m := mt.New().Interface()
+	if err := proto.Unmarshal(a.GetValue(), m); err != nil {
+		return raw
+	}
+	d, err := grpcMessageToMap(m)
+	if err != nil {
+		return raw
+	}
+	d["@type"] = a.GetTypeUrl()
+	return d
+}
+
+// findMessageType finds the message type from the registered types and the registered files ( protos, Buf and reflection ).
+func findMessageType(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(name); err == nil {
+		return mt, nil
+	}
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return nil, err
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("invalid message descriptor: %v", d)
+	}
+	return dynamicpb.NewMessageType(md), nil
+}
+
+func grpcMessageToMap(m proto.Message) (map[string]any, error) {
+	b, err := protojson.MarshalOptions{UseProtoNames: true, UseEnumNumbers: true, EmitUnpopulated: true}.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var msg map[string]any
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
 func dcopy(in any) any {
 	return copystructure.Must(copystructure.Copy(in))
 }
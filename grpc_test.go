@@ -3,17 +3,26 @@ package runn
 import (
 	"context"
 	"fmt"
+	"net"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/goccy/go-yaml"
 	"github.com/google/go-cmp/cmp"
 	"github.com/k1LoW/donegroup"
 	"github.com/k1LoW/grpcstub"
 	"github.com/k1LoW/runn/testutil"
 	"github.com/k1LoW/runn/version"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 func TestGrpcRunner(t *testing.T) {
@@ -602,3 +611,136 @@ func TestGrpcTraceHeader(t *testing.T) {
 		})
 	}
 }
+
+func TestGrpcStatusDetails(t *testing.T) {
+	stat, err := status.New(codes.InvalidArgument, "invalid request").WithDetails(
+		&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "name", Description: "name is required"},
+			},
+		},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(3 * time.Second)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp := stat.Proto()
+	sp.Details = append(sp.Details, &anypb.Any{TypeUrl: "type.googleapis.com/myapp.UnknownDetail", Value: []byte("hello")})
+	got := grpcStatusDetails(status.FromProto(sp))
+	want := []any{
+		map[string]any{
+			"@type": "type.googleapis.com/google.rpc.BadRequest",
+			"field_violations": []any{
+				map[string]any{"field": "name", "description": "name is required", "reason": "", "localized_message": nil},
+			},
+		},
+		map[string]any{
+			"@type":       "type.googleapis.com/google.rpc.RetryInfo",
+			"retry_delay": "3s",
+		},
+		map[string]any{
+			"@type": "type.googleapis.com/myapp.UnknownDetail",
+			"value": "aGVsbG8=",
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Error(diff)
+	}
+	if got := grpcStatusDetails(status.New(codes.OK, "")); len(got) != 0 {
+		t.Errorf("got %v want empty", got)
+	}
+}
+
+func TestGrpcRunnerClientOptions(t *testing.T) {
+	var got metadata.MD
+	srv := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		got, _ = metadata.FromIncomingContext(ctx)
+		return handler(ctx, req)
+	}))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	ctx, cancel := donegroup.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	o, err := New(Var("token", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := newGrpcRunner("greq", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTLS := false
+	r.tls = &useTLS
+	c := &grpcRunnerConfig{}
+	for _, opt := range []grpcRunnerOption{
+		GRPCCompression("gzip"),
+		GRPCKeepalive(30*time.Second, 10*time.Second, false),
+		GRPCMaxRecvMsgSize(64 * 1024 * 1024),
+		GRPCAuthority("api.example.com"),
+		GRPCServiceConfig(`{"loadBalancingConfig":[{"round_robin":{}}]}`),
+		GRPCBearerToken("{{ vars.token }}"),
+		GRPCPerRPCMetadata(map[string]string{"X-Tenant": "acme"}),
+	} {
+		if err := opt(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.setClientOptions(c); err != nil {
+		t.Fatal(err)
+	}
+	r.mds["grpc.health.v1.Health/Check"] = healthpb.File_grpc_health_v1_health_proto.Services().ByName("Health").Methods().ByName("Check")
+	req := &grpcRequest{
+		service:  "grpc.health.v1.Health",
+		method:   "Check",
+		headers:  metadata.MD{},
+		messages: []*grpcMessage{{op: GRPCOpMessage, params: map[string]any{}}},
+	}
+	s := newStep(0, "stepKey", o, nil)
+	if err := r.run(ctx, req, s); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{
+		"authorization": "Bearer secret",
+		"x-tenant":      "acme",
+		":authority":    "api.example.com",
+	} {
+		if v := got.Get(k); len(v) != 1 || v[0] != want {
+			t.Errorf("%s: got %v want %v", k, v, want)
+		}
+	}
+}
+
+func TestGrpcRunnerSetClientOptions(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{"addr: localhost:8080\ncompression: gzip\nmaxRecvMsgSize: 16MiB\nmaxSendMsgSize: 1048576\n", false},
+		{"addr: localhost:8080\nkeepalive:\n  time: 30sec\n  timeout: 10sec\n", false},
+		{"addr: localhost:8080\nserviceConfig:\n  loadBalancingConfig:\n    - round_robin: {}\n", false},
+		{"addr: localhost:8080\ncompression: zstd\n", true},
+		{"addr: localhost:8080\nmaxRecvMsgSize: invalid\n", true},
+		{"addr: localhost:8080\nserviceConfig: '{invalid'\n", true},
+	}
+	for _, tt := range tests {
+		c := &grpcRunnerConfig{}
+		if err := yaml.Unmarshal([]byte(tt.in), c); err != nil {
+			t.Fatal(err)
+		}
+		r, err := newGrpcRunner("greq", c.Addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.setClientOptions(c); (err != nil) != tt.wantErr {
+			t.Errorf("%q: got %v", tt.in, err)
+		}
+	}
+}
@@ -0,0 +1,646 @@
+package runn
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/k1LoW/runn/version"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Protocols of the gRPC runner.
+const (
+	grpcProtocolGRPC    = "grpc"
+	grpcProtocolGRPCWeb = "grpc-web"
+	grpcProtocolConnect = "connect"
+)
+
+// Codecs of the Connect protocol.
+const (
+	grpcCodecProto = "proto"
+	grpcCodecJSON  = "json"
+)
+
+const (
+	grpcHTTPFlagCompressed = 0x01
+	grpcWebFlagTrailer     = 0x80
+	connectFlagEndStream   = 0x02
+)
+
+const (
+	grpcStatusHeader        = "grpc-status"
+	grpcMessageHeader       = "grpc-message"
+	grpcStatusDetailsHeader = "grpc-status-details-bin"
+	connectTrailerPrefix    = "trailer-"
+)
+
+var connectCodes = map[string]codes.Code{
+	"canceled":            codes.Canceled,
+	"unknown":             codes.Unknown,
+	"invalid_argument":    codes.InvalidArgument,
+	"deadline_exceeded":   codes.DeadlineExceeded,
+	"not_found":           codes.NotFound,
+	"already_exists":      codes.AlreadyExists,
+	"permission_denied":   codes.PermissionDenied,
+	"resource_exhausted":  codes.ResourceExhausted,
+	"failed_precondition": codes.FailedPrecondition,
+	"aborted":             codes.Aborted,
+	"out_of_range":        codes.OutOfRange,
+	"unimplemented":       codes.Unimplemented,
+	"internal":            codes.Internal,
+	"unavailable":         codes.Unavailable,
+	"data_loss":           codes.DataLoss,
+	"unauthenticated":     codes.Unauthenticated,
+}
+
+// grpcHTTPResponse is the response of the RPC over gRPC-Web or Connect protocol.
+type grpcHTTPResponse struct {
+	status   *status.Status
+	headers  metadata.MD
+	trailers metadata.MD
+	messages []proto.Message
+}
+
+type connectError struct {
+	Code    string                `json:"code"`
+	Message string                `json:"message"`
+	Details []*connectErrorDetail `json:"details,omitempty"`
+}
+
+type connectErrorDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type connectEndStream struct {
+	Error    *connectError       `json:"error,omitempty"`
+	Metadata map[string][]string `json:"metadata,omitempty"`
+}
+
+func validateGRPCProtocol(protocol, codec string) error {
+	switch protocol {
+	case "", grpcProtocolGRPC, grpcProtocolGRPCWeb, grpcProtocolConnect:
+	default:
+		return fmt.Errorf("invalid protocol: %s (available protocols: %s, %s, %s)", protocol, grpcProtocolGRPC, grpcProtocolGRPCWeb, grpcProtocolConnect)
+	}
+	switch codec {
+	case "", grpcCodecProto:
+	case grpcCodecJSON:
+		if protocol != grpcProtocolConnect {
+			return fmt.Errorf("codec %s can only be used with protocol: %s", codec, grpcProtocolConnect)
+		}
+	default:
+		return fmt.Errorf("invalid codec: %s (available codecs: %s, %s)", codec, grpcCodecProto, grpcCodecJSON)
+	}
+	return nil
+}
+
+// isHTTPProtocol returns whether the runner speaks the protocol over HTTP instead of native gRPC.
+func (rnr *grpcRunner) isHTTPProtocol() bool {
+	return rnr.protocol == grpcProtocolGRPCWeb || rnr.protocol == grpcProtocolConnect
+}
+
+func (rnr *grpcRunner) newHTTPClient() (*http.Client, error) {
+	tlsc, err := rnr.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	tp, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("failed to cast: %v", http.DefaultTransport)
+	}
+	tp = tp.Clone()
+	tp.TLSClientConfig = tlsc
+	if len(rnr.hostRules) > 0 {
+		tp.DialContext = rnr.hostRules.dialContextFunc()
+	}
+	return &http.Client{Transport: tp}, nil
+}
+
+func (rnr *grpcRunner) baseURL() string {
+	if strings.HasPrefix(rnr.target, "http://") || strings.HasPrefix(rnr.target, "https://") {
+		return strings.TrimSuffix(rnr.target, "/")
+	}
+	if rnr.useTLS() {
+		return fmt.Sprintf("https://%s", rnr.target)
+	}
+	return fmt.Sprintf("http://%s", rnr.target)
+}
+
+func grpcTypeOf(md protoreflect.MethodDescriptor) GRPCType {
+	switch {
+	case md.IsStreamingServer() && md.IsStreamingClient():
+		return GRPCBidiStreaming
+	case md.IsStreamingServer():
+		return GRPCServerStreaming
+	case md.IsStreamingClient():
+		return GRPCClientStreaming
+	default:
+		return GRPCUnary
+	}
+}
+
+// invokeHTTP invokes the RPC using gRPC-Web or Connect protocol and records the response in the same layout as native gRPC.
+func (rnr *grpcRunner) invokeHTTP(ctx context.Context, md protoreflect.MethodDescriptor, r *grpcRequest, s *step) error {
+	o := s.parent
+	typ := grpcTypeOf(md)
+	o.capturers.captureGRPCStart(rnr.name, typ, r.service, r.method)
+	defer o.capturers.captureGRPCEnd(rnr.name, typ, r.service, r.method)
+	switch typ {
+	case GRPCUnary:
+		if len(r.messages) != 1 {
+			return errors.New("unary RPC message should be 1")
+		}
+	case GRPCServerStreaming:
+		if len(r.messages) != 1 {
+			return errors.New("server streaming RPC message should be 1")
+		}
+	case GRPCClientStreaming:
+		if rnr.protocol == grpcProtocolGRPCWeb {
+			return fmt.Errorf("client streaming RPC is not supported with protocol: %s", rnr.protocol)
+		}
+	case GRPCBidiStreaming:
+		return fmt.Errorf("bidirectional streaming RPC is not supported with protocol: %s", rnr.protocol)
+	}
+	if r.timeout > 0 {
+		cctx, cancel := context.WithTimeout(ctx, r.timeout)
+		ctx = cctx
+		defer cancel()
+	}
+
+	o.capturers.captureGRPCRequestHeaders(r.headers)
+
+	var reqs []proto.Message
+	for _, m := range r.messages {
+		if m.op != GRPCOpMessage {
+			return fmt.Errorf("invalid op: %v", m.op)
+		}
+		req := dynamicpb.NewMessage(md.Input())
+		if err := rnr.setMessage(req, m.params, s); err != nil {
+			return err
+		}
+		reqs = append(reqs, req)
+	}
+
+	var (
+		res *grpcHTTPResponse
+		err error
+	)
+	switch {
+	case rnr.protocol == grpcProtocolConnect && typ == GRPCUnary:
+		res, err = rnr.invokeConnectUnary(ctx, md, r, reqs[0])
+	case rnr.protocol == grpcProtocolConnect:
+		res, err = rnr.invokeConnectStreaming(ctx, md, r, reqs)
+	default:
+		res, err = rnr.invokeGRPCWeb(ctx, md, r, reqs)
+	}
+	if err != nil {
+		return err
+	}
+
+	d := map[string]any{
+		string(grpcStoreHeaderKey):  res.headers,
+		string(grpcStoreTrailerKey): res.trailers,
+		string(grpcStoreMessageKey): nil,
+		string(grpcStoreDetailsKey): []any{},
+	}
+	if typ == GRPCUnary {
+		d[grpcStoreStatusKey] = int(res.status.Code())
+	} else {
+		d[grpcStoreStatusKey] = int64(res.status.Code())
+	}
+
+	rnr.resolveDetailTypes(res.status)
+	o.capturers.captureGRPCResponseStatus(res.status)
+	o.capturers.captureGRPCResponseHeaders(res.headers)
+
+	var messages []map[string]any
+	for _, m := range res.messages {
+		msg, err := grpcMessageToMap(m)
+		if err != nil {
+			return err
+		}
+		d[grpcStoreMessageKey] = msg
+
+		o.capturers.captureGRPCResponseMessage(msg)
+
+		messages = append(messages, msg)
+	}
+	if res.status.Code() != codes.OK {
+		d[grpcStoreMessageKey] = res.status.Message()
+		d[grpcStoreDetailsKey] = grpcStatusDetails(res.status)
+	}
+	// Same layout as the native gRPC protocol: unary RPCs record messages only when the status is OK
+	if typ != GRPCUnary || res.status.Code() == codes.OK {
+		d[grpcStoreMessagesKey] = messages
+	}
+
+	o.capturers.captureGRPCResponseTrailers(res.trailers)
+
+	o.record(s.idx, map[string]any{
+		string(grpcStoreResponseKey): d,
+	})
+	return nil
+}
+
+func (rnr *grpcRunner) invokeGRPCWeb(ctx context.Context, md protoreflect.MethodDescriptor, r *grpcRequest, reqs []proto.Message) (*grpcHTTPResponse, error) {
+	var body []byte
+	for _, req := range reqs {
+		b, err := proto.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, encodeEnvelope(0, b)...)
+	}
+	hreq, err := rnr.newHTTPRequest(ctx, md, r, "application/grpc-web+proto", body)
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("X-Grpc-Web", "1")
+	if r.timeout > 0 {
+		hreq.Header.Set("Grpc-Timeout", fmt.Sprintf("%dm", r.timeout.Milliseconds()))
+	}
+	hres, err := rnr.hc.Do(hreq)
+	if err != nil {
+		return grpcHTTPErrorResponse(err)
+	}
+	defer hres.Body.Close()
+	headers := toMetadata(hres.Header)
+	if hres.StatusCode != http.StatusOK {
+		return &grpcHTTPResponse{
+			status:   status.New(httpStatusToCode(hres.StatusCode), hres.Status),
+			headers:  headers,
+			trailers: metadata.MD{},
+		}, nil
+	}
+	res := &grpcHTTPResponse{
+		headers:  headers,
+		trailers: metadata.MD{},
+	}
+	for {
+		flag, b, err := readEnvelope(hres.Body)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return grpcHTTPErrorResponse(err)
+		}
+		if flag&grpcWebFlagTrailer != 0 {
+			res.trailers = parseGRPCWebTrailer(b)
+			continue
+		}
+		if flag&grpcHTTPFlagCompressed != 0 {
+			return nil, errors.New("compressed messages are not supported")
+		}
+		m := dynamicpb.NewMessage(md.Output())
+		if err := proto.Unmarshal(b, m); err != nil {
+			return nil, err
+		}
+		res.messages = append(res.messages, m)
+	}
+	// Trailers-Only response has the status in the headers
+	st := res.trailers
+	if len(st.Get(grpcStatusHeader)) == 0 {
+		st = res.headers
+	}
+	stat, err := grpcStatusFromMetadata(st)
+	if err != nil {
+		return nil, err
+	}
+	res.status = stat
+	for _, k := range []string{grpcStatusHeader, grpcMessageHeader, grpcStatusDetailsHeader} {
+		res.headers.Delete(k)
+		res.trailers.Delete(k)
+	}
+	return res, nil
+}
+
+func (rnr *grpcRunner) invokeConnectUnary(ctx context.Context, md protoreflect.MethodDescriptor, r *grpcRequest, req proto.Message) (*grpcHTTPResponse, error) {
+	body, err := rnr.marshalHTTPMessage(req)
+	if err != nil {
+		return nil, err
+	}
+	hreq, err := rnr.newHTTPRequest(ctx, md, r, fmt.Sprintf("application/%s", rnr.connectCodec()), body)
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Connect-Protocol-Version", "1")
+	if r.timeout > 0 {
+		hreq.Header.Set("Connect-Timeout-Ms", strconv.FormatInt(r.timeout.Milliseconds(), 10))
+	}
+	hres, err := rnr.hc.Do(hreq)
+	if err != nil {
+		return grpcHTTPErrorResponse(err)
+	}
+	defer hres.Body.Close()
+	res := &grpcHTTPResponse{
+		headers:  metadata.MD{},
+		trailers: metadata.MD{},
+	}
+	// Trailers of the Connect unary RPC are sent as headers with the prefix
+	for k, v := range toMetadata(hres.Header) {
+		if strings.HasPrefix(k, connectTrailerPrefix) {
+			res.trailers.Append(strings.TrimPrefix(k, connectTrailerPrefix), v...)
+			continue
+		}
+		res.headers.Append(k, v...)
+	}
+	b, err := io.ReadAll(hres.Body)
+	if err != nil {
+		return grpcHTTPErrorResponse(err)
+	}
+	if hres.StatusCode != http.StatusOK {
+		var e connectError
+		if err := json.Unmarshal(b, &e); err != nil || e.Code == "" {
+			res.status = status.New(httpStatusToCode(hres.StatusCode), hres.Status)
+			return res, nil
+		}
+		res.status = e.status()
+		return res, nil
+	}
+	m := dynamicpb.NewMessage(md.Output())
+	if err := rnr.unmarshalHTTPMessage(b, m); err != nil {
+		return nil, err
+	}
+	res.messages = append(res.messages, m)
+	res.status = status.New(codes.OK, "")
+	return res, nil
+}
+
+func (rnr *grpcRunner) invokeConnectStreaming(ctx context.Context, md protoreflect.MethodDescriptor, r *grpcRequest, reqs []proto.Message) (*grpcHTTPResponse, error) {
+	var body []byte
+	for _, req := range reqs {
+		b, err := rnr.marshalHTTPMessage(req)
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, encodeEnvelope(0, b)...)
+	}
+	hreq, err := rnr.newHTTPRequest(ctx, md, r, fmt.Sprintf("application/connect+%s", rnr.connectCodec()), body)
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Connect-Protocol-Version", "1")
+	if r.timeout > 0 {
+		hreq.Header.Set("Connect-Timeout-Ms", strconv.FormatInt(r.timeout.Milliseconds(), 10))
+	}
+	hres, err := rnr.hc.Do(hreq)
+	if err != nil {
+		return grpcHTTPErrorResponse(err)
+	}
+	defer hres.Body.Close()
+	res := &grpcHTTPResponse{
+		headers:  toMetadata(hres.Header),
+		trailers: metadata.MD{},
+	}
+	if hres.StatusCode != http.StatusOK {
+		res.status = status.New(httpStatusToCode(hres.StatusCode), hres.Status)
+		return res, nil
+	}
+	for {
+		flag, b, err := readEnvelope(hres.Body)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return grpcHTTPErrorResponse(err)
+		}
+		if flag&connectFlagEndStream != 0 {
+			var es connectEndStream
+			if err := json.Unmarshal(b, &es); err != nil {
+				return nil, fmt.Errorf("invalid end stream message: %w", err)
+			}
+			for k, v := range es.Metadata {
+				res.trailers.Append(k, v...)
+			}
+			if es.Error != nil {
+				res.status = es.Error.status()
+			} else {
+				res.status = status.New(codes.OK, "")
+			}
+			continue
+		}
+		if flag&grpcHTTPFlagCompressed != 0 {
+			return nil, errors.New("compressed messages are not supported")
+		}
+		m := dynamicpb.NewMessage(md.Output())
+		if err := rnr.unmarshalHTTPMessage(b, m); err != nil {
+			return nil, err
+		}
+		res.messages = append(res.messages, m)
+	}
+	if res.status == nil {
+		res.status = status.New(codes.Internal, "missing end stream message")
+	}
+	return res, nil
+}
+
+func (rnr *grpcRunner) newHTTPRequest(ctx context.Context, md protoreflect.MethodDescriptor, r *grpcRequest, contentType string, body []byte) (*http.Request, error) {
+	u := rnr.baseURL() + toEndpoint(md.FullName())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", fmt.Sprintf("runn/%s", version.Version))
+	if rnr.authority != "" {
+		req.Host = rnr.authority
+	}
+	for k, v := range perRPCCredentialsFromContext(ctx) {
+		req.Header.Set(k, v)
+	}
+	for k, v := range r.headers {
+		for _, vv := range v {
+			if strings.HasSuffix(k, "-bin") {
+				vv = base64.StdEncoding.EncodeToString([]byte(vv))
+			}
+			req.Header.Add(k, vv)
+		}
+	}
+	return req, nil
+}
+
+func (rnr *grpcRunner) connectCodec() string {
+	if rnr.codec == "" {
+		return grpcCodecProto
+	}
+	return rnr.codec
+}
+
+func (rnr *grpcRunner) marshalHTTPMessage(m proto.Message) ([]byte, error) {
+	if rnr.codec == grpcCodecJSON {
+		return protojson.Marshal(m)
+	}
+	return proto.Marshal(m)
+}
+
+func (rnr *grpcRunner) unmarshalHTTPMessage(b []byte, m proto.Message) error {
+	if rnr.codec == grpcCodecJSON {
+		return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(b, m)
+	}
+	return proto.Unmarshal(b, m)
+}
+
+func (e *connectError) status() *status.Status {
+	code, ok := connectCodes[e.Code]
+	if !ok {
+		code = codes.Unknown
+	}
+	sp := &spb.Status{
+		Code:    int32(code), //nolint:gosec
+		Message: e.Message,
+	}
+	for _, d := range e.Details {
+		v, err := decodeBinaryHeader(d.Value)
+		if err != nil {
+			continue
+		}
+		sp.Details = append(sp.Details, &anypb.Any{
+			TypeUrl: fmt.Sprintf("type.googleapis.com/%s", d.Type),
+			Value:   v,
+		})
+	}
+	return status.FromProto(sp)
+}
+
+// grpcHTTPErrorResponse converts the error of the HTTP request to the response with the status as native gRPC does.
+func grpcHTTPErrorResponse(err error) (*grpcHTTPResponse, error) {
+	var code codes.Code
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	default:
+		return nil, err
+	}
+	return &grpcHTTPResponse{
+		status:   status.New(code, err.Error()),
+		headers:  metadata.MD{},
+		trailers: metadata.MD{},
+	}, nil
+}
+
+func grpcStatusFromMetadata(md metadata.MD) (*status.Status, error) {
+	if v := md.Get(grpcStatusDetailsHeader); len(v) > 0 {
+		sp := &spb.Status{}
+		if err := proto.Unmarshal([]byte(v[0]), sp); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", grpcStatusDetailsHeader, err)
+		}
+		return status.FromProto(sp), nil
+	}
+	v := md.Get(grpcStatusHeader)
+	if len(v) == 0 {
+		return status.New(codes.Internal, "missing grpc-status"), nil
+	}
+	code, err := strconv.Atoi(v[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", grpcStatusHeader, v[0])
+	}
+	var msg string
+	if m := md.Get(grpcMessageHeader); len(m) > 0 {
+		msg, err = url.PathUnescape(m[0])
+		if err != nil {
+			msg = m[0]
+		}
+	}
+	return status.New(codes.Code(code), msg), nil //nolint:gosec
+}
+
+// httpStatusToCode maps the HTTP status to the gRPC code.
+// ref: https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md
+func httpStatusToCode(st int) codes.Code {
+	switch st {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
+
+func toMetadata(h http.Header) metadata.MD {
+	md := metadata.MD{}
+	for k, v := range h {
+		k = strings.ToLower(k)
+		for _, vv := range v {
+			if strings.HasSuffix(k, "-bin") {
+				if b, err := decodeBinaryHeader(vv); err == nil {
+					vv = string(b)
+				}
+			}
+			md.Append(k, vv)
+		}
+	}
+	return md
+}
+
+func parseGRPCWebTrailer(b []byte) metadata.MD {
+	h := http.Header{}
+	for _, l := range strings.Split(string(b), "\r\n") {
+		k, v, ok := strings.Cut(l, ":")
+		if !ok {
+			continue
+		}
+		h.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	return toMetadata(h)
+}
+
+func decodeBinaryHeader(v string) ([]byte, error) {
+	if len(v)%4 == 0 {
+		return base64.StdEncoding.DecodeString(v)
+	}
+	return base64.RawStdEncoding.DecodeString(v)
+}
+
+func encodeEnvelope(flag byte, b []byte) []byte {
+	e := make([]byte, 5, 5+len(b))
+	e[0] = flag
+	binary.BigEndian.PutUint32(e[1:], uint32(len(b))) //nolint:gosec
+	return append(e, b...)
+}
+
+// readEnvelope reads the length-prefixed message. It returns io.EOF when there is no more message.
+func readEnvelope(r io.Reader) (byte, []byte, error) {
+	prefix := make([]byte, 5)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil, errors.New("incomplete envelope")
+		}
+		return 0, nil, err
+	}
+	b := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	if _, err := io.ReadFull(r, b); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil, errors.New("incomplete envelope")
+		}
+		return 0, nil, err
+	}
+	return prefix[0], b, nil
+}
@@ -0,0 +1,343 @@
+package runn
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/google/go-cmp/cmp"
+	"github.com/k1LoW/donegroup"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestGrpcRunnerHTTPProtocols(t *testing.T) {
+	serving := &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}
+	notServing := &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING}
+	grpcWebHandler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/grpc-web+proto" || r.Header.Get("X-Grpc-Web") != "1" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		_, b, err := readEnvelope(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		req := &healthpb.HealthCheckRequest{}
+		if err := proto.Unmarshal(b, req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc-web+proto")
+		w.Header().Set("Hello", "header")
+		if req.GetService() == "unknown" {
+			w.Header().Set("Grpc-Status", fmt.Sprint(int(codes.NotFound)))
+			w.Header().Set("Grpc-Message", "unknown%20service")
+			return
+		}
+		res := []proto.Message{serving}
+		if r.URL.Path == "/grpc.health.v1.Health/Watch" {
+			res = append(res, notServing)
+		}
+		for _, m := range res {
+			b, _ := proto.Marshal(m)
+			_, _ = w.Write(encodeEnvelope(0, b))
+		}
+		_, _ = w.Write(encodeEnvelope(grpcWebFlagTrailer, []byte("grpc-status: 0\r\nhello: trailer\r\n")))
+	}
+	connectHandler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Connect-Protocol-Version") != "1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Hello", "header")
+		ct := r.Header.Get("Content-Type")
+		switch ct {
+		case "application/json", "application/proto":
+			b, _ := io.ReadAll(r.Body)
+			req := &healthpb.HealthCheckRequest{}
+			if ct == "application/json" {
+				_ = protojson.Unmarshal(b, req)
+			} else {
+				_ = proto.Unmarshal(b, req)
+			}
+			if req.GetService() == "unknown" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"code":"not_found","message":"unknown service"}`))
+				return
+			}
+			w.Header().Set("Content-Type", ct)
+			w.Header().Set("Trailer-Hello", "trailer")
+			if ct == "application/json" {
+				b, _ = protojson.Marshal(serving)
+			} else {
+				b, _ = proto.Marshal(serving)
+			}
+			_, _ = w.Write(b)
+		case "application/connect+proto":
+			w.Header().Set("Content-Type", ct)
+			for _, m := range []proto.Message{serving, notServing} {
+				b, _ := proto.Marshal(m)
+				_, _ = w.Write(encodeEnvelope(0, b))
+			}
+			_, _ = w.Write(encodeEnvelope(connectFlagEndStream, []byte(`{"metadata":{"hello":["trailer"]}}`)))
+		default:
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		protocol     string
+		codec        string
+		handler      http.HandlerFunc
+		method       string
+		service      string
+		wantStatus   any
+		wantMessages int
+		wantMessage  any
+		wantTrailers metadata.MD
+	}{
+		{"grpc-web unary", grpcProtocolGRPCWeb, "", grpcWebHandler, "Check", "", int(codes.OK), 1, map[string]any{"status": float64(1)}, metadata.MD{"hello": {"trailer"}}},
+		{"grpc-web server streaming", grpcProtocolGRPCWeb, "", grpcWebHandler, "Watch", "", int64(codes.OK), 2, map[string]any{"status": float64(2)}, metadata.MD{"hello": {"trailer"}}},
+		{"grpc-web trailers-only", grpcProtocolGRPCWeb, "", grpcWebHandler, "Check", "unknown", int(codes.NotFound), 0, "unknown service", metadata.MD{}},
+		{"connect unary proto", grpcProtocolConnect, "", connectHandler, "Check", "", int(codes.OK), 1, map[string]any{"status": float64(1)}, metadata.MD{"hello": {"trailer"}}},
+		{"connect unary json", grpcProtocolConnect, grpcCodecJSON, connectHandler, "Check", "", int(codes.OK), 1, map[string]any{"status": float64(1)}, metadata.MD{"hello": {"trailer"}}},
+		{"connect unary error", grpcProtocolConnect, grpcCodecJSON, connectHandler, "Check", "unknown", int(codes.NotFound), 0, "unknown service", metadata.MD{}},
+		{"connect server streaming", grpcProtocolConnect, "", connectHandler, "Watch", "", int64(codes.OK), 2, map[string]any{"status": float64(2)}, metadata.MD{"hello": {"trailer"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := donegroup.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			ts := httptest.NewServer(tt.handler)
+			t.Cleanup(ts.Close)
+			o, err := New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := newGrpcRunner("greq", ts.Listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTLS := false
+			r.tls = &useTLS
+			r.protocol = tt.protocol
+			r.codec = tt.codec
+			sd := healthpb.File_grpc_health_v1_health_proto.Services().ByName("Health")
+			for _, m := range []string{"Check", "Watch"} {
+				r.mds["grpc.health.v1.Health/"+m] = sd.Methods().ByName(protoreflect.Name(m))
+			}
+			req := &grpcRequest{
+				service: "grpc.health.v1.Health",
+				method:  tt.method,
+				headers: metadata.MD{},
+				messages: []*grpcMessage{
+					{op: GRPCOpMessage, params: map[string]any{"service": tt.service}},
+				},
+			}
+			s := newStep(0, "stepKey", o, nil)
+			if err := r.run(ctx, req, s); err != nil {
+				t.Fatal(err)
+			}
+			sm := o.store.ToMap()
+			sl, ok := sm["steps"].([]map[string]any)
+			if !ok {
+				t.Fatal("steps not found")
+			}
+			res, ok := sl[0]["res"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid steps res: %v", sl[0]["res"])
+			}
+			if diff := cmp.Diff(res["status"], tt.wantStatus); diff != "" {
+				t.Error(diff)
+			}
+			assertGRPCHTTPResponse(t, res, tt.wantStatus, tt.wantMessages, tt.wantMessage, tt.wantTrailers)
+		})
+	}
+}
+
+func TestGrpcRunnerHTTPProtocolsWithConnectHandler(t *testing.T) {
+	check := func(_ context.Context, req *connect.Request[healthpb.HealthCheckRequest]) (*connect.Response[healthpb.HealthCheckResponse], error) {
+		if req.Msg.GetService() == "unknown" {
+			err := connect.NewError(connect.CodeNotFound, errors.New("unknown service"))
+			err.Meta().Set("Hello", "header")
+			return nil, err
+		}
+		res := connect.NewResponse(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+		res.Header().Set("Hello", "header")
+		res.Trailer().Set("Hello", "trailer")
+		return res, nil
+	}
+	watch := func(_ context.Context, _ *connect.Request[healthpb.HealthCheckRequest], stream *connect.ServerStream[healthpb.HealthCheckResponse]) error {
+		stream.ResponseHeader().Set("Hello", "header")
+		stream.ResponseTrailer().Set("Hello", "trailer")
+		for _, st := range []healthpb.HealthCheckResponse_ServingStatus{healthpb.HealthCheckResponse_SERVING, healthpb.HealthCheckResponse_NOT_SERVING} {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/grpc.health.v1.Health/Check", connect.NewUnaryHandler("/grpc.health.v1.Health/Check", check))
+	mux.Handle("/grpc.health.v1.Health/Watch", connect.NewServerStreamHandler("/grpc.health.v1.Health/Watch", watch))
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	tests := []struct {
+		name         string
+		protocol     string
+		codec        string
+		method       string
+		service      string
+		wantStatus   any
+		wantMessages int
+		wantMessage  any
+		wantTrailers metadata.MD
+	}{
+		{"grpc-web unary", grpcProtocolGRPCWeb, "", "Check", "", int(codes.OK), 1, map[string]any{"status": float64(1)}, metadata.MD{"hello": {"trailer"}}},
+		{"grpc-web server streaming", grpcProtocolGRPCWeb, "", "Watch", "", int64(codes.OK), 2, map[string]any{"status": float64(2)}, metadata.MD{"hello": {"trailer"}}},
+		{"grpc-web unary error", grpcProtocolGRPCWeb, "", "Check", "unknown", int(codes.NotFound), 0, "unknown service", metadata.MD{}},
+		{"connect unary proto", grpcProtocolConnect, grpcCodecProto, "Check", "", int(codes.OK), 1, map[string]any{"status": float64(1)}, metadata.MD{"hello": {"trailer"}}},
+		{"connect unary json", grpcProtocolConnect, grpcCodecJSON, "Check", "", int(codes.OK), 1, map[string]any{"status": float64(1)}, metadata.MD{"hello": {"trailer"}}},
+		{"connect unary error proto", grpcProtocolConnect, grpcCodecProto, "Check", "unknown", int(codes.NotFound), 0, "unknown service", metadata.MD{}},
+		{"connect unary error json", grpcProtocolConnect, grpcCodecJSON, "Check", "unknown", int(codes.NotFound), 0, "unknown service", metadata.MD{}},
+		{"connect server streaming proto", grpcProtocolConnect, grpcCodecProto, "Watch", "", int64(codes.OK), 2, map[string]any{"status": float64(2)}, metadata.MD{"hello": {"trailer"}}},
+		{"connect server streaming json", grpcProtocolConnect, grpcCodecJSON, "Watch", "", int64(codes.OK), 2, map[string]any{"status": float64(2)}, metadata.MD{"hello": {"trailer"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := donegroup.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			o, err := New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := newGrpcRunner("greq", ts.Listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTLS := false
+			r.tls = &useTLS
+			r.protocol = tt.protocol
+			r.codec = tt.codec
+			sd := healthpb.File_grpc_health_v1_health_proto.Services().ByName("Health")
+			for _, m := range []string{"Check", "Watch"} {
+				r.mds["grpc.health.v1.Health/"+m] = sd.Methods().ByName(protoreflect.Name(m))
+			}
+			req := &grpcRequest{
+				service: "grpc.health.v1.Health",
+				method:  tt.method,
+				headers: metadata.MD{},
+				messages: []*grpcMessage{
+					{op: GRPCOpMessage, params: map[string]any{"service": tt.service}},
+				},
+			}
+			s := newStep(0, "stepKey", o, nil)
+			if err := r.run(ctx, req, s); err != nil {
+				t.Fatal(err)
+			}
+			sm := o.store.ToMap()
+			sl, ok := sm["steps"].([]map[string]any)
+			if !ok {
+				t.Fatal("steps not found")
+			}
+			res, ok := sl[0]["res"].(map[string]any)
+			if !ok {
+				t.Fatalf("invalid steps res: %v", sl[0]["res"])
+			}
+			assertGRPCHTTPResponse(t, res, tt.wantStatus, tt.wantMessages, tt.wantMessage, tt.wantTrailers)
+		})
+	}
+}
+
+func assertGRPCHTTPResponse(t *testing.T, res map[string]any, wantStatus any, wantMessages int, wantMessage any, wantTrailers metadata.MD) {
+	t.Helper()
+	if diff := cmp.Diff(res["status"], wantStatus); diff != "" {
+		t.Error(diff)
+	}
+	if _, unary := wantStatus.(int); unary && wantStatus != int(codes.OK) {
+		// Same layout as the native gRPC protocol
+		if _, ok := res["messages"]; ok {
+			t.Errorf("messages should not be recorded: %v", res["messages"])
+		}
+	} else if got := len(res["messages"].([]map[string]any)); got != wantMessages {
+		t.Errorf("got %v want %v", got, wantMessages)
+	}
+	if diff := cmp.Diff(res["message"], wantMessage); diff != "" {
+		t.Error(diff)
+	}
+	if got := res["headers"].(metadata.MD).Get("hello"); len(got) != 1 || got[0] != "header" {
+		t.Errorf("invalid headers: %v", res["headers"])
+	}
+	if diff := cmp.Diff(res["trailers"].(metadata.MD), wantTrailers); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestReadEnvelope(t *testing.T) {
+	b := slices.Concat(encodeEnvelope(0, []byte("hello")), encodeEnvelope(grpcWebFlagTrailer, []byte("grpc-status: 0\r\n")))
+	r := bytes.NewReader(b)
+	{
+		flag, got, err := readEnvelope(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if flag != 0 || string(got) != "hello" {
+			t.Errorf("got %v %q", flag, got)
+		}
+	}
+	{
+		flag, got, err := readEnvelope(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if flag != grpcWebFlagTrailer {
+			t.Errorf("got %v", flag)
+		}
+		if diff := cmp.Diff(parseGRPCWebTrailer(got), metadata.MD{"grpc-status": {"0"}}); diff != "" {
+			t.Error(diff)
+		}
+	}
+	if _, _, err := readEnvelope(r); err != io.EOF {
+		t.Errorf("got %v want io.EOF", err)
+	}
+	if _, _, err := readEnvelope(bytes.NewReader(b[:7])); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestValidateGRPCProtocol(t *testing.T) {
+	tests := []struct {
+		protocol string
+		codec    string
+		wantErr  bool
+	}{
+		{"", "", false},
+		{grpcProtocolGRPC, "", false},
+		{grpcProtocolGRPCWeb, grpcCodecProto, false},
+		{grpcProtocolConnect, grpcCodecJSON, false},
+		{grpcProtocolGRPCWeb, grpcCodecJSON, true},
+		{"http3", "", true},
+		{grpcProtocolConnect, "xml", true},
+	}
+	for _, tt := range tests {
+		if err := validateGRPCProtocol(tt.protocol, tt.codec); (err != nil) != tt.wantErr {
+			t.Errorf("%s/%s: got %v", tt.protocol, tt.codec, err)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package runn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const grpcInspectorRunnerName = "greq"
+
+// GRPCInspector inspects the services of the gRPC target using proto sources ( or buf ) or server reflection.
+type GRPCInspector struct {
+	rnr *grpcRunner
+}
+
+// NewGRPCInspector returns a new GRPCInspector for the target ( "grpc://host:port" or "host:port" ).
+// The gRPC options such as GRPCNoTLS, GRPCProtos and GRPCBufDir are applied to the inspector.
+func NewGRPCInspector(target string, opts ...Option) (*GRPCInspector, error) {
+	target = strings.TrimPrefix(target, "grpc://")
+	if target == "" {
+		return nil, errors.New("target is empty")
+	}
+	o, err := New(slices.Concat([]Option{GrpcRunnerWithOptions(grpcInspectorRunnerName, target)}, opts)...)
+	if err != nil {
+		return nil, err
+	}
+	rnr, ok := o.grpcRunners[grpcInspectorRunnerName]
+	if !ok {
+		return nil, fmt.Errorf("failed to create gRPC runner: %s", target)
+	}
+	return &GRPCInspector{rnr: rnr}, nil
+}
+
+// Close closes the connection to the target.
+func (i *GRPCInspector) Close() error {
+	return i.rnr.Close()
+}
+
+// Services returns the names of the services.
+func (i *GRPCInspector) Services(ctx context.Context) ([]string, error) {
+	if err := i.resolve(ctx); err != nil {
+		return nil, err
+	}
+	var svcs []string
+	for k := range i.rnr.mds {
+		svc, _, _ := strings.Cut(k, "/")
+		if !slices.Contains(svcs, svc) {
+			svcs = append(svcs, svc)
+		}
+	}
+	slices.Sort(svcs)
+	return svcs, nil
+}
+
+// Methods returns the names of the methods ( "package.Service/Method" ) of the service. If service is empty, it returns the methods of all services.
+func (i *GRPCInspector) Methods(ctx context.Context, service string) ([]string, error) {
+	if err := i.resolve(ctx); err != nil {
+		return nil, err
+	}
+	var methods []string
+	for k := range i.rnr.mds {
+		if service != "" && !strings.HasPrefix(k, service+"/") {
+			continue
+		}
+		methods = append(methods, k)
+	}
+	if service != "" && len(methods) == 0 {
+		return nil, fmt.Errorf("service not found: %s", service)
+	}
+	slices.Sort(methods)
+	return methods, nil
+}
+
+// Describe returns the description of the service, method or message as a YAML skeleton.
+// The description of a method contains the zero-valued request message that can be used in the runbook.
+func (i *GRPCInspector) Describe(ctx context.Context, symbol string) (yaml.MapSlice, error) {
+	if err := i.resolve(ctx); err != nil {
+		return nil, err
+	}
+	if md, ok := i.method(symbol); ok {
+		return yaml.MapSlice{
+			{Key: "method", Value: grpcMethodKey(md)},
+			{Key: "type", Value: string(grpcTypeOf(md))},
+			{Key: "request", Value: string(md.Input().FullName())},
+			{Key: "response", Value: string(md.Output().FullName())},
+			{Key: "message", Value: grpcMessageSkeleton(md.Input(), map[protoreflect.FullName]struct{}{})},
+		}, nil
+	}
+	d, err := i.findDescriptor(protoreflect.FullName(symbol))
+	if err != nil {
+		return nil, fmt.Errorf("symbol not found: %s", symbol)
+	}
+	switch d := d.(type) {
+	case protoreflect.ServiceDescriptor:
+		methods := []string{}
+		for j := range d.Methods().Len() {
+			methods = append(methods, grpcMethodKey(d.Methods().Get(j)))
+		}
+		return yaml.MapSlice{
+			{Key: "service", Value: string(d.FullName())},
+			{Key: "methods", Value: methods},
+		}, nil
+	case protoreflect.MessageDescriptor:
+		return yaml.MapSlice{
+			{Key: "message", Value: string(d.FullName())},
+			{Key: "fields", Value: grpcMessageSkeleton(d, map[protoreflect.FullName]struct{}{})},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported symbol: %s", symbol)
+	}
+}
+
+// HealthCheck calls grpc.health.v1.Health/Check and returns the serving status of the service.
+func (i *GRPCInspector) HealthCheck(ctx context.Context, service string) (string, error) {
+	if i.rnr.cc == nil {
+		if err := i.rnr.dial(); err != nil {
+			return "", err
+		}
+	}
+	res, err := healthpb.NewHealthClient(i.rnr.cc).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		return "", err
+	}
+	return res.GetStatus().String(), nil
+}
+
+// step returns the step of the method with the zero-valued request message.
+func (i *GRPCInspector) step(ctx context.Context, method string) (yaml.MapSlice, error) {
+	if err := i.resolve(ctx); err != nil {
+		return nil, err
+	}
+	md, ok := i.method(method)
+	if !ok {
+		return nil, fmt.Errorf("method not found: %s", method)
+	}
+	msg := grpcMessageSkeleton(md.Input(), map[protoreflect.FullName]struct{}{})
+	var hm yaml.MapSlice
+	switch grpcTypeOf(md) {
+	case GRPCClientStreaming:
+		hm = yaml.MapSlice{{Key: "messages", Value: []any{msg}}}
+	case GRPCBidiStreaming:
+		hm = yaml.MapSlice{{Key: "messages", Value: []any{msg, string(GRPCOpReceive), string(GRPCOpClose)}}}
+	default:
+		hm = yaml.MapSlice{{Key: "message", Value: msg}}
+	}
+	return yaml.MapSlice{{Key: grpcMethodKey(md), Value: hm}}, nil
+}
+
+func (i *GRPCInspector) resolve(ctx context.Context) error {
+	if i.rnr.cc == nil {
+		if err := i.rnr.dial(); err != nil {
+			return err
+		}
+	}
+	if len(i.rnr.mds) > 0 {
+		return nil
+	}
+	return i.rnr.resolveAllMethods(ctx)
+}
+
+// method returns the method descriptor of "package.Service/Method" or "package.Service.Method".
+func (i *GRPCInspector) method(name string) (protoreflect.MethodDescriptor, bool) {
+	if md, ok := i.rnr.mds[name]; ok {
+		return md, true
+	}
+	if idx := strings.LastIndex(name, "."); idx > 0 && !strings.Contains(name, "/") {
+		md, ok := i.rnr.mds[name[:idx]+"/"+name[idx+1:]]
+		return md, ok
+	}
+	return nil, false
+}
+
+func (i *GRPCInspector) findDescriptor(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if i.rnr.refc == nil {
+		return protoregistry.GlobalFiles.FindDescriptorByName(name)
+	}
+	return i.rnr.findDescripter(name)
+}
+
+func grpcMethodKey(md protoreflect.MethodDescriptor) string {
+	return fmt.Sprintf("%s/%s", md.Parent().FullName(), md.Name())
+}
+
+// grpcMessageSkeleton returns the zero-valued message of the descriptor in the JSON mapping used by runbooks.
+// Only the first field of each oneof is set, and repeated message fields have one element to show their structure.
+func grpcMessageSkeleton(md protoreflect.MessageDescriptor, visited map[protoreflect.FullName]struct{}) any {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		return "1970-01-01T00:00:00Z"
+	case "google.protobuf.Duration":
+		return "0s"
+	case "google.protobuf.FieldMask":
+		return ""
+	case "google.protobuf.Value":
+		return nil
+	case "google.protobuf.ListValue":
+		return []any{}
+	case "google.protobuf.Struct":
+		return yaml.MapSlice{}
+	case "google.protobuf.Any":
+		return yaml.MapSlice{{Key: "@type", Value: ""}}
+	}
+	if md.ParentFile() != nil && md.ParentFile().Package() == "google.protobuf" && strings.HasSuffix(string(md.Name()), "Value") && md.Fields().Len() == 1 {
+		// Wrapper types
+		return grpcScalarSkeleton(md.Fields().Get(0), visited)
+	}
+	if _, ok := visited[md.FullName()]; ok {
+		// Recursive message
+		return yaml.MapSlice{}
+	}
+	visited[md.FullName()] = struct{}{}
+	defer delete(visited, md.FullName())
+
+	ms := yaml.MapSlice{}
+	oneofs := map[protoreflect.FullName]struct{}{}
+	fields := md.Fields()
+	for j := range fields.Len() {
+		fd := fields.Get(j)
+		if od := fd.ContainingOneof(); od != nil && !od.IsSynthetic() {
+			if _, ok := oneofs[od.FullName()]; ok {
+				continue
+			}
+			oneofs[od.FullName()] = struct{}{}
+		}
+		var v any
+		switch {
+		case fd.IsMap():
+			v = yaml.MapSlice{}
+		case fd.IsList() && (fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind):
+			v = []any{grpcScalarSkeleton(fd, visited)}
+		case fd.IsList():
+			v = []any{}
+		default:
+			v = grpcScalarSkeleton(fd, visited)
+		}
+		ms = append(ms, yaml.MapItem{Key: string(fd.Name()), Value: v})
+	}
+	return ms
+}
+
+func grpcScalarSkeleton(fd protoreflect.FieldDescriptor, visited map[protoreflect.FullName]struct{}) any {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return grpcMessageSkeleton(fd.Message(), visited)
+	case protoreflect.EnumKind:
+		if fd.Enum().Values().Len() == 0 {
+			return 0
+		}
+		return string(fd.Enum().Values().Get(0).Name())
+	case protoreflect.BoolKind:
+		return false
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		return ""
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return 0.0
+	default:
+		return 0
+	}
+}
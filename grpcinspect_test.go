@@ -0,0 +1,220 @@
+package runn
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestGRPCInspector(t *testing.T) {
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	hs.SetServingStatus("grpc.health.v1.Health", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	reflection.Register(srv)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+	target := "grpc://" + lis.Addr().String()
+	ctx := context.Background()
+
+	i, err := NewGRPCInspector(target, GRPCNoTLS(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = i.Close()
+	})
+
+	t.Run("Services", func(t *testing.T) {
+		got, err := i.Services(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"grpc.health.v1.Health", "grpc.reflection.v1.ServerReflection", "grpc.reflection.v1alpha.ServerReflection"}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("Methods", func(t *testing.T) {
+		got, err := i.Methods(ctx, "grpc.health.v1.Health")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"grpc.health.v1.Health/Check", "grpc.health.v1.Health/List", "grpc.health.v1.Health/Watch"}
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Error(diff)
+		}
+		if _, err := i.Methods(ctx, "unknown.Service"); err == nil {
+			t.Error("want error")
+		}
+	})
+
+	t.Run("Describe", func(t *testing.T) {
+		tests := []struct {
+			symbol  string
+			want    string
+			wantErr bool
+		}{
+			{
+				"grpc.health.v1.Health/Watch",
+				`method: grpc.health.v1.Health/Watch
+type: server
+request: grpc.health.v1.HealthCheckRequest
+response: grpc.health.v1.HealthCheckResponse
+message:
+  service: ""
+`,
+				false,
+			},
+			{
+				"grpc.health.v1.Health.Check",
+				`method: grpc.health.v1.Health/Check
+type: unary
+request: grpc.health.v1.HealthCheckRequest
+response: grpc.health.v1.HealthCheckResponse
+message:
+  service: ""
+`,
+				false,
+			},
+			{
+				"grpc.health.v1.HealthCheckResponse",
+				`message: grpc.health.v1.HealthCheckResponse
+fields:
+  status: UNKNOWN
+`,
+				false,
+			},
+			{
+				"grpc.health.v1.Health",
+				`service: grpc.health.v1.Health
+methods:
+- grpc.health.v1.Health/Check
+- grpc.health.v1.Health/List
+- grpc.health.v1.Health/Watch
+`,
+				false,
+			},
+			{"unknown.Message", "", true},
+		}
+		for _, tt := range tests {
+			t.Run(tt.symbol, func(t *testing.T) {
+				d, err := i.Describe(ctx, tt.symbol)
+				if err != nil {
+					if !tt.wantErr {
+						t.Error(err)
+					}
+					return
+				}
+				if tt.wantErr {
+					t.Fatal("want error")
+				}
+				got, err := yaml.Marshal(d)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(string(got), tt.want); diff != "" {
+					t.Error(diff)
+				}
+			})
+		}
+	})
+
+	t.Run("HealthCheck", func(t *testing.T) {
+		tests := []struct {
+			service string
+			want    string
+			wantErr bool
+		}{
+			{"", "SERVING", false},
+			{"grpc.health.v1.Health", "NOT_SERVING", false},
+			{"unknown", "", true},
+		}
+		for _, tt := range tests {
+			got, err := i.HealthCheck(ctx, tt.service)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%q: got %v", tt.service, err)
+			}
+			if got != tt.want {
+				t.Errorf("%q: got %v want %v", tt.service, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("AppendGRPCStep", func(t *testing.T) {
+		rb := NewRunbook("gRPC")
+		if err := rb.AppendGRPCStep(ctx, target+"/grpc.health.v1.Health/Check", GRPCNoTLS(true)); err != nil {
+			t.Fatal(err)
+		}
+		if err := rb.AppendGRPCStep(ctx, target+"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", GRPCNoTLS(true)); err != nil {
+			t.Fatal(err)
+		}
+		if err := rb.AppendGRPCStep(ctx, target+"/grpc.health.v1.Health/Unknown", GRPCNoTLS(true)); err == nil {
+			t.Error("want error")
+		}
+		got, err := yaml.Marshal(rb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `desc: gRPC
+runners:
+  greq: ` + target + `
+steps:
+- greq:
+    grpc.health.v1.Health/Check:
+      message:
+        service: ""
+- greq:
+    grpc.reflection.v1.ServerReflection/ServerReflectionInfo:
+      messages:
+      - host: ""
+        file_by_filename: ""
+      - receive
+      - close
+`
+		if diff := cmp.Diff(string(got), want); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
+
+func TestGRPCMessageSkeleton(t *testing.T) {
+	tests := []struct {
+		name string
+		md   protoreflect.MessageDescriptor
+		want any
+	}{
+		{"Timestamp", (&timestamppb.Timestamp{}).ProtoReflect().Descriptor(), "1970-01-01T00:00:00Z"},
+		{"Struct", (&structpb.Struct{}).ProtoReflect().Descriptor(), yaml.MapSlice{}},
+		{"StringValue", (&wrapperspb.StringValue{}).ProtoReflect().Descriptor(), ""},
+		{"Int64Value", (&wrapperspb.Int64Value{}).ProtoReflect().Descriptor(), 0},
+		{"HealthCheckResponse", (&healthpb.HealthCheckResponse{}).ProtoReflect().Descriptor(), yaml.MapSlice{{Key: "status", Value: "UNKNOWN"}}},
+		{"HealthListResponse", (&healthpb.HealthListResponse{}).ProtoReflect().Descriptor(), yaml.MapSlice{{Key: "statuses", Value: yaml.MapSlice{}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := grpcMessageSkeleton(tt.md, map[protoreflect.FullName]struct{}{})
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package runn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const historyFile = "history.json"
+
+// History is the pass/fail history of the runbooks aggregated across runs.
+type History struct {
+	Runbooks map[string]*RunbookHistory `json:"runbooks"` // key is the runbook ID.
+}
+
+// RunbookHistory is the history of the runbook.
+type RunbookHistory struct {
+	ID      string    `json:"id"`
+	Path    string    `json:"path"`
+	Runs    int64     `json:"runs"`
+	Success int64     `json:"success"`
+	Failure int64     `json:"failure"`
+	Skipped int64     `json:"skipped"`
+	Flaky   int64     `json:"flaky"` // Number of the runs that passed after retries.
+	Last    result    `json:"last"`
+	LastRun time.Time `json:"last_run"`
+}
+
+// HistoryPath returns the path of the history file in the state directory.
+func HistoryPath() (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, historyFile), nil
+}
+
+// LoadHistory loads the history file. It returns the empty history if the file does not exist.
+func LoadHistory(p string) (*History, error) {
+	h := &History{Runbooks: map[string]*RunbookHistory{}}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return h, nil
+		}
+		return nil, fmt.Errorf("failed to read the history: %w", err)
+	}
+	if err := json.Unmarshal(b, h); err != nil {
+		return nil, fmt.Errorf("invalid history (%s): %w", p, err)
+	}
+	if h.Runbooks == nil {
+		h.Runbooks = map[string]*RunbookHistory{}
+	}
+	return h, nil
+}
+
+// Save writes the history to the file.
+func (h *History) Save(p string) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, b, 0o600)
+}
+
+// Lookup returns the history of the runbook.
+// The ID is matched first because the ID of the same runbook may change with the set of the loaded runbooks.
+func (h *History) Lookup(id, path string) *RunbookHistory {
+	if rh, ok := h.Runbooks[id]; ok {
+		return rh
+	}
+	np := normalizePath(path)
+	for _, rh := range h.Runbooks {
+		if rh.Path == path || rh.Path == np {
+			return rh
+		}
+	}
+	return nil
+}
+
+// IsFlaky reports whether the runbook has passed after retries or has both passed and failed.
+func (rh *RunbookHistory) IsFlaky() bool {
+	return rh.Flaky > 0 || (rh.Success > 0 && rh.Failure > 0)
+}
+
+// record adds the results of the run to the history.
+func (h *History) record(r *runNResult, at time.Time) {
+	for _, rr := range r.RunResults {
+		if rr.included {
+			continue
+		}
+		s := simplifyRunResult(rr)
+		rh := h.Lookup(s.ID, s.Path)
+		if rh == nil {
+			rh = &RunbookHistory{}
+		}
+		if rh.ID != s.ID {
+			// Re-key the history when the ID of the runbook has changed.
+			delete(h.Runbooks, rh.ID)
+			rh.ID = s.ID
+		}
+		h.Runbooks[s.ID] = rh
+		rh.Path = s.Path
+		rh.Runs++
+		switch s.Result {
+		case resultSuccess:
+			rh.Success++
+		case resultFailure:
+			rh.Failure++
+		case resultSkipped:
+			rh.Skipped++
+		}
+		if s.Flaky {
+			rh.Flaky++
+		}
+		rh.Last = s.Result
+		rh.LastRun = at
+	}
+}
+
+// SaveHistory adds the result to the history file in the state directory.
+func (r *runNResult) SaveHistory() error {
+	p, err := HistoryPath()
+	if err != nil {
+		return err
+	}
+	h, err := LoadHistory(p)
+	if err != nil {
+		return err
+	}
+	h.record(r, time.Now())
+	return h.Save(p)
+}
@@ -0,0 +1,78 @@
+package runn
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHistory(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "history.json")
+	runs := [][]*RunResult{
+		{
+			{ID: "a", Path: "a.yml"},
+			{ID: "b", Path: "b.yml"},
+			{ID: "c", Path: "c.yml"},
+		},
+		{
+			{ID: "a", Path: "a.yml", Err: errDummy},
+			{ID: "b", Path: "b.yml", Flaky: true, Attempts: []*RunResult{{ID: "b", Path: "b.yml", Err: errDummy}}},
+			// The ID of c.yml has changed.
+			{ID: "c2", Path: "c.yml"},
+			{ID: "d", Path: "d.yml", included: true},
+		},
+	}
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, rrs := range runs {
+		h, err := LoadHistory(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.record(&runNResult{RunResults: rrs}, at)
+		if err := h.Save(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, err := LoadHistory(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]*RunbookHistory{
+		"a":  {ID: "a", Path: "a.yml", Runs: 2, Success: 1, Failure: 1, Last: resultFailure, LastRun: at},
+		"b":  {ID: "b", Path: "b.yml", Runs: 2, Success: 2, Flaky: 1, Last: resultSuccess, LastRun: at},
+		"c2": {ID: "c2", Path: "c.yml", Runs: 2, Success: 2, Last: resultSuccess, LastRun: at},
+	}
+	if diff := cmp.Diff(want, h.Runbooks); diff != "" {
+		t.Error(diff)
+	}
+
+	tests := []struct {
+		id        string
+		path      string
+		wantFlaky bool
+		wantNil   bool
+	}{
+		{"a", "", true, false},
+		{"b", "", true, false},
+		{"unknown", "c.yml", false, false},
+		{"unknown", "d.yml", false, true},
+	}
+	for _, tt := range tests {
+		rh := h.Lookup(tt.id, tt.path)
+		if rh == nil {
+			if !tt.wantNil {
+				t.Errorf("want history of %s", tt.id)
+			}
+			continue
+		}
+		if tt.wantNil {
+			t.Errorf("got %v", rh)
+			continue
+		}
+		if got := rh.IsFlaky(); got != tt.wantFlaky {
+			t.Errorf("%s: got %v, want %v", tt.id, got, tt.wantFlaky)
+		}
+	}
+}
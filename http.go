@@ -57,6 +57,7 @@ type httpRunner struct {
 	cert              []byte
 	key               []byte
 	skipVerify        bool
+	hostRules         hostRules
 	useCookie         *bool
 	trace             *bool
 	traceHeaderName   string
@@ -11,6 +11,7 @@ import (
 	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/ast"
 	"github.com/expr-lang/expr/file"
+	"github.com/expr-lang/expr/parser"
 	"github.com/expr-lang/expr/parser/lexer"
 	"github.com/goccy/go-yaml"
 	"github.com/k1LoW/expand"
@@ -185,6 +186,166 @@ func EvalExpand(in any, store exprtrace.EvalEnv) (any, error) {
 	return out, nil
 }
 
+// ExpandPartially expands `{{ }}` in `in` like EvalExpand, but leaves `{{ }}` whose expression is not resolvable as it is.
+// It returns the expressions that are left.
+func ExpandPartially(in any, store exprtrace.EvalEnv, resolvable func(e string) bool) (any, []string, error) {
+	switch v := in.(type) {
+	case string:
+		return expandPartiallyString(v, store, resolvable)
+	case map[string]any:
+		var left []string
+		out := map[string]any{}
+		for k, vv := range v {
+			ek, l, err := expandPartiallyString(k, store, resolvable)
+			if err != nil {
+				return nil, nil, err
+			}
+			left = append(left, l...)
+			ev, l, err := ExpandPartially(vv, store, resolvable)
+			if err != nil {
+				return nil, nil, err
+			}
+			left = append(left, l...)
+			out[fmt.Sprintf("%v", ek)] = ev
+		}
+		return out, left, nil
+	case []any:
+		var left []string
+		out := make([]any, 0, len(v))
+		for _, vv := range v {
+			ev, l, err := ExpandPartially(vv, store, resolvable)
+			if err != nil {
+				return nil, nil, err
+			}
+			left = append(left, l...)
+			out = append(out, ev)
+		}
+		return out, left, nil
+	default:
+		return v, nil, nil
+	}
+}
+
+func expandPartiallyString(in string, store exprtrace.EvalEnv, resolvable func(e string) bool) (any, []string, error) {
+	var left []string
+	for _, e := range TemplateExprs(in) {
+		if !resolvable(e) {
+			left = append(left, e)
+		}
+	}
+	if len(left) == 0 {
+		if !strings.Contains(in, delimStart) {
+			return in, nil, nil
+		}
+		out, err := EvalExpand(in, store)
+		if err != nil {
+			return nil, nil, err
+		}
+		return out, nil, nil
+	}
+	repFn := expand.ExprRepFn(delimStart, delimEnd, store)
+	var sb strings.Builder
+	rest := in
+	for {
+		si := strings.Index(rest, delimStart)
+		if si < 0 {
+			break
+		}
+		ei := strings.Index(rest[si+len(delimStart):], delimEnd)
+		if ei < 0 {
+			break
+		}
+		ei += si + len(delimStart) + len(delimEnd)
+		sb.WriteString(rest[:si])
+		tmpl := rest[si:ei]
+		if resolvable(strings.TrimSpace(tmpl[len(delimStart) : len(tmpl)-len(delimEnd)])) {
+			rep, err := repFn(tmpl)
+			if err != nil {
+				return nil, nil, err
+			}
+			sb.WriteString(rep)
+		} else {
+			sb.WriteString(tmpl)
+		}
+		rest = rest[ei:]
+	}
+	sb.WriteString(rest)
+	return sb.String(), left, nil
+}
+
+// Reference is the reference to the member of the root value in the expression.
+type Reference struct {
+	// Root - The identifier of the root value (e.g. "vars" of `vars.foo`).
+	Root string
+	// Key - The key (string) or the index (int) of the member. nil if the root value itself is referenced.
+	Key any
+}
+
+// References parses the expression without evaluating it and returns the references to the root values.
+func References(e string) ([]Reference, error) {
+	tree, err := parser.Parse(trimDeprecatedComment(e))
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+	v := &referenceVisitor{members: map[*ast.IdentifierNode]struct{}{}}
+	ast.Walk(&tree.Node, v)
+	refs := v.refs
+	for _, id := range v.idents {
+		if _, ok := v.members[id]; ok {
+			continue
+		}
+		refs = append(refs, Reference{Root: id.Value})
+	}
+	return refs, nil
+}
+
+type referenceVisitor struct {
+	refs    []Reference
+	idents  []*ast.IdentifierNode
+	members map[*ast.IdentifierNode]struct{}
+}
+
+// Visit implements ast.Visitor interface.
+func (v *referenceVisitor) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.IdentifierNode:
+		v.idents = append(v.idents, n)
+	case *ast.MemberNode:
+		id, ok := n.Node.(*ast.IdentifierNode)
+		if !ok {
+			return
+		}
+		switch p := n.Property.(type) {
+		case *ast.StringNode:
+			v.refs = append(v.refs, Reference{Root: id.Value, Key: p.Value})
+		case *ast.IntegerNode:
+			v.refs = append(v.refs, Reference{Root: id.Value, Key: p.Value})
+		default:
+			return
+		}
+		v.members[id] = struct{}{}
+	}
+}
+
+// TemplateExprs returns the expressions in `{{ }}` of `in`.
+func TemplateExprs(in string) []string {
+	var exprs []string
+	for {
+		si := strings.Index(in, delimStart)
+		if si < 0 {
+			break
+		}
+		in = in[si+len(delimStart):]
+		ei := strings.Index(in, delimEnd)
+		if ei < 0 {
+			break
+		}
+		exprs = append(exprs, strings.TrimSpace(in[:ei]))
+		in = in[ei+len(delimEnd):]
+	}
+	return exprs
+}
+
 func trimDeprecatedComment(cond string) string {
 	const commentToken = "#"
 	s := file.NewSource(cond)
@@ -2,6 +2,7 @@ package expr
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -815,3 +816,98 @@ func TestEvalExpand(t *testing.T) {
 		})
 	}
 }
+
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []Reference
+		wantErr bool
+	}{
+		{"vars.foo == 'bar'", []Reference{{Root: "vars", Key: "foo"}}, false},
+		{"vars['foo'].bar && steps[2].res.status == 200", []Reference{{Root: "vars", Key: "foo"}, {Root: "steps", Key: 2}}, false},
+		{"steps.login.res.status == 200", []Reference{{Root: "steps", Key: "login"}}, false},
+		{"len(vars) > steps[i]", []Reference{{Root: "vars"}, {Root: "steps"}, {Root: "i"}}, false},
+		{"current.res.status ==", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := References(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			// Ignore the root values other than the ones of the test
+			var filtered []Reference
+			for _, r := range got {
+				if r.Root == "vars" || r.Root == "steps" || r.Root == "i" {
+					filtered = append(filtered, r)
+				}
+			}
+			if diff := cmp.Diff(filtered, tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestTemplateExprs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"hello", nil},
+		{"/users/{{ vars.id }}", []string{"vars.id"}},
+		{"{{ steps[0].res.body.id }}-{{vars.suffix}}", []string{"steps[0].res.body.id", "vars.suffix"}},
+		{"{{ unclosed", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got := TemplateExprs(tt.in)
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestExpandPartially(t *testing.T) {
+	store := map[string]any{
+		"vars": map[string]any{
+			"id":   3,
+			"host": "example.com",
+		},
+	}
+	resolvable := func(e string) bool {
+		return strings.HasPrefix(e, "vars.")
+	}
+	tests := []struct {
+		in       any
+		want     any
+		wantLeft []string
+	}{
+		{"hello", "hello", nil},
+		{"{{ vars.id }}", uint64(3), nil},
+		{"/users/{{ vars.id }}", "/users/3", nil},
+		{"{{ steps[0].res.body.token }}", "{{ steps[0].res.body.token }}", []string{"steps[0].res.body.token"}},
+		{"Bearer {{ token }} for {{ vars.host }}", "Bearer {{ token }} for example.com", []string{"token"}},
+		{
+			map[string]any{"/users/{{ vars.id }}": []any{"{{ vars.host }}", "{{ steps[0].id }}"}},
+			map[string]any{"/users/3": []any{"example.com", "{{ steps[0].id }}"}},
+			[]string{"steps[0].id"},
+		},
+	}
+	for _, tt := range tests {
+		got, left, err := ExpandPartially(tt.in, store, resolvable)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff(tt.wantLeft, left); diff != "" {
+			t.Error(diff)
+		}
+	}
+}
@@ -22,59 +22,81 @@ import (
 var intRe = regexp.MustCompile(`^\-?[0-9]+$`)
 var floatRe = regexp.MustCompile(`^\-?[0-9.]+$`)
 
+// rerunFailedLast is the value of --rerun-failed to rerun the runbooks that failed in the last run.
+const rerunFailedLast = "last"
+
 type Flags struct {
-	Debug           bool     `usage:"debug"`
-	Long            bool     `usage:"long format"`
-	FailFast        bool     `usage:"fail fast"`
-	SkipTest        bool     `usage:"skip \"test:\" section"`
-	SkipIncluded    bool     `usage:"skip running the included runbook by itself"`
-	RunMatch        string   `usage:"run all runbooks with a matching file path, treating the value passed to the option as an unanchored regular expression"`
-	RunIDs          []string `usage:"run the matching runbooks in order if there is only one runbook with a forward matching ID"`
-	RunLabels       []string `usage:"run all runbooks matching the label specification"`
-	HTTPOpenApi3s   []string `usage:"set the path to the OpenAPI v3 document for HTTP runners (\"path/to/spec.yml\" or \"key:path/to/spec.yml\")"`
-	GRPCNoTLS       bool     `usage:"disable TLS use in all gRPC runners"`
-	GRPCProtos      []string `usage:"set the name of proto source for gRPC runners"`
-	GRPCImportPaths []string `usage:"set the path to the directory where proto sources can be imported for gRPC runners"`
-	GRPCBufDirs     []string `usage:"set the path to the buf directory for gRPC runners"`
-	GRPCBufLocks    []string `usage:"set the path to buf.lock for gRPC runners"`
-	GRPCBufConfigs  []string `usage:"set the path to buf.yaml for gRPC runners"`
-	GRPCBufModules  []string `usage:"set the buf modules for gRPC runners (\"buf.build/owner/repository\" or \"buf.build/owner/repository/tree/branch-or-commit\")"`
-	CaptureDir      string   `usage:"destination of runbook run capture results"`
-	Vars            []string `usage:"set var to runbook (\"key:value\")"`
-	Runners         []string `usage:"set runner to runbook (\"key:dsn\")"`
-	Overlays        []string `usage:"overlay values on the runbook"`
-	Underlays       []string `usage:"lay values under the runbook"`
-	Sample          int      `usage:"sample the specified number of runbooks"`
-	Shuffle         string   `usage:"randomize the order of running runbooks (\"on\",\"off\",N)"`
-	Concurrent      string   `usage:"run runbooks concurrently (\"on\",\"off\",N)"`
-	ShardIndex      int      `usage:"index of distributed runbooks"`
-	ShardN          int      `usage:"number of shards for distributing runbooks"`
-	Random          int      `usage:"run the specified number of runbooks at random"`
-	Desc            string   `usage:"description of runbook"`
-	Out             string   `usage:"target path of runbook"`
-	Format          string   `usage:"format of result output"`
-	AndRun          bool     `usage:"run created runbook and capture the response for test"`
-	LoadTConcurrent int      `usage:"number of concurrent load test runs. 0 means unlimited"`
-	LoadTDuration   string   `usage:"load test running duration"`
-	LoadTWarmUp     string   `usage:"warn-up time for load test"`
-	LoadTThreshold  string   `usage:"if this threshold condition is not met, loadt command returns exit status 1 (EXIT_FAILURE)"`
-	LoadTMaxRPS     int      `usage:"max RunN per second for load test. 0 means unlimited"`
-	Profile         bool     `usage:"profile runs of runbooks"`
-	ProfileOut      string   `usage:"profile output path"`
-	ProfileDepth    int      `usage:"depth of profile"`
-	ProfileUnit     string   `usage:"-"`
-	ProfileSort     string   `usage:"-"`
-	Attach          bool     `usage:"attach to runn process"`
-	CacheDir        string   `usage:"specify cache directory for remote runbooks"`
-	RetainCacheDir  bool     `usage:"retain cache directory for remote runbooks"`
-	Scopes          []string `usage:"additional scopes for runn"`
-	HostRules       []string `usage:"host rules for runn. (\"host rule,host rule,...\")"`
-	WaitTimeout     string   `usage:"timeout for waiting for cleanup process after running runbooks"`
-	EnvFile         string   `usage:"load environment variables from a file"`
-	ForceColor      bool     `usage:"force colorized output even in non-tty output streams"`
-	Verbose         bool     `usage:"verbose"`
-	Coverage        bool     `usage:"coverage for OpenAPI spec and protocol buffers"`
-	CoverageOut     string   `usage:"coverage output path (JSON format)"`
+	Debug             bool     `usage:"debug"`
+	Long              bool     `usage:"long format"`
+	FailFast          bool     `usage:"fail fast"`
+	Retries           int      `usage:"retry a failed runbook up to the specified number of times (\"retry:\" of the runbook takes precedence)"`
+	History           bool     `usage:"record the results of the run to the history file in the state directory (see runn list --flaky)"`
+	Flaky             bool     `usage:"list only the runbooks that are flaky in the history of the runs"`
+	SkipTest          bool     `usage:"skip \"test:\" section"`
+	SkipIncluded      bool     `usage:"skip running the included runbook by itself"`
+	RunMatch          string   `usage:"run all runbooks with a matching file path, treating the value passed to the option as an unanchored regular expression"`
+	RerunFailed       string   `usage:"rerun only the runbooks that failed in the previous run. Specify the result file of --format json, or 'last' for the result of the last run"`
+	RunIDs            []string `usage:"run the matching runbooks in order if there is only one runbook with a forward matching ID"`
+	RunLabels         []string `usage:"run all runbooks matching the label specification"`
+	HTTPOpenApi3s     []string `usage:"set the path to the OpenAPI v3 document for HTTP runners (\"path/to/spec.yml\" or \"key:path/to/spec.yml\")"`
+	GRPCNoTLS         bool     `usage:"disable TLS use in all gRPC runners"`
+	GRPCProtos        []string `usage:"set the name of proto source for gRPC runners"`
+	GRPCImportPaths   []string `usage:"set the path to the directory where proto sources can be imported for gRPC runners"`
+	GRPCBufDirs       []string `usage:"set the path to the buf directory for gRPC runners"`
+	GRPCBufLocks      []string `usage:"set the path to buf.lock for gRPC runners"`
+	GRPCBufConfigs    []string `usage:"set the path to buf.yaml for gRPC runners"`
+	GRPCBufModules    []string `usage:"set the buf modules for gRPC runners (\"buf.build/owner/repository\" or \"buf.build/owner/repository/tree/branch-or-commit\")"`
+	CaptureDir        string   `usage:"destination of runbook run capture results"`
+	Vars              []string `usage:"set var to runbook (\"key:value\")"`
+	Runners           []string `usage:"set runner to runbook (\"key:dsn\")"`
+	Overlays          []string `usage:"overlay values on the runbook"`
+	Underlays         []string `usage:"lay values under the runbook"`
+	Sample            int      `usage:"sample the specified number of runbooks"`
+	Shuffle           string   `usage:"randomize the order of running runbooks (\"on\",\"off\",N)"`
+	Concurrent        string   `usage:"run runbooks concurrently (\"on\",\"off\",N)"`
+	ShardIndex        int      `usage:"index of distributed runbooks"`
+	ShardN            int      `usage:"number of shards for distributing runbooks"`
+	Random            int      `usage:"run the specified number of runbooks at random"`
+	Desc              string   `usage:"description of runbook"`
+	Out               string   `usage:"target path of runbook"`
+	Format            string   `usage:"format of result output"`
+	DryRun            bool     `usage:"print the plan of the run (selected runbooks and expanded steps) as YAML (or JSON with --format json) without running"`
+	Watch             bool     `usage:"watch the runbooks and the files they depend on, and rerun the affected runbooks on changes"`
+	AndRun            bool     `usage:"run created runbook and capture the response for test"`
+	LoadTConcurrent   int      `usage:"number of concurrent load test runs. 0 means unlimited"`
+	LoadTDuration     string   `usage:"load test running duration"`
+	LoadTWarmUp       string   `usage:"warn-up time for load test"`
+	LintFormat        string   `usage:"format of lint result output (text, json or sarif)"`
+	GraphFormat       string   `usage:"format of dependency graph output (dot, mermaid or json)"`
+	LoadTThreshold    string   `usage:"if this threshold condition is not met, loadt command returns exit status 1 (EXIT_FAILURE)"`
+	LoadTMaxRPS       int      `usage:"max RunN per second for load test. 0 means unlimited"`
+	LoadTProfile      string   `usage:"load profile file (YAML) declaring the executor, the stages and the weights of runbooks. If set, --load-concurrent, --max-rps, --duration and --warm-up are ignored"`
+	LoadTFormat       string   `usage:"format of load test result output (text, json or csv)"`
+	LoadTTimeSeries   string   `usage:"write the time series of the load test results per second (RPS, latencies, errors and active RunNs) to the file as JSON Lines"`
+	LoadTMetricsAddr  string   `usage:"address to expose the load test results as Prometheus metrics at /metrics while the test runs (e.g. :9090)"`
+	LoadTWorkers      []string `usage:"addresses of workers (\"host:port\") to run distributed load test. The load is split among the workers"`
+	LoadTWorkerListen string   `usage:"address for the worker to listen on"`
+	LoadTWorkerToken  string   `usage:"shared token to authenticate the coordinator of distributed load test with the workers (or RUNN_LOADT_WORKER_TOKEN env)"`
+	Profile           bool     `usage:"profile runs of runbooks"`
+	ProfileOut        string   `usage:"profile output path"`
+	ProfileDepth      int      `usage:"depth of profile"`
+	ProfileUnit       string   `usage:"-"`
+	ProfileSort       string   `usage:"-"`
+	Attach            bool     `usage:"attach to runn process"`
+	DAP               string   `usage:"start the Debug Adapter Protocol server on the address (e.g. :4711) and wait for a DAP client"`
+	UpdateBaselines   bool     `usage:"update baseline images of compareScreenshot with captured screenshots"`
+	CacheDir          string   `usage:"specify cache directory for remote runbooks"`
+	RetainCacheDir    bool     `usage:"retain cache directory for remote runbooks"`
+	Scopes            []string `usage:"additional scopes for runn"`
+	HostRules         []string `usage:"host rules for runn. (\"host rule,host rule,...\")"`
+	WaitTimeout       string   `usage:"timeout for waiting for cleanup process after running runbooks"`
+	EnvFile           string   `usage:"load environment variables from a file"`
+	ForceColor        bool     `usage:"force colorized output even in non-tty output streams"`
+	Verbose           bool     `usage:"verbose"`
+	Coverage          bool     `usage:"coverage for OpenAPI spec and protocol buffers"`
+	CoverageOut       string   `usage:"coverage output path (JSON format)"`
+	ReportOut         string   `usage:"HTML report output path (run results, coverage and profile with --profile)"`
+	CoverageThreshold string   `usage:"if this coverage threshold condition is not met, the command returns exit status 1 (EXIT_FAILURE)"`
 }
 
 func (f *Flags) ToOpts() ([]runn.Option, error) {
@@ -105,6 +127,8 @@ func (f *Flags) ToOpts() ([]runn.Option, error) {
 		runn.RunLabel(f.RunLabels...),
 		runn.FailFast(f.FailFast),
 		runn.Attach(f.Attach),
+		runn.DAP(f.DAP),
+		runn.UpdateBaselines(f.UpdateBaselines),
 	}
 
 	// STDIN
@@ -126,6 +150,20 @@ func (f *Flags) ToOpts() ([]runn.Option, error) {
 	if f.RunMatch != "" {
 		opts = append(opts, runn.RunMatch(f.RunMatch))
 	}
+	if f.Retries > 0 {
+		opts = append(opts, runn.Retries(f.Retries))
+	}
+	if f.RerunFailed != "" {
+		p := f.RerunFailed
+		if p == rerunFailedLast {
+			lp, err := runn.LastResultPath()
+			if err != nil {
+				return nil, err
+			}
+			p = lp
+		}
+		opts = append(opts, runn.RerunFailed(p))
+	}
 	if f.Sample > 0 {
 		opts = append(opts, runn.RunSample(f.Sample))
 	}
@@ -142,6 +180,9 @@ func (f *Flags) ToOpts() ([]runn.Option, error) {
 			opts = append(opts, runn.RunShuffle(true, seed))
 		}
 	}
+	if f.Attach && f.DAP != "" {
+		return nil, errors.New("cannot use --dap with --attach")
+	}
 	if f.Concurrent != "" {
 		if f.Attach && f.Concurrent != off {
 			return nil, errors.New("cannot use --concurrent with --attach")
@@ -18,6 +18,7 @@ import (
 	"github.com/k1LoW/runn/internal/expr"
 	"github.com/k1LoW/runn/internal/kv"
 	"github.com/mattn/go-isatty"
+	"github.com/mitchellh/copystructure"
 	"github.com/samber/lo"
 	"github.com/spf13/cast"
 )
@@ -440,6 +441,38 @@ func (s *Store) ClearSteps() {
 	s.loopIndex = nil
 }
 
+// ClearStep removes the record of the step so that the step can be run again.
+func (s *Store) ClearStep(idx int) {
+	delete(s.stepList, idx)
+}
+
+// Snapshot is the snapshot of the values recorded in the store.
+type Snapshot struct {
+	stepList map[int]map[string]any
+	vars     map[string]any
+	bindVars map[string]any
+	cookies  map[string]map[string]*http.Cookie
+}
+
+// Snapshot takes the snapshot of the records of the steps, vars, bind variables and cookies.
+func (s *Store) Snapshot() *Snapshot {
+	return &Snapshot{
+		stepList: copyValue(s.stepList),
+		vars:     copyValue(s.vars),
+		bindVars: copyValue(s.bindVars),
+		cookies:  copyValue(s.cookies),
+	}
+}
+
+// Restore restores the store to the snapshot.
+func (s *Store) Restore(snap *Snapshot) {
+	// Copy again so that the snapshot can be restored multiple times
+	s.stepList = copyValue(snap.stepList)
+	s.vars = copyValue(snap.vars)
+	s.bindVars = copyValue(snap.bindVars)
+	s.cookies = copyValue(snap.cookies)
+}
+
 func (s *Store) SetMaskRule(mr *maskedio.Rule) {
 	s.mr = mr
 }
@@ -715,3 +748,16 @@ func mergeMapAny(org map[any]any, vars map[any]any) map[any]any {
 	}
 	return store
 }
+
+// copyValue returns the deep copy of the value. If the value cannot be copied, it returns the value as it is.
+func copyValue[T any](v T) T {
+	c, err := copystructure.Copy(v)
+	if err != nil {
+		return v
+	}
+	cc, ok := c.(T)
+	if !ok {
+		return v
+	}
+	return cc
+}
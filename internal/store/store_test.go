@@ -752,3 +752,30 @@ func TestMergeVars(t *testing.T) {
 		})
 	}
 }
+
+func TestSnapshot(t *testing.T) {
+	s := New(map[string]any{"token": "a"}, nil, nil, nil)
+	s.Record(0, map[string]any{"res": map[string]any{"status": 200}})
+	if err := s.SetBindVar("user", map[string]any{"name": "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	snap := s.Snapshot()
+	want := copyValue(s.ToMapForDbg())
+
+	s.Record(0, map[string]any{"res": map[string]any{"status": 500}})
+	s.Record(1, map[string]any{"res": map[string]any{"status": 404}})
+	s.SetVar("token", "b")
+	if err := s.RecordBindVar(`user["name"]`, `"bob"`, s.ToMap()); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		s.Restore(snap)
+		got := s.ToMapForDbg()
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Error(diff)
+		}
+		// Modify the restored values to check that the snapshot is not modified
+		s.vars["token"] = "c"
+	}
+}
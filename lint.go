@@ -0,0 +1,803 @@
+package runn
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/goccy/go-yaml"
+	"github.com/k1LoW/runn/internal/expr"
+	"github.com/k1LoW/runn/internal/fs"
+	"github.com/k1LoW/runn/internal/store"
+	"github.com/k1LoW/runn/version"
+	"github.com/samber/lo"
+)
+
+const (
+	// LintSeverityError is the severity of the lint issue that makes the runbook fail to load or run.
+	LintSeverityError = "error"
+	// LintSeverityWarning is the severity of the lint issue that does not make the runbook fail.
+	LintSeverityWarning = "warning"
+)
+
+const (
+	lintRuleInvalidRunbook  = "invalid-runbook"
+	lintRuleInvalidStep     = "invalid-step"
+	lintRuleUndefinedRunner = "undefined-runner"
+	lintRuleUndefinedVar    = "undefined-var"
+	lintRuleInvalidExpr     = "invalid-expr"
+	lintRuleInvalidStepRef  = "invalid-step-ref"
+	lintRuleUnusedVar       = "unused-var"
+	lintRuleMissingInclude  = "missing-include"
+	lintRuleNeedsCycle      = "needs-cycle"
+)
+
+type lintRule struct {
+	id       string
+	severity string
+	desc     string
+}
+
+var lintRules = []lintRule{
+	{lintRuleInvalidRunbook, LintSeverityError, "The runbook cannot be parsed or loaded."},
+	{lintRuleInvalidStep, LintSeverityError, "The step has invalid keys or sections."},
+	{lintRuleUndefinedRunner, LintSeverityError, "The step uses a runner that is not defined."},
+	{lintRuleUndefinedVar, LintSeverityError, "The expression refers to a var that is not defined."},
+	{lintRuleInvalidExpr, LintSeverityError, "The expression has a syntax error."},
+	{lintRuleInvalidStepRef, LintSeverityError, "The expression refers to a step that does not exist or has not run yet."},
+	{lintRuleUnusedVar, LintSeverityWarning, "The var is not used in the runbook."},
+	{lintRuleMissingInclude, LintSeverityError, "The included runbook is not found."},
+	{lintRuleNeedsCycle, LintSeverityError, "The runbooks of needs: refer to each other."},
+}
+
+// LintIssue is the issue found by Lint.
+type LintIssue struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Path     string `json:"path"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message"`
+}
+
+// LintIssues is the list of LintIssue.
+type LintIssues []*LintIssue
+
+// String returns the issue in the form of `path:line: severity: message (rule)`.
+func (i *LintIssue) String() string {
+	loc := i.Path
+	if i.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", i.Path, i.Line)
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", loc, i.Severity, i.Message, i.Rule)
+}
+
+// HasError returns whether the issues contain the issue of LintSeverityError.
+func (issues LintIssues) HasError() bool {
+	return slices.ContainsFunc(issues, func(i *LintIssue) bool {
+		return i.Severity == LintSeverityError
+	})
+}
+
+// Out writes the issues line by line.
+func (issues LintIssues) Out(out io.Writer) error {
+	for _, i := range issues {
+		if _, err := fmt.Fprintln(out, i.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OutJSON writes the issues as JSON.
+func (issues LintIssues) OutJSON(out io.Writer) error {
+	if issues == nil {
+		issues = LintIssues{}
+	}
+	b, err := json.MarshalIndentWithOption(issues, "", "  ", json.DisableHTMLEscape())
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(out, string(b)); err != nil {
+		return err
+	}
+	return nil
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// OutSARIF writes the issues as SARIF v2.1.0 for code scanning.
+func (issues LintIssues) OutSARIF(out io.Writer) error {
+	d := sarifDriver{
+		Name:           "runn",
+		Version:        version.Version,
+		InformationURI: "https://github.com/k1LoW/runn",
+	}
+	for _, r := range lintRules {
+		d.Rules = append(d.Rules, sarifRule{
+			ID:                   r.id,
+			ShortDescription:     sarifMessage{Text: r.desc},
+			DefaultConfiguration: sarifConfiguration{Level: r.severity},
+		})
+	}
+	run := sarifRun{Tool: sarifTool{Driver: d}, Results: []sarifResult{}}
+	for _, i := range issues {
+		l := sarifLocation{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(i.Path)},
+			},
+		}
+		if i.Line > 0 {
+			l.PhysicalLocation.Region = &sarifRegion{StartLine: i.Line}
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    i.Rule,
+			Level:     i.Severity,
+			Message:   sarifMessage{Text: i.Message},
+			Locations: []sarifLocation{l},
+		})
+	}
+	b, err := json.MarshalIndentWithOption(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}, "", "  ", json.DisableHTMLEscape())
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(out, string(b)); err != nil {
+		return err
+	}
+	return nil
+}
+
+type linter struct {
+	opts   []Option
+	issues LintIssues
+	// includedVars - The vars passed to the included runbooks by `include.vars:` (key is the path of the included runbook).
+	includedVars map[string]map[string]struct{}
+	// includedRunners - The runners of the runbooks that include the runbook (key is the path of the included runbook).
+	includedRunners map[string]map[string]struct{}
+	// includedBy - The runbooks that include the runbook (key is the path of the included runbook).
+	includedBy map[string][]*lintBook
+	// needs - The cache of the paths of `needs:` of the runbooks (key is the path of the runbook).
+	needs map[string][]string
+}
+
+type lintBook struct {
+	path  string
+	lines []string
+	areas *areas
+	bk    *book
+	op    *operator
+	root  string
+	vars  map[string]any
+	used  map[string]struct{}
+	// usedAll is true if the vars are referenced as a whole (e.g. `dump: vars`).
+	usedAll bool
+}
+
+// Lint loads the runbooks of the path pattern with LoadOnly and reports the problems of the runbooks without running them.
+func Lint(pathp string, opts ...Option) (LintIssues, error) {
+	paths, err := fs.FetchPaths(pathp)
+	if err != nil {
+		return nil, err
+	}
+	l := &linter{
+		opts:            opts,
+		includedVars:    map[string]map[string]struct{}{},
+		includedRunners: map[string]map[string]struct{}{},
+		includedBy:      map[string][]*lintBook{},
+		needs:           map[string][]string{},
+	}
+	var lbs []*lintBook
+	for _, p := range paths {
+		lb := l.load(p)
+		if lb == nil {
+			continue
+		}
+		lbs = append(lbs, lb)
+	}
+	for _, lb := range lbs {
+		l.collectIncluded(lb)
+	}
+	for _, lb := range lbs {
+		l.lintSteps(lb)
+		l.lintNeedsCycle(lb)
+	}
+	// Vars can be used by the included runbooks
+	for _, lb := range lbs {
+		l.lintUnusedVars(lb)
+	}
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		if l.issues[i].Path != l.issues[j].Path {
+			return l.issues[i].Path < l.issues[j].Path
+		}
+		if l.issues[i].Line != l.issues[j].Line {
+			return l.issues[i].Line < l.issues[j].Line
+		}
+		return l.issues[i].Message < l.issues[j].Message
+	})
+	return l.issues, nil
+}
+
+func (l *linter) report(rule, path string, line int, format string, a ...any) {
+	msg := fmt.Sprintf(format, a...)
+	for _, i := range l.issues {
+		if i.Rule == rule && i.Path == path && i.Line == line && i.Message == msg {
+			return
+		}
+	}
+	i := &LintIssue{
+		Rule:     rule,
+		Severity: LintSeverityError,
+		Path:     path,
+		Line:     line,
+		Message:  msg,
+	}
+	for _, r := range lintRules {
+		if r.id == rule {
+			i.Severity = r.severity
+		}
+	}
+	l.issues = append(l.issues, i)
+}
+
+// load parses the runbook and loads it with LoadOnly. It returns nil if the runbook cannot be parsed.
+func (l *linter) load(p string) *lintBook {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		l.report(lintRuleInvalidRunbook, p, 0, "%v", err)
+		return nil
+	}
+	var v any
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		var yerr yaml.Error
+		if errors.As(err, &yerr) && yerr.GetToken() != nil {
+			l.report(lintRuleInvalidRunbook, p, yerr.GetToken().Position.Line, "%s", yerr.GetMessage())
+			return nil
+		}
+		l.report(lintRuleInvalidRunbook, p, 0, "%v", err)
+		return nil
+	}
+	rb, err := parseRunbook(b)
+	if err != nil {
+		l.report(lintRuleInvalidRunbook, p, 0, "%v", err)
+		return nil
+	}
+	bk, err := rb.toBook()
+	if err != nil {
+		l.report(lintRuleInvalidRunbook, p, 0, "%v", err)
+		return nil
+	}
+	bk.path = p
+	root, err := bk.generateOperatorRoot()
+	if err != nil {
+		l.report(lintRuleInvalidRunbook, p, 0, "%v", err)
+		return nil
+	}
+	lb := &lintBook{
+		path:  p,
+		lines: strings.Split(string(b), "\n"),
+		areas: detectRunbookAreas(string(b)),
+		bk:    bk,
+		root:  root,
+		vars:  bk.vars,
+		used:  map[string]struct{}{},
+	}
+	valid := true
+	for i, s := range bk.rawSteps {
+		if err := validateStepKeys(s); err != nil {
+			l.report(lintRuleInvalidStep, p, lb.stepLine(i, ""), "invalid %s: %v", lb.stepName(i), err)
+			valid = false
+		}
+	}
+	if !valid {
+		// New fails on the invalid step keys even with LoadOnly
+		return lb
+	}
+	op, err := New(append([]Option{Book(p), LoadOnly()}, l.opts...)...)
+	if err != nil {
+		l.report(lintRuleInvalidRunbook, p, 0, "%v", err)
+		return lb
+	}
+	lb.op = op
+	if vars, ok := op.store.ToMap()[store.RootKeyVars].(map[string]any); ok {
+		lb.vars = vars
+	}
+	return lb
+}
+
+// collectIncluded collects the vars and the runners passed to the runbooks included by the runbook.
+func (l *linter) collectIncluded(lb *lintBook) {
+	for _, s := range lb.bk.rawSteps {
+		v, ok := s[includeRunnerKey]
+		if !ok {
+			continue
+		}
+		c, err := parseIncludeConfig(v)
+		if err != nil {
+			continue
+		}
+		p, err := fs.Path(c.path, lb.root)
+		if err != nil {
+			continue
+		}
+		p = filepath.Clean(p)
+		if _, ok := l.includedVars[p]; !ok {
+			l.includedVars[p] = map[string]struct{}{}
+			l.includedRunners[p] = map[string]struct{}{}
+		}
+		for k := range c.vars {
+			l.includedVars[p][k] = struct{}{}
+		}
+		l.includedBy[p] = append(l.includedBy[p], lb)
+		// The included runbook can use the runners of the parent runbook
+		for k := range lb.bk.runners {
+			l.includedRunners[p][k] = struct{}{}
+		}
+	}
+}
+
+func (l *linter) lintSteps(lb *lintBook) {
+	p := lb.path
+	// Runbook level sections are evaluated before all steps
+	if lb.bk.ifCond != "" {
+		l.lintExpr(lb, -1, -1, ifSectionKey, lb.bk.ifCond, lb.topLevelLine(ifSectionKey))
+	}
+	if lb.bk.loop != nil && lb.bk.loop.Until != "" {
+		l.lintExpr(lb, -1, len(lb.bk.rawSteps)-1, "loop.until", lb.bk.loop.Until, lb.topLevelLine(loopSectionKey))
+	}
+	l.lintTemplates(lb, -1, -1, lb.bk.runners, lb.topLevelLine("runners"))
+	l.lintTemplates(lb, -1, -1, lb.bk.vars, lb.topLevelLine("vars"))
+	for _, sec := range lb.bk.secrets {
+		l.lintExpr(lb, -1, -1, "secrets", sec, lb.topLevelLine("secrets"))
+	}
+
+	runners := map[string]struct{}{}
+	for k := range lb.bk.runners {
+		runners[k] = struct{}{}
+	}
+	for k := range l.includedRunners[filepath.Clean(p)] {
+		runners[k] = struct{}{}
+	}
+	// Runners that are registered without errors
+	loaded := map[string]struct{}{}
+	if lb.op != nil {
+		for _, keys := range [][]string{
+			lo.Keys(lb.op.httpRunners),
+			lo.Keys(lb.op.dbRunners),
+			lo.Keys(lb.op.grpcRunners),
+			lo.Keys(lb.op.cdpRunners),
+			lo.Keys(lb.op.sshRunners),
+			lo.Keys(lb.op.includeRunners),
+		} {
+			for _, k := range keys {
+				runners[k] = struct{}{}
+				loaded[k] = struct{}{}
+			}
+		}
+	}
+
+	for i, s := range lb.bk.rawSteps {
+		if err := validateStepKeys(s); err != nil {
+			continue
+		}
+		// Deferred steps run after all steps
+		before := i - 1
+		after := i
+		if v, ok := s[deferSectionKey].(bool); ok && v {
+			before = len(lb.bk.rawSteps) - 1
+			after = before
+		}
+		var runnerKey string
+		for k, v := range s {
+			line := lb.stepLine(i, k)
+			switch k {
+			case ifSectionKey:
+				if c, ok := v.(string); ok {
+					l.lintExpr(lb, i, before, k, c, line)
+				}
+			case testRunnerKey:
+				if c, ok := v.(string); ok {
+					l.lintExpr(lb, i, after, k, c, line)
+				}
+			case dumpRunnerKey:
+				switch vv := v.(type) {
+				case string:
+					l.lintExpr(lb, i, after, k, vv, line)
+				case map[string]any:
+					if e, ok := vv["expr"].(string); ok {
+						l.lintExpr(lb, i, after, k, e, line)
+					}
+				}
+			case bindRunnerKey:
+				l.lintBindExprs(lb, i, after, v, line)
+			case loopSectionKey:
+				lp, err := newLoop(v)
+				if err != nil {
+					break
+				}
+				if lp.Count != "" {
+					l.lintExpr(lb, i, before, "loop.count", lp.Count, line)
+				}
+				if lp.Until != "" {
+					l.lintExpr(lb, i, after, "loop.until", lp.Until, line)
+				}
+			case descSectionKey, deferSectionKey, forceSectionKey:
+			default:
+				runnerKey = k
+				l.lintTemplates(lb, i, before, v, line)
+			}
+		}
+		if runnerKey == "" {
+			continue
+		}
+		line := lb.stepLine(i, runnerKey)
+		switch runnerKey {
+		case includeRunnerKey:
+			l.lintInclude(lb, i, s[runnerKey], line)
+		case execRunnerKey:
+		case runnerRunnerKey:
+			// Runners defined by `runner:` can be used in the following steps
+			if d, ok := s[runnerKey].(map[string]any); ok {
+				for k := range d {
+					runners[k] = struct{}{}
+					loaded[k] = struct{}{}
+				}
+			}
+		default:
+			if _, ok := runners[runnerKey]; !ok {
+				l.report(lintRuleUndefinedRunner, p, line, "%s uses undefined runner %q", lb.stepName(i), runnerKey)
+				continue
+			}
+			if _, ok := loaded[runnerKey]; !ok {
+				// The runner failed to be registered (e.g. connection error) or is passed by the parent runbook
+				continue
+			}
+		}
+		if lb.op != nil {
+			// Check the step sections in the same way as loading without LoadOnly
+			c, ok := dcopy(s).(map[string]any)
+			if !ok {
+				continue
+			}
+			if err := lb.op.appendStep(i, lb.stepKey(i), c); err != nil {
+				l.report(lintRuleInvalidStep, p, lb.stepLine(i, ""), "invalid %s: %v", lb.stepName(i), err)
+			}
+		}
+	}
+}
+
+func (l *linter) lintBindExprs(lb *lintBook, idx, limit int, v any, line int) {
+	switch vv := v.(type) {
+	case string:
+		l.lintExpr(lb, idx, limit, bindRunnerKey, vv, line)
+	case map[string]any:
+		for _, e := range vv {
+			l.lintBindExprs(lb, idx, limit, e, line)
+		}
+	case []any:
+		for _, e := range vv {
+			l.lintBindExprs(lb, idx, limit, e, line)
+		}
+	}
+}
+
+// lintTemplates lints the expressions in `{{ }}` of the values.
+func (l *linter) lintTemplates(lb *lintBook, idx, limit int, v any, line int) {
+	switch vv := v.(type) {
+	case string:
+		for _, e := range expr.TemplateExprs(vv) {
+			l.lintExpr(lb, idx, limit, "{{ }}", e, line)
+		}
+	case map[string]any:
+		for k, e := range vv {
+			l.lintTemplates(lb, idx, limit, k, line)
+			l.lintTemplates(lb, idx, limit, e, line)
+		}
+	case []any:
+		for _, e := range vv {
+			l.lintTemplates(lb, idx, limit, e, line)
+		}
+	}
+}
+
+// lintExpr lints the expression of the section of steps[idx] (idx is -1 for the runbook level sections).
+// limit is the max index of the steps that have run when the expression is evaluated.
+func (l *linter) lintExpr(lb *lintBook, idx, limit int, section, e string, line int) {
+	p := lb.path
+	where := section
+	if idx >= 0 {
+		where = fmt.Sprintf("%s of %s", section, lb.stepName(idx))
+	}
+	refs, err := expr.References(e)
+	if err != nil {
+		// The error of the parser has the position of the expression on the following lines
+		msg, _, _ := strings.Cut(err.Error(), "\n")
+		l.report(lintRuleInvalidExpr, p, line, "invalid expression in %s: %s", where, msg)
+		return
+	}
+	for _, r := range refs {
+		switch r.Root {
+		case store.RootKeyVars:
+			k, ok := r.Key.(string)
+			if !ok {
+				lb.usedAll = true
+				continue
+			}
+			lb.used[k] = struct{}{}
+			if _, ok := lb.vars[k]; ok {
+				continue
+			}
+			if _, ok := l.includedVars[filepath.Clean(p)][k]; ok {
+				continue
+			}
+			l.report(lintRuleUndefinedVar, p, line, "%s refers to undefined var %q", where, k)
+		case store.RootKeySteps:
+			l.lintStepRef(lb, limit, where, r.Key, line)
+		case store.RootKeyParent:
+			for _, plb := range l.includedBy[filepath.Clean(p)] {
+				plb.usedAll = true
+			}
+		}
+	}
+}
+
+func (l *linter) lintStepRef(lb *lintBook, limit int, where string, key any, line int) {
+	p := lb.path
+	var (
+		idx = -1
+		ref string
+	)
+	switch k := key.(type) {
+	case int:
+		ref = fmt.Sprintf("steps[%d]", k)
+		if lb.bk.useMap || k < 0 {
+			return
+		}
+		idx = k
+		if idx >= len(lb.bk.rawSteps) {
+			l.report(lintRuleInvalidStepRef, p, line, "%s refers to %s that does not exist", where, ref)
+			return
+		}
+	case string:
+		ref = fmt.Sprintf("steps.%s", k)
+		if lb.bk.useMap {
+			idx = slices.Index(lb.bk.stepKeys, k)
+		}
+		if idx < 0 {
+			l.report(lintRuleInvalidStepRef, p, line, "%s refers to %s that does not exist", where, ref)
+			return
+		}
+	default:
+		return
+	}
+	if idx > limit {
+		l.report(lintRuleInvalidStepRef, p, line, "%s refers to %s that has not run yet", where, ref)
+	}
+}
+
+func (l *linter) lintInclude(lb *lintBook, idx int, v any, line int) {
+	c, err := parseIncludeConfig(v)
+	if err != nil {
+		// Reported as the invalid step
+		return
+	}
+	if strings.Contains(c.path, "{{") {
+		return
+	}
+	ip, err := fs.Path(c.path, lb.root)
+	if err != nil {
+		l.report(lintRuleMissingInclude, lb.path, line, "%s includes %s: %v", lb.stepName(idx), c.path, err)
+		return
+	}
+	if strings.Contains(ip, "://") {
+		return
+	}
+	if _, err := os.Stat(ip); err != nil {
+		l.report(lintRuleMissingInclude, lb.path, line, "%s includes %s that is not found", lb.stepName(idx), c.path)
+	}
+}
+
+func (l *linter) lintUnusedVars(lb *lintBook) {
+	if lb.usedAll {
+		return
+	}
+	vl := lb.topLevelLine("vars")
+	for k := range lb.bk.vars {
+		if _, ok := lb.used[k]; ok {
+			continue
+		}
+		line := vl
+		if lb.areas.Vars != nil {
+			line = lb.keyLine(lb.areas.Vars.Start.Line+1, lb.areas.Vars.End.Line, k, vl)
+		}
+		l.report(lintRuleUnusedVar, lb.path, line, "var %q is not used", k)
+	}
+}
+
+// lintNeedsCycle reports the cycle of `needs:` that goes back to the runbook.
+func (l *linter) lintNeedsCycle(lb *lintBook) {
+	start := filepath.Clean(lb.path)
+	visited := map[string]struct{}{}
+	var walk func(p string, trail []string) []string
+	walk = func(p string, trail []string) []string {
+		for _, n := range l.needsOf(p) {
+			if n == start {
+				return append(trail, n)
+			}
+			if _, ok := visited[n]; ok {
+				continue
+			}
+			visited[n] = struct{}{}
+			if c := walk(n, append(trail, n)); c != nil {
+				return c
+			}
+		}
+		return nil
+	}
+	if c := walk(start, []string{start}); c != nil {
+		l.report(lintRuleNeedsCycle, lb.path, lb.topLevelLine("needs"), "cycle of needs: %s", strings.Join(c, " -> "))
+	}
+}
+
+// needsOf returns the paths of `needs:` of the runbook.
+func (l *linter) needsOf(p string) []string {
+	if n, ok := l.needs[p]; ok {
+		return n
+	}
+	l.needs[p] = nil
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil
+	}
+	rb, err := parseRunbook(b)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, np := range rb.Needs {
+		pp, err := fs.Path(np, filepath.Dir(p))
+		if err != nil || strings.Contains(pp, "://") {
+			continue
+		}
+		paths = append(paths, filepath.Clean(pp))
+	}
+	sort.Strings(paths)
+	l.needs[p] = paths
+	return paths
+}
+
+func (lb *lintBook) stepKey(idx int) string {
+	if lb.bk.useMap {
+		return lb.bk.stepKeys[idx]
+	}
+	return fmt.Sprintf("%d", idx)
+}
+
+func (lb *lintBook) stepName(idx int) string {
+	if lb.bk.useMap {
+		return fmt.Sprintf("steps.%s", lb.bk.stepKeys[idx])
+	}
+	return fmt.Sprintf("steps[%d]", idx)
+}
+
+// stepLine returns the line of the key in steps[idx]. It returns the first line of the step if the key is empty or not found.
+func (lb *lintBook) stepLine(idx int, key string) int {
+	if idx >= len(lb.areas.Steps) {
+		return 0
+	}
+	a := lb.areas.Steps[idx]
+	if key == "" {
+		return a.Start.Line
+	}
+	start := a.Start.Line
+	if lb.bk.useMap {
+		// Skip the line of the key of the step
+		start++
+	}
+	return lb.keyLine(start, a.End.Line, key, a.Start.Line)
+}
+
+// lintKeyRe matches the line of a key of a mapping. The first submatch is the indentation including the sequence indicator.
+var lintKeyRe = regexp.MustCompile(`^(\s*(?:-\s+)?)["']?[^\s#"'-][^:]*["']?\s*:(\s|$)`)
+
+// topLevelLine returns the line of the top level key.
+func (lb *lintBook) topLevelLine(key string) int {
+	re := regexp.MustCompile(`^` + regexp.QuoteMeta(key) + `\s*:`)
+	for i, line := range lb.lines {
+		if re.MatchString(line) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// keyLine returns the first line of the key between the start and end lines. It returns def if not found.
+// Only the keys at the indentation of the first key between the lines ( the children of the mapping ) are matched.
+func (lb *lintBook) keyLine(start, end int, key string, def int) int {
+	re := regexp.MustCompile(`^(\s*(?:-\s+)?)["']?` + regexp.QuoteMeta(key) + `["']?\s*:`)
+	indent := -1
+	for i := start; i <= end && i <= len(lb.lines); i++ {
+		if i <= 0 {
+			continue
+		}
+		line := lb.lines[i-1]
+		if indent < 0 {
+			m := lintKeyRe.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			indent = len(m[1])
+		}
+		if m := re.FindStringSubmatch(line); m != nil && len(m[1]) == indent {
+			return i
+		}
+	}
+	return def
+}
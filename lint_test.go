@@ -0,0 +1,95 @@
+package runn
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		pathp string
+		want  LintIssues
+	}{
+		{"testdata/book/always_success.yml", nil},
+		{
+			"testdata/lint/issues.yml",
+			LintIssues{
+				{Rule: "unused-var", Severity: "warning", Path: "testdata/lint/issues.yml", Line: 6, Message: `var "unused" is not used`},
+				{Rule: "undefined-runner", Severity: "error", Path: "testdata/lint/issues.yml", Line: 15, Message: `steps[1] uses undefined runner "unknown"`},
+				{Rule: "invalid-step-ref", Severity: "error", Path: "testdata/lint/issues.yml", Line: 20, Message: "if of steps[2] refers to steps[3] that has not run yet"},
+				{Rule: "undefined-var", Severity: "error", Path: "testdata/lint/issues.yml", Line: 21, Message: `dump of steps[2] refers to undefined var "undefined"`},
+				{Rule: "invalid-expr", Severity: "error", Path: "testdata/lint/issues.yml", Line: 23, Message: "invalid expression in test of steps[3]: parse error: unexpected token EOF (1:21)"},
+				{Rule: "missing-include", Severity: "error", Path: "testdata/lint/issues.yml", Line: 25, Message: "steps[4] includes not_found.yml that is not found"},
+				{Rule: "invalid-step", Severity: "error", Path: "testdata/lint/issues.yml", Line: 26, Message: "invalid steps[5]: runners that cannot be running at the same time are specified"},
+				{Rule: "invalid-step-ref", Severity: "error", Path: "testdata/lint/issues.yml", Line: 34, Message: "test of steps[6] refers to steps[10] that does not exist"},
+			},
+		},
+		{
+			// The nested keys with the same name are not reported
+			"testdata/lint/nested.yml",
+			LintIssues{
+				{Rule: "unused-var", Severity: "warning", Path: "testdata/lint/nested.yml", Line: 7, Message: `var "unused" is not used`},
+				{Rule: "undefined-var", Severity: "error", Path: "testdata/lint/nested.yml", Line: 16, Message: `dump of steps.create refers to undefined var "undefined"`},
+			},
+		},
+		{
+			"testdata/lint/needs_a.yml",
+			LintIssues{
+				{Rule: "needs-cycle", Severity: "error", Path: "testdata/lint/needs_a.yml", Line: 2, Message: "cycle of needs: testdata/lint/needs_a.yml -> testdata/lint/needs_b.yml -> testdata/lint/needs_a.yml"},
+			},
+		},
+		{
+			// The runners of the parent runbook can be used in the included runbook
+			"testdata/book/db_connection.yml:testdata/book/db_connection_included.yml",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pathp, func(t *testing.T) {
+			got, err := Lint(tt.pathp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestLintIssuesOut(t *testing.T) {
+	issues := LintIssues{
+		{Rule: "unused-var", Severity: "warning", Path: "testdata/lint/issues.yml", Line: 6, Message: `var "unused" is not used`},
+		{Rule: "invalid-runbook", Severity: "error", Path: "testdata/book/invalid.yml", Message: "invalid host rule"},
+	}
+	if issues.HasError() != true {
+		t.Error("want error")
+	}
+	if issues[:1].HasError() != false {
+		t.Error("want no error")
+	}
+
+	buf := new(bytes.Buffer)
+	if err := issues.Out(buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `testdata/lint/issues.yml:6: warning: var "unused" is not used (unused-var)
+testdata/book/invalid.yml: error: invalid host rule (invalid-runbook)
+`
+	if diff := cmp.Diff(buf.String(), want); diff != "" {
+		t.Error(diff)
+	}
+
+	buf.Reset()
+	if err := issues.OutSARIF(buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"version": "2.1.0"`, `"ruleId": "unused-var"`, `"level": "warning"`, `"startLine": 6`, `"uri": "testdata/book/invalid.yml"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("got %s, want to contain %s", buf.String(), want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package runn
 import (
 	"fmt"
 	"io"
+	"text/tabwriter"
 	"text/template"
 	"time"
 
@@ -44,6 +45,21 @@ type loadtResult struct {
 	p90          float64
 	p50          float64
 	avg          float64
+	executor     string
+	dropped      int64
+	stages       []*loadtStageResult
+	runbooks     []*loadtRunbookResult
+	steps        map[string]*loadtResult
+	hist         *loadtHistogram // hist is the histogram of the latencies to merge the results of workers
+}
+
+// loadtStageResult is the result of the stage of the load profile.
+type loadtStageResult struct {
+	*loadtResult
+	name    string
+	from    float64
+	to      float64
+	dropped int64
 }
 
 // NewLoadtResult creates a new load test result with the provided parameters.
@@ -100,6 +116,57 @@ func NewLoadtResult(rc int, w, d time.Duration, c, m int, r *or.Result) (*loadtR
 		p90:          p90,
 		p50:          p50,
 		avg:          avg,
+		hist:         newLoadtHistogramFromResult(r),
+	}, nil
+}
+
+// loadtRunbookResult is the result of each runbook of the load test.
+type loadtRunbookResult struct {
+	*loadtResult
+	path  string
+	steps []*loadtStepResult
+}
+
+// loadtStepResult is the result of each step of the runbook of the load test.
+type loadtStepResult struct {
+	*loadtResult
+	key  string
+	desc string
+}
+
+// CollectBreakdown collects the latencies of each runbook and each step recorded while running the load test by opn.
+func (r *loadtResult) CollectBreakdown(opn *operatorN) error {
+	if opn.breakdown == nil {
+		return nil
+	}
+	runbooks, steps := opn.breakdown.aggregate()
+	r.runbooks = nil
+	for _, rb := range runbooks {
+		lrr := &loadtRunbookResult{loadtResult: newLoadtResultFromHistogram(1, r.warmUp, r.duration, 0, 0, rb.hist), path: rb.path}
+		for _, s := range rb.steps {
+			lrr.steps = append(lrr.steps, &loadtStepResult{loadtResult: newLoadtResultFromHistogram(1, r.warmUp, r.duration, 0, 0, s.hist), key: s.key, desc: s.desc})
+		}
+		r.runbooks = append(r.runbooks, lrr)
+	}
+	r.steps = map[string]*loadtResult{}
+	for k, h := range steps {
+		r.steps[k] = newLoadtResultFromHistogram(1, r.warmUp, r.duration, 0, 0, h)
+	}
+	return nil
+}
+
+// newLoadtResultOrEmpty creates a new load test result like NewLoadtResult, but returns an empty result instead of an error if there are no requests.
+func newLoadtResultOrEmpty(rc int, w, d time.Duration, c, m int, r *or.Result) (*loadtResult, error) {
+	if r.Succeeded()+r.Failed() > 0 {
+		return NewLoadtResult(rc, w, d, c, m, r)
+	}
+	return &loadtResult{
+		runbookCount: int64(rc),
+		warmUp:       w,
+		duration:     d,
+		concurrent:   int64(c),
+		maxRPS:       int64(m),
+		hist:         newLoadtHistogram(),
 	}, nil
 }
 
@@ -130,14 +197,93 @@ func (r *loadtResult) Report(w io.Writer) error {
 	if err := tmpl.Execute(w, data); err != nil {
 		return err
 	}
-	return nil
+	if err := r.reportStages(w); err != nil {
+		return err
+	}
+	return r.reportBreakdown(w)
 }
 
-func (r *loadtResult) CheckThreshold(threshold string) error {
-	if threshold == "" {
+func (r *loadtResult) reportStages(w io.Writer) error {
+	if len(r.stages) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "Executor.......................: %s\nDropped........................: %d\n\n", r.executor, r.dropped); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "Stage\tDuration\tFrom\tTo\tTotal\tFailed\tDropped\tError rate\tRunN per second\tavg\tmed\tp(90)\tp(99)\tmax"); err != nil {
+		return err
+	}
+	for _, s := range r.stages {
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s%%\t%s\t%sms\t%sms\t%sms\t%sms\t%sms\n",
+			s.name,
+			s.duration.String(),
+			humanize.CommafWithDigits(s.from, 1),
+			humanize.CommafWithDigits(s.to, 1),
+			s.total,
+			s.failed,
+			s.dropped,
+			humanize.CommafWithDigits(s.errorRate, 1),
+			humanize.CommafWithDigits(s.rps, 1),
+			humanize.CommafWithDigits(s.avg*1000, 1),
+			humanize.CommafWithDigits(s.p50*1000, 1),
+			humanize.CommafWithDigits(s.p90*1000, 1),
+			humanize.CommafWithDigits(s.p99*1000, 1),
+			humanize.CommafWithDigits(s.max*1000, 1),
+		); err != nil {
+			return err
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+func (r *loadtResult) reportBreakdown(w io.Writer) error {
+	if len(r.runbooks) == 0 {
 		return nil
 	}
-	store := map[string]any{
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "Runbook / Step\tDescription\tTotal\tFailed\tavg\tmin\tmed\tp(90)\tp(99)\tmax"); err != nil {
+		return err
+	}
+	row := func(name, desc string, lr *loadtResult) error {
+		_, err := fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%sms\t%sms\t%sms\t%sms\t%sms\t%sms\n",
+			name,
+			desc,
+			lr.total,
+			lr.failed,
+			humanize.CommafWithDigits(lr.avg*1000, 1),
+			humanize.CommafWithDigits(lr.min*1000, 1),
+			humanize.CommafWithDigits(lr.p50*1000, 1),
+			humanize.CommafWithDigits(lr.p90*1000, 1),
+			humanize.CommafWithDigits(lr.p99*1000, 1),
+			humanize.CommafWithDigits(lr.max*1000, 1),
+		)
+		return err
+	}
+	for _, rb := range r.runbooks {
+		if err := row(rb.path, "", rb.loadtResult); err != nil {
+			return err
+		}
+		for _, s := range rb.steps {
+			if err := row("  "+s.key, s.desc, s.loadtResult); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// thresholdStore returns the variables of the result for the threshold. Latencies are in milliseconds.
+func (r *loadtResult) thresholdStore() map[string]any {
+	return map[string]any{
 		"total":      r.total,
 		"succeeded":  r.succeeded,
 		"failed":     r.failed,
@@ -150,6 +296,38 @@ func (r *loadtResult) CheckThreshold(threshold string) error {
 		"p99":        r.p99 * 1000,
 		"avg":        r.avg * 1000,
 	}
+}
+
+func (r *loadtResult) CheckThreshold(threshold string) error {
+	if threshold == "" {
+		return nil
+	}
+	store := r.thresholdStore()
+	store["dropped"] = r.dropped
+	var stages []any
+	for _, s := range r.stages {
+		v := s.thresholdStore()
+		v["name"] = s.name
+		v["dropped"] = s.dropped
+		stages = append(stages, v)
+	}
+	store["stages"] = stages
+	runbooks := map[string]any{}
+	for _, rb := range r.runbooks {
+		v := rb.thresholdStore()
+		steps := map[string]any{}
+		for _, s := range rb.steps {
+			steps[s.key] = s.thresholdStore()
+		}
+		v["steps"] = steps
+		runbooks[rb.path] = v
+	}
+	store["runbooks"] = runbooks
+	steps := map[string]any{}
+	for k, s := range r.steps {
+		steps[k] = s.thresholdStore()
+	}
+	store["steps"] = steps
 	tf, err := expr.EvalWithTrace(threshold, store)
 	if err != nil {
 		return err
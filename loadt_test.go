@@ -1,11 +1,14 @@
 package runn
 
 import (
+	"bytes"
 	"context"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/k1LoW/runn/internal/scope"
 	"github.com/k1LoW/runn/testutil"
 	"github.com/ryo-yamaoka/otchkiss"
@@ -127,3 +130,137 @@ func TestCheckThreshold(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadtBreakdown(t *testing.T) {
+	hs := testutil.HTTPServer(t)
+	t.Setenv("TEST_HTTP_ENDPOINT", hs.URL)
+	opn, err := Load("testdata/book/loadt_steps.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := 200 * time.Millisecond
+	s, err := setting.New(2, 0, d, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ot, err := otchkiss.FromConfig(opn, s, 100_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ot.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	lr, err := NewLoadtResult(1, 0, d, 2, 0, ot.Result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lr.CollectBreakdown(opn); err != nil {
+		t.Fatal(err)
+	}
+	if len(lr.runbooks) != 1 {
+		t.Fatalf("got %d runbooks", len(lr.runbooks))
+	}
+	rb := lr.runbooks[0]
+	if rb.path != "testdata/book/loadt_steps.yml" {
+		t.Errorf("got %s", rb.path)
+	}
+	got := lo.Map(rb.steps, func(s *loadtStepResult, _ int) string { return s.key })
+	if diff := cmp.Diff(got, []string{"login", "list"}); diff != "" {
+		t.Error(diff)
+	}
+	for _, s := range rb.steps {
+		if s.total == 0 || s.failed != 0 {
+			t.Errorf("%s: got total %d failed %d", s.key, s.total, s.failed)
+		}
+		if s.max <= 0 || s.max > rb.max {
+			t.Errorf("%s: got max %v (runbook max %v)", s.key, s.max, rb.max)
+		}
+	}
+	if err := lr.CheckThreshold(`steps["login"].p99 < 10000 && runbooks["testdata/book/loadt_steps.yml"].steps["list"].failed == 0`); err != nil {
+		t.Error(err)
+	}
+	if err := lr.CheckThreshold(`steps["login"].p99 < 0`); err == nil {
+		t.Error("want error")
+	}
+	buf := new(bytes.Buffer)
+	if err := lr.Report(buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"testdata/book/loadt_steps.yml", "  login", "Get user", "  list", "List users"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report does not contain %q:\n%s", want, buf.String())
+		}
+	}
+
+	t.Run("Exclude warm-up", func(t *testing.T) {
+		b := newLoadtBreakdown(time.Hour)
+		b.record(&runNResult{RunResults: []*RunResult{
+			{Path: "testdata/book/loadt_steps.yml", Elapsed: time.Second, StepResults: []*StepResult{{Key: "login", Elapsed: time.Second}}},
+		}})
+		runbooks, steps := b.aggregate()
+		if len(runbooks) != 0 || len(steps) != 0 {
+			t.Errorf("got %d runbooks and %d steps", len(runbooks), len(steps))
+		}
+	})
+}
+
+func TestLoadtReportWithFormat(t *testing.T) {
+	lr := &loadtResult{
+		runbookCount: 1,
+		duration:     10 * time.Second,
+		total:        10,
+		succeeded:    9,
+		failed:       1,
+		errorRate:    10,
+		rps:          1,
+		max:          0.5,
+		min:          0.1,
+		p99:          0.5,
+		p90:          0.4,
+		p50:          0.2,
+		avg:          0.25,
+		runbooks: []*loadtRunbookResult{
+			{
+				loadtResult: &loadtResult{total: 10, succeeded: 9, failed: 1, max: 0.5},
+				path:        "path/to/login.yml",
+				steps: []*loadtStepResult{
+					{loadtResult: &loadtResult{total: 10, succeeded: 10, p99: 0.3}, key: "login", desc: "Login"},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		format  string
+		want    []string
+		wantErr bool
+	}{
+		{"text", []string{"Total..........................: 10", "  login"}, false},
+		{"json", []string{`"total": 10`, `"med": 200`, `"path": "path/to/login.yml"`, `"key": "login"`, `"p99": 300`}, false},
+		{"csv", []string{
+			"kind,name,step,total,succeeded,failed,dropped,error_rate,rps,avg,min,med,p90,p99,max\n",
+			"total,,,10,9,1,0,10,1,250,100,200,400,500,500\n",
+			"runbook,path/to/login.yml,,10,9,1,0,0,0,0,0,0,0,0,500\n",
+			"step,path/to/login.yml,login,10,10,0,0,0,0,0,0,0,0,300,0\n",
+		}, false},
+		{"xml", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			if err := lr.ReportWithFormat(buf, tt.format); err != nil {
+				if !tt.wantErr {
+					t.Error(err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("want error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("got:\n%s\nwant to contain %q", buf.String(), want)
+				}
+			}
+		})
+	}
+}
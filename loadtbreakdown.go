@@ -0,0 +1,112 @@
+package runn
+
+import (
+	"sync"
+	"time"
+)
+
+// loadtBreakdown collects the latencies of each runbook and each step of the load test.
+// The latencies are aggregated into histograms when they are recorded, so the memory usage does not grow with the number of RunN.
+type loadtBreakdown struct {
+	started  time.Time
+	warmUp   time.Duration
+	runbooks []*loadtBreakdownRunbook
+	steps    map[string]*loadtHistogram
+	mu       sync.Mutex
+}
+
+type loadtBreakdownRunbook struct {
+	path  string
+	hist  *loadtHistogram
+	steps []*loadtBreakdownStep
+}
+
+type loadtBreakdownStep struct {
+	key  string
+	desc string
+	hist *loadtHistogram
+}
+
+func newLoadtBreakdown(warmUp time.Duration) *loadtBreakdown {
+	return &loadtBreakdown{
+		started: time.Now(),
+		warmUp:  warmUp,
+		steps:   map[string]*loadtHistogram{},
+	}
+}
+
+// record records the elapsed time of the runbooks and the steps of the RunN result.
+// The results finished within the warm-up time are not recorded.
+// Latencies of steps with the same key in different runbooks are also merged into steps.
+func (b *loadtBreakdown) record(result *runNResult) {
+	if result == nil {
+		return
+	}
+	if time.Now().Before(b.started.Add(b.warmUp)) {
+		return
+	}
+	result.mu.Lock()
+	defer result.mu.Unlock()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, rr := range result.RunResults {
+		if rr == nil || rr.Skipped {
+			continue
+		}
+		rb := b.runbook(rr.Path)
+		rb.hist.record(rr.Elapsed.Seconds(), rr.Err != nil)
+		for _, sr := range rr.StepResults {
+			if sr == nil || sr.Skipped {
+				continue
+			}
+			rb.step(sr.Key, sr.Desc).hist.record(sr.Elapsed.Seconds(), sr.Err != nil)
+			h, ok := b.steps[sr.Key]
+			if !ok {
+				h = newLoadtHistogram()
+				b.steps[sr.Key] = h
+			}
+			h.record(sr.Elapsed.Seconds(), sr.Err != nil)
+		}
+	}
+}
+
+func (b *loadtBreakdown) runbook(path string) *loadtBreakdownRunbook {
+	for _, rb := range b.runbooks {
+		if rb.path == path {
+			return rb
+		}
+	}
+	rb := &loadtBreakdownRunbook{path: path, hist: newLoadtHistogram()}
+	b.runbooks = append(b.runbooks, rb)
+	return rb
+}
+
+func (rb *loadtBreakdownRunbook) step(key, desc string) *loadtBreakdownStep {
+	for _, st := range rb.steps {
+		if st.key == key {
+			return st
+		}
+	}
+	st := &loadtBreakdownStep{key: key, desc: desc, hist: newLoadtHistogram()}
+	rb.steps = append(rb.steps, st)
+	return st
+}
+
+// aggregate returns the copies of the latencies of each runbook and each step.
+func (b *loadtBreakdown) aggregate() ([]*loadtBreakdownRunbook, map[string]*loadtHistogram) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	runbooks := make([]*loadtBreakdownRunbook, 0, len(b.runbooks))
+	for _, rb := range b.runbooks {
+		c := &loadtBreakdownRunbook{path: rb.path, hist: rb.hist.copy()}
+		for _, st := range rb.steps {
+			c.steps = append(c.steps, &loadtBreakdownStep{key: st.key, desc: st.desc, hist: st.hist.copy()})
+		}
+		runbooks = append(runbooks, c)
+	}
+	steps := make(map[string]*loadtHistogram, len(b.steps))
+	for k, h := range b.steps {
+		steps[k] = h.copy()
+	}
+	return runbooks, steps
+}
@@ -0,0 +1,644 @@
+package runn
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/goccy/go-yaml"
+	"github.com/k1LoW/runn/internal/fs"
+	"github.com/ryo-yamaoka/otchkiss"
+	"github.com/ryo-yamaoka/otchkiss/setting"
+)
+
+const (
+	loadtWorkerPath             = "/run"
+	loadtWorkerProgressInterval = time.Second
+)
+
+// LoadtJob is the load test job that the coordinator ships to the workers.
+type LoadtJob struct {
+	// Files - Runbooks to run, runbooks loaded by `needs:` and `include:`, and the files they depend on ( the files of `vars:`, OpenAPI documents and proto files ). The key is the path relative to the working directory of the coordinator.
+	Files map[string]string `json:"files"`
+	// Paths - Paths of runbooks to run.
+	Paths []string `json:"paths"`
+	// Options - Options of the coordinator (e.g. command line flags). runn passes them to the worker as is, and the worker decides which of them to accept.
+	Options json.RawMessage `json:"options,omitempty"`
+	// Profile - Load profile (YAML). If it is set, Concurrent, MaxRPS, Duration and WarmUp are ignored.
+	Profile    string        `json:"profile,omitempty"`
+	Concurrent int           `json:"concurrent"`
+	MaxRPS     int           `json:"max_rps"`
+	Duration   time.Duration `json:"duration"`
+	WarmUp     time.Duration `json:"warm_up"`
+}
+
+// loadtWorkerMessage is the message that the worker streams to the coordinator as JSON Lines.
+type loadtWorkerMessage struct {
+	Progress *loadtHistogram      `json:"progress,omitempty"` // cumulative histogram of RunNs while running
+	Result   *loadtResultSnapshot `json:"result,omitempty"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// loadtResultSnapshot is the serializable load test result with histograms.
+type loadtResultSnapshot struct {
+	RunbookCount int64                      `json:"runbook_count"`
+	WarmUp       time.Duration              `json:"warm_up"`
+	Duration     time.Duration              `json:"duration"`
+	Concurrent   int64                      `json:"concurrent"`
+	MaxRPS       int64                      `json:"max_rps"`
+	Executor     string                     `json:"executor,omitempty"`
+	Dropped      int64                      `json:"dropped"`
+	Histogram    *loadtHistogram            `json:"histogram"`
+	Stages       []*loadtStageSnapshot      `json:"stages,omitempty"`
+	Runbooks     []*loadtRunbookSnapshot    `json:"runbooks,omitempty"`
+	Steps        map[string]*loadtHistogram `json:"steps,omitempty"`
+}
+
+type loadtStageSnapshot struct {
+	Name      string          `json:"name"`
+	Duration  time.Duration   `json:"duration"`
+	From      float64         `json:"from"`
+	To        float64         `json:"to"`
+	Dropped   int64           `json:"dropped"`
+	Histogram *loadtHistogram `json:"histogram"`
+}
+
+type loadtRunbookSnapshot struct {
+	Path      string               `json:"path"`
+	Histogram *loadtHistogram      `json:"histogram"`
+	Steps     []*loadtStepSnapshot `json:"steps"`
+}
+
+type loadtStepSnapshot struct {
+	Key       string          `json:"key"`
+	Desc      string          `json:"desc"`
+	Histogram *loadtHistogram `json:"histogram"`
+}
+
+// NewLoadtJob creates the load test job of the selected runbooks to ship to the workers.
+func (opn *operatorN) NewLoadtJob(options json.RawMessage) (*LoadtJob, error) {
+	job := &LoadtJob{
+		Files:   map[string]string{},
+		Options: options,
+	}
+	add := func(p string) error {
+		rel, err := loadtJobPath(p)
+		if err != nil {
+			return err
+		}
+		if _, ok := job.Files[rel]; ok {
+			return nil
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		job.Files[rel] = string(b)
+		return nil
+	}
+	bk := newBook()
+	if err := bk.applyOptions(opn.opts...); err != nil {
+		return nil, err
+	}
+	ops := append([]*operator{}, opn.ops...)
+	for _, op := range opn.om {
+		ops = append(ops, op)
+	}
+	for _, op := range opn.ops {
+		rel, err := loadtJobPath(op.bookPath)
+		if err != nil {
+			return nil, err
+		}
+		job.Paths = append(job.Paths, rel)
+	}
+	for _, op := range ops {
+		files, err := op.dependentFiles(bk)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range files {
+			if _, err := os.Stat(p); err != nil {
+				// Candidates of the proto files
+				continue
+			}
+			if err := add(p); err != nil {
+				return nil, err
+			}
+		}
+		for _, s := range op.steps {
+			if s.includeRunner == nil || s.includeConfig == nil {
+				continue
+			}
+			p, err := fs.Path(s.includeConfig.path, op.root)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := os.Stat(p); err != nil {
+				// The path of the included runbook may be determined at runtime
+				continue
+			}
+			if err := add(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return job, nil
+}
+
+// loadtJobPath returns the path relative to the working directory.
+func loadtJobPath(p string) (string, error) {
+	if filepath.IsAbs(p) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(wd, p)
+		if err != nil {
+			return "", err
+		}
+		p = rel
+	}
+	p = filepath.ToSlash(filepath.Clean(p))
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return "", fmt.Errorf("runbooks and the files they depend on for workers should be under the working directory: %s", p)
+	}
+	return p, nil
+}
+
+// split splits the job into n jobs so that the sum of the loads of the jobs equals the load of the job.
+func (job *LoadtJob) split(n int) ([]*LoadtJob, error) {
+	var jobs []*LoadtJob
+	var lp *LoadProfile
+	if job.Profile != "" {
+		var err error
+		lp, err = ParseLoadProfile([]byte(job.Profile))
+		if err != nil {
+			return nil, err
+		}
+	} else if job.Concurrent > 0 && job.Concurrent < n {
+		return nil, fmt.Errorf("the number of concurrent load test runs (%d) should be greater than or equal to the number of workers (%d)", job.Concurrent, n)
+	}
+	for i := range n {
+		j := *job
+		j.Concurrent = splitLoad(job.Concurrent, n, i)
+		j.MaxRPS = splitLoad(job.MaxRPS, n, i)
+		if job.MaxRPS > 0 && j.MaxRPS == 0 {
+			// Workers without RPS would run unlimited
+			return nil, fmt.Errorf("max RunN per second (%d) should be greater than or equal to the number of workers (%d)", job.MaxRPS, n)
+		}
+		if lp != nil {
+			b, err := yaml.Marshal(lp.scale(1 / float64(n)))
+			if err != nil {
+				return nil, err
+			}
+			j.Profile = string(b)
+		}
+		jobs = append(jobs, &j)
+	}
+	return jobs, nil
+}
+
+// splitLoad returns the i-th of the load split into n. 0 (unlimited) is not split.
+func splitLoad(v, n, i int) int {
+	if v <= 0 {
+		return v
+	}
+	l := v / n
+	if i < v%n {
+		l++
+	}
+	return l
+}
+
+// scale returns the load profile whose targets are scaled by f.
+func (lp *LoadProfile) scale(f float64) *LoadProfile {
+	c := *lp
+	c.MaxConcurrent = int(math.Ceil(float64(lp.MaxConcurrent) * f))
+	c.Stages = nil
+	for _, s := range lp.Stages {
+		from := s.from * f
+		c.Stages = append(c.Stages, &LoadStage{
+			Name:     s.Name,
+			Duration: s.duration.String(),
+			From:     &from,
+			To:       s.To * f,
+		})
+	}
+	return &c
+}
+
+// snapshot returns the serializable result. The paths of runbooks are made relative to root.
+func (r *loadtResult) snapshot(root string) *loadtResultSnapshot {
+	s := &loadtResultSnapshot{
+		RunbookCount: r.runbookCount,
+		WarmUp:       r.warmUp,
+		Duration:     r.duration,
+		Concurrent:   r.concurrent,
+		MaxRPS:       r.maxRPS,
+		Executor:     r.executor,
+		Dropped:      r.dropped,
+		Histogram:    r.hist,
+		Steps:        map[string]*loadtHistogram{},
+	}
+	for _, st := range r.stages {
+		s.Stages = append(s.Stages, &loadtStageSnapshot{
+			Name:      st.name,
+			Duration:  st.duration,
+			From:      st.from,
+			To:        st.to,
+			Dropped:   st.dropped,
+			Histogram: st.hist,
+		})
+	}
+	for _, rb := range r.runbooks {
+		p := rb.path
+		if root != "" {
+			if rel, err := filepath.Rel(root, p); err == nil {
+				p = filepath.ToSlash(rel)
+			}
+		}
+		rs := &loadtRunbookSnapshot{Path: p, Histogram: rb.hist}
+		for _, st := range rb.steps {
+			rs.Steps = append(rs.Steps, &loadtStepSnapshot{Key: st.key, Desc: st.desc, Histogram: st.hist})
+		}
+		s.Runbooks = append(s.Runbooks, rs)
+	}
+	for k, st := range r.steps {
+		s.Steps[k] = st.hist
+	}
+	return s
+}
+
+// mergeLoadtResultSnapshots merges the results of the workers into a single load test result.
+func mergeLoadtResultSnapshots(ss []*loadtResultSnapshot) (*loadtResult, error) {
+	if len(ss) == 0 {
+		return nil, errors.New("no results of workers")
+	}
+	m := &loadtResultSnapshot{
+		RunbookCount: ss[0].RunbookCount,
+		WarmUp:       ss[0].WarmUp,
+		Executor:     ss[0].Executor,
+		Histogram:    newLoadtHistogram(),
+		Steps:        map[string]*loadtHistogram{},
+	}
+	for _, s := range ss {
+		m.Duration = max(m.Duration, s.Duration)
+		m.Concurrent += s.Concurrent
+		m.MaxRPS += s.MaxRPS
+		m.Dropped += s.Dropped
+		m.Histogram.merge(s.Histogram)
+		if m.Stages == nil {
+			for _, st := range s.Stages {
+				m.Stages = append(m.Stages, &loadtStageSnapshot{Name: st.Name, Duration: st.Duration, Histogram: newLoadtHistogram()})
+			}
+		}
+		if len(s.Stages) != len(m.Stages) {
+			return nil, errors.New("the stages of the results of workers do not match")
+		}
+		for i, st := range s.Stages {
+			m.Stages[i].From += st.From
+			m.Stages[i].To += st.To
+			m.Stages[i].Dropped += st.Dropped
+			m.Stages[i].Histogram.merge(st.Histogram)
+		}
+		for _, rb := range s.Runbooks {
+			var mrb *loadtRunbookSnapshot
+			for _, r := range m.Runbooks {
+				if r.Path == rb.Path {
+					mrb = r
+					break
+				}
+			}
+			if mrb == nil {
+				mrb = &loadtRunbookSnapshot{Path: rb.Path, Histogram: newLoadtHistogram()}
+				m.Runbooks = append(m.Runbooks, mrb)
+			}
+			mrb.Histogram.merge(rb.Histogram)
+			for _, st := range rb.Steps {
+				var mst *loadtStepSnapshot
+				for _, s := range mrb.Steps {
+					if s.Key == st.Key {
+						mst = s
+						break
+					}
+				}
+				if mst == nil {
+					mst = &loadtStepSnapshot{Key: st.Key, Desc: st.Desc, Histogram: newLoadtHistogram()}
+					mrb.Steps = append(mrb.Steps, mst)
+				}
+				mst.Histogram.merge(st.Histogram)
+			}
+		}
+		for k, h := range s.Steps {
+			if _, ok := m.Steps[k]; !ok {
+				m.Steps[k] = newLoadtHistogram()
+			}
+			m.Steps[k].merge(h)
+		}
+	}
+	if len(m.Stages) > 0 {
+		// The load of each worker is rounded up, so use the targets of the merged stages
+		var target float64
+		for _, st := range m.Stages {
+			target = math.Max(target, math.Max(st.From, st.To))
+		}
+		if m.Executor == LoadExecutorArrivalRate {
+			m.MaxRPS = int64(math.Ceil(target))
+		} else {
+			m.Concurrent = int64(math.Ceil(target))
+		}
+	}
+	return m.result(), nil
+}
+
+// result returns the load test result of the snapshot.
+func (s *loadtResultSnapshot) result() *loadtResult {
+	r := newLoadtResultFromHistogram(s.RunbookCount, s.WarmUp, s.Duration, s.Concurrent, s.MaxRPS, s.Histogram)
+	r.executor = s.Executor
+	r.dropped = s.Dropped
+	for _, st := range s.Stages {
+		r.stages = append(r.stages, &loadtStageResult{
+			loadtResult: newLoadtResultFromHistogram(s.RunbookCount, 0, st.Duration, 0, 0, st.Histogram),
+			name:        st.Name,
+			from:        st.From,
+			to:          st.To,
+			dropped:     st.Dropped,
+		})
+	}
+	for _, rb := range s.Runbooks {
+		rr := &loadtRunbookResult{
+			loadtResult: newLoadtResultFromHistogram(1, s.WarmUp, s.Duration, 0, 0, rb.Histogram),
+			path:        rb.Path,
+		}
+		for _, st := range rb.Steps {
+			rr.steps = append(rr.steps, &loadtStepResult{
+				loadtResult: newLoadtResultFromHistogram(1, s.WarmUp, s.Duration, 0, 0, st.Histogram),
+				key:         st.Key,
+				desc:        st.Desc,
+			})
+		}
+		r.runbooks = append(r.runbooks, rr)
+	}
+	r.steps = map[string]*loadtResult{}
+	for k, h := range s.Steps {
+		r.steps[k] = newLoadtResultFromHistogram(1, s.WarmUp, s.Duration, 0, 0, h)
+	}
+	return r
+}
+
+// LoadtWorker is the worker of the distributed load test that runs the jobs shipped by the coordinator.
+type LoadtWorker struct {
+	token   string
+	options func(options json.RawMessage) ([]Option, error)
+	mu      sync.Mutex
+}
+
+// NewLoadtWorker returns a new worker that accepts only the jobs with the shared token.
+// options converts the options of the job into runn options.
+func NewLoadtWorker(token string, options func(options json.RawMessage) ([]Option, error)) *LoadtWorker {
+	return &LoadtWorker{token: token, options: options}
+}
+
+// ServeHTTP runs the job posted by the coordinator and streams the progress and the result as JSON Lines.
+func (w *LoadtWorker) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if !w.authorized(r) {
+		http.Error(rw, "invalid token", http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path != loadtWorkerPath {
+		http.NotFound(rw, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !w.mu.TryLock() {
+		http.Error(rw, "worker is busy", http.StatusConflict)
+		return
+	}
+	defer w.mu.Unlock()
+	job := &LoadtJob{}
+	if err := json.NewDecoder(r.Body).Decode(job); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/x-ndjson")
+	rw.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(rw)
+	emu := &sync.Mutex{}
+	send := func(m *loadtWorkerMessage) {
+		emu.Lock()
+		defer emu.Unlock()
+		_ = enc.Encode(m)
+		if f, ok := rw.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	s, err := w.run(r.Context(), job, func(h *loadtHistogram) {
+		send(&loadtWorkerMessage{Progress: h})
+	})
+	if err != nil {
+		send(&loadtWorkerMessage{Error: err.Error()})
+		return
+	}
+	send(&loadtWorkerMessage{Result: s})
+}
+
+// authorized reports whether the request has the shared token. A worker without the token accepts no jobs.
+func (w *LoadtWorker) authorized(r *http.Request) bool {
+	if w.token == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(w.token)) == 1
+}
+
+// run runs the job and returns the result.
+func (w *LoadtWorker) run(ctx context.Context, job *LoadtJob, progress func(*loadtHistogram)) (*loadtResultSnapshot, error) {
+	opts, err := w.options(job.Options)
+	if err != nil {
+		return nil, err
+	}
+	// Extract the runbooks under the working directory so that they are not treated as files in the parent directory
+	root, err := os.MkdirTemp(".", ".runn-loadt-worker-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(root)
+	for p, c := range job.Files {
+		rel, err := loadtJobPath(p)
+		if err != nil || filepath.IsAbs(rel) {
+			return nil, fmt.Errorf("invalid path of runbook: %s", p)
+		}
+		fp := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(fp), 0o700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(fp, []byte(c), 0o600); err != nil {
+			return nil, err
+		}
+	}
+	var paths []string
+	for _, p := range job.Paths {
+		paths = append(paths, filepath.Join(root, filepath.FromSlash(p)))
+	}
+	opn, err := Load(strings.Join(paths, string(filepath.ListSeparator)), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := opn.startTimeSeries(nil, loadtWorkerProgressInterval)
+	pctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(loadtWorkerProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-pctx.Done():
+				return
+			case <-ticker.C:
+				progress(ts.histogram())
+			}
+		}
+	}()
+	defer func() {
+		cancel()
+		<-done
+		_ = ts.Stop()
+	}()
+
+	var lr *loadtResult
+	if job.Profile != "" {
+		lp, err := ParseLoadProfile([]byte(job.Profile))
+		if err != nil {
+			return nil, err
+		}
+		lr, err = opn.RunLoadProfile(ctx, lp)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		s, err := setting.New(job.Concurrent, job.MaxRPS, job.Duration, job.WarmUp)
+		if err != nil {
+			return nil, err
+		}
+		selected, err := opn.SelectedOperators()
+		if err != nil {
+			return nil, err
+		}
+		opn.SetLoadtWarmUp(job.WarmUp)
+		ot, err := otchkiss.FromConfig(opn, s, 100_000_000)
+		if err != nil {
+			return nil, err
+		}
+		if err := ot.Start(ctx); err != nil {
+			return nil, err
+		}
+		lr, err = newLoadtResultOrEmpty(len(selected), job.WarmUp, job.Duration, job.Concurrent, job.MaxRPS, ot.Result)
+		if err != nil {
+			return nil, err
+		}
+		if err := lr.CollectBreakdown(opn); err != nil {
+			return nil, err
+		}
+	}
+	return lr.snapshot(root), nil
+}
+
+// RunLoadtWorkers ships the job to the workers ("host:port") with the shared token, runs it on all of them at the same time and merges their results into a single load test result.
+// The load (concurrency, RPS or targets of the load profile) is split among the workers.
+func RunLoadtWorkers(ctx context.Context, workers []string, token string, job *LoadtJob) (*loadtResult, error) {
+	if len(workers) == 0 {
+		return nil, errors.New("no workers")
+	}
+	jobs, err := job.split(len(workers))
+	if err != nil {
+		return nil, err
+	}
+	ss := make([]*loadtResultSnapshot, len(workers))
+	errs := make([]error, len(workers))
+	wg := &sync.WaitGroup{}
+	for i, addr := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ss[i], errs[i] = runLoadtWorker(ctx, addr, token, jobs[i])
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("worker %s: %w", addr, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return mergeLoadtResultSnapshots(ss)
+}
+
+// runLoadtWorker posts the job to the worker and reads the streamed messages until the result.
+func runLoadtWorker(ctx context.Context, addr, token string, job *LoadtJob) (*loadtResultSnapshot, error) {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	u := addr
+	if !strings.Contains(u, "://") {
+		u = "http://" + u
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(u, "/")+loadtWorkerPath, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := bufio.NewReader(res.Body).ReadString('\n')
+		return nil, fmt.Errorf("unexpected status %d: %s", res.StatusCode, strings.TrimSpace(msg))
+	}
+	var progress *loadtHistogram
+	sc := bufio.NewScanner(res.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 256*1024*1024)
+	for sc.Scan() {
+		m := &loadtWorkerMessage{}
+		if err := json.Unmarshal(sc.Bytes(), m); err != nil {
+			return nil, err
+		}
+		switch {
+		case m.Error != "":
+			return nil, errors.New(m.Error)
+		case m.Result != nil:
+			return m.Result, nil
+		case m.Progress != nil:
+			progress = m.Progress
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if progress != nil {
+		return nil, fmt.Errorf("connection closed before the result (%d RunNs were run)", progress.Count)
+	}
+	return nil, errors.New("connection closed before the result")
+}
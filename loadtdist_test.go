@@ -0,0 +1,257 @@
+package runn
+
+import (
+	"context"
+	"maps"
+	"math"
+	"math/rand"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/k1LoW/runn/internal/scope"
+	"github.com/k1LoW/runn/testutil"
+	or "github.com/ryo-yamaoka/otchkiss/result"
+)
+
+func TestLoadtHistogram(t *testing.T) {
+	r, err := or.WithCapacity(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec
+	a := newLoadtHistogram()
+	b := newLoadtHistogram()
+	for i := range 10000 {
+		l := rnd.ExpFloat64() / 10
+		failed := i%10 == 0
+		if failed {
+			r.AppendFail(l, nil)
+		} else {
+			r.AppendSuccess(l)
+		}
+		if i%2 == 0 {
+			a.record(l, failed)
+		} else {
+			b.record(l, failed)
+		}
+	}
+	want := newLoadtHistogramFromResult(r)
+	got := newLoadtHistogram()
+	got.merge(a)
+	got.merge(b)
+	if got.Count != want.Count || got.Succeeded != want.Succeeded || got.Failed != want.Failed || got.Min != want.Min || got.Max != want.Max {
+		t.Errorf("got %d %d %d %v %v, want %d %d %d %v %v", got.Count, got.Succeeded, got.Failed, got.Min, got.Max, want.Count, want.Succeeded, want.Failed, want.Min, want.Max)
+	}
+	for _, p := range []int{0, 1, 50, 90, 99, 100} {
+		exact, err := r.PercentileLatency(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := got.percentile(p); math.Abs(v-exact)/exact > 0.01 {
+			t.Errorf("p(%d): got %v, want %v", p, v, exact)
+		}
+	}
+}
+
+func TestSplitLoadtJob(t *testing.T) {
+	tests := []struct {
+		job            *LoadtJob
+		n              int
+		wantConcurrent []int
+		wantMaxRPS     []int
+		wantErr        bool
+	}{
+		{&LoadtJob{Concurrent: 5, MaxRPS: 0}, 2, []int{3, 2}, []int{0, 0}, false},
+		{&LoadtJob{Concurrent: 4, MaxRPS: 10}, 3, []int{2, 1, 1}, []int{4, 3, 3}, false},
+		{&LoadtJob{Concurrent: 0, MaxRPS: 0}, 2, []int{0, 0}, []int{0, 0}, false},
+		{&LoadtJob{Concurrent: 1, MaxRPS: 0}, 2, nil, nil, true},
+		{&LoadtJob{Concurrent: 2, MaxRPS: 1}, 2, nil, nil, true},
+	}
+	for _, tt := range tests {
+		jobs, err := tt.job.split(tt.n)
+		if err != nil {
+			if !tt.wantErr {
+				t.Error(err)
+			}
+			continue
+		}
+		if tt.wantErr {
+			t.Error("want error")
+			continue
+		}
+		var gotConcurrent, gotMaxRPS []int
+		for _, j := range jobs {
+			gotConcurrent = append(gotConcurrent, j.Concurrent)
+			gotMaxRPS = append(gotMaxRPS, j.MaxRPS)
+		}
+		if diff := cmp.Diff(gotConcurrent, tt.wantConcurrent); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff(gotMaxRPS, tt.wantMaxRPS); diff != "" {
+			t.Error(diff)
+		}
+	}
+
+	t.Run("Profile", func(t *testing.T) {
+		job := &LoadtJob{Profile: "executor: arrival-rate\nmaxConcurrent: 10\nstages:\n  - duration: 1500msec\n    to: 40\n  - name: keep\n    duration: 1sec\n    to: 40\n"}
+		jobs, err := job.split(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lp, err := ParseLoadProfile([]byte(jobs[1].Profile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lp.Executor != LoadExecutorArrivalRate || lp.MaxConcurrent != 5 || lp.Duration() != 2500*time.Millisecond {
+			t.Errorf("got %s %d %s", lp.Executor, lp.MaxConcurrent, lp.Duration())
+		}
+		got := [][]float64{}
+		for _, s := range lp.Stages {
+			got = append(got, []float64{s.from, s.To})
+		}
+		if diff := cmp.Diff(got, [][]float64{{0, 20}, {20, 20}}); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
+
+func TestNewLoadtJob(t *testing.T) {
+	opn, err := Load("testdata/loadtdist/main.yml", LoadOnly(), Scopes(scope.AllowReadParent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := opn.NewLoadtJob(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := slices.Sorted(maps.Keys(job.Files))
+	want := []string{
+		"testdata/grpctest.proto",
+		"testdata/loadtdist/included.yml",
+		"testdata/loadtdist/main.yml",
+		"testdata/loadtdist/vars.json",
+		"testdata/openapi3.yml",
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRunLoadtWorkers(t *testing.T) {
+	hs := testutil.HTTPServer(t)
+	t.Setenv("TEST_HTTP_ENDPOINT", hs.URL)
+	var workers []string
+	for range 2 {
+		w := NewLoadtWorker("secret", func(options json.RawMessage) ([]Option, error) {
+			var vars map[string]any
+			if err := json.Unmarshal(options, &vars); err != nil {
+				return nil, err
+			}
+			var opts []Option
+			for k, v := range vars {
+				opts = append(opts, Var(k, v))
+			}
+			return opts, nil
+		})
+		ws := httptest.NewServer(w)
+		t.Cleanup(ws.Close)
+		workers = append(workers, ws.Listener.Addr().String())
+	}
+
+	tests := []struct {
+		name string
+		job  func(job *LoadtJob)
+	}{
+		{
+			"concurrency",
+			func(job *LoadtJob) {
+				job.Concurrent = 2
+				job.Duration = 300 * time.Millisecond
+			},
+		},
+		{
+			"profile",
+			func(job *LoadtJob) {
+				job.Profile = "executor: arrival-rate\nstages:\n  - duration: 500msec\n    to: 40\n"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opn, err := Load("testdata/book/loadt_steps.yml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			job, err := opn.NewLoadtJob(json.RawMessage(`{"unused": "value"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(job.Paths, []string{"testdata/book/loadt_steps.yml"}); diff != "" {
+				t.Error(diff)
+			}
+			tt.job(job)
+			lr, err := RunLoadtWorkers(context.Background(), workers, "secret", job)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lr.total == 0 || lr.failed != 0 {
+				t.Errorf("got total %d failed %d", lr.total, lr.failed)
+			}
+			if len(lr.runbooks) != 1 || lr.runbooks[0].path != "testdata/book/loadt_steps.yml" {
+				t.Fatalf("got %#v", lr.runbooks)
+			}
+			if lr.runbooks[0].total != lr.total {
+				t.Errorf("got %d, want %d", lr.runbooks[0].total, lr.total)
+			}
+			if job.Profile != "" {
+				if len(lr.stages) != 1 || lr.stages[0].to != 40 || lr.maxRPS != 40 {
+					t.Errorf("got %d %#v", lr.maxRPS, lr.stages)
+				}
+			} else if lr.concurrent != 2 {
+				t.Errorf("got %d", lr.concurrent)
+			}
+			if err := lr.CheckThreshold(`failed == 0 && steps["login"].total == total`); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	t.Run("Invalid token", func(t *testing.T) {
+		opn, err := Load("testdata/book/loadt_steps.yml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		job, err := opn.NewLoadtJob(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.Concurrent = 2
+		job.Duration = 100 * time.Millisecond
+		for _, token := range []string{"", "invalid"} {
+			if _, err := RunLoadtWorkers(context.Background(), workers, token, job); err == nil || !strings.Contains(err.Error(), "401") {
+				t.Errorf("token %q: got %v, want unauthorized", token, err)
+			}
+		}
+	})
+
+	t.Run("Worker error", func(t *testing.T) {
+		opn, err := Load("testdata/book/loadt_steps.yml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		job, err := opn.NewLoadtJob(json.RawMessage(`invalid`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.Concurrent = 2
+		job.Duration = 100 * time.Millisecond
+		if _, err := RunLoadtWorkers(context.Background(), workers, "secret", job); err == nil {
+			t.Error("want error")
+		}
+	})
+}
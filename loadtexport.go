@@ -0,0 +1,189 @@
+package runn
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/goccy/go-json"
+)
+
+// loadtResultJSON is the summary of the load test for machine-readable output. Latencies are in milliseconds.
+type loadtResultJSON struct {
+	RunbooksPerRunN int64                     `json:"runbooks_per_runn"`
+	WarmUp          string                    `json:"warm_up"`
+	Duration        string                    `json:"duration"`
+	Concurrent      int64                     `json:"concurrent"`
+	MaxRPS          int64                     `json:"max_rps"`
+	Executor        string                    `json:"executor,omitempty"`
+	Dropped         int64                     `json:"dropped"`
+	Stages          []*loadtStageResultJSON   `json:"stages,omitempty"`
+	Runbooks        []*loadtRunbookResultJSON `json:"runbooks,omitempty"`
+	*loadtMetricsJSON
+}
+
+type loadtMetricsJSON struct {
+	Total     int64   `json:"total"`
+	Succeeded int64   `json:"succeeded"`
+	Failed    int64   `json:"failed"`
+	ErrorRate float64 `json:"error_rate"`
+	RPS       float64 `json:"rps"`
+	Max       float64 `json:"max"`
+	Min       float64 `json:"min"`
+	Avg       float64 `json:"avg"`
+	Med       float64 `json:"med"`
+	P90       float64 `json:"p90"`
+	P99       float64 `json:"p99"`
+}
+
+type loadtStageResultJSON struct {
+	Name     string  `json:"name"`
+	Duration string  `json:"duration"`
+	From     float64 `json:"from"`
+	To       float64 `json:"to"`
+	Dropped  int64   `json:"dropped"`
+	*loadtMetricsJSON
+}
+
+type loadtRunbookResultJSON struct {
+	Path  string                 `json:"path"`
+	Steps []*loadtStepResultJSON `json:"steps"`
+	*loadtMetricsJSON
+}
+
+type loadtStepResultJSON struct {
+	Key  string `json:"key"`
+	Desc string `json:"desc"`
+	*loadtMetricsJSON
+}
+
+func (r *loadtResult) metricsJSON() *loadtMetricsJSON {
+	return &loadtMetricsJSON{
+		Total:     r.total,
+		Succeeded: r.succeeded,
+		Failed:    r.failed,
+		ErrorRate: r.errorRate,
+		RPS:       r.rps,
+		Max:       r.max * 1000,
+		Min:       r.min * 1000,
+		Avg:       r.avg * 1000,
+		Med:       r.p50 * 1000,
+		P90:       r.p90 * 1000,
+		P99:       r.p99 * 1000,
+	}
+}
+
+func (r *loadtResult) toJSON() *loadtResultJSON {
+	j := &loadtResultJSON{
+		RunbooksPerRunN:  r.runbookCount,
+		WarmUp:           r.warmUp.String(),
+		Duration:         r.duration.String(),
+		Concurrent:       r.concurrent,
+		MaxRPS:           r.maxRPS,
+		Executor:         r.executor,
+		Dropped:          r.dropped,
+		loadtMetricsJSON: r.metricsJSON(),
+	}
+	for _, s := range r.stages {
+		j.Stages = append(j.Stages, &loadtStageResultJSON{
+			Name:             s.name,
+			Duration:         s.duration.String(),
+			From:             s.from,
+			To:               s.to,
+			Dropped:          s.dropped,
+			loadtMetricsJSON: s.metricsJSON(),
+		})
+	}
+	for _, rb := range r.runbooks {
+		rj := &loadtRunbookResultJSON{
+			Path:             rb.path,
+			Steps:            []*loadtStepResultJSON{},
+			loadtMetricsJSON: rb.metricsJSON(),
+		}
+		for _, s := range rb.steps {
+			rj.Steps = append(rj.Steps, &loadtStepResultJSON{
+				Key:              s.key,
+				Desc:             s.desc,
+				loadtMetricsJSON: s.metricsJSON(),
+			})
+		}
+		j.Runbooks = append(j.Runbooks, rj)
+	}
+	return j
+}
+
+// ReportWithFormat writes the summary of the load test in the format ("text", "json" or "csv").
+func (r *loadtResult) ReportWithFormat(w io.Writer, format string) error {
+	switch format {
+	case "", "text":
+		return r.Report(w)
+	case "json":
+		return r.ReportJSON(w)
+	case "csv":
+		return r.ReportCSV(w)
+	default:
+		return fmt.Errorf("invalid format: %s (available formats: text, json, csv)", format)
+	}
+}
+
+// ReportJSON writes the summary of the load test as JSON.
+func (r *loadtResult) ReportJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.toJSON())
+}
+
+// ReportCSV writes the summary of the load test as CSV.
+// Each row is the total, a stage, a runbook or a step distinguished by the `kind` column.
+func (r *loadtResult) ReportCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"kind", "name", "step", "total", "succeeded", "failed", "dropped", "error_rate", "rps", "avg", "min", "med", "p90", "p99", "max"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	row := func(kind, name, step string, dropped int64, lr *loadtResult) error {
+		m := lr.metricsJSON()
+		return cw.Write([]string{
+			kind,
+			name,
+			step,
+			strconv.FormatInt(m.Total, 10),
+			strconv.FormatInt(m.Succeeded, 10),
+			strconv.FormatInt(m.Failed, 10),
+			strconv.FormatInt(dropped, 10),
+			formatFloat(m.ErrorRate),
+			formatFloat(m.RPS),
+			formatFloat(m.Avg),
+			formatFloat(m.Min),
+			formatFloat(m.Med),
+			formatFloat(m.P90),
+			formatFloat(m.P99),
+			formatFloat(m.Max),
+		})
+	}
+	if err := row("total", "", "", r.dropped, r); err != nil {
+		return err
+	}
+	for _, s := range r.stages {
+		if err := row("stage", s.name, "", s.dropped, s.loadtResult); err != nil {
+			return err
+		}
+	}
+	for _, rb := range r.runbooks {
+		if err := row("runbook", rb.path, "", 0, rb.loadtResult); err != nil {
+			return err
+		}
+		for _, s := range rb.steps {
+			if err := row("step", rb.path, s.key, 0, s.loadtResult); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
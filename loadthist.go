@@ -0,0 +1,174 @@
+package runn
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	or "github.com/ryo-yamaoka/otchkiss/result"
+)
+
+const (
+	// loadtHistogramGrowth is the growth rate of the bucket boundaries. Percentiles have a relative error of at most 1%.
+	loadtHistogramGrowth = 1.01
+	// loadtHistogramUnit is the upper bound of the first bucket (sec).
+	loadtHistogramUnit = 1e-6
+)
+
+// loadtHistogram is the mergeable histogram of latencies (sec) of the load test.
+type loadtHistogram struct {
+	Buckets   map[int]int64 `json:"buckets"` // key is the index of the bucket whose upper bound is loadtHistogramUnit * loadtHistogramGrowth^index
+	Count     int64         `json:"count"`
+	Succeeded int64         `json:"succeeded"`
+	Failed    int64         `json:"failed"`
+	Sum       float64       `json:"sum"`
+	Min       float64       `json:"min"`
+	Max       float64       `json:"max"`
+}
+
+func newLoadtHistogram() *loadtHistogram {
+	return &loadtHistogram{Buckets: map[int]int64{}}
+}
+
+// newLoadtHistogramFromResult creates the histogram from the latencies of the result.
+func newLoadtHistogramFromResult(r *or.Result) *loadtHistogram {
+	h := newLoadtHistogram()
+	for _, l := range r.Latencies() {
+		h.add(l)
+	}
+	h.Succeeded = r.Succeeded()
+	h.Failed = r.Failed()
+	return h
+}
+
+func loadtHistogramIndex(l float64) int {
+	if l <= loadtHistogramUnit {
+		return 0
+	}
+	return int(math.Ceil(math.Log(l/loadtHistogramUnit) / math.Log(loadtHistogramGrowth)))
+}
+
+// add adds the latency without counting succeeded or failed.
+func (h *loadtHistogram) add(l float64) {
+	if h.Count == 0 || l < h.Min {
+		h.Min = l
+	}
+	if h.Count == 0 || l > h.Max {
+		h.Max = l
+	}
+	h.Buckets[loadtHistogramIndex(l)]++
+	h.Count++
+	h.Sum += l
+}
+
+// record adds the latency of the succeeded or failed RunN.
+func (h *loadtHistogram) record(l float64, failed bool) {
+	h.add(l)
+	if failed {
+		h.Failed++
+		return
+	}
+	h.Succeeded++
+}
+
+// merge merges the other histogram into the histogram.
+func (h *loadtHistogram) merge(o *loadtHistogram) {
+	if o == nil || o.Count == 0 {
+		h.Succeeded += o.succeeded()
+		h.Failed += o.failed()
+		return
+	}
+	if h.Count == 0 || o.Min < h.Min {
+		h.Min = o.Min
+	}
+	if h.Count == 0 || o.Max > h.Max {
+		h.Max = o.Max
+	}
+	for i, c := range o.Buckets {
+		h.Buckets[i] += c
+	}
+	h.Count += o.Count
+	h.Succeeded += o.Succeeded
+	h.Failed += o.Failed
+	h.Sum += o.Sum
+}
+
+func (h *loadtHistogram) succeeded() int64 {
+	if h == nil {
+		return 0
+	}
+	return h.Succeeded
+}
+
+func (h *loadtHistogram) failed() int64 {
+	if h == nil {
+		return 0
+	}
+	return h.Failed
+}
+
+func (h *loadtHistogram) copy() *loadtHistogram {
+	c := newLoadtHistogram()
+	c.merge(h)
+	return c
+}
+
+// percentile returns the p-th percentile of the latencies in the same way as (*or.Result).PercentileLatency.
+func (h *loadtHistogram) percentile(p int) float64 {
+	switch {
+	case h.Count == 0:
+		return 0
+	case p <= 0:
+		return h.Min
+	case p >= 100:
+		return h.Max
+	}
+	rank := int64(float64(h.Count) * (float64(p) / 100))
+	if rank < 1 {
+		rank = 1
+	}
+	idxs := make([]int, 0, len(h.Buckets))
+	for i := range h.Buckets {
+		idxs = append(idxs, i)
+	}
+	sort.Ints(idxs)
+	var n int64
+	for _, i := range idxs {
+		n += h.Buckets[i]
+		if n >= rank {
+			v := loadtHistogramUnit * math.Pow(loadtHistogramGrowth, float64(i))
+			return math.Min(math.Max(v, h.Min), h.Max)
+		}
+	}
+	return h.Max
+}
+
+// newLoadtResultFromHistogram creates a new load test result from the histogram.
+func newLoadtResultFromHistogram(rc int64, w, d time.Duration, c, m int64, h *loadtHistogram) *loadtResult {
+	r := &loadtResult{
+		runbookCount: rc,
+		warmUp:       w,
+		duration:     d,
+		concurrent:   c,
+		maxRPS:       m,
+		hist:         h,
+	}
+	total := h.Succeeded + h.Failed
+	if total == 0 {
+		return r
+	}
+	r.total = total
+	r.succeeded = h.Succeeded
+	r.failed = h.Failed
+	r.errorRate = float64(h.Failed) / float64(total) * 100
+	r.rps = float64(total) / d.Seconds()
+	r.max = h.percentile(100)
+	r.min = h.percentile(0)
+	r.p99 = h.percentile(99)
+	r.p90 = h.percentile(90)
+	r.p50 = h.percentile(50)
+	if h.Count > 0 {
+		r.avg = h.Sum / float64(h.Count)
+	}
+	return r
+}
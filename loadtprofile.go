@@ -0,0 +1,393 @@
+package runn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/k1LoW/runn/internal/store"
+	"github.com/k1LoW/waitmap"
+	or "github.com/ryo-yamaoka/otchkiss/result"
+)
+
+const (
+	// LoadExecutorConcurrency is the closed model executor that ramps the number of concurrent RunNs.
+	LoadExecutorConcurrency = "concurrency"
+	// LoadExecutorArrivalRate is the open model executor that ramps the number of RunNs started per second regardless of the response time.
+	LoadExecutorArrivalRate = "arrival-rate"
+)
+
+const (
+	loadProfileDefaultMaxConcurrent = 100
+	loadProfileTick                 = 10 * time.Millisecond
+)
+
+// LoadProfile is the load profile of the load test.
+type LoadProfile struct {
+	// Executor - The executor of the load test ("concurrency" or "arrival-rate").
+	Executor string `yaml:"executor,omitempty"`
+	// MaxConcurrent - The max number of concurrent RunNs of the arrival-rate executor. RunNs that exceed it are dropped.
+	MaxConcurrent int `yaml:"maxConcurrent,omitempty"`
+	// Stages - The stages of the load test.
+	Stages []*LoadStage `yaml:"stages"`
+	// Weights - The weights of the runbooks (key is the path or the ID of the runbook). Runbooks without weights are weighted 1.
+	// If weights are set, one runbook selected by the weights is run per request instead of all of the runbooks.
+	Weights map[string]int `yaml:"weights,omitempty"`
+}
+
+// LoadStage is the stage of the load profile that ramps the concurrency or the arrival rate from `From` to `To` over `Duration`.
+type LoadStage struct {
+	Name     string `yaml:"name,omitempty"`
+	Duration string `yaml:"duration"`
+	// From - The concurrency or the arrival rate at the start of the stage. It defaults to `To` of the previous stage (0 for the first stage).
+	From *float64 `yaml:"from,omitempty"`
+	// To - The concurrency or the arrival rate at the end of the stage.
+	To float64 `yaml:"to"`
+
+	duration time.Duration
+	from     float64
+}
+
+// ReadLoadProfile reads the load profile from the YAML file.
+func ReadLoadProfile(p string) (*LoadProfile, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLoadProfile(b)
+}
+
+// ParseLoadProfile parses the load profile.
+func ParseLoadProfile(b []byte) (*LoadProfile, error) {
+	lp := &LoadProfile{}
+	if err := yaml.UnmarshalWithOptions(b, lp, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf("invalid load profile: %w", err)
+	}
+	switch lp.Executor {
+	case "":
+		lp.Executor = LoadExecutorConcurrency
+	case LoadExecutorConcurrency, LoadExecutorArrivalRate:
+	default:
+		return nil, fmt.Errorf("invalid load profile: invalid executor: %s (available executors: %s, %s)", lp.Executor, LoadExecutorConcurrency, LoadExecutorArrivalRate)
+	}
+	if lp.MaxConcurrent < 0 {
+		return nil, fmt.Errorf("invalid load profile: invalid maxConcurrent: %d", lp.MaxConcurrent)
+	}
+	if lp.MaxConcurrent == 0 {
+		lp.MaxConcurrent = loadProfileDefaultMaxConcurrent
+	}
+	if len(lp.Stages) == 0 {
+		return nil, errors.New("invalid load profile: stages not found")
+	}
+	prev := 0.0
+	for i, s := range lp.Stages {
+		if s == nil {
+			return nil, fmt.Errorf("invalid load profile: stages[%d] is empty", i)
+		}
+		d, err := parseDuration(s.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid load profile: stages[%d]: %w", i, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid load profile: stages[%d]: duration should be positive: %s", i, s.Duration)
+		}
+		s.duration = d
+		s.from = prev
+		if s.From != nil {
+			s.from = *s.From
+		}
+		if s.from < 0 || s.To < 0 {
+			return nil, fmt.Errorf("invalid load profile: stages[%d]: from and to should not be negative", i)
+		}
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("stages[%d]", i)
+		}
+		prev = s.To
+	}
+	for k, w := range lp.Weights {
+		if w < 0 {
+			return nil, fmt.Errorf("invalid load profile: invalid weight of %s: %d", k, w)
+		}
+	}
+	return lp, nil
+}
+
+// Duration returns the total duration of the stages.
+func (lp *LoadProfile) Duration() time.Duration {
+	var d time.Duration
+	for _, s := range lp.Stages {
+		d += s.duration
+	}
+	return d
+}
+
+// stageAt returns the index of the stage and the target value (concurrency or arrival rate) at the elapsed time.
+// It returns -1 if the elapsed time exceeds the duration of all stages.
+func (lp *LoadProfile) stageAt(elapsed time.Duration) (int, float64) {
+	for i, s := range lp.Stages {
+		if elapsed < s.duration {
+			progress := float64(elapsed) / float64(s.duration)
+			return i, s.from + (s.To-s.from)*progress
+		}
+		elapsed -= s.duration
+	}
+	return -1, 0
+}
+
+// max returns the max target value of the stages.
+func (lp *LoadProfile) max() float64 {
+	var m float64
+	for _, s := range lp.Stages {
+		m = math.Max(m, math.Max(s.from, s.To))
+	}
+	return m
+}
+
+// loadRequester is the requester of the load test that runs the runbooks.
+type loadRequester struct {
+	opns    []*operatorN
+	weights []int
+	total   int
+	mu      sync.Mutex
+	rnd     *rand.Rand
+}
+
+// RunLoadProfile runs the load test of the selected runbooks according to the load profile.
+func (opn *operatorN) RunLoadProfile(ctx context.Context, lp *LoadProfile) (*loadtResult, error) {
+	selected, err := opn.SelectedOperators()
+	if err != nil {
+		return nil, err
+	}
+	rq, err := opn.newLoadRequester(selected, lp.Weights)
+	if err != nil {
+		return nil, err
+	}
+	defer opn.Close()
+	if !opn.profile {
+		opn.sw.Disable()
+	}
+	if err := opn.Init(); err != nil {
+		return nil, err
+	}
+	for _, sub := range rq.opns {
+		sub.breakdown = opn.breakdown
+		sub.series = opn.series
+	}
+
+	total, err := or.WithCapacity(0)
+	if err != nil {
+		return nil, err
+	}
+	stages := make([]*or.Result, len(lp.Stages))
+	for i := range stages {
+		stages[i], err = or.WithCapacity(0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	dropped := make([]atomic.Int64, len(lp.Stages))
+	request := func(stage int) {
+		started := time.Now()
+		err := rq.requestOne(ctx)
+		l := time.Since(started).Seconds()
+		for _, r := range []*or.Result{total, stages[stage]} {
+			if err != nil {
+				r.AppendFail(l, err)
+			} else {
+				r.AppendSuccess(l)
+			}
+		}
+	}
+
+	started := time.Now()
+	wg := &sync.WaitGroup{}
+	switch lp.Executor {
+	case LoadExecutorArrivalRate:
+		sem := make(chan struct{}, lp.MaxConcurrent)
+		ticker := time.NewTicker(loadProfileTick)
+		defer ticker.Stop()
+		// Accumulate the arrivals by integrating the arrival rate over each tick
+		var arrivals float64
+		prev := started
+	L:
+		for {
+			select {
+			case <-ctx.Done():
+				break L
+			case now := <-ticker.C:
+				stage, rate := lp.stageAt(now.Sub(started))
+				if stage < 0 {
+					break L
+				}
+				arrivals += rate * now.Sub(prev).Seconds()
+				prev = now
+				for ; arrivals >= 1; arrivals-- {
+					select {
+					case sem <- struct{}{}:
+						wg.Add(1)
+						go func() {
+							defer func() {
+								<-sem
+								wg.Done()
+							}()
+							request(stage)
+						}()
+					default:
+						dropped[stage].Add(1)
+					}
+				}
+			}
+		}
+	default:
+		for i := range int(math.Ceil(lp.max())) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					default:
+					}
+					stage, concurrency := lp.stageAt(time.Since(started))
+					if stage < 0 {
+						return
+					}
+					if float64(i) >= math.Ceil(concurrency) {
+						time.Sleep(loadProfileTick)
+						continue
+					}
+					request(stage)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	c, m := int(math.Ceil(lp.max())), 0
+	if lp.Executor == LoadExecutorArrivalRate {
+		c, m = lp.MaxConcurrent, int(math.Ceil(lp.max()))
+	}
+	lr, err := newLoadtResultOrEmpty(len(selected), 0, time.Since(started), c, m, total)
+	if err != nil {
+		return nil, err
+	}
+	lr.executor = lp.Executor
+	if err := lr.CollectBreakdown(opn); err != nil {
+		return nil, err
+	}
+	for i, s := range lp.Stages {
+		sr, err := newLoadtResultOrEmpty(len(selected), 0, s.duration, 0, 0, stages[i])
+		if err != nil {
+			return nil, err
+		}
+		lr.dropped += dropped[i].Load()
+		lr.stages = append(lr.stages, &loadtStageResult{
+			name:        s.Name,
+			from:        s.from,
+			to:          s.To,
+			dropped:     dropped[i].Load(),
+			loadtResult: sr,
+		})
+	}
+	return lr, nil
+}
+
+// newLoadRequester creates the requester. If weights are set, the selected runbooks are split into operatorNs for each runbook.
+func (opn *operatorN) newLoadRequester(selected []*operator, weights map[string]int) (*loadRequester, error) {
+	rq := &loadRequester{
+		rnd: rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec
+	}
+	if len(weights) == 0 {
+		rq.opns = []*operatorN{opn}
+		rq.weights = []int{1}
+		rq.total = 1
+		return rq, nil
+	}
+	matched := map[string]struct{}{}
+	for _, op := range selected {
+		if !opn.isTopLevel(op) {
+			// Runbooks that are only loaded by `needs:` are run by the runbooks that need them
+			continue
+		}
+		w := 1
+		for k, v := range weights {
+			if op.bookPath == k || strings.HasPrefix(op.id, k) {
+				w = v
+				matched[k] = struct{}{}
+				break
+			}
+		}
+		if w == 0 {
+			continue
+		}
+		sub := &operatorN{
+			ops:          []*operator{op},
+			om:           map[string]*operator{},
+			nm:           waitmap.New[string, *store.Store](),
+			skipIncluded: opn.skipIncluded,
+			included:     map[string][]string{},
+			t:            opn.t,
+			sw:           opn.sw,
+			profile:      opn.profile,
+			waitTimeout:  opn.waitTimeout,
+			failFast:     opn.failFast,
+			concmax:      1,
+			opts:         opn.opts,
+			kv:           opn.kv,
+			dbg:          opn.dbg,
+		}
+		sub.runNIndex.Store(-1) // Set index to -1 ( no runN )
+		rq.opns = append(rq.opns, sub)
+		rq.weights = append(rq.weights, w)
+		rq.total += w
+	}
+	for k := range weights {
+		if _, ok := matched[k]; !ok {
+			return nil, fmt.Errorf("invalid load profile: runbook of weights not found: %s", k)
+		}
+	}
+	if rq.total == 0 {
+		return nil, errors.New("invalid load profile: no runbooks to run (all weights are 0)")
+	}
+	return rq, nil
+}
+
+// isTopLevel returns whether the operator is one of the runbooks loaded by the path pattern.
+func (opn *operatorN) isTopLevel(op *operator) bool {
+	for _, o := range opn.ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (rq *loadRequester) requestOne(ctx context.Context) error {
+	return rq.choose().RequestOne(ctx)
+}
+
+// choose chooses an operatorN by the weights.
+func (rq *loadRequester) choose() *operatorN {
+	if len(rq.opns) == 1 {
+		return rq.opns[0]
+	}
+	rq.mu.Lock()
+	n := rq.rnd.Intn(rq.total)
+	rq.mu.Unlock()
+	for i, w := range rq.weights {
+		if n < w {
+			return rq.opns[i]
+		}
+		n -= w
+	}
+	return rq.opns[len(rq.opns)-1]
+}
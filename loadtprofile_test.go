@@ -0,0 +1,202 @@
+package runn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/k1LoW/runn/testutil"
+)
+
+func TestParseLoadProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{
+			"concurrency",
+			`
+stages:
+  - duration: 1sec
+    to: 2
+  - name: keep
+    duration: 2sec
+    to: 2
+`,
+			false,
+		},
+		{
+			"arrival-rate",
+			`
+executor: arrival-rate
+maxConcurrent: 10
+stages:
+  - duration: 1sec
+    from: 5
+    to: 10
+weights:
+  testdata/book/loadt_users.yml: 3
+`,
+			false,
+		},
+		{"no stages", `executor: concurrency`, true},
+		{"invalid executor", "executor: invalid\nstages:\n  - duration: 1sec\n    to: 1\n", true},
+		{"invalid duration", "stages:\n  - duration: invalid\n    to: 1\n", true},
+		{"negative target", "stages:\n  - duration: 1sec\n    to: -1\n", true},
+		{"negative weight", "stages:\n  - duration: 1sec\n    to: 1\nweights:\n  a: -1\n", true},
+		{"unknown field", "stages:\n  - duration: 1sec\n    target: 1\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseLoadProfile([]byte(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadProfileStageAt(t *testing.T) {
+	lp, err := ParseLoadProfile([]byte(`
+stages:
+  - duration: 10sec
+    to: 10
+  - duration: 10sec
+    to: 10
+  - duration: 10sec
+    from: 20
+    to: 0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		elapsed   time.Duration
+		wantStage int
+		want      float64
+	}{
+		{0, 0, 0},
+		{5 * time.Second, 0, 5},
+		{15 * time.Second, 1, 10},
+		{25 * time.Second, 2, 10},
+		{30 * time.Second, -1, 0},
+	}
+	for _, tt := range tests {
+		stage, got := lp.stageAt(tt.elapsed)
+		if stage != tt.wantStage || got != tt.want {
+			t.Errorf("%s: got %d %v, want %d %v", tt.elapsed, stage, got, tt.wantStage, tt.want)
+		}
+	}
+	if got := lp.Duration(); got != 30*time.Second {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestRunLoadProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		profile  string
+		wantHits map[string]bool
+	}{
+		{
+			"concurrency",
+			`
+stages:
+  - name: ramp-up
+    duration: 200msec
+    to: 2
+  - name: keep
+    duration: 200msec
+    to: 2
+`,
+			map[string]bool{"/users": true, "/hello": true},
+		},
+		{
+			"arrival-rate",
+			`
+executor: arrival-rate
+stages:
+  - name: ramp-up
+    duration: 300msec
+    from: 20
+    to: 50
+  - name: keep
+    duration: 300msec
+    to: 50
+`,
+			map[string]bool{"/users": true, "/hello": true},
+		},
+		{
+			"weights",
+			`
+stages:
+  - duration: 300msec
+    to: 2
+weights:
+  testdata/book/loadt_hello.yml: 0
+`,
+			map[string]bool{"/users": true, "/hello": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs, hr := testutil.HTTPServerAndRouter(t)
+			t.Setenv("TEST_HTTP_ENDPOINT", hs.URL)
+			lp, err := ParseLoadProfile([]byte(tt.profile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			opn, err := Load("testdata/book/loadt_*.yml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			lr, err := opn.RunLoadProfile(context.Background(), lp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lr.total == 0 {
+				t.Error("no requests")
+			}
+			if lr.failed != 0 {
+				t.Errorf("got %d failed", lr.failed)
+			}
+			if len(lr.stages) != len(lp.Stages) {
+				t.Fatalf("got %d stages", len(lr.stages))
+			}
+			var total int64
+			for _, s := range lr.stages {
+				total += s.total
+			}
+			if total != lr.total {
+				t.Errorf("sum of stages: got %d, want %d", total, lr.total)
+			}
+			hits := map[string]bool{}
+			for _, r := range hr.Requests() {
+				hits[r.URL.Path] = true
+			}
+			for p, want := range tt.wantHits {
+				if hits[p] != want {
+					t.Errorf("%s: got %v, want %v", p, hits[p], want)
+				}
+			}
+			if err := lr.CheckThreshold(`len(stages) == 2 ? stages[1].failed == 0 : stages[0].failed == 0`); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRunLoadProfileUnknownWeight(t *testing.T) {
+	lp, err := ParseLoadProfile([]byte("stages:\n  - duration: 100msec\n    to: 1\nweights:\n  testdata/book/unknown.yml: 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opn, err := Load("testdata/book/loadt_*.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := opn.RunLoadProfile(context.Background(), lp); err == nil {
+		t.Error("want error")
+	}
+}
@@ -0,0 +1,230 @@
+package runn
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+const loadtTimeSeriesInterval = time.Second
+
+// LoadtTimeSeries aggregates the results of the load test per second while the test runs.
+type LoadtTimeSeries struct {
+	w         io.Writer
+	enc       *json.Encoder
+	started   time.Time
+	flushed   time.Time // time of the last flush
+	interval  time.Duration
+	active    atomic.Int64
+	succeeded atomic.Int64
+	failed    atomic.Int64
+	latencies []float64 // latencies (sec) of the current interval
+	errors    int64     // number of failures of the current interval
+	latest    *loadtTimeSeriesPoint
+	hist      *loadtHistogram // cumulative histogram
+	cancel    context.CancelFunc
+	done      chan struct{}
+	err       error
+	mu        sync.Mutex
+}
+
+// loadtTimeSeriesPoint is the result of the load test aggregated per second. Latencies are in milliseconds.
+type loadtTimeSeriesPoint struct {
+	Time     time.Time `json:"time"`
+	Elapsed  float64   `json:"elapsed"`
+	Requests int64     `json:"requests"`
+	Errors   int64     `json:"errors"`
+	RPS      float64   `json:"rps"`
+	Active   int64     `json:"active"`
+	Avg      float64   `json:"avg"`
+	Med      float64   `json:"med"`
+	P90      float64   `json:"p90"`
+	P99      float64   `json:"p99"`
+	Max      float64   `json:"max"`
+}
+
+// StartTimeSeries starts aggregating the results of the load test per second.
+// If w is not nil, each aggregated result is written to w as JSON Lines.
+// Stop must be called after the load test.
+func (opn *operatorN) StartTimeSeries(w io.Writer) *LoadtTimeSeries {
+	return opn.startTimeSeries(w, loadtTimeSeriesInterval)
+}
+
+func (opn *operatorN) startTimeSeries(w io.Writer, interval time.Duration) *LoadtTimeSeries {
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	ts := &LoadtTimeSeries{
+		w:        w,
+		started:  now,
+		flushed:  now,
+		interval: interval,
+		hist:     newLoadtHistogram(),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	if w != nil {
+		ts.enc = json.NewEncoder(w)
+	}
+	opn.series = ts
+	go func() {
+		defer close(ts.done)
+		ticker := time.NewTicker(ts.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				ts.flush(time.Now(), true)
+				return
+			case now := <-ticker.C:
+				ts.flush(now, false)
+			}
+		}
+	}()
+	return ts
+}
+
+// Stop stops aggregating and writes the result of the last interval.
+func (ts *LoadtTimeSeries) Stop() error {
+	ts.cancel()
+	<-ts.done
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.err
+}
+
+// begin records the start of a RunN.
+func (ts *LoadtTimeSeries) begin() {
+	ts.active.Add(1)
+}
+
+// end records the end of a RunN.
+func (ts *LoadtTimeSeries) end(elapsed time.Duration, err error) {
+	ts.active.Add(-1)
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.latencies = append(ts.latencies, elapsed.Seconds())
+	ts.hist.record(elapsed.Seconds(), err != nil)
+	if err != nil {
+		ts.failed.Add(1)
+		ts.errors++
+		return
+	}
+	ts.succeeded.Add(1)
+}
+
+// histogram returns the cumulative histogram of RunNs.
+func (ts *LoadtTimeSeries) histogram() *loadtHistogram {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.hist.copy()
+}
+
+// flush aggregates the results of the interval. The last interval is not aggregated if it has no results.
+func (ts *LoadtTimeSeries) flush(now time.Time, last bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if last && len(ts.latencies) == 0 {
+		return
+	}
+	d := now.Sub(ts.flushed)
+	ts.flushed = now
+	p := &loadtTimeSeriesPoint{
+		Time:     now,
+		Elapsed:  now.Sub(ts.started).Seconds(),
+		Requests: int64(len(ts.latencies)),
+		Errors:   ts.errors,
+		RPS:      float64(len(ts.latencies)) / d.Seconds(),
+		Active:   ts.active.Load(),
+	}
+	if len(ts.latencies) > 0 {
+		sort.Float64s(ts.latencies)
+		var sum float64
+		for _, l := range ts.latencies {
+			sum += l
+		}
+		p.Avg = sum / float64(len(ts.latencies)) * 1000
+		p.Med = percentile(ts.latencies, 50) * 1000
+		p.P90 = percentile(ts.latencies, 90) * 1000
+		p.P99 = percentile(ts.latencies, 99) * 1000
+		p.Max = ts.latencies[len(ts.latencies)-1] * 1000
+	}
+	ts.latencies = ts.latencies[:0]
+	ts.errors = 0
+	ts.latest = p
+	if ts.enc != nil && ts.err == nil {
+		ts.err = ts.enc.Encode(p)
+	}
+}
+
+// percentile returns the p-th percentile of the sorted values using the nearest-rank method.
+func percentile(sorted []float64, p float64) float64 {
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// MetricsHandler returns the handler that exposes the results of the load test in the Prometheus text format.
+// Gauges are the results of the latest interval.
+func (ts *LoadtTimeSeries) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = ts.WriteMetrics(w)
+	})
+}
+
+// WriteMetrics writes the results of the load test in the Prometheus text format.
+func (ts *LoadtTimeSeries) WriteMetrics(w io.Writer) error {
+	ts.mu.Lock()
+	p := ts.latest
+	ts.mu.Unlock()
+	if p == nil {
+		p = &loadtTimeSeriesPoint{}
+	}
+	metrics := []struct {
+		name  string
+		typ   string
+		help  string
+		value []string
+	}{
+		{"runn_loadt_requests_total", "counter", "Total number of RunNs.", []string{
+			fmt.Sprintf(`{result="succeeded"} %d`, ts.succeeded.Load()),
+			fmt.Sprintf(`{result="failed"} %d`, ts.failed.Load()),
+		}},
+		{"runn_loadt_active", "gauge", "Number of RunNs in progress.", []string{
+			fmt.Sprintf(" %d", ts.active.Load()),
+		}},
+		{"runn_loadt_rps", "gauge", "RunN per second of the latest interval.", []string{
+			fmt.Sprintf(" %s", formatFloat(p.RPS)),
+		}},
+		{"runn_loadt_errors", "gauge", "Number of failed RunNs of the latest interval.", []string{
+			fmt.Sprintf(" %d", p.Errors),
+		}},
+		{"runn_loadt_latency_milliseconds", "gauge", "Latency of RunN of the latest interval.", []string{
+			fmt.Sprintf(`{quantile="0.5"} %s`, formatFloat(p.Med)),
+			fmt.Sprintf(`{quantile="0.9"} %s`, formatFloat(p.P90)),
+			fmt.Sprintf(`{quantile="0.99"} %s`, formatFloat(p.P99)),
+			fmt.Sprintf(`{quantile="1"} %s`, formatFloat(p.Max)),
+		}},
+	}
+	for _, m := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ); err != nil {
+			return err
+		}
+		for _, v := range m.value {
+			if _, err := fmt.Fprintf(w, "%s%s\n", m.name, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
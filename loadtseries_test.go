@@ -0,0 +1,66 @@
+package runn
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/k1LoW/runn/testutil"
+)
+
+func TestLoadtTimeSeries(t *testing.T) {
+	hs := testutil.HTTPServer(t)
+	t.Setenv("TEST_HTTP_ENDPOINT", hs.URL)
+	opn, err := Load("testdata/book/loadt_users.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp, err := ParseLoadProfile([]byte("stages:\n  - duration: 500msec\n    to: 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	ts := opn.startTimeSeries(buf, 100*time.Millisecond)
+	lr, err := opn.RunLoadProfile(context.Background(), lp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests int64
+	s := bufio.NewScanner(buf)
+	for s.Scan() {
+		p := &loadtTimeSeriesPoint{}
+		if err := json.Unmarshal(s.Bytes(), p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Requests > 0 && (p.Med <= 0 || p.Max < p.P99 || p.P99 < p.Med) {
+			t.Errorf("invalid latencies: %#v", p)
+		}
+		requests += p.Requests
+	}
+	if requests != lr.total {
+		t.Errorf("got %d requests, want %d", requests, lr.total)
+	}
+
+	m := new(bytes.Buffer)
+	if err := ts.WriteMetrics(m); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE runn_loadt_requests_total counter\n",
+		`runn_loadt_requests_total{result="failed"} 0`,
+		"runn_loadt_active 0\n",
+		`runn_loadt_latency_milliseconds{quantile="0.99"} `,
+	} {
+		if !strings.Contains(m.String(), want) {
+			t.Errorf("metrics does not contain %q:\n%s", want, m.String())
+		}
+	}
+}
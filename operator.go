@@ -80,7 +80,8 @@ type operator struct {
 	profile         bool
 	interval        time.Duration
 	loop            *Loop
-	loopIndex       *int // Index of the loop is dynamically recorded at runtime
+	loopIndex       *int   // Index of the loop is dynamically recorded at runtime
+	retry           *Retry // Retry policy of the runbook
 	concurrency     []string
 	root            string // Root directory of runbook ( rubbook directory or working directory )
 	t               *testing.T
@@ -106,6 +107,7 @@ type operator struct {
 	dbg             *dbg
 	hasRunnerRunner bool
 	maskRule        *maskedio.Rule
+	statuses        map[int][]int // Response statuses observed by the executed steps for coverage. key is the step index.
 
 	mu sync.Mutex
 }
@@ -154,6 +156,10 @@ func (op *operator) Close(force bool) {
 		if !force && r.target == "" {
 			continue
 		}
+		if r.operatorID == watchRunnerOwnerID {
+			// The connection is kept across runs and closed by the watcher.
+			continue
+		}
 		_ = r.Close()
 	}
 	for _, r := range op.cdpRunners {
@@ -166,11 +172,15 @@ func (op *operator) Close(force bool) {
 		if !force && r.dsn == "" {
 			continue
 		}
+		if r.operatorID == watchRunnerOwnerID {
+			// The connection is kept across runs and closed by the watcher.
+			continue
+		}
 		_ = r.Close()
 	}
 }
 
-func (op *operator) runStep(ctx context.Context, s *step) error {
+func (op *operator) runStep(ctx context.Context, s *step) (rerr error) {
 	idx := s.idx
 	if op.t != nil {
 		op.t.Helper()
@@ -178,6 +188,11 @@ func (op *operator) runStep(ctx context.Context, s *step) error {
 	if err := op.dbg.attach(ctx, s); err != nil {
 		return err
 	}
+	defer func() {
+		if err := op.dbg.attachAfter(ctx, s, rerr); err != nil {
+			rerr = err
+		}
+	}()
 	trs := s.trails()
 	defer op.sw.Start(trs.toProfileIDs()...).Stop()
 	op.capturers.setCurrentTrails(trs)
@@ -241,6 +256,7 @@ func (op *operator) runStep(ctx context.Context, s *step) error {
 			if err := s.httpRunner.Run(ctx, s); err != nil {
 				return fmt.Errorf("http request failed on %s: %w", op.stepName(idx), err)
 			}
+			op.observeStatus(idx, httpStoreResponseKey, httpStoreStatusKey)
 			run = true
 		case s.dbRunner != nil && s.dbQuery != nil:
 			if err := s.dbRunner.Run(ctx, s); err != nil {
@@ -251,6 +267,7 @@ func (op *operator) runStep(ctx context.Context, s *step) error {
 			if err := s.grpcRunner.Run(ctx, s); err != nil {
 				return fmt.Errorf("gRPC request failed on %s: %w", op.stepName(idx), err)
 			}
+			op.observeStatus(idx, grpcStoreResponseKey, grpcStoreStatusKey)
 			run = true
 		case s.cdpRunner != nil && s.cdpActions != nil:
 			if err := s.cdpRunner.Run(ctx, s); err != nil {
@@ -435,6 +452,22 @@ func (op *operator) record(idx int, v map[string]any) {
 	op.store.Record(idx, v)
 }
 
+// observeStatus keeps the response status recorded by the step for coverage.
+func (op *operator) observeStatus(idx int, resKey, statusKey string) {
+	res, ok := op.store.Latest()[resKey].(map[string]any)
+	if !ok {
+		return
+	}
+	status, err := cast.ToIntE(res[statusKey])
+	if err != nil {
+		return
+	}
+	if op.statuses == nil {
+		op.statuses = map[int][]int{}
+	}
+	op.statuses[idx] = append(op.statuses[idx], status)
+}
+
 func (op *operator) recordResult(idx int, v result) error {
 	r := op.Result()
 	r.StepResults = op.StepResults()
@@ -500,6 +533,7 @@ func New(opts ...Option) (*operator, error) {
 		profile:        bk.profile,
 		interval:       bk.interval,
 		loop:           bk.loop,
+		retry:          bk.retry,
 		concurrency:    bk.concurrency,
 		t:              bk.t,
 		thisT:          bk.t,
@@ -526,6 +560,11 @@ func New(opts ...Option) (*operator, error) {
 		op.capturers = append(op.capturers, NewDebugger(op.stderr))
 	}
 
+	if op.retry == nil && bk.retries > 0 {
+		// `retry:` of the runbook takes precedence over the option.
+		op.retry = &Retry{Count: bk.retries}
+	}
+
 	root, err := bk.generateOperatorRoot()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate root path (%s): %w", bk.path, err)
@@ -580,6 +619,7 @@ func New(opts ...Option) (*operator, error) {
 				return nil, fmt.Errorf("failed to cast: %v", v.client.Transport)
 			}
 			tp.DialContext = hostRules.dialContextFunc()
+			v.hostRules = hostRules
 		}
 		op.httpRunners[k] = v
 	}
@@ -633,6 +673,9 @@ func New(opts ...Option) (*operator, error) {
 		if len(hostRules) > 0 {
 			v.opts = append(v.opts, hostRules.chromedpOpt())
 		}
+		if bk.updateBaselines {
+			v.updateBaselines = true
+		}
 		if err := v.Renew(); err != nil {
 			return nil, err
 		}
@@ -1032,11 +1075,7 @@ func (op *operator) run(ctx context.Context) error {
 		op.t.Run(op.testName(), func(t *testing.T) {
 			t.Helper()
 			op.thisT = t
-			if op.loop != nil {
-				err = op.runLoop(ctx)
-			} else {
-				err = op.runInternal(ctx)
-			}
+			err = op.runWithRetry(ctx)
 			if err != nil {
 				// Skip parent runner t.Error if there is an error in the included runbook
 				if !errors.Is(&includedRunErr{}, err) {
@@ -1072,17 +1111,40 @@ func (op *operator) run(ctx context.Context) error {
 		}
 		return nil
 	}
-	if op.loop != nil {
-		err = op.runLoop(ctx)
-	} else {
-		err = op.runInternal(ctx)
-	}
-	if err != nil {
+	if err := op.runWithRetry(ctx); err != nil {
 		return fmt.Errorf("failed to run %s: %w", op.bookPathOrID(), err)
 	}
 	return nil
 }
 
+// runWithRetry runs the runbook and runs it again according to the retry policy if it fails.
+// The results of the failed attempts are kept in RunResult.Attempts.
+// Included runbooks are not retried by themselves ( the root runbook is retried ) .
+func (op *operator) runWithRetry(ctx context.Context) error {
+	var attempts []*RunResult
+	for i := 0; ; i++ {
+		var err error
+		start := time.Now()
+		if op.loop != nil {
+			err = op.runLoop(ctx)
+		} else {
+			err = op.runInternal(ctx)
+		}
+		if err == nil || op.retry == nil || op.included || i >= op.retry.Count || ctx.Err() != nil {
+			op.runResult.Attempts = attempts
+			op.runResult.Flaky = err == nil && len(attempts) > 0
+			return err
+		}
+		op.runResult.Elapsed = time.Since(start)
+		op.Debugf(yellow("Retry runbook (%d/%d): %s\n"), i+1, op.retry.Count, op.bookPathOrID())
+		if werr := op.retry.wait(ctx); werr != nil {
+			op.runResult.Attempts = attempts
+			return errors.Join(err, werr)
+		}
+		attempts = append(attempts, op.runResult)
+	}
+}
+
 func (op *operator) runLoop(ctx context.Context) error {
 	if op.loop == nil {
 		panic("invalid usage")
@@ -1257,15 +1319,26 @@ func (op *operator) runInternal(ctx context.Context) (rerr error) {
 	failed := false
 	force := op.force
 	var deferred []*deferredOpAndStep
+	stepErrs := map[int]error{}
+	retried := false
 
-	for _, s := range op.steps {
+	for i := 0; i < len(op.steps); i++ {
+		s := op.steps[i]
 		if s.deferred {
+			if slices.ContainsFunc(deferred, func(d *deferredOpAndStep) bool { return d.step == s }) {
+				// Already registered before rewinding
+				continue
+			}
 			d := &deferredOpAndStep{op: op, step: s}
 			deferred = append([]*deferredOpAndStep{d}, deferred...)
 			op.deferred.steps = append([]*deferredOpAndStep{d}, op.deferred.steps...)
 			op.record(s.idx, nil)
 			continue
 		}
+		if op.dbg.enabled() && !retried {
+			s.snapshot = op.store.Snapshot()
+		}
+		retried = false
 		if failed && !force && !s.force {
 			s.setResult(errStepSkipped)
 			op.recordNotRun(s.idx)
@@ -1275,6 +1348,36 @@ func (op *operator) runInternal(ctx context.Context) (rerr error) {
 			continue
 		}
 		err := op.runStep(ctx, s)
+		rewound := &stepRewoundError{}
+		switch {
+		case errors.Is(err, errStepRetried):
+			// Run the step again
+			op.store.ClearStep(s.idx)
+			s.clearResult()
+			retried = true
+			i--
+			continue
+		case errors.As(err, &rewound):
+			// Rewind to the step by restoring the store snapshot taken before it
+			op.store.Restore(op.steps[rewound.idx].snapshot)
+			for _, ss := range op.steps[rewound.idx : i+1] {
+				ss.clearResult()
+			}
+			rerr, failed = nil, false
+			for _, ss := range op.steps[:rewound.idx] {
+				if err, ok := stepErrs[ss.idx]; ok {
+					rerr = errors.Join(rerr, err)
+					failed = true
+				}
+			}
+			for idx := range stepErrs {
+				if idx >= rewound.idx {
+					delete(stepErrs, idx)
+				}
+			}
+			i = rewound.idx - 1
+			continue
+		}
 		s.setResult(err)
 		switch {
 		case errors.Is(errStepSkipped, err):
@@ -1288,6 +1391,7 @@ func (op *operator) runInternal(ctx context.Context) (rerr error) {
 				return err
 			}
 			rerr = errors.Join(rerr, err)
+			stepErrs[s.idx] = err
 			failed = true
 		default:
 			if err := op.recordResult(s.idx, resultSuccess); err != nil {
@@ -1478,6 +1582,9 @@ type operatorN struct {
 	runNIndex    atomic.Int64 // runNIndex holds the runN execution index (starting from 0). It is incremented each time runN is executed
 	kv           *kv.KV
 	dbg          *dbg
+	breakdown    *loadtBreakdown  // breakdown collects the latencies of each runbook and step of the load test. It is set by Init.
+	loadtWarmUp  time.Duration    // loadtWarmUp is the warm-up time of the load test excluded from the breakdown. It is set by SetLoadtWarmUp.
+	series       *LoadtTimeSeries // series aggregates the results of the load test per second. It is set by StartTimeSeries.
 	mu           sync.Mutex
 }
 
@@ -1561,6 +1668,18 @@ func Load(pathp string, opts ...Option) (*operatorN, error) {
 			op.Debugf(yellow("Skip %s because it does not match %s\n"), p, bk.runMatch.String())
 			continue
 		}
+		// --rerun-failed
+		if bk.rerunFailed != nil && !bk.rerunFailed.contains(op) {
+			op.Debugf(yellow("Skip %s because it did not fail in the previous run\n"), p)
+			continue
+		}
+		// --watch
+		if bk.runPaths != nil {
+			if _, ok := bk.runPaths[p]; !ok {
+				op.Debugf(yellow("Skip %s because it is not affected by the changes\n"), p)
+				continue
+			}
+		}
 		// RUNN_LABEL, --label
 		tf, err := expr.EvalCond(cond, labelEnv(op.labels))
 		if err != nil {
@@ -1615,6 +1734,13 @@ func Load(pathp string, opts ...Option) (*operatorN, error) {
 	if err := opn.skipIncludedOperators(); err != nil {
 		return nil, err
 	}
+	if bk.dapAddr != "" {
+		d, err := newDAPServer(bk.dapAddr)
+		if err != nil {
+			return nil, err
+		}
+		opn.dbg.dap = d
+	}
 	return opn, nil
 }
 
@@ -1634,6 +1760,7 @@ func (opn *operatorN) RunN(ctx context.Context) (err error) {
 		}
 		err = errors.Join(err, errr)
 		opn.nm.Close()
+		opn.dbg.close()
 	}()
 	if opn.t != nil {
 		opn.t.Helper()
@@ -1682,9 +1809,16 @@ func (opn *operatorN) DumpProfile(w io.Writer) error {
 // Init initializes the operatorN for use with otchkiss.
 // This is part of the otchkiss.Requester interface implementation.
 func (opn *operatorN) Init() error {
+	opn.breakdown = newLoadtBreakdown(opn.loadtWarmUp)
 	return nil
 }
 
+// SetLoadtWarmUp sets the warm-up time of the load test. The results within the warm-up time are excluded from the breakdown of each runbook and step.
+// It must be called before Init.
+func (opn *operatorN) SetLoadtWarmUp(w time.Duration) {
+	opn.loadtWarmUp = w
+}
+
 // RequestOne executes a single request as part of the otchkiss.Requester interface.
 // It runs the runbooks and handles profiling.
 func (opn *operatorN) RequestOne(ctx context.Context) error {
@@ -1692,14 +1826,20 @@ func (opn *operatorN) RequestOne(ctx context.Context) error {
 		opn.sw.Disable()
 	}
 	ctx = context.WithoutCancel(ctx)
+	if opn.series != nil {
+		opn.series.begin()
+	}
 	result, err := opn.runN(ctx)
-	if err != nil {
-		return err
+	if err == nil && result.HasFailure() {
+		err = errors.New("result has failure")
 	}
-	if result.HasFailure() {
-		return errors.New("result has failure")
+	if opn.breakdown != nil {
+		opn.breakdown.record(result)
 	}
-	return nil
+	if opn.series != nil {
+		opn.series.end(result.elapsed, err)
+	}
+	return err
 }
 
 // Terminate cleans up resources as part of the otchkiss.Requester interface.
@@ -1784,23 +1924,8 @@ func (opn *operatorN) CollectCoverage(ctx context.Context) (*Coverage, error) {
 		if err != nil {
 			return nil, err
 		}
-		// Merge coverage
-		for _, sc := range c.Specs {
-			spec, ok := lo.Find(cov.Specs, func(i *SpecCoverage) bool {
-				return sc.Key == i.Key
-			})
-			if !ok {
-				cov.Specs = append(cov.Specs, sc)
-				continue
-			}
-			for k, v := range sc.Coverages {
-				spec.Coverages[k] += v
-			}
-		}
+		cov.Merge(c)
 	}
-	sort.SliceStable(cov.Specs, func(i, j int) bool {
-		return cov.Specs[i].Key < cov.Specs[j].Key
-	})
 	return cov, nil
 }
 
@@ -1831,6 +1956,10 @@ func (opn *operatorN) runN(ctx context.Context) (*runNResult, error) {
 	if opn.t != nil {
 		opn.t.Helper()
 	}
+	started := time.Now()
+	defer func() {
+		result.elapsed = time.Since(started)
+	}()
 	defer opn.sw.Start().Stop()
 	defer opn.Close()
 	runNIndex := opn.runNIndex.Add(1)
@@ -1841,6 +1970,13 @@ func (opn *operatorN) runN(ctx context.Context) (*runNResult, error) {
 		return result, err
 	}
 	result.Total.Add(int64(len(selected)))
+	if opn.breakdown != nil {
+		// Measure the elapsed time of each runbook and step per RunN
+		sw := stopw.New()
+		for _, op := range selected {
+			op.sw = sw
+		}
+	}
 	for _, op := range selected {
 		op.store.SetRunNIndex(int(runNIndex)) // Set runN index
 		cg.GoMulti(op.concurrency, func() error {
@@ -1849,6 +1985,7 @@ func (opn *operatorN) runN(ctx context.Context) (*runNResult, error) {
 				op.capturers.captureResult(op.trails(), r)
 				op.capturers.captureEnd(op.trails(), op.bookPath, op.desc)
 				op.Close(false)
+				opn.dbg.finish(op)
 				result.mu.Lock()
 				result.RunResults = append(result.RunResults, r)
 				result.mu.Unlock()
@@ -502,6 +502,16 @@ func GrpcRunnerWithOptions(name, target string, opts ...grpcRunnerOption) Option
 			r.bufLocks = c.BufLocks
 			r.bufConfigs = c.BufConfigs
 			r.bufModules = c.BufModules
+			if err := validateGRPCProtocol(c.Protocol, c.Codec); err != nil {
+				bk.runnerErrs[name] = err
+				return nil
+			}
+			r.protocol = c.Protocol
+			r.codec = c.Codec
+			if err := r.setClientOptions(c); err != nil {
+				bk.runnerErrs[name] = err
+				return nil
+			}
 			r.skipVerify = c.SkipVerify
 			r.trace = c.Trace.Enable
 			r.traceHeaderName = c.Trace.HeaderName
@@ -764,6 +774,21 @@ func Interval(d time.Duration) Option {
 	}
 }
 
+// Retries - Set the number of times to retry a failed runbook.
+// `retry:` of the runbook takes precedence.
+func Retries(n int) Option {
+	return func(bk *book) error {
+		if bk == nil {
+			return ErrNilBook
+		}
+		if n < 0 {
+			return fmt.Errorf("invalid number of retries: %d", n)
+		}
+		bk.retries = n
+		return nil
+	}
+}
+
 // FailFast - Enable fail-fast.
 func FailFast(enable bool) Option {
 	return func(bk *book) error {
@@ -825,6 +850,17 @@ func Trace(enable bool) Option {
 	}
 }
 
+// UpdateBaselines - Overwrite baseline images of `compareScreenshot` with the captured screenshots.
+func UpdateBaselines(enable bool) Option {
+	return func(bk *book) error {
+		if bk == nil {
+			return ErrNilBook
+		}
+		bk.updateBaselines = enable
+		return nil
+	}
+}
+
 // Attach - Enable or disable debbuging attachment.
 func Attach(enable bool) Option {
 	return func(bk *book) error {
@@ -840,6 +876,20 @@ func Attach(enable bool) Option {
 	}
 }
 
+// DAP - Start the Debug Adapter Protocol server on the address and wait for a DAP client before running steps.
+func DAP(addr string) Option {
+	return func(bk *book) error {
+		if bk == nil {
+			return ErrNilBook
+		}
+		if addr != "" && bk.attach {
+			return fmt.Errorf("cannot enable both attach and DAP")
+		}
+		bk.dapAddr = addr
+		return nil
+	}
+}
+
 // WaitTimeout - Set the timeout for waiting for sub-processes to complete after the Run or RunN context is canceled.
 func WaitTimeout(d time.Duration) Option {
 	return func(bk *book) error {
@@ -1076,6 +1126,24 @@ func RunID(ids ...string) Option { //nostyle:repetition
 	}
 }
 
+// RerunFailed - Run only runbooks that failed in the previous run recorded in the result file ( `runn run --format json` ) .
+func RerunFailed(p string) Option {
+	return func(bk *book) error {
+		if bk == nil {
+			return ErrNilBook
+		}
+		if p == "" {
+			return nil
+		}
+		f, err := readFailedRunbooks(p)
+		if err != nil {
+			return err
+		}
+		bk.rerunFailed = f
+		return nil
+	}
+}
+
 // RunLabel - Run all runbooks matching the label specification.
 func RunLabel(labels ...string) Option { //nostyle:repetition
 	return func(bk *book) error {
@@ -1271,6 +1339,20 @@ func Books(pathp string) ([]Option, error) {
 	return opts, nil
 }
 
+// runPaths - Run only the runbooks of the paths.
+func runPaths(paths []string) Option {
+	return func(bk *book) error {
+		if bk == nil {
+			return ErrNilBook
+		}
+		bk.runPaths = map[string]struct{}{}
+		for _, p := range paths {
+			bk.runPaths[p] = struct{}{}
+		}
+		return nil
+	}
+}
+
 func reuseHTTPRunner(name string, r *httpRunner) Option {
 	return func(bk *book) error {
 		if bk == nil {
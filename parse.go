@@ -1,6 +1,7 @@
 package runn
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/goccy/go-yaml"
 	"github.com/k1LoW/duration"
+	"github.com/spf13/cast"
 	"google.golang.org/grpc/metadata"
 )
 
@@ -339,7 +341,11 @@ func parseCDPActions(v map[string]any, s *step, expand func(any, *step) (any, er
 				ca.Fn = k
 				switch vvvv := vvv.(type) {
 				case string:
-					ca.Args[fn.Args[0].Key] = vvvv
+					args := fn.Args.ArgArgs()
+					if len(args) == 0 {
+						return nil, fmt.Errorf("invalid action args: %s does not take a value: %s(%v)", k, k, vvv)
+					}
+					ca.Args[args[0].Key] = vvvv
 				case map[string]any:
 					ca.Args = vvvv
 				default:
@@ -453,9 +459,123 @@ func parseExecCommand(v map[string]any) (*execCommand, error) {
 			c.env[k] = vs
 		}
 	}
+	t, ok := v["timeout"]
+	if ok {
+		d, err := parseDuration(cast.ToString(t))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout: %s", string(part))
+		}
+		c.timeout = d
+	}
+	d, ok := v["dir"]
+	if ok {
+		dir, ok := d.(string)
+		if !ok || dir == "" {
+			return nil, fmt.Errorf("invalid dir: %s", string(part))
+		}
+		c.dir = dir
+	}
+	ec, ok := v["expectedExitCode"]
+	if ok {
+		codes, err := parseExpectedExitCodes(ec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expectedExitCode: %s", string(part))
+		}
+		c.expectedExitCodes = codes
+	}
+	r, ok := v["ready"]
+	if ok {
+		if !c.background {
+			return nil, fmt.Errorf("ready can only be used with background: true: %s", string(part))
+		}
+		rm, ok := r.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid ready: %s", string(part))
+		}
+		ready, err := parseExecReadiness(rm)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ready: %w: %s", err, string(part))
+		}
+		c.ready = ready
+	}
 	return c, nil
 }
 
+func parseExpectedExitCodes(v any) ([]int, error) {
+	switch vv := v.(type) {
+	case []any:
+		if len(vv) == 0 {
+			return nil, errors.New("empty exit codes")
+		}
+		var codes []int
+		for _, c := range vv {
+			code, err := cast.ToIntE(c)
+			if err != nil {
+				return nil, err
+			}
+			codes = append(codes, code)
+		}
+		return codes, nil
+	default:
+		code, err := cast.ToIntE(vv)
+		if err != nil {
+			return nil, err
+		}
+		return []int{code}, nil
+	}
+}
+
+func parseExecReadiness(v map[string]any) (*execReadiness, error) {
+	r := &execReadiness{
+		timeout:  execDefaultReadyTimeout,
+		interval: execDefaultReadyInterval,
+	}
+	for k, vv := range v {
+		switch k {
+		case "stdout":
+			s, ok := vv.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid stdout: %v", vv)
+			}
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, err
+			}
+			r.stdout = re
+		case "tcp":
+			s, ok := vv.(string)
+			if !ok || s == "" {
+				return nil, fmt.Errorf("invalid tcp: %v", vv)
+			}
+			r.tcp = s
+		case "http":
+			s, ok := vv.(string)
+			if !ok || s == "" {
+				return nil, fmt.Errorf("invalid http: %v", vv)
+			}
+			r.http = s
+		case "timeout":
+			d, err := parseDuration(cast.ToString(vv))
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid timeout: %v", vv)
+			}
+			r.timeout = d
+		case "interval":
+			d, err := parseDuration(cast.ToString(vv))
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid interval: %v", vv)
+			}
+			r.interval = d
+		default:
+			return nil, fmt.Errorf("unknown key: %s", k)
+		}
+	}
+	if r.stdout == nil && r.tcp == "" && r.http == "" {
+		return nil, errors.New("one of stdout, tcp or http is required")
+	}
+	return r, nil
+}
+
 func parseIncludeConfig(v any) (*includeConfig, error) {
 	c := &includeConfig{vars: map[string]any{}}
 	switch vv := v.(type) {
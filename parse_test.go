@@ -2,6 +2,7 @@ package runn
 
 import (
 	"net/http"
+	"regexp"
 	"testing"
 	"time"
 
@@ -400,6 +401,78 @@ my.custom.server.Service/Method:
 	}
 }
 
+func TestParseCDPActions(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    CDPActions
+		wantErr bool
+	}{
+		{
+			`
+actions:
+  - navigate: https://example.com
+  - mainFrame
+  - click:
+      sel: "#submit"
+`,
+			CDPActions{
+				{Fn: "navigate", Args: map[string]any{"url": "https://example.com"}},
+				{Fn: "mainFrame", Args: map[string]any{}},
+				{Fn: "click", Args: map[string]any{"sel": "#submit"}},
+			},
+			false,
+		},
+		{
+			`
+actions:
+  - mainFrame: ""
+`,
+			nil,
+			true,
+		},
+		{
+			`
+actions:
+  - clearCookies: x
+`,
+			nil,
+			true,
+		},
+		{
+			`
+actions:
+  - unknownAction: x
+`,
+			nil,
+			true,
+		},
+	}
+	o, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		var v map[string]any
+		if err := yaml.Unmarshal([]byte(tt.in), &v); err != nil {
+			t.Fatal(err)
+		}
+		got, err := parseCDPActions(v, &step{}, o.expandBeforeRecord)
+		if err != nil {
+			if !tt.wantErr {
+				t.Error(err)
+			}
+			continue
+		}
+		if tt.wantErr {
+			t.Error("want error")
+		}
+		if diff := cmp.Diff(got, tt.want); diff != "" {
+			t.Error(diff)
+		}
+	}
+}
+
 func TestParseExecCommand(t *testing.T) {
 	tests := []struct {
 		in      string
@@ -474,6 +547,80 @@ env:
 command: echo test
 env:
   INVALID_VALUE: 123
+`,
+			nil,
+			true,
+		},
+		{
+			`
+command: make build
+timeout: 30sec
+dir: ./app
+expectedExitCode: [0, 2]
+`,
+			&execCommand{
+				command:           "make build",
+				timeout:           30 * time.Second,
+				dir:               "./app",
+				expectedExitCodes: []int{0, 2},
+			},
+			false,
+		},
+		{
+			`
+command: exit 1
+expectedExitCode: 1
+`,
+			&execCommand{
+				command:           "exit 1",
+				expectedExitCodes: []int{1},
+			},
+			false,
+		},
+		{
+			`
+command: echo test
+timeout: invalid
+`,
+			nil,
+			true,
+		},
+		{
+			`
+command: ./server
+background: true
+ready:
+  stdout: 'listening on :\d+'
+  tcp: 127.0.0.1:8080
+  timeout: 10sec
+`,
+			&execCommand{
+				command:    "./server",
+				background: true,
+				ready: &execReadiness{
+					stdout:   regexp.MustCompile(`listening on :\d+`),
+					tcp:      "127.0.0.1:8080",
+					timeout:  10 * time.Second,
+					interval: execDefaultReadyInterval,
+				},
+			},
+			false,
+		},
+		{
+			`
+command: ./server
+ready:
+  tcp: 127.0.0.1:8080
+`,
+			nil,
+			true,
+		},
+		{
+			`
+command: ./server
+background: true
+ready:
+  timeout: 10sec
 `,
 			nil,
 			true,
@@ -495,8 +642,13 @@ env:
 		if tt.wantErr {
 			t.Error("want error")
 		}
-		opts := cmp.AllowUnexported(execCommand{})
-		if diff := cmp.Diff(got, tt.want, opts); diff != "" {
+		opts := []cmp.Option{
+			cmp.AllowUnexported(execCommand{}, execReadiness{}),
+			cmp.Comparer(func(x, y *regexp.Regexp) bool {
+				return x.String() == y.String()
+			}),
+		}
+		if diff := cmp.Diff(got, tt.want, opts...); diff != "" {
 			t.Error(diff)
 		}
 	}
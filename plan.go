@@ -0,0 +1,268 @@
+package runn
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+
+	"github.com/goccy/go-json"
+	"github.com/goccy/go-yaml"
+	"github.com/k1LoW/runn/internal/expr"
+	"github.com/k1LoW/runn/internal/store"
+)
+
+// planRuntimeRootKeys are the root keys of the store whose values are not available until the steps run.
+var planRuntimeRootKeys = []string{
+	store.RootKeySteps,
+	store.RootKeyPrevious,
+	store.RootKeyCurrent,
+	store.RootKeyParent,
+	store.RootKeyIncluded,
+	store.RootKeyNeeds,
+	store.RootKeyCookie,
+	store.RootKeyNodes,
+	store.RootKeyLoopCountIndex,
+	store.RootKeyRunn,
+}
+
+// Plan is the plan of the run ( dry-run ) .
+type Plan struct {
+	Runbooks []*RunbookPlan `json:"runbooks"`
+}
+
+// RunbookPlan is the plan of the runbook.
+type RunbookPlan struct {
+	ID     string            `json:"id"`
+	Desc   string            `json:"desc,omitempty"`
+	Path   string            `json:"path"`
+	Labels []string          `json:"labels,omitempty"`
+	Needs  map[string]string `json:"needs,omitempty"`
+	If     *PlanCond         `json:"if,omitempty"`
+	Loop   any               `json:"loop,omitempty"`
+	Vars   map[string]any    `json:"vars,omitempty"`
+	Steps  []*StepPlan       `json:"steps"`
+}
+
+// StepPlan is the plan of the step.
+type StepPlan struct {
+	Index      int        `json:"index"`
+	Key        string     `json:"key,omitempty"`
+	Desc       string     `json:"desc,omitempty"`
+	If         *PlanCond  `json:"if,omitempty"`
+	Loop       any        `json:"loop,omitempty"`
+	Defer      bool       `json:"defer,omitempty"`
+	RunnerKey  string     `json:"runner,omitempty"`
+	RunnerType RunnerType `json:"type,omitempty"`
+	// Request - The request of the runner expanded with the values available before running.
+	Request any    `json:"request,omitempty"`
+	Bind    any    `json:"bind,omitempty"`
+	Test    string `json:"test,omitempty"`
+	// Unresolved - The expressions that depend on the values not available until the steps run ( e.g. `steps[0].res.body` ) .
+	Unresolved []string `json:"unresolved,omitempty"`
+	// Error - The error of expanding the request.
+	Error string `json:"error,omitempty"`
+}
+
+// PlanCond is the condition of `if:` and the result if it can be evaluated before running.
+type PlanCond struct {
+	Cond string `json:"cond"`
+	// Result - The result of the condition. nil if the condition depends on the values not available until the steps run.
+	Result *bool `json:"result"`
+}
+
+// Plan returns the plan of the selected runbooks without running them.
+// The requests of the steps are expanded with the values available before running ( vars, env, ... ) ,
+// and the expressions that depend on the results of the steps are left as they are.
+func (opn *operatorN) Plan() (*Plan, error) {
+	selected, err := opn.SelectedOperators()
+	if err != nil {
+		return nil, err
+	}
+	p := &Plan{Runbooks: []*RunbookPlan{}}
+	for _, op := range selected {
+		rp, err := op.plan()
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan (%s): %w", op.bookPath, err)
+		}
+		p.Runbooks = append(p.Runbooks, rp)
+	}
+	return p, nil
+}
+
+type planner struct {
+	op       *operator
+	sm       map[string]any
+	bindKeys []string
+}
+
+func (op *operator) plan() (*RunbookPlan, error) {
+	if len(op.steps) != op.numberOfSteps {
+		// Invalid steps are skipped when loading only.
+		return nil, fmt.Errorf("failed to load %d of %d steps (see `runn lint` for details)", op.numberOfSteps-len(op.steps), op.numberOfSteps)
+	}
+	pl := &planner{
+		op: op,
+		sm: op.store.ToMap(), // ToMap also sets the secrets to be masked.
+	}
+	for _, s := range op.steps {
+		for k := range s.bindCond {
+			pl.bindKeys = append(pl.bindKeys, k)
+		}
+	}
+	vars, _ := pl.mask(pl.sm[store.RootKeyVars]).(map[string]any)
+	rp := &RunbookPlan{
+		ID:     op.id,
+		Desc:   op.desc,
+		Path:   op.bookPath,
+		Labels: op.labels,
+		If:     pl.cond(op.ifCond),
+		Vars:   vars,
+		Steps:  []*StepPlan{},
+	}
+	if len(op.needs) > 0 {
+		rp.Needs = map[string]string{}
+		for k, n := range op.needs {
+			rp.Needs[k] = n.path
+		}
+	}
+	if op.loop != nil {
+		rp.Loop = loopPlan(op.loop)
+	}
+	for _, s := range op.steps {
+		rp.Steps = append(rp.Steps, pl.step(s))
+	}
+	return rp, nil
+}
+
+func (pl *planner) step(s *step) *StepPlan {
+	sp := &StepPlan{
+		Index:      s.idx,
+		Key:        s.key,
+		Desc:       s.desc,
+		If:         pl.cond(s.ifCond),
+		Defer:      s.deferred,
+		RunnerKey:  s.runnerKey,
+		RunnerType: s.generateTrail().StepRunnerType,
+		Test:       pl.op.maskRule.Mask(s.testCond),
+	}
+	if sp.If != nil && sp.If.Result == nil {
+		sp.Unresolved = append(sp.Unresolved, sp.If.Cond)
+	}
+	if s.loop != nil {
+		sp.Loop = loopPlan(s.loop)
+	}
+	if s.runnerKey != "" {
+		if v, ok := s.rawStep[s.runnerKey]; ok {
+			req, left, err := expr.ExpandPartially(v, pl.sm, pl.resolvable)
+			if err != nil {
+				// Show the request as it is
+				req = v
+				sp.Error = fmt.Sprintf("failed to expand the request: %v", err)
+			}
+			sp.Request = pl.mask(req)
+			sp.Unresolved = append(sp.Unresolved, left...)
+		}
+	}
+	if s.bindCond != nil {
+		sp.Bind = pl.mask(s.bindCond)
+	}
+	sp.Unresolved = sortedUnique(sp.Unresolved)
+	return sp
+}
+
+// cond evaluates the condition if it does not depend on the values not available until the steps run.
+func (pl *planner) cond(c string) *PlanCond {
+	if c == "" {
+		return nil
+	}
+	pc := &PlanCond{Cond: pl.op.maskRule.Mask(c)}
+	if !pl.resolvable(c) {
+		return pc
+	}
+	tf, err := expr.EvalCond(c, pl.sm)
+	if err != nil {
+		return pc
+	}
+	pc.Result = &tf
+	return pc
+}
+
+// resolvable reports whether the expression can be evaluated before running.
+func (pl *planner) resolvable(e string) bool {
+	refs, err := expr.References(e)
+	if err != nil {
+		return false
+	}
+	for _, r := range refs {
+		if slices.Contains(planRuntimeRootKeys, r.Root) || slices.Contains(pl.bindKeys, r.Root) {
+			return false
+		}
+	}
+	if _, err := expr.Eval(e, pl.sm); err != nil {
+		return false
+	}
+	return true
+}
+
+func loopPlan(l *Loop) map[string]any {
+	m := map[string]any{"count": l.Count}
+	if l.Until != "" {
+		m["until"] = l.Until
+	}
+	if l.Interval != "" {
+		m["interval"] = l.Interval
+	}
+	return m
+}
+
+// mask masks the secrets in the string values.
+func (pl *planner) mask(v any) any {
+	switch vv := v.(type) {
+	case string:
+		return pl.op.maskRule.Mask(vv)
+	case map[string]any:
+		m := make(map[string]any, len(vv))
+		for k, vvv := range vv {
+			m[k] = pl.mask(vvv)
+		}
+		return m
+	case []any:
+		s := make([]any, 0, len(vv))
+		for _, vvv := range vv {
+			s = append(s, pl.mask(vvv))
+		}
+		return s
+	default:
+		return vv
+	}
+}
+
+func sortedUnique(in []string) []string {
+	if len(in) == 0 {
+		return nil
+	}
+	out := slices.Clone(in)
+	sort.Strings(out)
+	return slices.Compact(out)
+}
+
+// OutYAML writes the plan as YAML.
+func (p *Plan) OutYAML(w io.Writer) error {
+	b, err := yaml.MarshalWithOptions(p, yaml.UseJSONMarshaler())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// OutJSON writes the plan as JSON.
+func (p *Plan) OutJSON(w io.Writer) error {
+	b, err := json.MarshalIndentWithOption(p, "", "  ", json.DisableHTMLEscape())
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
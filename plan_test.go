@@ -0,0 +1,99 @@
+package runn
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPlan(t *testing.T) {
+	opn, err := Load("testdata/plan/plan.yml", LoadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := opn.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Runbooks) != 1 {
+		t.Fatalf("got %d runbooks", len(p.Runbooks))
+	}
+	rp := p.Runbooks[0]
+	tr := true
+	if diff := cmp.Diff(&PlanCond{Cond: `vars.user == "alice"`, Result: &tr}, rp.If); diff != "" {
+		t.Error(diff)
+	}
+	if got := rp.Vars["token"]; got != "*****" {
+		t.Errorf("secrets should be masked: %v", got)
+	}
+	want := []*StepPlan{
+		{
+			Index:      0,
+			Key:        "login",
+			RunnerKey:  "req",
+			RunnerType: RunnerTypeHTTP,
+			Request: map[string]any{
+				"/login?u=alice": map[string]any{
+					"post": map[string]any{
+						"body": map[string]any{
+							"application/json": map[string]any{
+								"user":  "alice",
+								"token": "*****",
+							},
+						},
+					},
+				},
+			},
+			Bind: map[string]any{"session": "current.res.body.session"},
+		},
+		{
+			Index:      1,
+			Key:        "me",
+			If:         &PlanCond{Cond: "steps.login.res.status == 200"},
+			RunnerKey:  "req",
+			RunnerType: RunnerTypeHTTP,
+			Request: map[string]any{
+				"/me": map[string]any{
+					"get": map[string]any{
+						"headers": map[string]any{
+							"Authorization": "Bearer {{ session }} on example.com",
+						},
+					},
+				},
+			},
+			Test:       "current.res.status == 200",
+			Unresolved: []string{"session", "steps.login.res.status == 200"},
+		},
+	}
+	if diff := cmp.Diff(want, rp.Steps); diff != "" {
+		t.Error(diff)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := p.OutYAML(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("Authorization: Bearer {{ session }} on example.com")) {
+		t.Errorf("got %s", buf.String())
+	}
+}
+
+func TestPlanWithNeeds(t *testing.T) {
+	opn, err := Load("testdata/book/needs_3.yml", LoadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := opn.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, rp := range p.Runbooks {
+		got = append(got, rp.Path)
+	}
+	want := []string{"testdata/book/needs_1.yml", "testdata/book/needs_2.yml", "testdata/book/needs_3.yml"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+}
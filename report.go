@@ -0,0 +1,396 @@
+package runn
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/k1LoW/runn/internal/fs"
+	"github.com/k1LoW/runn/version"
+	"github.com/k1LoW/stopw"
+	"github.com/samber/lo"
+)
+
+// reportProfileMaxDepth is the max depth of profile spans in the HTML report.
+const reportProfileMaxDepth = 4
+
+type htmlReport struct {
+	Version     string
+	GeneratedAt time.Time
+	Summary     runNResultSimplified
+	Runbooks    []*reportRunbook
+	Failures    []*reportFailure
+	Coverage    []*reportSpec
+	Profile     []*reportSpan
+}
+
+type reportRunbook struct {
+	ID      string
+	Desc    string
+	Path    string
+	Result  result
+	Elapsed time.Duration
+	Steps   []*reportStep
+}
+
+type reportStep struct {
+	Key      string
+	Desc     string
+	Result   result
+	Err      string
+	Elapsed  time.Duration
+	Included []*reportRunbook
+}
+
+type reportFailure struct {
+	ID    string
+	Trace []string
+	Err   string
+	Step  string
+}
+
+type reportSpec struct {
+	Key        string
+	Covered    int
+	Total      int
+	Operations []*reportOperation
+}
+
+type reportOperation struct {
+	Key     string
+	Count   int
+	Details []*reportDetail
+}
+
+type reportDetail struct {
+	Name  string
+	Items []reportDetailItem
+	// Undocumented reports whether the items are observed but not documented (not part of the coverage).
+	Undocumented bool
+}
+
+type reportDetailItem struct {
+	Key   string
+	Count int
+}
+
+type reportSpan struct {
+	Name    string
+	Depth   int
+	Elapsed time.Duration
+	Percent float64
+}
+
+// DumpReport writes a self-contained HTML report of the run results, the coverage and the profile.
+// The coverage is included only when cov is not nil (e.g. `--coverage`), and the profile only when profiling is enabled (e.g. `--profile`).
+func (opn *operatorN) DumpReport(w io.Writer, cov *Coverage) error {
+	if len(opn.results) == 0 {
+		return fmt.Errorf("no run results")
+	}
+	r := opn.Result()
+	rep := &htmlReport{
+		Version:     version.Version,
+		GeneratedAt: time.Now(),
+		Summary:     r.simplify(),
+	}
+	rep.Summary.Elapsed = r.elapsed
+	for _, rr := range r.RunResults {
+		rep.Runbooks = append(rep.Runbooks, newReportRunbook(rr))
+		failures, err := newReportFailures(rr)
+		if err != nil {
+			return err
+		}
+		rep.Failures = append(rep.Failures, failures...)
+	}
+	if cov != nil {
+		rep.Coverage = newReportCoverage(cov)
+	}
+	if opn.profile {
+		if s := opn.sw.Result(); s != nil {
+			rep.Profile = newReportProfile(s)
+		}
+	}
+	return htmlReportTmpl.Execute(w, rep)
+}
+
+func newReportRunbook(rr *RunResult) *reportRunbook {
+	res := resultSuccess
+	switch {
+	case rr.Err != nil:
+		res = resultFailure
+	case rr.Skipped:
+		res = resultSkipped
+	}
+	rb := &reportRunbook{
+		ID:      rr.ID,
+		Desc:    rr.Desc,
+		Path:    normalizePath(rr.Path),
+		Result:  res,
+		Elapsed: rr.Elapsed,
+	}
+	for _, sr := range rr.StepResults {
+		st := &reportStep{
+			Key:     sr.Key,
+			Desc:    sr.Desc,
+			Result:  resultSuccess,
+			Elapsed: sr.Elapsed,
+		}
+		switch {
+		case sr.Err != nil:
+			st.Result = resultFailure
+			st.Err = sr.Err.Error()
+		case sr.Skipped:
+			st.Result = resultSkipped
+		}
+		for _, ir := range sr.IncludedRunResults {
+			st.Included = append(st.Included, newReportRunbook(ir))
+		}
+		rb.Steps = append(rb.Steps, st)
+	}
+	return rb
+}
+
+func newReportFailures(rr *RunResult) ([]*reportFailure, error) {
+	var failures []*reportFailure
+	paths, indexes, errs := failedRunbookPathsAndErrors(rr)
+	for i, p := range paths {
+		f := &reportFailure{
+			ID:    rr.ID,
+			Trace: lo.Map(p, func(pp string, _ int) string { return normalizePath(pp) }),
+			Err:   strings.TrimRight(errs[i].Error(), "\n"),
+		}
+		if indexes[i] >= 0 {
+			b, err := fs.ReadFile(p[len(p)-1])
+			if err != nil {
+				return nil, err
+			}
+			picked, err := pickStepYAML(string(b), indexes[i])
+			if err != nil {
+				return nil, err
+			}
+			f.Step = picked
+		}
+		failures = append(failures, f)
+	}
+	return failures, nil
+}
+
+func newReportCoverage(cov *Coverage) []*reportSpec {
+	var specs []*reportSpec
+	for _, sc := range cov.Specs {
+		spec := &reportSpec{Key: sc.Key}
+		keys := lo.Keys(sc.Coverages)
+		sort.Strings(keys)
+		for _, k := range keys {
+			spec.Total++
+			if sc.Coverages[k] > 0 {
+				spec.Covered++
+			}
+			op := &reportOperation{Key: k, Count: sc.Coverages[k]}
+			if d, ok := sc.Details[k]; ok && d != nil {
+				for _, c := range []struct {
+					name      string
+					coverages map[string]int
+				}{
+					{"fields", d.Fields},
+					{"statuses", d.Statuses},
+					{"enums", d.Enums},
+				} {
+					if len(c.coverages) == 0 {
+						continue
+					}
+					dk := lo.Keys(c.coverages)
+					sort.Strings(dk)
+					op.Details = append(op.Details, &reportDetail{
+						Name: c.name,
+						Items: lo.Map(dk, func(k string, _ int) reportDetailItem {
+							return reportDetailItem{Key: k, Count: c.coverages[k]}
+						}),
+					})
+				}
+				if len(d.Undocumented) > 0 {
+					dk := lo.Keys(d.Undocumented)
+					sort.Strings(dk)
+					op.Details = append(op.Details, &reportDetail{
+						Name: "undocumented statuses",
+						Items: lo.Map(dk, func(k string, _ int) reportDetailItem {
+							return reportDetailItem{Key: k, Count: d.Undocumented[k]}
+						}),
+						Undocumented: true,
+					})
+				}
+			}
+			spec.Operations = append(spec.Operations, op)
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+func newReportProfile(s *stopw.Span) []*reportSpan {
+	total := s.Elapsed()
+	var spans []*reportSpan
+	var walk func(p *stopw.Span, depth int)
+	walk = func(p *stopw.Span, depth int) {
+		if depth > reportProfileMaxDepth {
+			return
+		}
+		for _, b := range p.Breakdown {
+			name := fmt.Sprint(b.ID)
+			if tr, ok := b.ID.(Trail); ok {
+				name = tr.String()
+				if tr.Type == TrailTypeRunbook {
+					name = fmt.Sprintf("runbook[%s](%s)", tr.Desc, fs.ShortenPath(tr.RunbookPath))
+				}
+			}
+			var pct float64
+			if total > 0 {
+				pct = float64(b.Elapsed()) / float64(total) * 100
+			}
+			spans = append(spans, &reportSpan{Name: name, Depth: depth, Elapsed: b.Elapsed(), Percent: pct})
+			walk(b, depth+1)
+		}
+	}
+	walk(s, 0)
+	return spans
+}
+
+func (s *reportSpec) Percent() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Covered) / float64(s.Total) * 100
+}
+
+func (d *reportDetail) Covered() int {
+	return lo.CountBy(d.Items, func(i reportDetailItem) bool { return i.Count > 0 })
+}
+
+var htmlReportTmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ms": func(d time.Duration) string {
+		return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+	},
+	"indent": func(depth int) string {
+		return fmt.Sprintf("%.1fem", float64(depth)*1.5)
+	},
+	"inc": func(i int) int {
+		return i + 1
+	},
+	"percent": func(f float64) string {
+		return fmt.Sprintf("%.1f%%", f)
+	},
+}).Parse(htmlReportTemplate))
+
+// htmlReportTemplate is the template of the HTML report. It must not depend on external resources.
+const htmlReportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>runn report</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292f; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.3em; border-bottom: 1px solid #d0d7de; padding-bottom: .3em; margin-top: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: .3em .6em; border-bottom: 1px solid #eaeef2; vertical-align: top; }
+td.num, th.num { text-align: right; white-space: nowrap; }
+pre { background: #f6f8fa; padding: .8em; overflow-x: auto; }
+summary { cursor: pointer; }
+.success { color: #1a7f37; }
+.failure { color: #cf222e; }
+.skipped { color: #9a6700; }
+.covered { color: #1a7f37; }
+.uncovered { color: #cf222e; }
+.undocumented { color: #9a6700; }
+.meta { color: #57606a; font-size: .9em; }
+.bar { background: #ddf4ff; height: .8em; display: inline-block; }
+.cards { display: flex; gap: 1em; }
+.card { border: 1px solid #d0d7de; border-radius: 6px; padding: .6em 1.2em; }
+.card .value { font-size: 1.6em; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>runn report</h1>
+<p class="meta">Generated at {{ .GeneratedAt.Format "2006-01-02T15:04:05Z07:00" }} by runn {{ .Version }}</p>
+<div class="cards">
+<div class="card"><div>Total</div><div class="value">{{ .Summary.Total }}</div></div>
+<div class="card success"><div>Success</div><div class="value">{{ .Summary.Success }}</div></div>
+<div class="card failure"><div>Failure</div><div class="value">{{ .Summary.Failure }}</div></div>
+<div class="card skipped"><div>Skipped</div><div class="value">{{ .Summary.Skipped }}</div></div>
+<div class="card"><div>Elapsed</div><div class="value">{{ ms .Summary.Elapsed }}</div></div>
+</div>
+{{- if .Failures }}
+<h2>Failures</h2>
+{{- range $i, $f := .Failures }}
+<h3>{{ inc $i }}) <span class="failure">{{ index $f.Trace 0 }}</span> <span class="meta">{{ $f.ID }}</span></h3>
+{{- if gt (len $f.Trace) 1 }}
+<ul>{{ range $f.Trace }}<li>{{ . }}</li>{{ end }}</ul>
+{{- end }}
+<pre class="failure">{{ $f.Err }}</pre>
+{{- if $f.Step }}
+<pre>{{ $f.Step }}</pre>
+{{- end }}
+{{- end }}
+{{- end }}
+<h2>Runbooks</h2>
+{{- range .Runbooks }}
+{{ template "runbook" . }}
+{{- end }}
+{{- if .Coverage }}
+<h2>Coverage</h2>
+<table>
+<tr><th>Spec</th><th class="num">Coverage</th></tr>
+{{- range .Coverage }}
+<tr><td>
+<details><summary>{{ .Key }}</summary>
+<table>
+<tr><th>Operation</th><th>Details</th><th class="num">Count</th></tr>
+{{- range .Operations }}
+<tr><td class="{{ if gt .Count 0 }}covered{{ else }}uncovered{{ end }}">{{ .Key }}</td><td>
+{{- range .Details }}
+{{- if .Undocumented }}
+<details><summary>{{ .Name }} ({{ len .Items }})</summary>
+{{- range .Items }} <span class="undocumented">{{ .Key }} ({{ .Count }})</span>{{ end }}
+</details>
+{{- else }}
+<details><summary>{{ .Name }} ({{ .Covered }}/{{ len .Items }})</summary>
+{{- range .Items }} <span class="{{ if gt .Count 0 }}covered{{ else }}uncovered{{ end }}">{{ .Key }}</span>{{ end }}
+</details>
+{{- end }}
+{{- end }}
+</td><td class="num">{{ .Count }}</td></tr>
+{{- end }}
+</table>
+</details>
+</td><td class="num">{{ percent .Percent }}</td></tr>
+{{- end }}
+</table>
+{{- end }}
+{{- if .Profile }}
+<h2>Profile</h2>
+<table>
+<tr><th>Span</th><th class="num">Elapsed</th><th></th></tr>
+{{- range .Profile }}
+<tr><td style="padding-left: {{ indent .Depth }}">{{ .Name }}</td><td class="num">{{ ms .Elapsed }}</td><td style="width: 30%"><span class="bar" style="width: {{ percent .Percent }}"></span></td></tr>
+{{- end }}
+</table>
+{{- end }}
+</body>
+</html>
+{{ define "runbook" -}}
+<details{{ if eq .Result "failure" }} open{{ end }}>
+<summary><span class="{{ .Result }}">[{{ .Result }}]</span> {{ if .Desc }}{{ .Desc }} {{ end }}<span class="meta">{{ .Path }} {{ .ID }} {{ ms .Elapsed }}</span></summary>
+<table>
+<tr><th>Step</th><th>Description</th><th>Result</th><th class="num">Elapsed</th></tr>
+{{- range .Steps }}
+<tr><td>{{ .Key }}</td><td>{{ .Desc }}</td><td class="{{ .Result }}">{{ .Result }}{{ if .Err }}<pre>{{ .Err }}</pre>{{ end }}
+{{- range .Included }}{{ template "runbook" . }}{{ end }}</td><td class="num">{{ ms .Elapsed }}</td></tr>
+{{- end }}
+</table>
+</details>
+{{- end }}
+`
@@ -0,0 +1,115 @@
+package runn
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDumpReport(t *testing.T) {
+	ctx := context.Background()
+	opn, err := Load("testdata/book/always_failure.yml", Profile(true), Stdout(io.Discard), Stderr(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := opn.RunN(ctx); err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := opn.DumpReport(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"<title>runn report</title>",
+		"Always failure scenario",
+		"[failure]",
+		"testdata/book/always_failure.yml",
+		"test: &#39;false&#39;",
+		"<h2>Profile</h2>",
+		"runbook[Always failure scenario]",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got %q, want to contain %q", got, want)
+		}
+	}
+	for _, notWant := range []string{"<script", "http://", "https://"} {
+		if strings.Contains(got, notWant) {
+			t.Errorf("report should be self-contained: %q", notWant)
+		}
+	}
+}
+
+func TestDumpReportWithoutRun(t *testing.T) {
+	opn, err := Load("testdata/book/always_failure.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := opn.DumpReport(io.Discard, nil); err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestDumpReportWithoutProfile(t *testing.T) {
+	ctx := context.Background()
+	opn, err := Load("testdata/book/always_failure.yml", Stdout(io.Discard), Stderr(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := opn.RunN(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if opn.Result().elapsed <= 0 {
+		t.Error("elapsed should be recorded")
+	}
+	buf := new(bytes.Buffer)
+	if err := opn.DumpReport(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "<h2>Profile</h2>") {
+		t.Error("profile should not be included without profiling")
+	}
+	if strings.Contains(buf.String(), "<h2>Coverage</h2>") {
+		t.Error("coverage should not be included without coverage")
+	}
+}
+
+func TestDumpReportWithCoverage(t *testing.T) {
+	ctx := context.Background()
+	opn, err := Load("testdata/book/always_failure.yml", Stdout(io.Discard), Stderr(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := opn.RunN(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cov := &Coverage{Specs: []*SpecCoverage{
+		{
+			Key:       "spec:0.0.1",
+			Coverages: map[string]int{"GET /users": 1},
+			Details: map[string]*DetailCoverage{
+				"GET /users": {
+					Statuses:     map[string]int{"200": 1},
+					Undocumented: map[string]int{"500": 2},
+				},
+			},
+		},
+	}}
+	buf := new(bytes.Buffer)
+	if err := opn.DumpReport(buf, cov); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"<h2>Coverage</h2>",
+		"GET /users",
+		"undocumented statuses (1)",
+		`<span class="undocumented">500 (2)</span>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got %q, want to contain %q", got, want)
+		}
+	}
+}
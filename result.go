@@ -35,6 +35,8 @@ type RunResult struct {
 	Err         error         // Error during runbook run.
 	StepResults []*StepResult // Step results of runbook run
 	Elapsed     time.Duration // Elapsed time of runbook run
+	Attempts    []*RunResult  // Results of the failed attempts before the last attempt ( retried by `retry:` or Retries )
+	Flaky       bool          // Whether runbook run passed after retries
 	store       *store.Store  // Store of runbook run
 	included    bool          // Whether runbook is included or not
 }
@@ -54,6 +56,7 @@ type StepResult struct {
 type runNResult struct {
 	Total      atomic.Int64
 	RunResults []*RunResult
+	elapsed    time.Duration // Wall-clock elapsed time of RunN
 	mu         sync.Mutex
 }
 
@@ -62,17 +65,20 @@ type runNResultSimplified struct {
 	Success int64                  `json:"success"`
 	Failure int64                  `json:"failure"`
 	Skipped int64                  `json:"skipped"`
+	Flaky   int64                  `json:"flaky,omitempty"`
 	Results []*runResultSimplified `json:"results"`
 	Elapsed time.Duration          `json:"elapsed,omitempty"`
 }
 
 type runResultSimplified struct {
-	ID      string                  `json:"id"`
-	Labels  []string                `json:"labels,omitempty"`
-	Path    string                  `json:"path"`
-	Result  result                  `json:"result"`
-	Steps   []*stepResultSimplified `json:"steps"`
-	Elapsed time.Duration           `json:"elapsed,omitempty"`
+	ID       string                  `json:"id"`
+	Labels   []string                `json:"labels,omitempty"`
+	Path     string                  `json:"path"`
+	Result   result                  `json:"result"`
+	Flaky    bool                    `json:"flaky,omitempty"`
+	Steps    []*stepResultSimplified `json:"steps"`
+	Attempts []*runResultSimplified  `json:"attempts,omitempty"`
+	Elapsed  time.Duration           `json:"elapsed,omitempty"`
 }
 
 type stepResultSimplified struct {
@@ -126,6 +132,9 @@ func (r *runNResult) Out(out io.Writer) error {
 		ts = fmt.Sprintf("%d scenarios", rs.Total)
 	}
 	ss := fmt.Sprintf("%d skipped", rs.Skipped)
+	if rs.Flaky > 0 {
+		ss += fmt.Sprintf(", %d flaky", rs.Flaky)
+	}
 	if rs.Failure == 1 {
 		fs = fmt.Sprintf("%d failure", rs.Failure)
 	} else {
@@ -180,6 +189,9 @@ func (r *runNResult) simplify() runNResultSimplified {
 		default:
 			s.Success += 1
 		}
+		if rr.Flaky {
+			s.Flaky += 1
+		}
 		s.Results = append(s.Results, simplifyRunResult(rr))
 	}
 	return s
@@ -262,9 +274,10 @@ func simplifyRunResult(rr *RunResult) *runResultSimplified {
 		return nil
 	}
 	np := normalizePath(rr.Path)
+	var s *runResultSimplified
 	switch {
 	case rr.Err != nil:
-		return &runResultSimplified{
+		s = &runResultSimplified{
 			ID:      rr.ID,
 			Path:    np,
 			Result:  resultFailure,
@@ -272,7 +285,7 @@ func simplifyRunResult(rr *RunResult) *runResultSimplified {
 			Elapsed: rr.Elapsed,
 		}
 	case rr.Skipped:
-		return &runResultSimplified{
+		s = &runResultSimplified{
 			ID:      rr.ID,
 			Path:    np,
 			Result:  resultSkipped,
@@ -280,7 +293,7 @@ func simplifyRunResult(rr *RunResult) *runResultSimplified {
 			Elapsed: rr.Elapsed,
 		}
 	default:
-		return &runResultSimplified{
+		s = &runResultSimplified{
 			ID:      rr.ID,
 			Path:    np,
 			Result:  resultSuccess,
@@ -288,6 +301,11 @@ func simplifyRunResult(rr *RunResult) *runResultSimplified {
 			Elapsed: rr.Elapsed,
 		}
 	}
+	s.Flaky = rr.Flaky
+	for _, a := range rr.Attempts {
+		s.Attempts = append(s.Attempts, simplifyRunResult(a))
+	}
+	return s
 }
 
 func simplifyStepResults(stepResults []*StepResult) []*stepResultSimplified {
@@ -0,0 +1,65 @@
+package runn
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	retrySectionKey = "retry"
+)
+
+// Retry is the retry policy of the runbook.
+// A failed runbook is run again up to Count times.
+type Retry struct {
+	Count    int    `yaml:"count"`
+	Interval string `yaml:"interval,omitempty"`
+
+	interval time.Duration
+}
+
+func newRetry(v any) (*Retry, error) {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	r := &Retry{}
+	if err := yaml.Unmarshal(b, r); err != nil {
+		// short syntax
+		c, err := strconv.Atoi(strings.TrimRight(string(b), "\n\r"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", retrySectionKey, v)
+		}
+		r.Count = c
+	}
+	if r.Count < 0 {
+		return nil, fmt.Errorf("invalid %s count: %d", retrySectionKey, r.Count)
+	}
+	if r.Interval != "" {
+		r.interval, err = parseDuration(r.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s interval: %w", retrySectionKey, err)
+		}
+	}
+	return r, nil
+}
+
+// wait waits for the interval before the next attempt.
+func (r *Retry) wait(ctx context.Context) error {
+	if r.interval <= 0 {
+		return nil
+	}
+	t := time.NewTimer(r.interval)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
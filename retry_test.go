@@ -0,0 +1,109 @@
+package runn
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/k1LoW/runn/internal/scope"
+)
+
+func TestNewRetry(t *testing.T) {
+	tests := []struct {
+		v       any
+		want    *Retry
+		wantErr bool
+	}{
+		{uint64(3), &Retry{Count: 3}, false},
+		{map[string]any{"count": 1, "interval": "1"}, &Retry{Count: 1, Interval: "1", interval: time.Second}, false},
+		{map[string]any{"count": 2, "interval": "500ms"}, &Retry{Count: 2, Interval: "500ms", interval: 500 * time.Millisecond}, false},
+		{-1, nil, true},
+		{"invalid", nil, true},
+		{map[string]any{"count": 1, "interval": "invalid"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := newRetry(tt.v)
+		if err != nil {
+			if !tt.wantErr {
+				t.Errorf("got error: %v", err)
+			}
+			continue
+		}
+		if tt.wantErr {
+			t.Errorf("want error: %v", tt.v)
+			continue
+		}
+		if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(Retry{})); diff != "" {
+			t.Error(diff)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		book         string
+		opts         []Option
+		wantErr      bool
+		wantFlaky    bool
+		wantAttempts int
+	}{
+		{"testdata/retry/flaky.yml", nil, true, false, 0},
+		{"testdata/retry/flaky.yml", []Option{Retries(1)}, false, true, 1},
+		{"testdata/retry/flaky_with_retry.yml", nil, false, true, 1},
+		{"testdata/retry/flaky_with_retry.yml", []Option{Retries(3)}, false, true, 1},
+		{"testdata/book/always_success.yml", []Option{Retries(2)}, false, false, 0},
+		{"testdata/book/always_failure.yml", []Option{Retries(2)}, true, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.book, func(t *testing.T) {
+			flag := filepath.Join(t.TempDir(), "flag")
+			opts := append([]Option{Book(tt.book), Var("flag", flag), Scopes(scope.AllowRunExec)}, tt.opts...)
+			o, err := New(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = o.Run(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got %v, want error %v", err, tt.wantErr)
+			}
+			r := o.Result()
+			if r.Flaky != tt.wantFlaky {
+				t.Errorf("got %v, want %v", r.Flaky, tt.wantFlaky)
+			}
+			if len(r.Attempts) != tt.wantAttempts {
+				t.Fatalf("got %d attempts, want %d", len(r.Attempts), tt.wantAttempts)
+			}
+			for _, a := range r.Attempts {
+				if a.Err == nil {
+					t.Error("want error of the failed attempt")
+				}
+			}
+		})
+	}
+}
+
+func TestSimplifyFlaky(t *testing.T) {
+	r := &runNResult{
+		RunResults: []*RunResult{
+			{ID: "a", Path: "a.yml", Flaky: true, Attempts: []*RunResult{{ID: "a", Path: "a.yml", Err: errDummy}}},
+			{ID: "b", Path: "b.yml"},
+		},
+	}
+	r.Total.Store(2)
+	got := r.simplify()
+	want := runNResultSimplified{
+		Total:   2,
+		Success: 2,
+		Flaky:   1,
+		Results: []*runResultSimplified{
+			{ID: "a", Path: "a.yml", Result: resultSuccess, Flaky: true, Attempts: []*runResultSimplified{{ID: "a", Path: "a.yml", Result: resultFailure}}},
+			{ID: "b", Path: "b.yml", Result: resultSuccess},
+		},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Error(diff)
+	}
+}
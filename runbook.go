@@ -1,6 +1,7 @@
 package runn
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -51,6 +52,7 @@ type runbook struct {
 	If          string            `yaml:"if,omitempty"`
 	SkipTest    bool              `yaml:"skipTest,omitempty"`
 	Loop        any               `yaml:"loop,omitempty"`
+	Retry       any               `yaml:"retry,omitempty"`
 	Concurrency any               `yaml:"concurrency,omitempty"`
 	Force       bool              `yaml:"force,omitempty"`
 	Trace       bool              `yaml:"trace,omitempty"`
@@ -76,6 +78,7 @@ type runbookMapped struct {
 	If          string            `yaml:"if,omitempty"`
 	SkipTest    bool              `yaml:"skipTest,omitempty"`
 	Loop        any               `yaml:"loop,omitempty"`
+	Retry       any               `yaml:"retry,omitempty"`
 	Concurrency any               `yaml:"concurrency,omitempty"`
 	Force       bool              `yaml:"force,omitempty"`
 	Trace       bool              `yaml:"trace,omitempty"`
@@ -157,6 +160,7 @@ func parseRunbookAsMapped(b []byte, rb *runbook) error {
 	rb.If = m.If
 	rb.SkipTest = m.SkipTest
 	rb.Loop = m.Loop
+	rb.Retry = m.Retry
 	rb.Concurrency = m.Concurrency
 	rb.Force = m.Force
 	rb.Trace = m.Trace
@@ -205,6 +209,34 @@ func (rb *runbook) AppendStep(in ...string) error {
 	}
 }
 
+// AppendGRPCStep appends the gRPC step with the zero-valued request message of the method ( "grpc://host:port/package.Service/Method" ).
+// The method is resolved using proto sources ( or buf ) or server reflection.
+func (rb *runbook) AppendGRPCStep(ctx context.Context, in string, opts ...Option) error {
+	u := strings.TrimPrefix(in, "grpc://")
+	addr, method, ok := strings.Cut(u, "/")
+	if !ok || addr == "" || method == "" {
+		return fmt.Errorf("invalid gRPC method: %s", in)
+	}
+	i, err := NewGRPCInspector(addr, opts...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = i.Close()
+	}()
+	step, err := i.step(ctx, method)
+	if err != nil {
+		return err
+	}
+	if rb.useMap {
+		key := fmt.Sprintf("%s%d", "grpc", len(rb.stepKeys))
+		rb.stepKeys = append(rb.stepKeys, key)
+	}
+	key := rb.setRunner(fmt.Sprintf("grpc://%s", addr))
+	rb.Steps = append(rb.Steps, yaml.MapSlice{{Key: key, Value: step}})
+	return nil
+}
+
 func (rb *runbook) MarshalYAML() (any, error) {
 	if !rb.useMap {
 		return &runbookListed{
@@ -221,6 +253,7 @@ func (rb *runbook) MarshalYAML() (any, error) {
 			If:          rb.If,
 			SkipTest:    rb.SkipTest,
 			Loop:        rb.Loop,
+			Retry:       rb.Retry,
 			Concurrency: rb.Concurrency,
 			Force:       rb.Force,
 			Trace:       rb.Trace,
@@ -245,6 +278,7 @@ func (rb *runbook) MarshalYAML() (any, error) {
 	m.If = rb.If
 	m.SkipTest = rb.SkipTest
 	m.Loop = rb.Loop
+	m.Retry = rb.Retry
 	m.Concurrency = rb.Concurrency
 	m.Force = rb.Force
 	m.Trace = rb.Trace
@@ -487,6 +521,12 @@ func (rb *runbook) toBook() (*book, error) {
 			return nil, err
 		}
 	}
+	if rb.Retry != nil {
+		bk.retry, err = newRetry(rb.Retry)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if rb.Concurrency != nil {
 		bk.concurrency, err = newConcurrency(rb.Concurrency)
 		if err != nil {
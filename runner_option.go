@@ -2,8 +2,10 @@ package runn
 
 import (
 	"fmt"
+	"maps"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/goccy/go-yaml"
 	"github.com/k1LoW/runn/internal/deprecation"
@@ -37,25 +39,45 @@ type traceConfig struct {
 }
 
 type grpcRunnerConfig struct {
-	Addr        string   `yaml:"addr"`
-	TLS         *bool    `yaml:"tls,omitempty"`
-	CACert      string   `yaml:"cacert,omitempty"`
-	Cert        string   `yaml:"cert,omitempty"`
-	Key         string   `yaml:"key,omitempty"`
-	SkipVerify  bool     `yaml:"skipVerify,omitempty"`
-	ImportPaths []string `yaml:"importPaths,omitempty"`
-	Protos      []string `yaml:"protos,omitempty"`
-	BufDirs     []string `yaml:"bufDirs,omitempty"`
-	BufLocks    []string `yaml:"bufLocks,omitempty"`
-	BufConfigs  []string `yaml:"bufConfigs,omitempty"`
-	BufModules  []string `yaml:"bufModules,omitempty"`
-	Trace       traceConfig
+	Addr              string                       `yaml:"addr"`
+	TLS               *bool                        `yaml:"tls,omitempty"`
+	CACert            string                       `yaml:"cacert,omitempty"`
+	Cert              string                       `yaml:"cert,omitempty"`
+	Key               string                       `yaml:"key,omitempty"`
+	SkipVerify        bool                         `yaml:"skipVerify,omitempty"`
+	ImportPaths       []string                     `yaml:"importPaths,omitempty"`
+	Protos            []string                     `yaml:"protos,omitempty"`
+	BufDirs           []string                     `yaml:"bufDirs,omitempty"`
+	BufLocks          []string                     `yaml:"bufLocks,omitempty"`
+	BufConfigs        []string                     `yaml:"bufConfigs,omitempty"`
+	BufModules        []string                     `yaml:"bufModules,omitempty"`
+	Protocol          string                       `yaml:"protocol,omitempty"`
+	Codec             string                       `yaml:"codec,omitempty"`
+	Compression       string                       `yaml:"compression,omitempty"`
+	Keepalive         *grpcKeepaliveConfig         `yaml:"keepalive,omitempty"`
+	MaxRecvMsgSize    any                          `yaml:"maxRecvMsgSize,omitempty"`
+	MaxSendMsgSize    any                          `yaml:"maxSendMsgSize,omitempty"`
+	Authority         string                       `yaml:"authority,omitempty"`
+	ServiceConfig     any                          `yaml:"serviceConfig,omitempty"`
+	PerRPCCredentials *grpcPerRPCCredentialsConfig `yaml:"perRPCCredentials,omitempty"`
+	Trace             traceConfig
 
 	cacert []byte
 	cert   []byte
 	key    []byte
 }
 
+type grpcKeepaliveConfig struct {
+	Time                string `yaml:"time,omitempty"`
+	Timeout             string `yaml:"timeout,omitempty"`
+	PermitWithoutStream bool   `yaml:"permitWithoutStream,omitempty"`
+}
+
+type grpcPerRPCCredentialsConfig struct {
+	Bearer   string            `yaml:"bearer,omitempty"`
+	Metadata map[string]string `yaml:"metadata,omitempty"`
+}
+
 type dbRunnerConfig struct {
 	DSN   string `yaml:"dsn"`
 	Trace *bool  `yaml:"trace,omitempty"`
@@ -343,6 +365,99 @@ func BufModule(modules ...string) grpcRunnerOption {
 	}
 }
 
+// GRPCProtocol sets the protocol of the gRPC runner (grpc, grpc-web or connect).
+func GRPCProtocol(protocol string) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		c.Protocol = protocol
+		return nil
+	}
+}
+
+// GRPCCodec sets the codec of the Connect protocol (proto or json).
+func GRPCCodec(codec string) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		c.Codec = codec
+		return nil
+	}
+}
+
+// GRPCCompression sets the compressor of the gRPC runner (gzip).
+func GRPCCompression(name string) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		c.Compression = name
+		return nil
+	}
+}
+
+// GRPCKeepalive sets the keepalive parameters of the gRPC runner.
+func GRPCKeepalive(t, timeout time.Duration, permitWithoutStream bool) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		c.Keepalive = &grpcKeepaliveConfig{
+			Time:                t.String(),
+			Timeout:             timeout.String(),
+			PermitWithoutStream: permitWithoutStream,
+		}
+		return nil
+	}
+}
+
+// GRPCMaxRecvMsgSize sets the maximum message size in bytes the gRPC runner can receive.
+func GRPCMaxRecvMsgSize(size int) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		c.MaxRecvMsgSize = size
+		return nil
+	}
+}
+
+// GRPCMaxSendMsgSize sets the maximum message size in bytes the gRPC runner can send.
+func GRPCMaxSendMsgSize(size int) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		c.MaxSendMsgSize = size
+		return nil
+	}
+}
+
+// GRPCAuthority sets the :authority pseudo-header of the gRPC runner.
+func GRPCAuthority(authority string) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		c.Authority = authority
+		return nil
+	}
+}
+
+// GRPCServiceConfig sets the default service config (JSON) of the gRPC runner.
+func GRPCServiceConfig(config string) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		c.ServiceConfig = config
+		return nil
+	}
+}
+
+// GRPCBearerToken sets the bearer token sent as per-RPC credentials. The token can be an expression expanded using the store.
+func GRPCBearerToken(token string) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		if c.PerRPCCredentials == nil {
+			c.PerRPCCredentials = &grpcPerRPCCredentialsConfig{}
+		}
+		c.PerRPCCredentials.Bearer = token
+		return nil
+	}
+}
+
+// GRPCPerRPCMetadata sets the metadata sent as per-RPC credentials. The values can be expressions expanded using the store.
+func GRPCPerRPCMetadata(md map[string]string) grpcRunnerOption {
+	return func(c *grpcRunnerConfig) error {
+		if c.PerRPCCredentials == nil {
+			c.PerRPCCredentials = &grpcPerRPCCredentialsConfig{}
+		}
+		if c.PerRPCCredentials.Metadata == nil {
+			c.PerRPCCredentials.Metadata = map[string]string{}
+		}
+		maps.Copy(c.PerRPCCredentials.Metadata, md)
+		return nil
+	}
+}
+
 func DBTrace(trace bool) dbRunnerOption {
 	return func(c *dbRunnerConfig) error {
 		c.Trace = &trace
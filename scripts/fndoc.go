@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -44,7 +45,7 @@ func main() {
 		_, _ = fmt.Fprintf(rep, "%s\n\n", fn.Desc)
 		_, _ = fmt.Fprint(rep, "```yaml\n")
 		_, _ = fmt.Fprint(rep, "actions:\n")
-		if len(fn.Args.ArgArgs()) == 0 {
+		if len(fn.Args.ArgArgs()) == 0 && len(fn.Args.OptArgs()) == 0 {
 			_, _ = fmt.Fprintf(rep, "  - %s\n", k)
 		} else {
 			_, _ = fmt.Fprintf(rep, "  - %s:\n", k)
@@ -52,6 +53,13 @@ func main() {
 		for _, a := range fn.Args.ArgArgs() {
 			_, _ = fmt.Fprintf(rep, "      %s: %q\n", a.Key, a.Example)
 		}
+		for _, a := range fn.Args.OptArgs() {
+			e := fmt.Sprintf("%q", a.Example)
+			if json.Valid([]byte(a.Example)) {
+				e = a.Example
+			}
+			_, _ = fmt.Fprintf(rep, "      %s: %s # optional\n", a.Key, e)
+		}
 		for _, a := range fn.Args.ResArgs() {
 			_, _ = fmt.Fprintf(rep, "# record to current.%s:\n", a.Key)
 		}
@@ -0,0 +1,111 @@
+package runn
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// stateDirEnvKey is the environment variable to override the directory of the state of runs.
+	stateDirEnvKey = "RUNN_STATE_DIR"
+	lastResultFile = "last.json"
+)
+
+// StateDir returns the directory to store the state of runs ( e.g. the result of the last run ) .
+// It is `$RUNN_STATE_DIR` or the directory for the current working directory under the user cache directory.
+func StateDir() (string, error) {
+	if dir := os.Getenv(stateDirEnvKey); dir != "" {
+		return dir, nil
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256([]byte(wd))
+	return filepath.Join(cache, "runn", "state", hex.EncodeToString(h[:])[:16]), nil
+}
+
+// LastResultPath returns the path of the result of the last run in the state directory.
+func LastResultPath() (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, lastResultFile), nil
+}
+
+// SaveLastResult saves the result to the state directory as the result of the last run.
+func (r *runNResult) SaveLastResult() error {
+	p, err := LastResultPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	if err := r.OutJSON(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// failedRunbooks is the set of the runbooks that failed in the previous run.
+type failedRunbooks struct {
+	ids   map[string]struct{}
+	paths map[string]struct{}
+}
+
+// readFailedRunbooks reads the runbooks that failed from the result of `runn run --format json` .
+func readFailedRunbooks(p string) (*failedRunbooks, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the result of the previous run: %w", err)
+	}
+	var s runNResultSimplified
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("invalid result of the previous run (%s): %w", p, err)
+	}
+	f := &failedRunbooks{
+		ids:   map[string]struct{}{},
+		paths: map[string]struct{}{},
+	}
+	for _, rr := range s.Results {
+		if rr.Result != resultFailure {
+			continue
+		}
+		if rr.ID != "" {
+			f.ids[rr.ID] = struct{}{}
+		}
+		if rr.Path != "" {
+			f.paths[rr.Path] = struct{}{}
+		}
+	}
+	return f, nil
+}
+
+// contains reports whether the runbook failed in the previous run.
+// The ID is matched first because the path of the same runbook may be written differently.
+func (f *failedRunbooks) contains(op *operator) bool {
+	if _, ok := f.ids[op.id]; ok {
+		return true
+	}
+	if _, ok := f.paths[op.bookPath]; ok {
+		return true
+	}
+	// The paths in the result are relative to the project root.
+	_, ok := f.paths[normalizePath(op.bookPath)]
+	return ok
+}
@@ -0,0 +1,99 @@
+package runn
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRerunFailed(t *testing.T) {
+	tests := []struct {
+		name   string
+		result string
+		want   []string
+	}{
+		{
+			"failed by path",
+			`{"results": [
+  {"id": "unknown", "path": "testdata/book/always_failure.yml", "result": "failure"},
+  {"id": "unknown", "path": "testdata/book/always_success.yml", "result": "success"}
+]}`,
+			[]string{"testdata/book/always_failure.yml"},
+		},
+		{
+			"with needs",
+			`{"results": [
+  {"id": "unknown", "path": "testdata/book/needs_3.yml", "result": "failure"}
+]}`,
+			[]string{"testdata/book/needs_1.yml", "testdata/book/needs_2.yml", "testdata/book/needs_3.yml"},
+		},
+		{
+			"no failures",
+			`{"results": [
+  {"id": "unknown", "path": "testdata/book/always_failure.yml", "result": "success"}
+]}`,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "result.json")
+			if err := os.WriteFile(p, []byte(tt.result), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			opn, err := Load("testdata/book/always_*.yml"+string(filepath.ListSeparator)+"testdata/book/needs_3.yml", RerunFailed(p))
+			if err != nil {
+				t.Fatal(err)
+			}
+			selected, err := opn.SelectedOperators()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, op := range selected {
+				got = append(got, op.bookPath)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestSaveLastResult(t *testing.T) {
+	t.Setenv(stateDirEnvKey, t.TempDir())
+	opn, err := Load("testdata/book/always_*.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := opn.RunN(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := opn.Result().SaveLastResult(); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LastResultPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rerun with the saved result
+	rerun, err := Load("testdata/book/always_*.yml", RerunFailed(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	selected, err := rerun.SelectedOperators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, op := range selected {
+		got = append(got, op.bookPath)
+	}
+	if diff := cmp.Diff([]string{"testdata/book/always_failure.yml"}, got); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -3,6 +3,8 @@ package runn
 import (
 	"errors"
 	"fmt"
+
+	"github.com/k1LoW/runn/internal/store"
 )
 
 type step struct {
@@ -48,6 +50,8 @@ type step struct {
 	nodes   map[string]any
 	debug   bool
 	result  *StepResult
+	// snapshot of the store taken before the step runs for rewinding in the debugger.
+	snapshot *store.Snapshot
 }
 
 func newStep(idx int, key string, parent *operator, rawStep map[string]any) *step {
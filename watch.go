@@ -0,0 +1,408 @@
+package runn
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/k1LoW/runn/internal/fs"
+	"google.golang.org/grpc"
+)
+
+// watchRunnerOwnerID is the owner of the runners whose connections are kept across runs by the watcher.
+// The operators do not close the connections of them.
+const watchRunnerOwnerID = "watch"
+
+// watchInterval is the interval of polling the files to watch.
+var watchInterval = 500 * time.Millisecond
+
+// Watcher runs the runbooks and reruns the runbooks affected by the changes of the files they depend on
+// ( the runbooks of `include:` and `needs:` , the files of `vars:` , OpenAPI documents and proto files ) .
+type Watcher struct {
+	pathp    string
+	opts     []Option
+	books    []string             // Paths of the runbooks matching the path pattern.
+	deps     map[string][]string  // Files that the runbook depends on. key is the runbook path.
+	stamps   map[string]fileStamp // key is the absolute path of the watched file.
+	affected []string             // Paths of the runbooks to run next. nil means all runbooks.
+	pending  bool                 // Whether the runbooks to run failed to be loaded in the last run.
+	pool     *runnerPool
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+// NewWatcher returns a new watcher of the runbooks matching the path pattern.
+func NewWatcher(pathp string, opts ...Option) *Watcher {
+	return &Watcher{
+		pathp:  pathp,
+		opts:   opts,
+		deps:   map[string][]string{},
+		stamps: map[string]fileStamp{},
+		pool:   newRunnerPool(),
+	}
+}
+
+// Run runs the runbooks affected by the changes detected by Wait ( all runbooks for the first run ) .
+// The connections of the HTTP, gRPC and DB runners are kept across runs.
+func (w *Watcher) Run(ctx context.Context) (*operatorN, error) {
+	opts := slices.Clone(w.opts)
+	if w.affected != nil {
+		opts = append(opts, runPaths(w.affected))
+	}
+	opn, err := Load(w.pathp, opts...)
+	// Fetch the paths after loading because the scopes are set by the options.
+	if books, err := fs.FetchPaths(w.pathp); err == nil {
+		w.books = books
+	}
+	if err != nil {
+		// Keep watching the files to rerun after they are fixed.
+		w.pending = true
+		w.snapshot()
+		return nil, err
+	}
+	deps, err := opn.watchFiles()
+	if err != nil {
+		w.pending = true
+		w.snapshot()
+		return nil, err
+	}
+	w.pending = false
+	maps.Copy(w.deps, deps)
+	// Take the snapshot before running to detect the changes during the run.
+	w.snapshot()
+	w.pool.attach(opn)
+	defer w.pool.collect()
+	return opn, opn.RunN(ctx)
+}
+
+// Wait waits until the files that the runbooks depend on are changed or the runbooks matching the path pattern are added.
+func (w *Watcher) Wait(ctx context.Context) error {
+	t := time.NewTicker(watchInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+		affected := w.changed()
+		if len(affected) == 0 {
+			continue
+		}
+		switch {
+		case !w.pending:
+			w.affected = affected
+		case w.affected != nil:
+			// Also rerun the runbooks that failed to be loaded.
+			w.affected = sortedUnique(append(w.affected, affected...))
+		}
+		return nil
+	}
+}
+
+// Close closes the connections of the runners kept across runs.
+func (w *Watcher) Close() {
+	w.pool.close()
+}
+
+// changed returns the paths of the runbooks affected by the changes since the last snapshot.
+func (w *Watcher) changed() []string {
+	if books, err := fs.FetchPaths(w.pathp); err == nil {
+		w.books = books
+	}
+	changed := map[string]struct{}{}
+	for p, s := range w.stamps {
+		if stampFile(p) != s {
+			changed[p] = struct{}{}
+		}
+	}
+	var affected []string
+	for _, b := range w.books {
+		ab, err := filepath.Abs(b)
+		if err != nil {
+			continue
+		}
+		if _, ok := w.stamps[ab]; !ok {
+			// Added runbook
+			affected = append(affected, b)
+			continue
+		}
+		files, ok := w.deps[b]
+		if !ok {
+			files = []string{ab}
+		}
+		for _, f := range files {
+			if _, ok := changed[f]; ok {
+				affected = append(affected, b)
+				break
+			}
+		}
+	}
+	if len(affected) > 0 || len(changed) > 0 {
+		w.snapshot()
+	}
+	return affected
+}
+
+// snapshot records the stamps of the watched files.
+func (w *Watcher) snapshot() {
+	for b := range w.deps {
+		if !slices.Contains(w.books, b) {
+			// Removed runbook
+			delete(w.deps, b)
+		}
+	}
+	stamps := map[string]fileStamp{}
+	for _, b := range w.books {
+		if ab, err := filepath.Abs(b); err == nil {
+			stamps[ab] = stampFile(ab)
+		}
+		for _, f := range w.deps[b] {
+			stamps[f] = stampFile(f)
+		}
+	}
+	w.stamps = stamps
+}
+
+func stampFile(p string) fileStamp {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size(), exists: true}
+}
+
+// watchFiles returns the absolute paths of the files that each runbook to run depends on.
+// key is the runbook path.
+func (opn *operatorN) watchFiles() (map[string][]string, error) {
+	gb, err := opn.buildGraph()
+	if err != nil {
+		return nil, err
+	}
+	bk := newBook()
+	if err := bk.applyOptions(opn.opts...); err != nil {
+		return nil, err
+	}
+	files := map[string][]string{}
+	for _, n := range gb.graph.Nodes {
+		op, ok := gb.ops[n.Path]
+		if !ok {
+			// The runbook may be created later.
+			files[n.Path] = []string{n.Path}
+			continue
+		}
+		ff, err := op.dependentFiles(bk)
+		if err != nil {
+			return nil, err
+		}
+		files[n.Path] = ff
+	}
+	adj := map[string][]string{}
+	for _, e := range gb.graph.Edges {
+		adj[e.From] = append(adj[e.From], e.To)
+	}
+	deps := map[string][]string{}
+	for _, op := range opn.ops {
+		var abs []string
+		visited := map[string]struct{}{}
+		stack := []string{op.bookPath}
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if _, ok := visited[p]; ok {
+				continue
+			}
+			visited[p] = struct{}{}
+			for _, f := range files[p] {
+				if a, err := filepath.Abs(f); err == nil {
+					abs = append(abs, a)
+				}
+			}
+			stack = append(stack, adj[p]...)
+		}
+		deps[op.bookPath] = sortedUnique(abs)
+	}
+	return deps, nil
+}
+
+// runnerPool keeps the connections of the HTTP, gRPC and DB runners across runs of the watcher.
+// The connections are keyed by the runbook path, the runner name, the endpoint and the configuration of the runner, so they are renewed when the runner is redefined.
+type runnerPool struct {
+	httpClients map[string]*http.Client
+	dbClients   map[string]TxQuerier
+	grpcConns   map[string]*grpc.ClientConn
+	grpcClients map[string]*http.Client
+	dbRunners   map[string]*dbRunner   // Runners attached for the current run.
+	grpcRunners map[string]*grpcRunner // Runners attached for the current run.
+}
+
+func newRunnerPool() *runnerPool {
+	return &runnerPool{
+		httpClients: map[string]*http.Client{},
+		dbClients:   map[string]TxQuerier{},
+		grpcConns:   map[string]*grpc.ClientConn{},
+		grpcClients: map[string]*http.Client{},
+		dbRunners:   map[string]*dbRunner{},
+		grpcRunners: map[string]*grpcRunner{},
+	}
+}
+
+// attach sets the kept connections to the runners of the operators.
+// The connections of the runners whose endpoint or configuration has changed are closed.
+func (p *runnerPool) attach(opn *operatorN) {
+	for _, op := range opn.om {
+		for name, r := range op.httpRunners {
+			if r.handler != nil || r.endpoint == nil {
+				continue
+			}
+			key := poolKey(op.bookPath, name, r.endpoint.String(), httpPoolConfig(r))
+			if c, ok := p.httpClients[key]; ok {
+				r.client = c
+				continue
+			}
+			p.evict(op.bookPath, name)
+			p.httpClients[key] = r.client
+		}
+		for name, r := range op.dbRunners {
+			if r.dsn == "" {
+				// Runners created with the runn.DBRunner option
+				continue
+			}
+			key := poolKey(op.bookPath, name, r.dsn, fmt.Sprintf("%v", r.hostRules))
+			r.operatorID = watchRunnerOwnerID
+			if c, ok := p.dbClients[key]; ok {
+				r.client = c
+			} else {
+				p.evict(op.bookPath, name)
+			}
+			p.dbRunners[key] = r
+		}
+		for name, r := range op.grpcRunners {
+			if r.target == "" {
+				// Runners created with the runn.GrpcRunner option
+				continue
+			}
+			key := poolKey(op.bookPath, name, r.target, grpcPoolConfig(r))
+			r.operatorID = watchRunnerOwnerID
+			cc, ok := p.grpcConns[key]
+			if ok {
+				r.cc = cc
+			}
+			hc, hok := p.grpcClients[key]
+			if hok {
+				r.hc = hc
+			}
+			if !ok && !hok {
+				p.evict(op.bookPath, name)
+			}
+			p.grpcRunners[key] = r
+		}
+	}
+}
+
+// evict closes the kept connections of the runner.
+func (p *runnerPool) evict(bookPath, name string) {
+	prefix := poolKey(bookPath, name)
+	for key, c := range p.httpClients {
+		if strings.HasPrefix(key, prefix) {
+			c.CloseIdleConnections()
+			delete(p.httpClients, key)
+		}
+	}
+	for key, c := range p.dbClients {
+		if strings.HasPrefix(key, prefix) {
+			r := &dbRunner{client: c}
+			_ = r.Close()
+			delete(p.dbClients, key)
+		}
+	}
+	for key, cc := range p.grpcConns {
+		if strings.HasPrefix(key, prefix) {
+			_ = cc.Close()
+			delete(p.grpcConns, key)
+		}
+	}
+	for key, hc := range p.grpcClients {
+		if strings.HasPrefix(key, prefix) {
+			hc.CloseIdleConnections()
+			delete(p.grpcClients, key)
+		}
+	}
+}
+
+// collect keeps the connections of the runners attached for the current run.
+func (p *runnerPool) collect() {
+	for key, r := range p.dbRunners {
+		if r.client != nil {
+			p.dbClients[key] = r.client
+		}
+	}
+	for key, r := range p.grpcRunners {
+		if r.cc != nil {
+			p.grpcConns[key] = r.cc
+		}
+		if r.hc != nil {
+			p.grpcClients[key] = r.hc
+		}
+	}
+	p.dbRunners = map[string]*dbRunner{}
+	p.grpcRunners = map[string]*grpcRunner{}
+}
+
+func (p *runnerPool) close() {
+	for _, c := range p.httpClients {
+		c.CloseIdleConnections()
+	}
+	for _, c := range p.dbClients {
+		r := &dbRunner{client: c}
+		_ = r.Close()
+	}
+	for _, cc := range p.grpcConns {
+		_ = cc.Close()
+	}
+	for _, hc := range p.grpcClients {
+		hc.CloseIdleConnections()
+	}
+}
+
+// poolKey returns the key of the kept connection.
+// The configuration of the runner is hashed so that the connection is renewed when the runner is reconfigured.
+func poolKey(bookPath, name string, endpointAndConfig ...string) string {
+	key := bookPath + "\x00" + name + "\x00"
+	if len(endpointAndConfig) == 0 {
+		return key
+	}
+	sum := sha256.Sum256([]byte(strings.Join(endpointAndConfig[1:], "\x00")))
+	return key + endpointAndConfig[0] + "\x00" + hex.EncodeToString(sum[:])
+}
+
+// httpPoolConfig returns the configuration of the HTTP runner that affects the connection.
+func httpPoolConfig(r *httpRunner) string {
+	return fmt.Sprintf("%x %x %x %t %s %t %v", r.cacert, r.cert, r.key, r.skipVerify, r.client.Timeout, r.useCookie != nil && *r.useCookie, r.hostRules)
+}
+
+// grpcPoolConfig returns the configuration of the gRPC runner that affects the connection.
+func grpcPoolConfig(r *grpcRunner) string {
+	var tls, ka string
+	if r.tls != nil {
+		tls = fmt.Sprintf("%t", *r.tls)
+	}
+	if r.keepalive != nil {
+		ka = fmt.Sprintf("%+v", *r.keepalive)
+	}
+	return fmt.Sprintf("%s %x %x %x %t %s %s %s %s %d %d %s %s %s %v %v",
+		tls, r.cacert, r.cert, r.key, r.skipVerify, r.protocol, r.codec, r.compression, ka,
+		r.maxRecvMsgSize, r.maxSendMsgSize, r.authority, r.serviceConfig, r.bearerToken, r.perRPCMetadata, r.hostRules)
+}
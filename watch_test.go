@@ -0,0 +1,187 @@
+package runn
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/k1LoW/runn/internal/scope"
+	"github.com/k1LoW/runn/testutil"
+)
+
+func TestWatchFiles(t *testing.T) {
+	opn, err := Load("testdata/watch/main.yml", LoadOnly(), Scopes(scope.AllowReadParent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deps, err := opn.watchFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range deps["testdata/watch/main.yml"] {
+		rel, err := filepath.Rel(wd, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, filepath.ToSlash(rel))
+	}
+	want := []string{
+		"testdata/grpctest.proto",
+		"testdata/openapi3.yml",
+		"testdata/watch/grpctest.proto",
+		"testdata/watch/included.yml",
+		"testdata/watch/main.yml",
+		"testdata/watch/sub.yml",
+		"testdata/watch/vars.json",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	orig := watchInterval
+	watchInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		watchInterval = orig
+	})
+	dir := t.TempDir()
+	books := map[string]string{
+		"a.yml": "desc: A\nvars:\n  v: json://v.json\nsteps:\n  -\n    include:\n      path: b.yml\n  -\n    test: vars.v.ok\n",
+		"b.yml": "desc: B\nsteps:\n  -\n    test: 'true'\n",
+		"c.yml": "desc: C\nsteps:\n  -\n    test: 'true'\n",
+	}
+	for n, b := range books {
+		if err := os.WriteFile(filepath.Join(dir, n), []byte(b), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(n, b string) {
+		t.Helper()
+		p := filepath.Join(dir, n)
+		if err := os.WriteFile(p, []byte(b), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		// Make sure the change is detected even if the file system has a coarse timestamp.
+		future := time.Now().Add(time.Duration(len(b)) * time.Second)
+		if err := os.Chtimes(p, future, future); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("v.json", `{"ok": true}`)
+
+	ctx := context.Background()
+	w := NewWatcher(filepath.Join(dir, "a.yml")+string(filepath.ListSeparator)+filepath.Join(dir, "c.yml"), Scopes(scope.AllowReadParent))
+	t.Cleanup(w.Close)
+
+	tests := []struct {
+		name   string
+		change func()
+		want   []string
+	}{
+		{"first run", nil, []string{"A", "C"}},
+		{"change vars file", func() { write("v.json", `{"ok": false}`) }, []string{"A"}},
+		{"change included runbook", func() { write("b.yml", books["b.yml"]+"# changed\n") }, []string{"A"}},
+		{"change runbook", func() { write("c.yml", books["c.yml"]+"# changed\n") }, []string{"C"}},
+	}
+	for _, tt := range tests {
+		if tt.change != nil {
+			tt.change()
+			wctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			err := w.Wait(wctx)
+			cancel()
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		}
+		opn, err := w.Run(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []string
+		for _, rr := range opn.Result().RunResults {
+			got = append(got, rr.Desc)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("%s: %s", tt.name, diff)
+		}
+	}
+}
+
+func TestWatcherKeepsConnections(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "db.yml")
+	b := "desc: DB\nrunners:\n  db: sqlite3://" + filepath.ToSlash(filepath.Join(dir, "test.db")) + "\nsteps:\n  -\n    db:\n      query: SELECT 1;\n"
+	if err := os.WriteFile(p, []byte(b), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	w := NewWatcher(p, Scopes(scope.AllowReadParent))
+	t.Cleanup(w.Close)
+	var clients []TxQuerier
+	for range 2 {
+		opn, err := w.Run(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opn.Result().HasFailure() {
+			t.Fatal("want no failure")
+		}
+		if len(w.pool.dbClients) != 1 {
+			t.Fatalf("got %d connections, want 1", len(w.pool.dbClients))
+		}
+		for _, c := range w.pool.dbClients {
+			clients = append(clients, c)
+		}
+	}
+	if clients[0] != clients[1] {
+		t.Error("want the connection kept across runs")
+	}
+}
+
+func TestWatcherRenewsConnections(t *testing.T) {
+	hs := testutil.HTTPServer(t)
+	dir := t.TempDir()
+	p := filepath.Join(dir, "http.yml")
+	write := func(timeout string) {
+		t.Helper()
+		b := "desc: HTTP\nrunners:\n  req:\n    endpoint: " + hs.URL + "\n    timeout: " + timeout + "\nsteps:\n  -\n    req:\n      /users:\n        get:\n          body: null\n"
+		if err := os.WriteFile(p, []byte(b), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	w := NewWatcher(p)
+	t.Cleanup(w.Close)
+	var clients []*http.Client
+	for _, timeout := range []string{"10sec", "10sec", "20sec"} {
+		write(timeout)
+		if _, err := w.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if len(w.pool.httpClients) != 1 {
+			t.Fatalf("got %d connections, want 1", len(w.pool.httpClients))
+		}
+		for _, c := range w.pool.httpClients {
+			clients = append(clients, c)
+		}
+	}
+	if clients[0] != clients[1] {
+		t.Error("want the connection kept across runs")
+	}
+	if clients[1] == clients[2] {
+		t.Error("want the connection renewed after the runner is reconfigured")
+	}
+	if clients[2].Timeout != 20*time.Second {
+		t.Errorf("got %v, want %v", clients[2].Timeout, 20*time.Second)
+	}
+}